@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/dnhan1707/trader/internal/api"
+	"github.com/dnhan1707/trader/internal/auth"
+	"github.com/dnhan1707/trader/internal/candles"
+	"github.com/dnhan1707/trader/internal/config"
+	"github.com/dnhan1707/trader/internal/quotes"
+	"github.com/dnhan1707/trader/internal/recorder"
+	"github.com/dnhan1707/trader/internal/ws"
+	"github.com/gofiber/fiber/v2"
+)
+
+// replay serves /api/ws like cmd/server, but the hub is fed from a recording
+// made with RECORD_DIR instead of the Massive sockets. No API key or network
+// access is needed.
+//
+//	go run ./cmd/replay -path recordings -speed 10
+func main() {
+	path := flag.String("path", "recordings", "recording file or directory")
+	speed := flag.Float64("speed", 1, "playback rate: 1 = real time, 0 = as fast as possible")
+	loop := flag.Bool("loop", false, "restart the recording when it ends")
+	flag.Parse()
+
+	cfg := config.Load()
+
+	// No upstream to tell about subscriptions: the recording has what it has.
+	hub := ws.NewHub(nil)
+	quoteCache := quotes.New(nil)
+	hub.Snapshots = quoteCache
+	go quoteCache.Run(hub.Tap(4096))
+	agg := candles.New(hub.Publish, candles.DefaultHistory)
+	barsHandler := api.NewBarsHandler(agg)
+	go agg.Run(hub.Tap(4096))
+	go hub.Run()
+
+	player := &recorder.Player{Path: *path, Speed: *speed, Loop: *loop}
+	go func() {
+		if err := player.Play(context.Background(), hub.Broadcast); err != nil {
+			log.Fatal("replay: ", err)
+		}
+		log.Println("[Replay] Recording finished")
+	}()
+
+	app := fiber.New()
+
+	app.Get("/health", func(c *fiber.Ctx) error {
+		return c.SendString("ok")
+	})
+
+	apiGroup := app.Group("/api", auth.Middleware(cfg.JwtSecret))
+	apiGroup.Get("/bars/live/:ticker", barsHandler.GetLiveBars)
+	apiGroup.Get("/ws", ws.NewHandler(hub))
+	apiGroup.Get("/stream/quotes", ws.NewSSEHandler(hub))
+
+	log.Fatal(app.Listen(":" + cfg.Port))
+}
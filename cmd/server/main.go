@@ -1,27 +1,43 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
+	"github.com/dnhan1707/trader/internal/alerts"
 	"github.com/dnhan1707/trader/internal/api"
 	"github.com/dnhan1707/trader/internal/auth"
 	"github.com/dnhan1707/trader/internal/cache"
+	"github.com/dnhan1707/trader/internal/candles"
 	"github.com/dnhan1707/trader/internal/chat"
+	"github.com/dnhan1707/trader/internal/cluster"
 	"github.com/dnhan1707/trader/internal/config"
 	"github.com/dnhan1707/trader/internal/eodhd"
+	"github.com/dnhan1707/trader/internal/feed"
 	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/dnhan1707/trader/internal/quotes"
+	"github.com/dnhan1707/trader/internal/recorder"
+	"github.com/dnhan1707/trader/internal/screener"
 	"github.com/dnhan1707/trader/internal/services"
+	"github.com/dnhan1707/trader/internal/trading"
 	"github.com/dnhan1707/trader/internal/ws"
 	"github.com/gofiber/fiber/v2"
 	_ "github.com/lib/pq"
 )
 
+// requestTimeout bounds the upstream calls an /api request makes.
+const requestTimeout = time.Minute
+
 func main() {
 	cfg := config.Load()
 
 	// Redis Cache
-	cacheClient := cache.New(cfg.RedisAddr, cfg.RedisPass, cfg.RedisDB, cfg.CacheTTL)
+	cacheClient := cache.New(cfg.RedisAddr, cfg.RedisPass, cfg.RedisDB, cfg.CacheTTL, cfg.CacheHardTTL, cfg.CacheLastGood, cfg.CacheLastGoodMaxKB)
 	defer cacheClient.Close()
 
 	// Postgres Connection
@@ -33,25 +49,102 @@ func main() {
 	defer db.Close()
 
 	// Handlers Setup
-	massiveClient := massive.New(cfg.MassiveBase, cfg.MassiveKey)
+	massiveClient := massive.New(cfg.MassiveBase, cfg.MassiveKey, cfg.MassiveRate)
 	eodhClient := eodhd.New(cfg.EODHD_BASE, cfg.EODHD_API_KEY)
 	instSvc := services.NewInstitutionalOwnershipService(db, massiveClient, eodhClient)
 	insiderSvc := services.NewInsiderOwnershipService(db, massiveClient)
 	authService := services.NewAuthService(db)
 	dmService := services.NewDMService(db)
+	alertService := services.NewAlertService(db)
+	tradingService := services.NewTradingService(db, cfg.PaperStartingCash)
+	portfolioService := services.NewPortfolioService(db)
+	watchlistService := services.NewWatchlistService(db)
+	backtestService := services.NewBacktestService(db)
+	screenerService := services.NewScreenerService(db)
 	authHandler := api.NewAuthHandler(authService, cfg.JwtSecret, cfg.JwtExpiresIn)
 	dmHandler := api.NewDMHandler(dmService)
 	handler := api.New(cacheClient, massiveClient, instSvc, insiderSvc)
 
 	// Websocket initialization
-	hub := ws.NewHub()
+	// current having 2 channels, buffered so a reconnecting upstream
+	// never stalls the hub
+	stockSubChan := make(chan ws.SubRequest, 256)
+	indexSubChan := make(chan ws.SubRequest, 256)
+	subs := ws.NewSubscriptionManager(stockSubChan, indexSubChan)
+	hub := ws.NewHub(subs)
+	if cfg.WsSendBuffer > 0 {
+		hub.SendBuffer = cfg.WsSendBuffer
+	}
+	if hub.SlowConsumer, err = ws.ParsePolicy(cfg.WsSlowConsumer); err != nil {
+		log.Fatal("ws:", err)
+	}
+
+	// Optional capture of the upstream stream for cmd/replay
+	var rec *recorder.Recorder
+	if cfg.RecordDir != "" {
+		if rec, err = recorder.New(cfg.RecordDir); err != nil {
+			log.Fatal("recorder:", err)
+		}
+		go rec.Run(hub.Tap(4096))
+	}
+
+	// Last-value cache, sent to clients when they subscribe
+	quoteCache := quotes.New(massiveClient)
+	hub.Snapshots = quoteCache
+	hub.Watchlists = watchlistService
+	go quoteCache.Run(hub.Tap(4096))
+
+	// Local 5s..1h candles from the trade stream
+	agg := candles.New(hub.Publish, candles.DefaultHistory)
+	barsHandler := api.NewBarsHandler(agg)
+	go agg.Run(hub.Tap(4096))
+
+	// Price alerts are evaluated on every instance, see internal/alerts
+	alertFrames := hub.Tap(4096)
+
+	// Paper-trading fills, same model
+	orderFrames := hub.Tap(4096)
 
-	// current having 2 channels
-	stockSubChan := make(chan string)
-	indexSubChan := make(chan string)
 	go hub.Run()
-	go massive.ListenStocks(cfg.MassiveKey, hub, stockSubChan)
-	go massive.ListenIndices(cfg.MassiveKey, hub, indexSubChan)
+
+	// Upstream: Massive by default, or the simulator / a recording (FEED_SOURCE)
+	source, err := feed.New(cfg)
+	if err != nil {
+		log.Fatal("feed:", err)
+	}
+	// In cluster mode only the elected leader runs the source; every
+	// instance receives its tickers through Redis pub/sub.
+	var node *cluster.Node
+	if cfg.ClusterMode {
+		node = cluster.New(cfg.ClusterNodeID, cacheClient, source, hub)
+		go node.Run(stockSubChan, indexSubChan)
+	} else {
+		go source.Run(context.Background(), hub.Broadcast, stockSubChan, indexSubChan)
+	}
+	adminHandler := api.NewAdminHandler(subs, hub, node)
+
+	// Alert and order frames reach the user's connections on any instance
+	var notifier ws.Notifier = hub
+	if node != nil {
+		notifier = node
+	}
+	alertEngine := alerts.New(alertService, subs, massiveClient, quoteCache, notifier)
+	alertHandler := api.NewAlertHandler(alertService, alertEngine)
+	go alertEngine.Run(alertFrames)
+
+	tradingEngine := trading.New(tradingService, subs, quoteCache, notifier)
+	tradingHandler := api.NewTradingHandler(tradingService, tradingEngine, quoteCache)
+	go tradingEngine.Run(orderFrames)
+
+	portfolioHandler := api.NewPortfolioHandler(portfolioService, massiveClient, quoteCache)
+	watchlistHandler := api.NewWatchlistHandler(watchlistService, dmService, quoteCache)
+	backtestHandler := api.NewBacktestHandler(backtestService, massiveClient)
+	screenerHandler := api.NewScreenerHandler(screenerService)
+
+	// Screener universe, rebuilt by one instance at a time (advisory lock)
+	if cfg.ScreenerRefresh > 0 && cfg.MassiveKey != "" {
+		go screener.NewRefresher(screenerService, massiveClient, cfg.ScreenerRefresh, cfg.ScreenerHistoryDays).Run()
+	}
 
 	// Fiber App
 	app := fiber.New()
@@ -66,7 +159,7 @@ func main() {
 	app.Post("/api/auth/logout", authHandler.Logout)
 
 	// Protect all other /api routes
-	apiGroup := app.Group("/api", auth.Middleware(cfg.JwtSecret))
+	apiGroup := app.Group("/api", auth.Middleware(cfg.JwtSecret), api.RequestContext(requestTimeout))
 
 	// DM chat routes (1:1)
 	chatGroup := apiGroup.Group("/chat")
@@ -77,11 +170,13 @@ func main() {
 	chatGroup.Post("/dm/threads/:threadId/read", dmHandler.MarkThreadRead)
 
 	apiGroup.Get("/tickers/:symbol", handler.GetTickerDetails)
-	// app.Get("/api/aggs/ticker/:stocksTicker/range/:multiplier/:timespan/:from/:to", handler.GetCustomBars)
+	apiGroup.Get("/aggs/ticker/:stocksTicker/range/:multiplier/:timespan/:from/:to", handler.GetCustomBars)
 	apiGroup.Get("/indicators/sma/:stocksTicker", handler.GetSMA)
 	apiGroup.Get("/indicators/ema/:stocksTicker", handler.GetEMA)
 	apiGroup.Get("/indicators/macd/:stocksTicker", handler.GetMACD)
 	apiGroup.Get("/indicators/rsi/:stocksTicker", handler.GetRSI)
+	// computed locally from aggregates, ?set=rsi:14,bb:20:2,atr:14
+	apiGroup.Get("/indicators/:ticker", handler.GetIndicators)
 	apiGroup.Get("/exchanges", handler.GetExchanges)
 	apiGroup.Get("/market/upcoming", handler.GetMarketHolidays)
 	apiGroup.Get("/market/now", handler.GetMarketStatus)
@@ -94,20 +189,104 @@ func main() {
 	apiGroup.Get("/stocks/ratios", handler.GetRatios)
 	apiGroup.Get("/snapshot/stocks/tickers/:stocksTicker", handler.GetTickerSnapshot)
 	apiGroup.Get("/stocks/:stocksTicker/52week", handler.Get52WeekStats)
+	apiGroup.Get("/stocks/:stocksTicker/stats", handler.GetPriceStats)
 	apiGroup.Get("/stocks/financials/income-statements", handler.GetIncomeStatements)
 	apiGroup.Get("/stocks/ownership", handler.GetTopOwners)
 	apiGroup.Get("/stocks/ownership/cusip", handler.GetTopOwnersByCusip)
 	apiGroup.Get("/stocks/insiders", handler.GetTopInsiders)
+	apiGroup.Get("/bars/live/:ticker", barsHandler.GetLiveBars)
+
+	// Price alerts
+	alertGroup := apiGroup.Group("/alerts")
+	alertGroup.Post("/", alertHandler.CreateAlert)
+	alertGroup.Get("/", alertHandler.ListAlerts)
+	alertGroup.Get("/:id", alertHandler.GetAlert)
+	alertGroup.Patch("/:id", alertHandler.UpdateAlert)
+	alertGroup.Delete("/:id", alertHandler.DeleteAlert)
+	alertGroup.Get("/:id/events", alertHandler.ListAlertEvents)
+
+	// Paper trading; order updates also go out as "order" events on /api/ws
+	tradingGroup := apiGroup.Group("/trading")
+	tradingGroup.Get("/account", tradingHandler.GetAccount)
+	tradingGroup.Get("/positions", tradingHandler.ListPositions)
+	tradingGroup.Post("/orders", tradingHandler.PlaceOrder)
+	tradingGroup.Get("/orders", tradingHandler.ListOrders)
+	tradingGroup.Get("/orders/:id", tradingHandler.GetOrder)
+	tradingGroup.Delete("/orders/:id", tradingHandler.CancelOrder)
+	tradingGroup.Get("/fills", tradingHandler.ListFills)
+
+	// Portfolio: paper fills plus manual transactions, ?method=fifo|lifo|avg
+	portfolioGroup := apiGroup.Group("/portfolio")
+	portfolioGroup.Get("/", portfolioHandler.GetPortfolio)
+	portfolioGroup.Get("/performance", portfolioHandler.GetPerformance)
+	portfolioGroup.Get("/dividends", portfolioHandler.ListDividends)
+	portfolioGroup.Get("/transactions", portfolioHandler.ListTransactions)
+	portfolioGroup.Post("/transactions", portfolioHandler.CreateTransaction)
+	portfolioGroup.Delete("/transactions/:id", portfolioHandler.DeleteTransaction)
+
+	// Watchlists; open one on /api/ws with {"action":"open_watchlist","watchlist_id":N}
+	watchlistGroup := apiGroup.Group("/watchlists")
+	watchlistGroup.Get("/", watchlistHandler.ListWatchlists)
+	watchlistGroup.Post("/", watchlistHandler.CreateWatchlist)
+	watchlistGroup.Get("/:id", watchlistHandler.GetWatchlist)
+	watchlistGroup.Patch("/:id", watchlistHandler.UpdateWatchlist)
+	watchlistGroup.Delete("/:id", watchlistHandler.DeleteWatchlist)
+	watchlistGroup.Post("/:id/tickers", watchlistHandler.AddTicker)
+	watchlistGroup.Delete("/:id/tickers/:ticker", watchlistHandler.RemoveTicker)
+	watchlistGroup.Get("/:id/snapshot", watchlistHandler.GetSnapshot)
+	watchlistGroup.Get("/:id/shares", watchlistHandler.ListShares)
+	watchlistGroup.Post("/:id/shares", watchlistHandler.ShareWatchlist)
+	watchlistGroup.Delete("/:id/shares/:userID", watchlistHandler.UnshareWatchlist)
+
+	backtestGroup := apiGroup.Group("/backtests")
+	backtestGroup.Get("/", backtestHandler.ListBacktests)
+	backtestGroup.Post("/", backtestHandler.CreateBacktest)
+	backtestGroup.Get("/:id", backtestHandler.GetBacktest)
+	backtestGroup.Delete("/:id", backtestHandler.DeleteBacktest)
+
+	screenerGroup := apiGroup.Group("/screener")
+	screenerGroup.Get("/", screenerHandler.Screen)
+	screenerGroup.Get("/fields", screenerHandler.GetFields)
+	screenerGroup.Get("/screens", screenerHandler.ListScreens)
+	screenerGroup.Post("/screens", screenerHandler.CreateScreen)
+	screenerGroup.Get("/screens/:id", screenerHandler.GetScreen)
+	screenerGroup.Patch("/screens/:id", screenerHandler.UpdateScreen)
+	screenerGroup.Delete("/screens/:id", screenerHandler.DeleteScreen)
+	screenerGroup.Get("/screens/:id/results", screenerHandler.RunScreen)
 
 	// user search for starting DMs
 	apiGroup.Get("/chat/users/search", dmHandler.SearchUsers)
 
+	// Admin routes
+	adminGroup := apiGroup.Group("/admin", api.RequireAdmin(authService))
+	adminGroup.Get("/subscriptions", adminHandler.GetSubscriptions)
+	adminGroup.Get("/stream", adminHandler.GetStreamStats)
+	adminGroup.Get("/stream/clients", adminHandler.GetStreamClients)
+	adminGroup.Get("/cluster", adminHandler.GetCluster)
+
 	// WebSocket route
-	apiGroup.Get("/ws", ws.NewHandler(hub, stockSubChan, indexSubChan))
+	apiGroup.Get("/ws", ws.NewHandler(hub))
+	apiGroup.Get("/stream/quotes", ws.NewSSEHandler(hub))
 	// apiGroup.Get("/ws/dm", dmws.NewDMWebsocketHandler(dmService, cfg.JwtSecret))
 
 	// Start simple WS chat server (rooms = DM thread IDs), non-blocking
 	go chat.Start(":8081", dmService)
 
-	log.Fatal(app.Listen(":" + cfg.Port))
+	// Shut down on SIGINT / SIGTERM so the recording is closed properly
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+		<-sig
+		log.Println("Shutting down")
+		if err := app.Shutdown(); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+	}()
+
+	if err := app.Listen(":" + cfg.Port); err != nil {
+		log.Fatal(err)
+	}
+	if rec != nil {
+		rec.Close()
+	}
 }
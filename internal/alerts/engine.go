@@ -0,0 +1,397 @@
+/*
+Package alerts evaluates users' price alerts against the live stream (a hub
+tap) and delivers them over the user's websocket / SSE connections and to
+optional signed webhooks.
+
+Every instance evaluates every active alert; firing goes through an atomic
+claim in Postgres, so an alert fires once even when several instances see
+the same trade.
+*/
+
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/quotes"
+	"github.com/dnhan1707/trader/internal/services"
+	"github.com/dnhan1707/trader/internal/ws"
+)
+
+// EventType is the "ev" of an alert frame sent to the user.
+const EventType = "alert"
+
+const (
+	// Active alerts are re-read this often so edits made on another
+	// instance are picked up.
+	reloadEvery = 30 * time.Second
+
+	// Minute bars averaged for volume_spike, and how many are needed first.
+	volumeWindow  = 20
+	volumeMinBars = 5
+
+	// 52-week levels come from daily bars and only change once a day.
+	levelsTTL     = 12 * time.Hour
+	levelsTimeout = 30 * time.Second
+
+	dbTimeout = 5 * time.Second
+)
+
+// Payload is what the user receives, over the websocket (as an "alert"
+// event) and as the webhook body.
+type Payload struct {
+	EventID     int64     `json:"event_id"`
+	AlertID     int64     `json:"alert_id"`
+	Ticker      string    `json:"ticker"`
+	Condition   string    `json:"condition"`
+	Threshold   float64   `json:"threshold"`
+	Mode        string    `json:"mode"`
+	Price       float64   `json:"price"`
+	Value       float64   `json:"value"`
+	Message     string    `json:"message"`
+	Note        string    `json:"note,omitempty"`
+	TriggeredAt time.Time `json:"triggered_at"`
+}
+
+type watch struct {
+	alert services.PriceAlert
+	armed bool
+}
+
+// tickerState is what the conditions need from the stream.
+type tickerState struct {
+	price   float64
+	open    float64   // official open from AM events
+	volumes []float64 // recent minute volumes, oldest first
+}
+
+type levels struct {
+	high, low float64
+	fetched   time.Time
+}
+
+type Engine struct {
+	svc     *services.AlertService
+	subs    *ws.SubscriptionManager
+	massive *massive.Client // 52-week levels; may be nil
+	quotes  *quotes.Cache   // open price fallback; may be nil
+	notify  ws.Notifier
+	webhook *WebhookSender
+
+	reload chan struct{}
+
+	// owned by Run
+	watches map[string][]*watch // ticker -> alerts
+	held    map[string]bool     // tickers acquired from subs
+	state   map[string]*tickerState
+
+	mu       sync.Mutex
+	levels   map[string]levels
+	fetching map[string]bool
+}
+
+func New(svc *services.AlertService, subs *ws.SubscriptionManager, m *massive.Client, q *quotes.Cache, notify ws.Notifier) *Engine {
+	return &Engine{
+		svc:      svc,
+		subs:     subs,
+		massive:  m,
+		quotes:   q,
+		notify:   notify,
+		webhook:  NewWebhookSender(services.WebhookIPAllowed),
+		reload:   make(chan struct{}, 1),
+		watches:  make(map[string][]*watch),
+		held:     make(map[string]bool),
+		state:    make(map[string]*tickerState),
+		levels:   make(map[string]levels),
+		fetching: make(map[string]bool),
+	}
+}
+
+// Reload asks Run to re-read the active alerts, e.g. after an API change.
+func (e *Engine) Reload() {
+	select {
+	case e.reload <- struct{}{}:
+	default:
+	}
+}
+
+// Run consumes frames (a hub tap) and never returns.
+func (e *Engine) Run(frames <-chan []stream.Event) {
+	e.load()
+	ticker := time.NewTicker(reloadEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case events := <-frames:
+			for _, ev := range events {
+				e.handle(ev)
+			}
+		case <-ticker.C:
+			e.load()
+		case <-e.reload:
+			e.load()
+		}
+	}
+}
+
+// load replaces the watched alerts with the active ones from Postgres and
+// keeps their tickers streaming even when no client is watching them.
+func (e *Engine) load() {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	alerts, err := e.svc.ListActiveAlerts(ctx)
+	if err != nil {
+		log.Printf("[Alerts] load: %v", err)
+		return
+	}
+
+	watches := make(map[string][]*watch)
+	for _, a := range alerts {
+		watches[a.Ticker] = append(watches[a.Ticker], &watch{alert: a, armed: a.Armed})
+		if a.Condition == services.AlertCross52wHigh || a.Condition == services.AlertCross52wLow {
+			e.ensureLevels(a.Ticker)
+		}
+	}
+	e.watches = watches
+
+	for t := range watches {
+		if !e.held[t] {
+			e.held[t] = true
+			e.subs.Acquire(t)
+		}
+	}
+	for t := range e.held {
+		if _, ok := watches[t]; !ok {
+			delete(e.held, t)
+			delete(e.state, t)
+			e.subs.Release(t)
+		}
+	}
+}
+
+func (e *Engine) handle(ev stream.Event) {
+	watches, ok := e.watches[ev.Symbol]
+	if !ok {
+		return
+	}
+	st := e.state[ev.Symbol]
+	if st == nil {
+		st = &tickerState{}
+		e.state[ev.Symbol] = st
+	}
+
+	switch {
+	case ev.Trade != nil:
+		st.price = ev.Trade.Price
+		e.evaluate(ev.Symbol, st, watches, false)
+
+	case ev.Index != nil:
+		st.price = ev.Index.Value
+		e.evaluate(ev.Symbol, st, watches, false)
+
+	case ev.Type == stream.TypeMinuteAgg && ev.Aggregate != nil:
+		if ev.Aggregate.OfficialOpen > 0 {
+			st.open = ev.Aggregate.OfficialOpen
+		}
+		if st.price == 0 {
+			st.price = ev.Aggregate.Close
+		}
+		// Compare the bar against the bars before it, then add it.
+		st.volumes = append(st.volumes, ev.Aggregate.Volume)
+		e.evaluate(ev.Symbol, st, watches, true)
+		if len(st.volumes) > volumeWindow+1 {
+			st.volumes = st.volumes[1:]
+		}
+	}
+}
+
+// evaluate checks every alert on one ticker. Volume alerts only run on
+// minute bars, price alerts on every price update.
+func (e *Engine) evaluate(ticker string, st *tickerState, watches []*watch, minuteBar bool) {
+	now := time.Now()
+	for _, w := range watches {
+		if (w.alert.Condition == services.AlertVolumeSpike) != minuteBar {
+			continue
+		}
+		hit, value, known := e.check(ticker, st, w.alert)
+		if !known {
+			continue
+		}
+
+		switch {
+		case hit && w.armed:
+			w.armed = false
+			w.alert.LastTriggeredAt = &now
+			go e.fire(w.alert, st.price, value)
+
+		// A re-arming alert fires again only after the condition went away,
+		// so a price sitting on the threshold doesn't fire on every trade.
+		case !hit && !w.armed && w.alert.Mode == services.AlertModeRearm && cooledDown(w.alert, now):
+			w.armed = true
+			go e.rearm(w.alert.ID)
+		}
+	}
+}
+
+func cooledDown(a services.PriceAlert, now time.Time) bool {
+	if a.LastTriggeredAt == nil {
+		return true
+	}
+	return now.Sub(*a.LastTriggeredAt) >= time.Duration(a.CooldownSeconds)*time.Second
+}
+
+// check reports whether the alert's condition holds and the value it
+// compared. known is false while the data it needs isn't there yet.
+func (e *Engine) check(ticker string, st *tickerState, a services.PriceAlert) (hit bool, value float64, known bool) {
+	if st.price <= 0 {
+		return false, 0, false
+	}
+
+	switch a.Condition {
+	case services.AlertPriceAbove:
+		return st.price >= a.Threshold, st.price, true
+
+	case services.AlertPriceBelow:
+		return st.price <= a.Threshold, st.price, true
+
+	case services.AlertPctFromOpen:
+		open := e.open(ticker, st)
+		if open <= 0 {
+			return false, 0, false
+		}
+		pct := (st.price - open) / open * 100
+		if a.Threshold > 0 {
+			return pct >= a.Threshold, pct, true
+		}
+		return pct <= a.Threshold, pct, true
+
+	case services.AlertCross52wHigh, services.AlertCross52wLow:
+		lv, ok := e.cachedLevels(ticker)
+		if !ok {
+			return false, 0, false
+		}
+		if a.Condition == services.AlertCross52wHigh {
+			return st.price > lv.high, lv.high, true
+		}
+		return st.price < lv.low, lv.low, true
+
+	case services.AlertVolumeSpike:
+		n := len(st.volumes) - 1 // the newest bar is the one being judged
+		if n < volumeMinBars {
+			return false, 0, false
+		}
+		var sum float64
+		for _, v := range st.volumes[:n] {
+			sum += v
+		}
+		avg := sum / float64(n)
+		if avg <= 0 {
+			return false, 0, false
+		}
+		ratio := st.volumes[n] / avg
+		return ratio >= a.Threshold, ratio, true
+	}
+	return false, 0, false
+}
+
+// open is the official open from the minute bars, or the day bar of the
+// quote cache before the first one arrives.
+func (e *Engine) open(ticker string, st *tickerState) float64 {
+	if st.open > 0 || e.quotes == nil {
+		return st.open
+	}
+	if q, ok := e.quotes.Get(ticker); ok && q.Day != nil {
+		return q.Day.Open
+	}
+	return 0
+}
+
+// fire claims the alert and delivers it. It runs on its own goroutine.
+func (e *Engine) fire(a services.PriceAlert, price, value float64) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	claimed, ok, err := e.svc.ClaimAlert(ctx, a.ID)
+	if err != nil {
+		log.Printf("[Alerts] claim %d: %v", a.ID, err)
+		return
+	}
+	if !ok {
+		// Another instance fired it, or it was edited in the meantime.
+		return
+	}
+
+	event, err := e.svc.RecordAlertEvent(ctx, services.PriceAlertEvent{
+		AlertID: claimed.ID,
+		UserID:  claimed.UserID,
+		Ticker:  claimed.Ticker,
+		Price:   price,
+		Value:   value,
+		Message: describe(*claimed, price, value),
+	})
+	if err != nil {
+		log.Printf("[Alerts] record %d: %v", a.ID, err)
+		return
+	}
+	log.Printf("[Alerts] %d fired for user %s: %s", claimed.ID, claimed.UserID, event.Message)
+
+	p := Payload{
+		EventID:     event.ID,
+		AlertID:     claimed.ID,
+		Ticker:      claimed.Ticker,
+		Condition:   claimed.Condition,
+		Threshold:   claimed.Threshold,
+		Mode:        claimed.Mode,
+		Price:       price,
+		Value:       value,
+		Message:     event.Message,
+		Note:        claimed.Note,
+		TriggeredAt: event.TriggeredAt,
+	}
+	frame := stream.Encode([]stream.Event{stream.NewCustom(EventType, claimed.Ticker, p)})
+	e.notify.SendToUser(claimed.UserID, frame)
+
+	if claimed.WebhookURL == "" {
+		return
+	}
+	status := e.webhook.Send(claimed.WebhookURL, claimed.WebhookSecret, p)
+	ctx, cancel = context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	if err := e.svc.SetWebhookStatus(ctx, event.ID, status); err != nil {
+		log.Printf("[Alerts] webhook status %d: %v", event.ID, err)
+	}
+}
+
+func (e *Engine) rearm(id int64) {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+	if err := e.svc.RearmAlert(ctx, id); err != nil {
+		log.Printf("[Alerts] rearm %d: %v", id, err)
+	}
+}
+
+func describe(a services.PriceAlert, price, value float64) string {
+	switch a.Condition {
+	case services.AlertPriceAbove:
+		return fmt.Sprintf("%s at %.2f is above %.2f", a.Ticker, price, a.Threshold)
+	case services.AlertPriceBelow:
+		return fmt.Sprintf("%s at %.2f is below %.2f", a.Ticker, price, a.Threshold)
+	case services.AlertPctFromOpen:
+		return fmt.Sprintf("%s at %.2f moved %+.2f%% from the open", a.Ticker, price, value)
+	case services.AlertCross52wHigh:
+		return fmt.Sprintf("%s at %.2f crossed its 52-week high of %.2f", a.Ticker, price, value)
+	case services.AlertCross52wLow:
+		return fmt.Sprintf("%s at %.2f crossed its 52-week low of %.2f", a.Ticker, price, value)
+	case services.AlertVolumeSpike:
+		return fmt.Sprintf("%s minute volume is %.1fx its recent average", a.Ticker, value)
+	}
+	return a.Ticker + " alert"
+}
@@ -0,0 +1,79 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// cachedLevels returns the 52-week high / low for ticker if they are known.
+func (e *Engine) cachedLevels(ticker string) (levels, bool) {
+	e.mu.Lock()
+	lv, ok := e.levels[ticker]
+	e.mu.Unlock()
+
+	if !ok || time.Since(lv.fetched) > levelsTTL {
+		e.ensureLevels(ticker)
+	}
+	return lv, ok
+}
+
+// ensureLevels fetches the levels in the background unless they are fresh
+// or already being fetched. Run never waits on the REST API.
+func (e *Engine) ensureLevels(ticker string) {
+	if e.massive == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if lv, ok := e.levels[ticker]; ok && time.Since(lv.fetched) <= levelsTTL {
+		return
+	}
+	if e.fetching[ticker] {
+		return
+	}
+	e.fetching[ticker] = true
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), levelsTimeout)
+		lv, err := e.fetchLevels(ctx, ticker)
+		cancel()
+
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		delete(e.fetching, ticker)
+		if err != nil {
+			log.Printf("[Alerts] 52-week levels %s: %v", ticker, err)
+			return
+		}
+		e.levels[ticker] = lv
+	}()
+}
+
+// fetchLevels reads a year of daily bars up to yesterday, so today's own
+// high doesn't hide the cross.
+func (e *Engine) fetchLevels(ctx context.Context, ticker string) (levels, error) {
+	now := time.Now()
+	from := now.AddDate(0, 0, -365).Format("2006-01-02")
+	to := now.AddDate(0, 0, -1).Format("2006-01-02")
+
+	bars, err := e.massive.Aggregates(ctx, ticker, 1, "day", from, to, true)
+	if err != nil {
+		return levels{}, err
+	}
+	if len(bars) == 0 {
+		return levels{}, fmt.Errorf("no daily bars")
+	}
+
+	lv := levels{fetched: now, high: bars[0].High, low: bars[0].Low}
+	for _, b := range bars[1:] {
+		if b.High > lv.high {
+			lv.high = b.High
+		}
+		if b.Low < lv.low {
+			lv.low = b.Low
+		}
+	}
+	return lv, nil
+}
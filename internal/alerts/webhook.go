@@ -0,0 +1,120 @@
+package alerts
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Webhook headers. The signature is HMAC-SHA256 over "<timestamp>.<body>"
+// keyed with the alert's webhook_secret, so receivers can reject both forged
+// and replayed calls.
+const (
+	HeaderSignature = "X-Alert-Signature"
+	HeaderTimestamp = "X-Alert-Timestamp"
+)
+
+const (
+	webhookTimeout  = 5 * time.Second
+	webhookAttempts = 3
+)
+
+// errNotPublic is a webhook address refused at dial time. It isn't retried.
+var errNotPublic = errors.New("not public")
+
+// WebhookSender posts signed alert payloads.
+type WebhookSender struct {
+	client *http.Client
+}
+
+// NewWebhookSender's client only connects to addresses that pass allowed
+// (services.WebhookIPAllowed in production), checked on the resolved address
+// at dial time, and doesn't follow redirects, so a webhook can't be bounced
+// into the private network.
+func NewWebhookSender(allowed func(net.IP) bool) *WebhookSender {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowed(ip) {
+				return fmt.Errorf("webhook address %s is %w", host, errNotPublic)
+			}
+			return nil
+		},
+	}
+	return &WebhookSender{client: &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Sign returns the X-Alert-Signature value for body.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send posts p to url, retrying server errors and network failures, and
+// returns the outcome stored with the alert event.
+func (s *WebhookSender) Send(url, secret string, p Payload) string {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return "failed: " + err.Error()
+	}
+
+	var status string
+	backoff := time.Second
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		retry := false
+		status, retry = s.post(url, secret, body)
+		if !retry {
+			break
+		}
+	}
+	return status
+}
+
+func (s *WebhookSender) post(url, secret string, body []byte) (status string, retry bool) {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "failed: " + err.Error(), false
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, Sign(secret, ts, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "failed: " + err.Error(), !errors.Is(err, errNotPublic)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return "delivered", false
+	}
+	return fmt.Sprintf("failed: status %d", resp.StatusCode), resp.StatusCode >= 500
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"log"
+
+	"github.com/dnhan1707/trader/internal/cluster"
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/services"
+	"github.com/dnhan1707/trader/internal/ws"
+	"github.com/gofiber/fiber/v2"
+)
+
+type AdminHandler struct {
+	subs *ws.SubscriptionManager
+	hub  *ws.Hub
+	node *cluster.Node // nil unless CLUSTER_MODE is on
+}
+
+func NewAdminHandler(subs *ws.SubscriptionManager, hub *ws.Hub, node *cluster.Node) *AdminHandler {
+	return &AdminHandler{subs: subs, hub: hub, node: node}
+}
+
+// RequireAdmin lets a request through only if the authenticated user has
+// the admin role. Mount it after auth.Middleware.
+func RequireAdmin(authService *services.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(string)
+		if !ok || userID == "" {
+			return c.Status(401).JSON(fiber.Map{"error": "unauthorized"})
+		}
+		admin, err := authService.IsAdmin(c.UserContext(), userID)
+		if err != nil {
+			log.Printf("admin check for %s: %v", userID, err)
+			return c.Status(500).JSON(fiber.Map{"error": "internal error"})
+		}
+		if !admin {
+			return c.Status(403).JSON(fiber.Map{"error": "admin only"})
+		}
+		return c.Next()
+	}
+}
+
+// GetSubscriptions lists every ticker currently streamed from upstream and
+// how many viewers hold it.
+func (h *AdminHandler) GetSubscriptions(c *fiber.Ctx) error {
+	counts := h.subs.Counts()
+	return c.JSON(fiber.Map{
+		"tickers": counts,
+		"total":   len(counts),
+	})
+}
+
+// GetStreamStats reports how many upstream frames were decoded and how many
+// were dropped as malformed.
+func (h *AdminHandler) GetStreamStats(c *fiber.Ctx) error {
+	return c.JSON(stream.Counters())
+}
+
+// GetStreamClients lists connected websocket / SSE clients with their
+// sent, dropped and queued message counters.
+func (h *AdminHandler) GetStreamClients(c *fiber.Ctx) error {
+	clients := h.hub.Clients()
+	return c.JSON(fiber.Map{
+		"policy":  h.hub.SlowConsumer,
+		"buffer":  h.hub.SendBuffer,
+		"clients": clients,
+		"total":   len(clients),
+	})
+}
+
+// GetCluster reports whether this instance is the feed leader and which
+// tickers it needs from the cluster.
+func (h *AdminHandler) GetCluster(c *fiber.Ctx) error {
+	if h.node == nil {
+		return c.JSON(fiber.Map{"enabled": false})
+	}
+	return c.JSON(fiber.Map{
+		"enabled": true,
+		"status":  h.node.Status(),
+	})
+}
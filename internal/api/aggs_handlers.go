@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/gofiber/fiber/v2"
+)
+
+// parseAggDate accepts YYYY-MM-DD or a unix ms timestamp, like Massive.
+func parseAggDate(s string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.ParseInLocation("2006-01-02", s, loc); err == nil {
+		return t, true
+	}
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil && ms >= 0 {
+		return time.UnixMilli(ms).In(loc), true
+	}
+	return time.Time{}, false
+}
+
+// GetCustomBars serves
+// /api/aggs/ticker/:stocksTicker/range/:multiplier/:timespan/:from/:to.
+// The whole range is returned (pages are followed). Query parameters:
+// adjusted (default true), resample (e.g. week, 4hour), sort (asc|desc),
+// limit (newest bars kept) and format (json|csv, or Accept: text/csv).
+func (h *Handler) GetCustomBars(c *fiber.Ctx) error {
+	ticker := strings.ToUpper(param(c, "stocksTicker"))
+	if ticker == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "missing stocksTicker"})
+	}
+	multiplier, err := strconv.Atoi(param(c, "multiplier"))
+	if err != nil || multiplier < 1 || multiplier > 1000 {
+		return c.Status(400).JSON(fiber.Map{"error": "multiplier must be a whole number between 1 and 1000"})
+	}
+	timespan := param(c, "timespan")
+	if !massive.ValidTimespan(timespan) {
+		return c.Status(400).JSON(fiber.Map{"error": "timespan must be one of " + strings.Join(massive.Timespans, ", ")})
+	}
+
+	market, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		market = time.UTC
+	}
+	from, ok := parseAggDate(param(c, "from"), market)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "from must be YYYY-MM-DD or unix ms"})
+	}
+	to, ok := parseAggDate(param(c, "to"), market)
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "to must be YYYY-MM-DD or unix ms"})
+	}
+	if from.After(to) {
+		return c.Status(400).JSON(fiber.Map{"error": "from is after to"})
+	}
+
+	adjusted := true
+	if v := query(c, "adjusted"); v != "" {
+		if adjusted, err = strconv.ParseBool(v); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "adjusted must be true or false"})
+		}
+	}
+	sortOrder := query(c, "sort", "asc")
+	if sortOrder != "asc" && sortOrder != "desc" {
+		return c.Status(400).JSON(fiber.Map{"error": "sort must be asc or desc"})
+	}
+	limit := 0
+	if v := query(c, "limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			return c.Status(400).JSON(fiber.Map{"error": "limit must be a positive number"})
+		}
+	}
+
+	var resample *massive.Period
+	if v := query(c, "resample"); v != "" {
+		p, err := massive.ParsePeriod(v)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+		}
+		if !p.CoarserThan(multiplier, timespan) {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("cannot resample %d %s bars into %s", multiplier, timespan, p)})
+		}
+		resample = &p
+	}
+
+	format := query(c, "format")
+	if format == "" {
+		format = "json"
+		if strings.Contains(c.Get("Accept"), "text/csv") {
+			format = "csv"
+		}
+	}
+	if format != "json" && format != "csv" {
+		return c.Status(400).JSON(fiber.Map{"error": "format must be json or csv"})
+	}
+
+	fromParam, toParam := param(c, "from"), param(c, "to")
+	cacheKey := fmt.Sprintf("aggs:v2:%s:%d:%s:%s:%s:adj=%t", ticker, multiplier, timespan, fromParam, toParam, adjusted)
+
+	// The complete range is cached; resampling, sorting and the limit are
+	// cheap and applied per request.
+	cached, err := h.cached(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.Aggregates(ctx, ticker, multiplier, timespan, fromParam, toParam, adjusted)
+	})
+	if err == massive.ErrTooManyBars {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return upstreamError(c, err)
+	}
+	var bars []massive.Agg
+	if err := json.Unmarshal([]byte(cached), &bars); err != nil {
+		return c.Status(500).JSON(fiber.Map{"error": "failed to read cached bars"})
+	}
+
+	if resample != nil {
+		bars = massive.Resample(bars, *resample, market)
+	}
+	if limit > 0 && len(bars) > limit {
+		bars = bars[len(bars)-limit:]
+	}
+	if sortOrder == "desc" {
+		out := make([]massive.Agg, len(bars))
+		copy(out, bars)
+		sort.SliceStable(out, func(i, j int) bool { return out[i].Timestamp > out[j].Timestamp })
+		bars = out
+	}
+	if bars == nil {
+		bars = []massive.Agg{}
+	}
+
+	if format == "csv" {
+		return writeBarsCSV(c, ticker, bars, market)
+	}
+
+	res := fiber.Map{
+		"ticker":     ticker,
+		"multiplier": multiplier,
+		"timespan":   timespan,
+		"from":       fromParam,
+		"to":         toParam,
+		"adjusted":   adjusted,
+		"count":      len(bars),
+		"bars":       bars,
+	}
+	if resample != nil {
+		res["resample"] = resample.String()
+	}
+	return c.JSON(res)
+}
+
+func writeBarsCSV(c *fiber.Ctx, ticker string, bars []massive.Agg, loc *time.Location) error {
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	w.Write([]string{"timestamp", "time", "open", "high", "low", "close", "volume", "vwap", "transactions"})
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	for _, bar := range bars {
+		w.Write([]string{
+			strconv.FormatInt(bar.Timestamp, 10),
+			time.UnixMilli(bar.Timestamp).In(loc).Format(time.RFC3339),
+			f(bar.Open), f(bar.High), f(bar.Low), f(bar.Close),
+			f(bar.Volume), f(bar.VWAP),
+			strconv.FormatInt(bar.Transactions, 10),
+		})
+	}
+	w.Flush()
+
+	c.Set("Content-Type", "text/csv; charset=utf-8")
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", ticker+"-bars.csv"))
+	return c.SendString(b.String())
+}
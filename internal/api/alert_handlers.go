@@ -0,0 +1,182 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/dnhan1707/trader/internal/alerts"
+	"github.com/dnhan1707/trader/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+type AlertHandler struct {
+	alertService *services.AlertService
+	engine       *alerts.Engine
+}
+
+func NewAlertHandler(alertService *services.AlertService, engine *alerts.Engine) *AlertHandler {
+	return &AlertHandler{alertService: alertService, engine: engine}
+}
+
+type createAlertRequest struct {
+	Ticker          string  `json:"ticker"`
+	Condition       string  `json:"condition"`
+	Threshold       float64 `json:"threshold"`
+	Mode            string  `json:"mode"`
+	CooldownSeconds *int    `json:"cooldown_seconds"`
+	Note            string  `json:"note"`
+	WebhookURL      string  `json:"webhook_url"`
+}
+
+func alertID(ctx *fiber.Ctx) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+func (h *AlertHandler) CreateAlert(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req createAlertRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+
+	a := services.PriceAlert{
+		UserID:          currentUserID,
+		Ticker:          req.Ticker,
+		Condition:       req.Condition,
+		Threshold:       req.Threshold,
+		Mode:            req.Mode,
+		CooldownSeconds: 300,
+		Note:            req.Note,
+		WebhookURL:      req.WebhookURL,
+	}
+	if req.CooldownSeconds != nil {
+		a.CooldownSeconds = *req.CooldownSeconds
+	}
+	if err := services.ValidateAlert(&a); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	created, err := h.alertService.CreateAlert(context.Background(), a)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not create alert"})
+	}
+	h.engine.Reload()
+
+	return ctx.Status(http.StatusCreated).JSON(created)
+}
+
+func (h *AlertHandler) ListAlerts(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	list, err := h.alertService.ListAlerts(context.Background(), currentUserID)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not list alerts"})
+	}
+	return ctx.JSON(list)
+}
+
+func (h *AlertHandler) GetAlert(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := alertID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid alert id"})
+	}
+
+	a, err := h.alertService.GetAlert(context.Background(), currentUserID, id)
+	if err == sql.ErrNoRows {
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "alert not found"})
+	}
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load alert"})
+	}
+	return ctx.JSON(a)
+}
+
+func (h *AlertHandler) UpdateAlert(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := alertID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid alert id"})
+	}
+
+	var req services.PriceAlertUpdate
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+
+	a, err := h.alertService.UpdateAlert(context.Background(), currentUserID, id, req)
+	if err == sql.ErrNoRows {
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "alert not found"})
+	}
+	if err != nil {
+		if verr, ok := err.(services.ValidationError); ok {
+			return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": verr.Error()})
+		}
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not update alert"})
+	}
+	h.engine.Reload()
+
+	return ctx.JSON(a)
+}
+
+func (h *AlertHandler) DeleteAlert(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := alertID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid alert id"})
+	}
+
+	err := h.alertService.DeleteAlert(context.Background(), currentUserID, id)
+	if err == sql.ErrNoRows {
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "alert not found"})
+	}
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not delete alert"})
+	}
+	h.engine.Reload()
+
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// ListAlertEvents returns the latest firings of one alert with the webhook
+// outcome of each.
+func (h *AlertHandler) ListAlertEvents(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := alertID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid alert id"})
+	}
+
+	limit, err := strconv.Atoi(ctx.Query("limit", "50"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	events, err := h.alertService.ListAlertEvents(context.Background(), currentUserID, id, limit)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not list alert events"})
+	}
+	return ctx.JSON(events)
+}
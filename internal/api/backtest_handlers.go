@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/backtest"
+	"github.com/dnhan1707/trader/internal/indicators"
+	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/dnhan1707/trader/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// BacktestHandler serves /api/backtests. Runs are synchronous; every run is
+// saved so it can be compared with later ones.
+type BacktestHandler struct {
+	backtestService *services.BacktestService
+	massive         *massive.Client
+	market          *time.Location
+}
+
+func NewBacktestHandler(backtestService *services.BacktestService, m *massive.Client) *BacktestHandler {
+	market, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		market = time.UTC
+	}
+	return &BacktestHandler{backtestService: backtestService, massive: m, market: market}
+}
+
+// createBacktestRequest is the data to test on plus the strategy and costs,
+// e.g. {"ticker": "AAPL", "from": "2020-01-01", "entry": {...}, "exit":
+// {...}, "slippage_bps": 5}.
+type createBacktestRequest struct {
+	Name       string `json:"name"`
+	Ticker     string `json:"ticker"`
+	Timespan   string `json:"timespan"`   // minute, hour, day (default) or week
+	Multiplier int    `json:"multiplier"` // default 1
+	From       string `json:"from"`       // YYYY-MM-DD
+	To         string `json:"to"`         // YYYY-MM-DD, default today
+	Adjusted   *bool  `json:"adjusted"`   // default true
+	backtest.Config
+}
+
+var backtestTimespans = map[string]bool{"minute": true, "hour": true, "day": true, "week": true}
+
+// savedResult is what goes in the result column; the stats have their own.
+type savedResult struct {
+	Trades []backtest.Trade `json:"trades"`
+	Equity []backtest.Point `json:"equity"`
+}
+
+func (h *BacktestHandler) CreateBacktest(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req createBacktestRequest
+	if err := json.Unmarshal(ctx.Body(), &req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body: " + err.Error()})
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Ticker = strings.ToUpper(strings.TrimSpace(req.Ticker))
+	if req.Ticker == "" {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "ticker required"})
+	}
+	if req.Timespan == "" {
+		req.Timespan = "day"
+	}
+	if !backtestTimespans[req.Timespan] {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "timespan must be minute, hour, day or week"})
+	}
+	if req.Multiplier == 0 {
+		req.Multiplier = 1
+	}
+	if req.Multiplier < 1 || req.Multiplier > 1000 {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "multiplier must be between 1 and 1000"})
+	}
+	if req.Adjusted == nil {
+		adjusted := true
+		req.Adjusted = &adjusted
+	}
+
+	from, err := time.ParseInLocation("2006-01-02", req.From, h.market)
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "from must be YYYY-MM-DD"})
+	}
+	to := time.Now().In(h.market)
+	if req.To != "" {
+		if to, err = time.ParseInLocation("2006-01-02", req.To, h.market); err != nil {
+			return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "to must be YYYY-MM-DD"})
+		}
+	}
+	if from.After(to) {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "from is after to"})
+	}
+	req.To = to.Format("2006-01-02")
+
+	if err := req.Config.Normalize(); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	start := indicators.LookbackStart(from, req.Timespan, req.Multiplier, req.Config.Warmup())
+	aggs, err := h.massive.Aggregates(ctx.UserContext(), req.Ticker, req.Multiplier, req.Timespan, start.Format("2006-01-02"), req.To, *req.Adjusted)
+	if err == massive.ErrTooManyBars {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return upstreamError(ctx, err)
+	}
+	bars := indicatorBars(aggs)
+	first := sort.Search(len(bars), func(i int) bool { return bars[i].Time >= from.UnixMilli() })
+	if first == len(bars) {
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": "no bars between from and to"})
+	}
+
+	res, err := backtest.Run(req.Config, bars, first, indicatorOptions(req.Timespan, h.market))
+	if err != nil {
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	config, err := json.Marshal(req)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not save backtest"})
+	}
+	stats, err := json.Marshal(res.Stats)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not save backtest"})
+	}
+	result, err := json.Marshal(savedResult{Trades: res.Trades, Equity: res.Equity})
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not save backtest"})
+	}
+
+	saved, err := h.backtestService.CreateBacktest(context.Background(), services.Backtest{
+		UserID:     currentUserID,
+		Name:       req.Name,
+		Ticker:     req.Ticker,
+		Timespan:   req.Timespan,
+		Multiplier: req.Multiplier,
+		From:       req.From,
+		To:         req.To,
+		Config:     config,
+		Stats:      stats,
+		Result:     result,
+	})
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not save backtest"})
+	}
+	return ctx.Status(http.StatusCreated).JSON(saved)
+}
+
+// ListBacktests returns saved runs with their stats, newest first.
+// ?ids=1,2,3 picks runs to compare; ?ticker= narrows to one ticker.
+func (h *BacktestHandler) ListBacktests(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var ids []int64
+	if v := ctx.Query("ids"); v != "" {
+		for _, s := range strings.Split(v, ",") {
+			id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+			if err != nil || id <= 0 {
+				return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "ids must be a comma-separated list of backtest ids"})
+			}
+			ids = append(ids, id)
+		}
+	}
+	ticker := strings.ToUpper(ctx.Query("ticker"))
+
+	list, err := h.backtestService.ListBacktests(context.Background(), currentUserID, ids, ticker)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not list backtests"})
+	}
+	return ctx.JSON(list)
+}
+
+// GetBacktest returns one run with its trades and equity curve.
+func (h *BacktestHandler) GetBacktest(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid backtest id"})
+	}
+
+	b, err := h.backtestService.GetBacktest(context.Background(), currentUserID, id)
+	if err == sql.ErrNoRows {
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "backtest not found"})
+	}
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load backtest"})
+	}
+	return ctx.JSON(b)
+}
+
+func (h *BacktestHandler) DeleteBacktest(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid backtest id"})
+	}
+
+	err = h.backtestService.DeleteBacktest(context.Background(), currentUserID, id)
+	if err == sql.ErrNoRows {
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "backtest not found"})
+	}
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not delete backtest"})
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
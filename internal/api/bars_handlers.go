@@ -0,0 +1,48 @@
+package api
+
+import (
+	"strings"
+
+	"github.com/dnhan1707/trader/internal/candles"
+	"github.com/gofiber/fiber/v2"
+)
+
+type BarsHandler struct {
+	candles *candles.Aggregator
+}
+
+func NewBarsHandler(agg *candles.Aggregator) *BarsHandler {
+	return &BarsHandler{candles: agg}
+}
+
+// GetLiveBars returns the locally built bars for a ticker. Bars only exist
+// for tickers someone is streaming, since they are built from the live feed.
+func (h *BarsHandler) GetLiveBars(c *fiber.Ctx) error {
+	ticker := c.Params("ticker")
+	if ticker == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "ticker is required"})
+	}
+
+	name := c.Query("interval", "1m")
+	interval, ok := candles.ParseInterval(name)
+	if !ok {
+		names := make([]string, 0, len(candles.Intervals))
+		for _, iv := range candles.Intervals {
+			names = append(names, iv.Name)
+		}
+		return c.Status(400).JSON(fiber.Map{"error": "invalid interval; allowed: " + strings.Join(names, ",")})
+	}
+
+	limit := c.QueryInt("limit", candles.DefaultHistory)
+	if limit <= 0 {
+		return c.Status(400).JSON(fiber.Map{"error": "limit query parameter cannot be 0 or less"})
+	}
+
+	bars, current := h.candles.History(ticker, interval.Name, limit)
+	return c.JSON(fiber.Map{
+		"ticker":   ticker,
+		"interval": interval.Name,
+		"bars":     bars,
+		"current":  current,
+	})
+}
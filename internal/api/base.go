@@ -1,10 +1,17 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dnhan1707/trader/internal/cache"
+	"github.com/dnhan1707/trader/internal/flight"
 	"github.com/dnhan1707/trader/internal/massive"
 	"github.com/dnhan1707/trader/internal/services"
 	"github.com/gofiber/fiber/v2"
@@ -15,6 +22,7 @@ type Handler struct {
 	massive          *massive.Client
 	institutionalSvc *services.InstitutionalOwnershipService
 	insiderSvc       *services.InsiderOwnershipService
+	flight           *flight.Group
 }
 
 func New(c *cache.Cache, m *massive.Client, inst *services.InstitutionalOwnershipService, insider *services.InsiderOwnershipService) *Handler {
@@ -23,31 +31,121 @@ func New(c *cache.Cache, m *massive.Client, inst *services.InstitutionalOwnershi
 		massive:          m,
 		institutionalSvc: inst,
 		insiderSvc:       insider,
+		flight:           flight.New(loadTimeout),
 	}
 }
 
-// cachedJSON: unified cache -> fetch -> async set -> respond flow
-func (h *Handler) cachedJSON(c *fiber.Ctx, cacheKey string, fetch func() (interface{}, error)) error {
-	if cached, err := h.cache.Get(cacheKey); err == nil {
-		return c.Type("json").SendString(cached)
+// param and query return a copy of a path or query value. Fiber's own
+// point into a buffer that is reused once the request returns, and what a
+// fetch captures can outlive it (see cached).
+func param(c *fiber.Ctx, key string) string {
+	return strings.Clone(c.Params(key))
+}
+
+func query(c *fiber.Ctx, key string, defaultValue ...string) string {
+	return strings.Clone(c.Query(key, defaultValue...))
+}
+
+// paging reads ?cursor= (a next_cursor from an earlier page) and ?all=true
+// for the list endpoints; key goes at the end of their cache key.
+func paging(c *fiber.Ctx) (p massive.Paging, key string) {
+	p = massive.Paging{Cursor: query(c, "cursor"), All: c.QueryBool("all")}
+	return p, fmt.Sprintf(":all=%t:cursor=%s", p.All, p.Cursor)
+}
+
+// A miss is fetched by one request per key in this process, and through a
+// short Redis lock by one instance; the rest wait up to loadWait for its
+// result before fetching themselves. The fetch gets its own context, as it
+// is shared by every waiting request; it is cancelled once they have all
+// gone.
+const (
+	loadTimeout = 30 * time.Second
+	loadWait    = 3 * time.Second
+	loadPoll    = 50 * time.Millisecond
+	lockPrefix  = "lock:"
+)
+
+// cachedJSON: unified cache -> fetch -> set -> respond flow. A value past
+// its soft TTL is served while one background fetch refreshes it.
+func (h *Handler) cachedJSON(c *fiber.Ctx, cacheKey string, fetch func(ctx context.Context) (interface{}, error)) error {
+	v, err := h.cached(c, cacheKey, fetch)
+	if err != nil {
+		return upstreamError(c, err)
 	}
+	return c.Type("json").SendString(v)
+}
 
-	data, err := fetch()
+// cached is the JSON for cacheKey, from the cache or fetch. When the fetch
+// fails with the upstream down it falls back to the last good copy and
+// marks the response stale. A background refresh, or a load other requests
+// still wait on, outlives this request: cacheKey is copied here, and fetch
+// must only hold values read with param and query.
+func (h *Handler) cached(c *fiber.Ctx, cacheKey string, fetch func(ctx context.Context) (interface{}, error)) (string, error) {
+	cacheKey = strings.Clone(cacheKey)
+	if v, stale, err := h.cache.Lookup(cacheKey); err == nil {
+		if stale {
+			h.flight.Start("refresh:"+cacheKey, func(ctx context.Context) (string, error) { return h.load(ctx, cacheKey, fetch, false) })
+		}
+		return v, nil
+	}
+
+	v, err := h.flight.Do(c.UserContext(), cacheKey, func(ctx context.Context) (string, error) { return h.load(ctx, cacheKey, fetch, true) })
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+		lv, savedAt, ok := h.stale(cacheKey, err)
+		if !ok {
+			return "", err
+		}
+		markStale(c, savedAt)
+		return lv, nil
 	}
+	return v, nil
+}
 
+// load fetches cacheKey and stores it, unless another instance holds its
+// lock: then it waits for that instance's value, or with wait false leaves
+// the refresh to it and returns "".
+func (h *Handler) load(ctx context.Context, cacheKey string, fetch func(ctx context.Context) (interface{}, error), wait bool) (string, error) {
+	lockKey, owner := lockPrefix+cacheKey, strconv.FormatUint(rand.Uint64(), 36)
+	locked, lerr := h.cache.TryLock(lockKey, owner, loadTimeout)
+	if locked {
+		defer h.cache.ReleaseLock(lockKey, owner)
+	} else if lerr == nil {
+		if !wait {
+			return "", nil
+		}
+		if v, ok := h.awaitFresh(ctx, cacheKey); ok {
+			return v, nil
+		}
+	}
+
+	data, err := fetch(ctx)
+	if err != nil {
+		if !wait {
+			log.Printf("[Cache] refresh %s: %v", cacheKey, err)
+		}
+		return "", err
+	}
 	jsonData, err := json.Marshal(data)
 	if err != nil {
-		return c.Status(500).JSON(fiber.Map{"error": "failed to marshal response"})
+		return "", fmt.Errorf("failed to marshal response: %w", err)
+	}
+	if err := h.cache.Set(cacheKey, string(jsonData)); err != nil {
+		log.Printf("[Cache] set %s: %v", cacheKey, err)
 	}
+	return string(jsonData), nil
+}
 
-	// fire-and-forget cache write
-	go func(k, v string) {
-		if err := h.cache.Set(k, v); err != nil {
-			fmt.Println("Redis set error:", err)
+// awaitFresh polls for a fresh value of cacheKey for up to loadWait.
+func (h *Handler) awaitFresh(ctx context.Context, cacheKey string) (string, bool) {
+	for deadline := time.Now().Add(loadWait); time.Now().Before(deadline); {
+		select {
+		case <-time.After(loadPoll):
+		case <-ctx.Done():
+			return "", false
 		}
-	}(cacheKey, string(jsonData))
-
-	return c.Type("json").SendString(string(jsonData))
+		if v, stale, err := h.cache.Lookup(cacheKey); err == nil && !stale {
+			return v, true
+		}
+	}
+	return "", false
 }
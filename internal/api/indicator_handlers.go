@@ -1,89 +1,96 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/dnhan1707/trader/internal/indicators"
+	"github.com/dnhan1707/trader/internal/massive"
 	"github.com/gofiber/fiber/v2"
 )
 
 func (h *Handler) GetSMA(c *fiber.Ctx) error {
-	stocksTicker := c.Params("stocksTicker")
+	stocksTicker := param(c, "stocksTicker")
 	if stocksTicker == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "missing stocksTicker"})
 	}
 	extra := map[string]string{
-		"timestamp":         c.Query("timestamp", ""),
-		"timespan":          c.Query("timespan", ""),
-		"adjusted":          c.Query("adjusted", ""),
-		"window":            c.Query("window", ""),
-		"series_type":       c.Query("series_type", ""),
-		"expand_underlying": c.Query("expand_underlying", ""),
-		"order":             c.Query("order", ""),
-		"limit":             c.Query("limit", ""),
-		"timestamp.gte":     c.Query("timestamp.gte", ""),
-		"timestamp.gt":      c.Query("timestamp.gt", ""),
-		"timestamp.lte":     c.Query("timestamp.lte", ""),
-		"timestamp.lt":      c.Query("timestamp.lt", ""),
+		"timestamp":         query(c, "timestamp"),
+		"timespan":          query(c, "timespan"),
+		"adjusted":          query(c, "adjusted"),
+		"window":            query(c, "window"),
+		"series_type":       query(c, "series_type"),
+		"expand_underlying": query(c, "expand_underlying"),
+		"order":             query(c, "order"),
+		"limit":             query(c, "limit"),
+		"timestamp.gte":     query(c, "timestamp.gte"),
+		"timestamp.gt":      query(c, "timestamp.gt"),
+		"timestamp.lte":     query(c, "timestamp.lte"),
+		"timestamp.lt":      query(c, "timestamp.lt"),
 	}
 	cacheKey := fmt.Sprintf("sma:%s:ts=%s:tsps=%s:adj=%s:w=%s:st=%s:exp=%s:ord=%s:lim=%s:gte=%s:gt=%s:lte=%s:lt=%s",
 		stocksTicker, extra["timestamp"], extra["timespan"], extra["adjusted"], extra["window"],
 		extra["series_type"], extra["expand_underlying"], extra["order"], extra["limit"],
 		extra["timestamp.gte"], extra["timestamp.gt"], extra["timestamp.lte"], extra["timestamp.lt"],
 	)
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetSMA(stocksTicker, extra)
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetSMA(ctx, stocksTicker, extra)
 	})
 }
 
 func (h *Handler) GetEMA(c *fiber.Ctx) error {
-	stocksTicker := c.Params("stocksTicker")
+	stocksTicker := param(c, "stocksTicker")
 	if stocksTicker == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "missing stocksTicker"})
 	}
 	extra := map[string]string{
-		"timestamp":         c.Query("timestamp", ""),
-		"timespan":          c.Query("timespan", ""),
-		"adjusted":          c.Query("adjusted", ""),
-		"window":            c.Query("window", ""),
-		"series_type":       c.Query("series_type", ""),
-		"expand_underlying": c.Query("expand_underlying", ""),
-		"order":             c.Query("order", ""),
-		"limit":             c.Query("limit", ""),
-		"timestamp.gte":     c.Query("timestamp.gte", ""),
-		"timestamp.gt":      c.Query("timestamp.gt", ""),
-		"timestamp.lte":     c.Query("timestamp.lte", ""),
-		"timestamp.lt":      c.Query("timestamp.lt", ""),
+		"timestamp":         query(c, "timestamp"),
+		"timespan":          query(c, "timespan"),
+		"adjusted":          query(c, "adjusted"),
+		"window":            query(c, "window"),
+		"series_type":       query(c, "series_type"),
+		"expand_underlying": query(c, "expand_underlying"),
+		"order":             query(c, "order"),
+		"limit":             query(c, "limit"),
+		"timestamp.gte":     query(c, "timestamp.gte"),
+		"timestamp.gt":      query(c, "timestamp.gt"),
+		"timestamp.lte":     query(c, "timestamp.lte"),
+		"timestamp.lt":      query(c, "timestamp.lt"),
 	}
 	cacheKey := fmt.Sprintf("ema:%s:ts=%s:tsps=%s:adj=%s:w=%s:st=%s:exp=%s:ord=%s:lim=%s:gte=%s:gt=%s:lte=%s:lt=%s",
 		stocksTicker, extra["timestamp"], extra["timespan"], extra["adjusted"], extra["window"],
 		extra["series_type"], extra["expand_underlying"], extra["order"], extra["limit"],
 		extra["timestamp.gte"], extra["timestamp.gt"], extra["timestamp.lte"], extra["timestamp.lt"],
 	)
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetEMA(stocksTicker, extra)
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetEMA(ctx, stocksTicker, extra)
 	})
 }
 
 func (h *Handler) GetMACD(c *fiber.Ctx) error {
-	stocksTicker := c.Params("stocksTicker")
+	stocksTicker := param(c, "stocksTicker")
 	if stocksTicker == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "missing stocksTicker"})
 	}
 	extra := map[string]string{
-		"timestamp":         c.Query("timestamp", ""),
-		"timespan":          c.Query("timespan", ""),
-		"adjusted":          c.Query("adjusted", ""),
-		"short_window":      c.Query("short_window", ""),
-		"long_window":       c.Query("long_window", ""),
-		"signal_window":     c.Query("signal_window", ""),
-		"series_type":       c.Query("series_type", ""),
-		"expand_underlying": c.Query("expand_underlying", ""),
-		"order":             c.Query("order", ""),
-		"limit":             c.Query("limit", ""),
-		"timestamp.gte":     c.Query("timestamp.gte", ""),
-		"timestamp.gt":      c.Query("timestamp.gt", ""),
-		"timestamp.lte":     c.Query("timestamp.lte", ""),
-		"timestamp.lt":      c.Query("timestamp.lt", ""),
+		"timestamp":         query(c, "timestamp"),
+		"timespan":          query(c, "timespan"),
+		"adjusted":          query(c, "adjusted"),
+		"short_window":      query(c, "short_window"),
+		"long_window":       query(c, "long_window"),
+		"signal_window":     query(c, "signal_window"),
+		"series_type":       query(c, "series_type"),
+		"expand_underlying": query(c, "expand_underlying"),
+		"order":             query(c, "order"),
+		"limit":             query(c, "limit"),
+		"timestamp.gte":     query(c, "timestamp.gte"),
+		"timestamp.gt":      query(c, "timestamp.gt"),
+		"timestamp.lte":     query(c, "timestamp.lte"),
+		"timestamp.lt":      query(c, "timestamp.lt"),
 	}
 	cacheKey := fmt.Sprintf("macd:%s:ts=%s:tsps=%s:adj=%s:sw=%s:lw=%s:sig=%s:st=%s:exp=%s:ord=%s:lim=%s:gte=%s:gt=%s:lte=%s:lt=%s",
 		stocksTicker, extra["timestamp"], extra["timespan"], extra["adjusted"],
@@ -91,36 +98,163 @@ func (h *Handler) GetMACD(c *fiber.Ctx) error {
 		extra["series_type"], extra["expand_underlying"], extra["order"], extra["limit"],
 		extra["timestamp.gte"], extra["timestamp.gt"], extra["timestamp.lte"], extra["timestamp.lt"],
 	)
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetMACD(stocksTicker, extra)
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetMACD(ctx, stocksTicker, extra)
 	})
 }
 
 func (h *Handler) GetRSI(c *fiber.Ctx) error {
-	stocksTicker := c.Params("stocksTicker")
+	stocksTicker := param(c, "stocksTicker")
 	if stocksTicker == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "missing stocksTicker"})
 	}
 	extra := map[string]string{
-		"timestamp":         c.Query("timestamp", ""),
-		"timespan":          c.Query("timespan", ""),
-		"adjusted":          c.Query("adjusted", ""),
-		"window":            c.Query("window", ""),
-		"series_type":       c.Query("series_type", ""),
-		"expand_underlying": c.Query("expand_underlying", ""),
-		"order":             c.Query("order", ""),
-		"limit":             c.Query("limit", ""),
-		"timestamp.gte":     c.Query("timestamp.gte", ""),
-		"timestamp.gt":      c.Query("timestamp.gt", ""),
-		"timestamp.lte":     c.Query("timestamp.lte", ""),
-		"timestamp.lt":      c.Query("timestamp.lt", ""),
+		"timestamp":         query(c, "timestamp"),
+		"timespan":          query(c, "timespan"),
+		"adjusted":          query(c, "adjusted"),
+		"window":            query(c, "window"),
+		"series_type":       query(c, "series_type"),
+		"expand_underlying": query(c, "expand_underlying"),
+		"order":             query(c, "order"),
+		"limit":             query(c, "limit"),
+		"timestamp.gte":     query(c, "timestamp.gte"),
+		"timestamp.gt":      query(c, "timestamp.gt"),
+		"timestamp.lte":     query(c, "timestamp.lte"),
+		"timestamp.lt":      query(c, "timestamp.lt"),
 	}
 	cacheKey := fmt.Sprintf("rsi:%s:ts=%s:tsps=%s:adj=%s:w=%s:st=%s:exp=%s:ord=%s:lim=%s:gte=%s:gt=%s:lte=%s:lt=%s",
 		stocksTicker, extra["timestamp"], extra["timespan"], extra["adjusted"], extra["window"],
 		extra["series_type"], extra["expand_underlying"], extra["order"], extra["limit"],
 		extra["timestamp.gte"], extra["timestamp.gt"], extra["timestamp.lte"], extra["timestamp.lt"],
 	)
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetRSI(stocksTicker, extra)
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetRSI(ctx, stocksTicker, extra)
+	})
+}
+
+// Default lookback per timespan when ?from= is missing.
+var indicatorLookback = map[string]time.Duration{
+	"minute":  5 * 24 * time.Hour,
+	"hour":    60 * 24 * time.Hour,
+	"day":     365 * 24 * time.Hour,
+	"week":    5 * 365 * 24 * time.Hour,
+	"month":   10 * 365 * 24 * time.Hour,
+	"quarter": 20 * 365 * 24 * time.Hour,
+}
+
+func indicatorBars(aggs []massive.Agg) []indicators.Bar {
+	bars := make([]indicators.Bar, len(aggs))
+	for i, a := range aggs {
+		bars[i] = indicators.Bar{Time: a.Timestamp, Open: a.Open, High: a.High, Low: a.Low,
+			Close: a.Close, Volume: a.Volume, VWAP: a.VWAP}
+	}
+	return bars
+}
+
+func indicatorOptions(timespan string, market *time.Location) indicators.Options {
+	var opts indicators.Options
+	if timespan == "minute" || timespan == "hour" {
+		// Intraday VWAP starts over every trading day.
+		opts.NewSession = func(prev, cur indicators.Bar) bool {
+			return time.UnixMilli(prev.Time).In(market).YearDay() != time.UnixMilli(cur.Time).In(market).YearDay()
+		}
+	}
+	return opts
+}
+
+// GetIndicators computes several indicators locally from one aggregates
+// request: /api/indicators/AAPL?set=rsi:14,bb:20:2,atr:14&timespan=day.
+// Every series is aligned to the returned bar timestamps "t"; warm-up
+// values are null. Bars before ?from= are loaded so the first values have
+// settled.
+func (h *Handler) GetIndicators(c *fiber.Ctx) error {
+	ticker := strings.ToUpper(param(c, "ticker"))
+	if ticker == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "missing ticker"})
+	}
+	specs, err := indicators.ParseSet(query(c, "set"))
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	timespan := query(c, "timespan", "day")
+	lookback, ok := indicatorLookback[timespan]
+	if !ok {
+		return c.Status(400).JSON(fiber.Map{"error": "timespan must be minute, hour, day, week, month or quarter"})
+	}
+	multiplier, err := strconv.Atoi(query(c, "multiplier", "1"))
+	if err != nil || multiplier < 1 || multiplier > 1000 {
+		return c.Status(400).JSON(fiber.Map{"error": "invalid multiplier"})
+	}
+	adjusted := query(c, "adjusted", "true") != "false"
+
+	market, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		market = time.UTC
+	}
+	to := time.Now().In(market)
+	if v := query(c, "to"); v != "" {
+		if to, err = time.ParseInLocation("2006-01-02", v, market); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "to must be YYYY-MM-DD"})
+		}
+	}
+	from := to.Add(-lookback)
+	if v := query(c, "from"); v != "" {
+		if from, err = time.ParseInLocation("2006-01-02", v, market); err != nil {
+			return c.Status(400).JSON(fiber.Map{"error": "from must be YYYY-MM-DD"})
+		}
+	}
+	if from.After(to) {
+		return c.Status(400).JSON(fiber.Map{"error": "from is after to"})
+	}
+	from = time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, market)
+	fromDate, toDate := from.Format("2006-01-02"), to.Format("2006-01-02")
+
+	keys := make([]string, len(specs))
+	warmup := 0
+	for i, s := range specs {
+		keys[i] = s.Key()
+		if w := s.Warmup(); w > warmup {
+			warmup = w
+		}
+	}
+
+	cacheKey := fmt.Sprintf("indicators:%s:%d:%s:%s:%s:adj=%t:set=%s",
+		ticker, multiplier, timespan, fromDate, toDate, adjusted, strings.Join(keys, ","))
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		start := indicators.LookbackStart(from, timespan, multiplier, warmup).Format("2006-01-02")
+		aggs, err := h.massive.Aggregates(ctx, ticker, multiplier, timespan, start, toDate, adjusted)
+		if err != nil {
+			return nil, err
+		}
+
+		bars := indicatorBars(aggs)
+		opts := indicatorOptions(timespan, market)
+
+		// Drop the warm-up bars from the output.
+		first := sort.Search(len(bars), func(i int) bool { return bars[i].Time >= from.UnixMilli() })
+		times := make([]int64, 0, len(bars)-first)
+		for _, b := range bars[first:] {
+			times = append(times, b.Time)
+		}
+		series := make(map[string]map[string]indicators.Line, len(specs))
+		for i, s := range specs {
+			lines := s.Compute(bars, opts)
+			for name, l := range lines {
+				lines[name] = l[first:]
+			}
+			series[keys[i]] = lines
+		}
+
+		return fiber.Map{
+			"ticker":     ticker,
+			"timespan":   timespan,
+			"multiplier": multiplier,
+			"from":       fromDate,
+			"to":         toDate,
+			"adjusted":   adjusted,
+			"t":          times,
+			"indicators": series,
+		}, nil
 	})
 }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
@@ -8,42 +9,43 @@ import (
 
 func (h *Handler) GetExchanges(c *fiber.Ctx) error {
 	extra := map[string]string{
-		"asset_class": c.Query("asset_class", ""),
-		"locale":      c.Query("locale", ""),
+		"asset_class": query(c, "asset_class"),
+		"locale":      query(c, "locale"),
 	}
 	cacheKey := fmt.Sprintf("exchanges:asset=%s:locale=%s", extra["asset_class"], extra["locale"])
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetExchanges(extra)
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetExchanges(ctx, extra)
 	})
 }
 
 func (h *Handler) GetMarketHolidays(c *fiber.Ctx) error {
-	return h.cachedJSON(c, "market:upcoming", func() (interface{}, error) {
-		return h.massive.GetMarketHolidays()
+	return h.cachedJSON(c, "market:upcoming", func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetMarketHolidays(ctx)
 	})
 }
 
 func (h *Handler) GetMarketStatus(c *fiber.Ctx) error {
-	return h.cachedJSON(c, "market:now", func() (interface{}, error) {
-		return h.massive.GetMarketStatus()
+	return h.cachedJSON(c, "market:now", func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetMarketStatus(ctx)
 	})
 }
 
 func (h *Handler) GetConditions(c *fiber.Ctx) error {
 	extra := map[string]string{
-		"asset_class": c.Query("asset_class", ""),
-		"data_type":   c.Query("data_type", ""),
-		"id":          c.Query("id", ""),
-		"sip":         c.Query("sip", ""),
-		"order":       c.Query("order", ""),
-		"limit":       c.Query("limit", ""),
-		"sort":        c.Query("sort", ""),
+		"asset_class": query(c, "asset_class"),
+		"data_type":   query(c, "data_type"),
+		"id":          query(c, "id"),
+		"sip":         query(c, "sip"),
+		"order":       query(c, "order"),
+		"limit":       query(c, "limit"),
+		"sort":        query(c, "sort"),
 	}
+	page, pageKey := paging(c)
 	cacheKey := fmt.Sprintf("conditions:asset=%s:data=%s:id=%s:sip=%s:order=%s:limit=%s:sort=%s",
 		extra["asset_class"], extra["data_type"], extra["id"], extra["sip"],
 		extra["order"], extra["limit"], extra["sort"],
-	)
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetConditions(extra)
+	) + pageKey
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetConditions(ctx, extra, page)
 	})
 }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -8,8 +9,8 @@ import (
 )
 
 func (h *Handler) GetNews(c *fiber.Ctx) error {
-	rawSort := c.Query("sort", "published_utc")
-	rawOrder := c.Query("order", "")
+	rawSort := query(c, "sort", "published_utc")
+	rawOrder := query(c, "order")
 
 	var sortField string
 	var order string
@@ -38,30 +39,31 @@ func (h *Handler) GetNews(c *fiber.Ctx) error {
 	}
 
 	extra := map[string]string{
-		"ticker":            c.Query("ticker", ""),
-		"published_utc":     c.Query("published_utc", ""),
-		"ticker.gte":        c.Query("ticker.gte", ""),
-		"ticker.gt":         c.Query("ticker.gt", ""),
-		"ticker.lte":        c.Query("ticker.lte", ""),
-		"ticker.lt":         c.Query("ticker.lt", ""),
-		"published_utc.gte": c.Query("published_utc.gte", ""),
-		"published_utc.gt":  c.Query("published_utc.gt", ""),
-		"published_utc.lte": c.Query("published_utc.lte", ""),
-		"published_utc.lt":  c.Query("published_utc.lt", ""),
+		"ticker":            query(c, "ticker"),
+		"published_utc":     query(c, "published_utc"),
+		"ticker.gte":        query(c, "ticker.gte"),
+		"ticker.gt":         query(c, "ticker.gt"),
+		"ticker.lte":        query(c, "ticker.lte"),
+		"ticker.lt":         query(c, "ticker.lt"),
+		"published_utc.gte": query(c, "published_utc.gte"),
+		"published_utc.gt":  query(c, "published_utc.gt"),
+		"published_utc.lte": query(c, "published_utc.lte"),
+		"published_utc.lt":  query(c, "published_utc.lt"),
 		"order":             order,
-		"limit":             c.Query("limit", ""),
+		"limit":             query(c, "limit"),
 		"sort":              sortField,
 	}
 
+	page, pageKey := paging(c)
 	cacheKey := fmt.Sprintf(
 		"news:t=%s:pub=%s:sort=%s:order=%s:tgte=%s:tgt=%s:tlte=%s:tlt=%s:pgte=%s:pgt=%s:plte=%s:plt=%s:lim=%s",
 		extra["ticker"], extra["published_utc"], extra["sort"], extra["order"],
 		extra["ticker.gte"], extra["ticker.gt"], extra["ticker.lte"], extra["ticker.lt"],
 		extra["published_utc.gte"], extra["published_utc.gt"], extra["published_utc.lte"], extra["published_utc.lt"],
 		extra["limit"],
-	)
+	) + pageKey
 
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetNews(extra)
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetNews(ctx, extra, page)
 	})
 }
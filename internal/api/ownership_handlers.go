@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -9,8 +10,8 @@ import (
 )
 
 func (h *Handler) GetTopOwners(c *fiber.Ctx) error {
-	ticker := c.Query("ticker")
-	companyName := c.Query("companyName")
+	ticker := query(c, "ticker")
+	companyName := query(c, "companyName")
 	limit := c.QueryInt("limit")
 
 	if ticker == "" {
@@ -28,13 +29,13 @@ func (h *Handler) GetTopOwners(c *fiber.Ctx) error {
 	log.Debug(fmt.Sprintf("TopOwner - Company name query = %s", companyName))
 	cacheKey := fmt.Sprintf("top-owners:%s:%s", ticker, companyName)
 
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
+	return h.cachedJSON(c, cacheKey, func(context.Context) (interface{}, error) {
 		return h.institutionalSvc.GetTopOwnersByNameWithTicker(companyName, ticker, limit)
 	})
 }
 
 func (h *Handler) GetTopOwnersByCusip(c *fiber.Ctx) error {
-	ticker := c.Query("ticker")
+	ticker := query(c, "ticker")
 	limit := c.QueryInt("limit")
 
 	if ticker == "" {
@@ -47,13 +48,13 @@ func (h *Handler) GetTopOwnersByCusip(c *fiber.Ctx) error {
 	log.Debug(fmt.Sprintf("TopOwnersByCusip - Ticker query = %s", ticker))
 	cacheKey := fmt.Sprintf("top-owners-cusip:%s:%d", ticker, limit)
 
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
+	return h.cachedJSON(c, cacheKey, func(context.Context) (interface{}, error) {
 		return h.institutionalSvc.GetTopOwnersByCusip(ticker, limit)
 	})
 }
 
 func (h *Handler) GetTopInsiders(c *fiber.Ctx) error {
-	ticker := c.Query("ticker")
+	ticker := query(c, "ticker")
 	startYear := c.QueryInt("startYear")
 	limit := c.QueryInt("limit")
 
@@ -70,7 +71,7 @@ func (h *Handler) GetTopInsiders(c *fiber.Ctx) error {
 	log.Debug(fmt.Sprintf("TopInsiders - Ticker = %s, StartYear = %d", ticker, startYear))
 	cacheKey := fmt.Sprintf("top-insiders:%s:%d:%d", ticker, startYear, limit)
 
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
+	return h.cachedJSON(c, cacheKey, func(context.Context) (interface{}, error) {
 		return h.insiderSvc.GetTopInsidersFiltered(ticker, startYear, limit)
 	})
 }
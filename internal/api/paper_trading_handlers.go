@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dnhan1707/trader/internal/quotes"
+	"github.com/dnhan1707/trader/internal/services"
+	"github.com/dnhan1707/trader/internal/trading"
+	"github.com/gofiber/fiber/v2"
+)
+
+// TradingHandler serves the paper-trading routes under /api/trading.
+type TradingHandler struct {
+	tradingService *services.TradingService
+	engine         *trading.Engine
+	quotes         *quotes.Cache
+}
+
+func NewTradingHandler(tradingService *services.TradingService, engine *trading.Engine, q *quotes.Cache) *TradingHandler {
+	return &TradingHandler{tradingService: tradingService, engine: engine, quotes: q}
+}
+
+type placeOrderRequest struct {
+	Ticker      string   `json:"ticker"`
+	Side        string   `json:"side"`
+	Type        string   `json:"type"`
+	Qty         float64  `json:"qty"`
+	LimitPrice  *float64 `json:"limit_price"`
+	StopPrice   *float64 `json:"stop_price"`
+	TimeInForce string   `json:"time_in_force"`
+}
+
+// positionView is a position marked to the live price when there is one.
+type positionView struct {
+	services.PaperPosition
+	LastPrice     *float64 `json:"last_price"`
+	MarketValue   *float64 `json:"market_value"`
+	UnrealizedPnL *float64 `json:"unrealized_pnl"`
+}
+
+func (h *TradingHandler) markPositions(positions []services.PaperPosition) ([]positionView, float64) {
+	views := make([]positionView, 0, len(positions))
+	var total float64
+	for _, p := range positions {
+		v := positionView{PaperPosition: p}
+		value := p.Qty * p.AvgCost // cost basis until we have a price
+		if q, ok := h.quotes.Get(p.Ticker); ok && q.Price() > 0 {
+			price := q.Price()
+			value = p.Qty * price
+			pnl := (price - p.AvgCost) * p.Qty
+			v.LastPrice, v.MarketValue, v.UnrealizedPnL = &price, &value, &pnl
+		}
+		total += value
+		views = append(views, v)
+	}
+	return views, total
+}
+
+// GetAccount returns the user's cash and equity, opening the account on
+// first use.
+func (h *TradingHandler) GetAccount(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	acct, err := h.tradingService.GetOrCreateAccount(context.Background(), currentUserID)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load account"})
+	}
+	positions, err := h.tradingService.ListPositions(context.Background(), currentUserID)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load positions"})
+	}
+	_, positionsValue := h.markPositions(positions)
+
+	return ctx.JSON(fiber.Map{
+		"account":         acct,
+		"positions_value": positionsValue,
+		"equity":          acct.Cash + positionsValue,
+	})
+}
+
+func (h *TradingHandler) ListPositions(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	positions, err := h.tradingService.ListPositions(context.Background(), currentUserID)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load positions"})
+	}
+	views, _ := h.markPositions(positions)
+	return ctx.JSON(views)
+}
+
+func (h *TradingHandler) PlaceOrder(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req placeOrderRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+	o := services.PaperOrder{
+		UserID:      currentUserID,
+		Ticker:      req.Ticker,
+		Side:        req.Side,
+		Type:        req.Type,
+		Qty:         req.Qty,
+		LimitPrice:  req.LimitPrice,
+		StopPrice:   req.StopPrice,
+		TimeInForce: req.TimeInForce,
+	}
+	if err := services.ValidateOrder(&o); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	placed, err := h.engine.Submit(context.Background(), o)
+	if err != nil {
+		if verr, ok := err.(services.ValidationError); ok {
+			return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": verr.Error()})
+		}
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not place order"})
+	}
+	return ctx.Status(http.StatusCreated).JSON(placed)
+}
+
+// ListOrders returns the order history, newest first. ?status= filters by
+// status, "working" for open and triggered orders.
+func (h *TradingHandler) ListOrders(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	status := ctx.Query("status")
+	switch status {
+	case "", "working", services.OrderOpen, services.OrderTriggered, services.OrderFilled,
+		services.OrderCancelled, services.OrderExpired, services.OrderRejected:
+	default:
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid status"})
+	}
+
+	limit, err := strconv.Atoi(ctx.Query("limit", "100"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+
+	orders, err := h.tradingService.ListOrders(context.Background(), currentUserID, status, limit)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not list orders"})
+	}
+	return ctx.JSON(orders)
+}
+
+func (h *TradingHandler) GetOrder(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid order id"})
+	}
+
+	o, err := h.tradingService.GetOrder(context.Background(), currentUserID, id)
+	if err == sql.ErrNoRows {
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	}
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load order"})
+	}
+	return ctx.JSON(o)
+}
+
+func (h *TradingHandler) CancelOrder(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid order id"})
+	}
+
+	o, err := h.engine.Cancel(context.Background(), currentUserID, id)
+	switch {
+	case err == sql.ErrNoRows:
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "order not found"})
+	case err == services.ErrOrderNotWorking:
+		return ctx.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case err != nil:
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not cancel order"})
+	}
+	return ctx.JSON(o)
+}
+
+// ListFills returns executions, newest first, optionally for one ?ticker=.
+func (h *TradingHandler) ListFills(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	limit, err := strconv.Atoi(ctx.Query("limit", "500"))
+	if err != nil || limit <= 0 || limit > 5000 {
+		limit = 500
+	}
+
+	fills, err := h.tradingService.ListFills(context.Background(), currentUserID, strings.ToUpper(ctx.Query("ticker")), limit)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not list fills"})
+	}
+	return ctx.JSON(fills)
+}
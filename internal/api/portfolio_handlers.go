@@ -0,0 +1,477 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/dnhan1707/trader/internal/portfolio"
+	"github.com/dnhan1707/trader/internal/quotes"
+	"github.com/dnhan1707/trader/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// Dividend lists are re-fetched after this long.
+const dividendsTTL = 12 * time.Hour
+
+type dividendEntry struct {
+	from    string // ex dates on or after this were fetched
+	divs    []massive.Dividend
+	fetched time.Time
+}
+
+// PortfolioHandler serves /api/portfolio. Positions are rebuilt from the
+// user's paper fills and manual transactions on every request, see
+// internal/portfolio.
+type PortfolioHandler struct {
+	portfolioService *services.PortfolioService
+	massive          *massive.Client
+	quotes           *quotes.Cache
+	market           *time.Location
+
+	mu        sync.Mutex
+	sectors   map[string]string // ticker -> sic_description
+	dividends map[string]dividendEntry
+}
+
+func NewPortfolioHandler(portfolioService *services.PortfolioService, m *massive.Client, q *quotes.Cache) *PortfolioHandler {
+	market, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		market = time.UTC
+	}
+	return &PortfolioHandler{
+		portfolioService: portfolioService,
+		massive:          m,
+		quotes:           q,
+		market:           market,
+		sectors:          make(map[string]string),
+		dividends:        make(map[string]dividendEntry),
+	}
+}
+
+type createTransactionRequest struct {
+	Ticker   string  `json:"ticker"`
+	Side     string  `json:"side"`
+	Qty      float64 `json:"qty"`
+	Price    float64 `json:"price"`
+	Fees     float64 `json:"fees"`
+	TradedAt string  `json:"traded_at"` // RFC 3339 or YYYY-MM-DD
+	Note     string  `json:"note"`
+}
+
+// book is what every portfolio endpoint starts from.
+type book struct {
+	method  portfolio.Method
+	trades  []portfolio.Trade
+	account *services.PaperAccount // nil for ?source=manual or no paper account
+}
+
+// loadBook reads ?method= and ?source= (all, paper or manual) and the
+// matching trades. It writes the error response itself and returns nil.
+func (h *PortfolioHandler) loadBook(ctx *fiber.Ctx, userID string) *book {
+	method, ok := portfolio.ParseMethod(ctx.Query("method"))
+	if !ok {
+		ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "method must be fifo, lifo or avg"})
+		return nil
+	}
+	source := ctx.Query("source", "all")
+	if source != "all" && source != portfolio.SourcePaper && source != portfolio.SourceManual {
+		ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "source must be all, paper or manual"})
+		return nil
+	}
+
+	b := &book{method: method, trades: []portfolio.Trade{}}
+	if source != portfolio.SourceManual {
+		acct, err := h.portfolioService.GetPaperAccount(context.Background(), userID)
+		if err != nil && err != sql.ErrNoRows {
+			ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load paper account"})
+			return nil
+		}
+		if err == nil {
+			b.account = acct
+			fills, err := h.portfolioService.ListPaperFills(context.Background(), userID)
+			if err != nil {
+				ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load fills"})
+				return nil
+			}
+			for _, f := range fills {
+				b.trades = append(b.trades, portfolio.Trade{
+					Source: portfolio.SourcePaper, ID: f.ID, Ticker: f.Ticker,
+					Side: f.Side, Qty: f.Qty, Price: f.Price, Time: f.FilledAt,
+				})
+			}
+		}
+	}
+	if source != portfolio.SourcePaper {
+		txs, err := h.portfolioService.ListTransactions(context.Background(), userID)
+		if err != nil {
+			ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load transactions"})
+			return nil
+		}
+		b.trades = append(b.trades, manualTrades(txs)...)
+	}
+	portfolio.SortTrades(b.trades)
+	return b
+}
+
+func manualTrades(txs []services.PortfolioTransaction) []portfolio.Trade {
+	trades := make([]portfolio.Trade, 0, len(txs))
+	for _, t := range txs {
+		trades = append(trades, portfolio.Trade{
+			Source: portfolio.SourceManual, ID: t.ID, Ticker: t.Ticker,
+			Side: t.Side, Qty: t.Qty, Price: t.Price, Fees: t.Fees, Time: t.TradedAt,
+		})
+	}
+	return trades
+}
+
+func (h *PortfolioHandler) today() string {
+	return time.Now().In(h.market).Format("2006-01-02")
+}
+
+// tickers returns every traded ticker with the date it was first traded.
+func (h *PortfolioHandler) tickers(trades []portfolio.Trade) map[string]string {
+	first := make(map[string]string)
+	for _, t := range trades {
+		d := t.Time.In(h.market).Format("2006-01-02")
+		if cur, ok := first[t.Ticker]; !ok || d < cur {
+			first[t.Ticker] = d
+		}
+	}
+	return first
+}
+
+// sector returns the ticker's SIC description, "" when unknown.
+func (h *PortfolioHandler) sector(reqCtx context.Context, ticker string) string {
+	h.mu.Lock()
+	s, ok := h.sectors[ticker]
+	h.mu.Unlock()
+	if ok {
+		return s
+	}
+
+	d, err := h.massive.Details(reqCtx, ticker)
+	if err != nil {
+		log.Printf("[Portfolio] details %s: %v", ticker, err)
+		return ""
+	}
+	h.mu.Lock()
+	h.sectors[ticker] = d.SICDescription
+	h.mu.Unlock()
+	return d.SICDescription
+}
+
+// credits loads the dividends of every traded ticker and matches them
+// against the trades.
+func (h *PortfolioHandler) credits(reqCtx context.Context, trades []portfolio.Trade) []portfolio.DividendCredit {
+	var divs []massive.Dividend
+	for ticker, from := range h.tickers(trades) {
+		h.mu.Lock()
+		e, ok := h.dividends[ticker]
+		h.mu.Unlock()
+
+		if !ok || e.from > from || time.Since(e.fetched) > dividendsTTL {
+			list, err := h.massive.Dividends(reqCtx, ticker, from)
+			if err != nil {
+				log.Printf("[Portfolio] dividends %s: %v", ticker, err)
+				continue
+			}
+			e = dividendEntry{from: from, divs: list, fetched: time.Now()}
+			h.mu.Lock()
+			h.dividends[ticker] = e
+			h.mu.Unlock()
+		}
+		divs = append(divs, e.divs...)
+	}
+	return portfolio.CreditDividends(trades, divs, h.market, h.today())
+}
+
+// GetPortfolio returns positions with realized and unrealized P&L, dividends
+// and exposure by sector. Open positions are marked to the live quote.
+func (h *PortfolioHandler) GetPortfolio(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	b := h.loadBook(ctx, currentUserID)
+	if b == nil {
+		return nil
+	}
+
+	positions, err := portfolio.Book(b.trades, b.method)
+	if err != nil {
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var (
+		list                                  = make([]*portfolio.Position, 0, len(positions))
+		open                                  []*portfolio.Position
+		costBasis, marketValue, realized, unr float64
+	)
+	for _, p := range positions {
+		list = append(list, p)
+		realized += p.RealizedPnL
+		if p.Qty <= 0 {
+			continue
+		}
+		open = append(open, p)
+		if q, ok := h.quotes.Lookup(p.Ticker); ok && q.Price() > 0 {
+			p.MarkAt(q.Price())
+			unr += *p.UnrealizedPnL
+		}
+		p.Sector = h.sector(ctx.UserContext(), p.Ticker)
+		costBasis += p.CostBasis
+		marketValue += p.Value()
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Ticker < list[j].Ticker })
+
+	credits := h.credits(ctx.UserContext(), b.trades)
+	var paid, pending float64
+	for _, c := range credits {
+		if c.Paid {
+			paid += c.Amount
+		} else {
+			pending += c.Amount
+		}
+	}
+
+	res := fiber.Map{
+		"method":    b.method,
+		"positions": list,
+		"totals": fiber.Map{
+			"cost_basis":        costBasis,
+			"market_value":      marketValue,
+			"realized_pnl":      realized,
+			"unrealized_pnl":    unr,
+			"dividends_paid":    paid,
+			"dividends_pending": pending,
+			"total_pnl":         realized + unr + paid,
+		},
+		"exposure": portfolio.Exposure(open),
+	}
+	if b.account != nil {
+		res["cash"] = b.account.Cash
+	}
+	return ctx.JSON(res)
+}
+
+// GetPerformance returns the daily equity curve with its time-weighted
+// return, max drawdown and Sharpe ratio. ?from=YYYY-MM-DD trims the curve,
+// ?rf= is the annual risk-free rate (0.04 for 4%).
+func (h *PortfolioHandler) GetPerformance(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	from := ctx.Query("from")
+	if from != "" {
+		if _, err := time.Parse("2006-01-02", from); err != nil {
+			return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "from must be YYYY-MM-DD"})
+		}
+	}
+	rf, err := strconv.ParseFloat(ctx.Query("rf", "0"), 64)
+	if err != nil || rf < 0 || rf > 1 {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "rf must be between 0 and 1"})
+	}
+
+	b := h.loadBook(ctx, currentUserID)
+	if b == nil {
+		return nil
+	}
+	if _, err := portfolio.Book(b.trades, b.method); err != nil {
+		return ctx.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	today := h.today()
+	px := portfolio.Prices{
+		Closes: h.closes(ctx.UserContext(), b.trades, today),
+		Marks:  make(map[string]float64),
+		Today:  today,
+		Loc:    h.market,
+	}
+	for ticker := range px.Closes {
+		if q, ok := h.quotes.Get(ticker); ok && q.Price() > 0 {
+			px.Marks[ticker] = q.Price()
+		}
+	}
+
+	var acct *portfolio.Account
+	if b.account != nil {
+		acct = &portfolio.Account{
+			StartingCash: b.account.StartingCash,
+			Opened:       b.account.CreatedAt.In(h.market).Format("2006-01-02"),
+		}
+	}
+
+	points := portfolio.EquityCurve(b.trades, h.credits(ctx.UserContext(), b.trades), acct, px)
+	if from != "" {
+		points = portfolio.Since(points, from)
+	}
+	return ctx.JSON(fiber.Map{
+		"method": b.method,
+		"stats":  portfolio.Summarize(points, rf),
+		"curve":  points,
+	})
+}
+
+// closes fetches unadjusted daily closes (trade prices are unadjusted too)
+// for every traded ticker since its first trade.
+func (h *PortfolioHandler) closes(reqCtx context.Context, trades []portfolio.Trade, today string) map[string]map[string]float64 {
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		out = make(map[string]map[string]float64)
+	)
+	for ticker, from := range h.tickers(trades) {
+		wg.Add(1)
+		go func(ticker, from string) {
+			defer wg.Done()
+			bars, err := h.massive.Aggregates(reqCtx, ticker, 1, "day", from, today, false)
+			if err != nil {
+				log.Printf("[Portfolio] daily bars %s: %v", ticker, err)
+				bars = nil
+			}
+			closes := make(map[string]float64, len(bars))
+			for _, bar := range bars {
+				d := time.UnixMilli(bar.Timestamp).In(h.market).Format("2006-01-02")
+				closes[d] = bar.Close
+			}
+			mu.Lock()
+			out[ticker] = closes
+			mu.Unlock()
+		}(ticker, from)
+	}
+	wg.Wait()
+	return out
+}
+
+// ListDividends returns the dividends credited to the portfolio.
+func (h *PortfolioHandler) ListDividends(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	b := h.loadBook(ctx, currentUserID)
+	if b == nil {
+		return nil
+	}
+	return ctx.JSON(h.credits(ctx.UserContext(), b.trades))
+}
+
+func (h *PortfolioHandler) ListTransactions(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	txs, err := h.portfolioService.ListTransactions(context.Background(), currentUserID)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not list transactions"})
+	}
+	return ctx.JSON(txs)
+}
+
+// CreateTransaction records a manual trade. A sell larger than the manual
+// holdings at that time is rejected.
+func (h *PortfolioHandler) CreateTransaction(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req createTransactionRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+	t := services.PortfolioTransaction{
+		UserID: currentUserID,
+		Ticker: req.Ticker,
+		Side:   req.Side,
+		Qty:    req.Qty,
+		Price:  req.Price,
+		Fees:   req.Fees,
+		Note:   req.Note,
+	}
+	if req.TradedAt != "" {
+		at, err := time.Parse(time.RFC3339, req.TradedAt)
+		if err != nil {
+			at, err = time.ParseInLocation("2006-01-02", req.TradedAt, h.market)
+		}
+		if err != nil {
+			return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "traded_at must be RFC 3339 or YYYY-MM-DD"})
+		}
+		t.TradedAt = at
+	}
+	if err := services.ValidateTransaction(&t); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	txs, err := h.portfolioService.ListTransactions(context.Background(), currentUserID)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load transactions"})
+	}
+	if err := checkManualBook(append(txs, t)); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	created, err := h.portfolioService.CreateTransaction(context.Background(), t)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not save transaction"})
+	}
+	return ctx.Status(http.StatusCreated).JSON(created)
+}
+
+// DeleteTransaction removes a manual trade, unless a later sell depends on it.
+func (h *PortfolioHandler) DeleteTransaction(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid transaction id"})
+	}
+
+	txs, err := h.portfolioService.ListTransactions(context.Background(), currentUserID)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load transactions"})
+	}
+	rest := make([]services.PortfolioTransaction, 0, len(txs))
+	for _, t := range txs {
+		if t.ID != id {
+			rest = append(rest, t)
+		}
+	}
+	if len(rest) == len(txs) {
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "transaction not found"})
+	}
+	if err := checkManualBook(rest); err != nil {
+		return ctx.Status(http.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	err = h.portfolioService.DeleteTransaction(context.Background(), currentUserID, id)
+	if err == sql.ErrNoRows {
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "transaction not found"})
+	}
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not delete transaction"})
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// checkManualBook replays the manual trades on their own and reports an
+// oversold ticker. Paper fills are checked by the trading service, so the
+// combined book is consistent whenever both halves are.
+func checkManualBook(txs []services.PortfolioTransaction) error {
+	trades := manualTrades(txs)
+	portfolio.SortTrades(trades)
+	_, err := portfolio.Book(trades, portfolio.FIFO)
+	return err
+}
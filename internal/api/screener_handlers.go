@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+
+	"github.com/dnhan1707/trader/internal/screener"
+	"github.com/dnhan1707/trader/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	screenerDefaultLimit = 50
+	screenerMaxLimit     = 500
+	screenerDefaultSort  = "-market_cap"
+)
+
+// ScreenerHandler serves /api/screener over the universe kept by
+// screener.Refresher.
+type ScreenerHandler struct {
+	screenerService *services.ScreenerService
+}
+
+func NewScreenerHandler(screenerService *services.ScreenerService) *ScreenerHandler {
+	return &ScreenerHandler{screenerService: screenerService}
+}
+
+type saveScreenRequest struct {
+	Name   *string `json:"name"`
+	Filter *string `json:"filter"`
+	Sort   *string `json:"sort"`
+}
+
+func screenID(ctx *fiber.Ctx) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// screenerError maps service errors to responses; fallback is the 500
+// message.
+func screenerError(ctx *fiber.Ctx, err error, fallback string) error {
+	if e, ok := err.(services.ValidationError); ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": e.Error()})
+	}
+	if err == sql.ErrNoRows {
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "screen not found"})
+	}
+	return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": fallback})
+}
+
+// screen runs filter and sort with ?limit= and ?offset= and writes the
+// page.
+func (h *ScreenerHandler) screen(ctx *fiber.Ctx, filter, sort string) error {
+	if sort == "" {
+		sort = screenerDefaultSort
+	}
+	f, err := screener.ParseFilter(filter)
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "filter: " + err.Error()})
+	}
+	orderBy, err := screener.ParseSort(sort)
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "sort: " + err.Error()})
+	}
+	limit, err := strconv.Atoi(ctx.Query("limit", strconv.Itoa(screenerDefaultLimit)))
+	if err != nil || limit < 1 || limit > screenerMaxLimit {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "limit must be between 1 and " + strconv.Itoa(screenerMaxLimit)})
+	}
+	offset, err := strconv.Atoi(ctx.Query("offset", "0"))
+	if err != nil || offset < 0 {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "offset must not be negative"})
+	}
+
+	rows, total, err := h.screenerService.Screen(context.Background(), f.Where(), f.Args(), orderBy, limit, offset)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not run screen"})
+	}
+	updated, _, err := h.screenerService.UniverseUpdatedAt(context.Background())
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not run screen"})
+	}
+	return ctx.JSON(fiber.Map{
+		"filter":     filter,
+		"sort":       sort,
+		"total":      total,
+		"limit":      limit,
+		"offset":     offset,
+		"updated_at": updated,
+		"results":    rows,
+	})
+}
+
+// Screen runs ?filter= (e.g. "rsi_14 < 30 and market_cap > 10B") sorted by
+// ?sort= (e.g. "-market_cap,ticker"). See GET /api/screener/fields.
+func (h *ScreenerHandler) Screen(ctx *fiber.Ctx) error {
+	return h.screen(ctx, ctx.Query("filter"), ctx.Query("sort"))
+}
+
+// GetFields lists what filters and sorts can use and how fresh the
+// universe is.
+func (h *ScreenerHandler) GetFields(ctx *fiber.Ctx) error {
+	updated, count, err := h.screenerService.UniverseUpdatedAt(context.Background())
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not load universe"})
+	}
+	return ctx.JSON(fiber.Map{
+		"fields":     screener.Fields,
+		"tickers":    count,
+		"updated_at": updated,
+	})
+}
+
+// checkScreen validates the filter and sort a screen is saved with.
+func checkScreen(ctx *fiber.Ctx, filter, sort *string) bool {
+	if filter != nil {
+		if _, err := screener.ParseFilter(*filter); err != nil {
+			ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "filter: " + err.Error()})
+			return false
+		}
+	}
+	if sort != nil {
+		if _, err := screener.ParseSort(*sort); err != nil {
+			ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "sort: " + err.Error()})
+			return false
+		}
+	}
+	return true
+}
+
+func (h *ScreenerHandler) ListScreens(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	screens, err := h.screenerService.ListScreens(context.Background(), currentUserID)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not list screens"})
+	}
+	return ctx.JSON(screens)
+}
+
+func (h *ScreenerHandler) CreateScreen(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req saveScreenRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+	if req.Name == nil || req.Filter == nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "name and filter required"})
+	}
+	if !checkScreen(ctx, req.Filter, req.Sort) {
+		return nil
+	}
+	sc := services.SavedScreen{UserID: currentUserID, Name: *req.Name, Filter: *req.Filter}
+	if req.Sort != nil {
+		sc.Sort = *req.Sort
+	}
+
+	saved, err := h.screenerService.CreateScreen(context.Background(), sc)
+	if err != nil {
+		return screenerError(ctx, err, "could not save screen")
+	}
+	return ctx.Status(http.StatusCreated).JSON(saved)
+}
+
+func (h *ScreenerHandler) GetScreen(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := screenID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid screen id"})
+	}
+
+	sc, err := h.screenerService.GetScreen(context.Background(), currentUserID, id)
+	if err != nil {
+		return screenerError(ctx, err, "could not load screen")
+	}
+	return ctx.JSON(sc)
+}
+
+// UpdateScreen changes the name, filter and/or sort.
+func (h *ScreenerHandler) UpdateScreen(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := screenID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid screen id"})
+	}
+
+	var req saveScreenRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+	if !checkScreen(ctx, req.Filter, req.Sort) {
+		return nil
+	}
+
+	sc, err := h.screenerService.UpdateScreen(context.Background(), currentUserID, id, req.Name, req.Filter, req.Sort)
+	if err != nil {
+		return screenerError(ctx, err, "could not update screen")
+	}
+	return ctx.JSON(sc)
+}
+
+func (h *ScreenerHandler) DeleteScreen(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := screenID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid screen id"})
+	}
+
+	if err := h.screenerService.DeleteScreen(context.Background(), currentUserID, id); err != nil {
+		return screenerError(ctx, err, "could not delete screen")
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// RunScreen runs a saved screen; ?limit= and ?offset= page through it.
+func (h *ScreenerHandler) RunScreen(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := screenID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid screen id"})
+	}
+
+	sc, err := h.screenerService.GetScreen(context.Background(), currentUserID, id)
+	if err != nil {
+		return screenerError(ctx, err, "could not load screen")
+	}
+	return h.screen(ctx, sc.Filter, sc.Sort)
+}
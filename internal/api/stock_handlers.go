@@ -1,144 +1,164 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/dnhan1707/trader/internal/pricestats"
 	"github.com/gofiber/fiber/v2"
 )
 
+const (
+	defaultStatsWindows = "1m,3m,ytd,1y,5y"
+	defaultBenchmark    = "I:SPX"
+)
+
 func (h *Handler) GetTickerDetails(c *fiber.Ctx) error {
-	symbol := c.Params("symbol")
+	symbol := param(c, "symbol")
 	if symbol == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "symbol is required"})
 	}
 	cacheKey := "ticker:" + symbol
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetTickerDetails(symbol)
-	})
-}
-
-func (h *Handler) GetCustomBars(c *fiber.Ctx) error {
-	stocksTicker := c.Params("stocksTicker")
-	multiplier := c.Params("multiplier")
-	timespan := c.Params("timespan")
-	from := c.Params("from")
-	to := c.Params("to")
-	if stocksTicker == "" || multiplier == "" || timespan == "" || from == "" || to == "" {
-		return c.Status(400).JSON(fiber.Map{"error": "missing required path parameter"})
-	}
-	extra := map[string]string{
-		"adjusted": c.Query("adjusted", ""),
-		"sort":     c.Query("sort", ""),
-		"limit":    c.Query("limit", ""),
-	}
-	cacheKey := fmt.Sprintf("aggs:%s:%s:%s:%s:%s:adj=%s:sort=%s:limit=%s",
-		stocksTicker, multiplier, timespan, from, to,
-		extra["adjusted"], extra["sort"], extra["limit"],
-	)
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetCustomBars(stocksTicker, multiplier, timespan, from, to, extra)
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetTickerDetails(ctx, symbol)
 	})
 }
 
 func (h *Handler) Get52WeekStats(c *fiber.Ctx) error {
-	stocksTicker := c.Params("stocksTicker")
+	stocksTicker := param(c, "stocksTicker")
 	if stocksTicker == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "stocksTicker is required"})
 	}
 
 	cacheKey := fmt.Sprintf("52week:%s", stocksTicker)
 
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		// ...existing code for 52-week stats calculation...
-		fromDate := time.Now().AddDate(0, 0, -365).Format("2006-01-02")
-		toDate := time.Now().Format("2006-01-02")
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		market := marketLocation()
+		now := time.Now().In(market)
+		w := pricestats.Window{Name: "52w", Start: now.AddDate(0, 0, -365)}
 
-		data, err := h.massive.GetCustomBars(stocksTicker, "1", "day", fromDate, toDate, map[string]string{
-			"adjusted": "true",
-			"sort":     "asc",
-			"limit":    "1000",
-		})
+		bars, err := h.dailyBars(ctx, stocksTicker, w.Start, now)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch 52-week data: %w", err)
 		}
-
-		dataMap, ok := interface{}(data).(map[string]interface{})
+		s, ok := pricestats.Compute(bars, nil, w, market)
 		if !ok {
-			return nil, fmt.Errorf("unexpected response format")
-		}
-
-		results, ok := dataMap["results"].([]interface{})
-		if !ok || len(results) == 0 {
 			return nil, fmt.Errorf("no data available for %s", stocksTicker)
 		}
 
-		var weekHigh, weekLow float64
-		var highDate, lowDate int64
+		return map[string]interface{}{
+			"ticker":             stocksTicker,
+			"current_price":      s.Close,
+			"week_52_high":       s.High,
+			"week_52_low":        s.Low,
+			"week_52_high_date":  s.HighDate,
+			"week_52_low_date":   s.LowDate,
+			"percent_from_high":  s.PctFromHigh,
+			"percent_from_low":   s.PctFromLow,
+			"range_position":     s.RangePosition,
+			"total_trading_days": s.Bars,
+			"status":             "OK",
+		}, nil
+	})
+}
 
-		for i, result := range results {
-			bar, ok := result.(map[string]interface{})
-			if !ok {
-				continue
-			}
+// GetPriceStats summarizes ?window= (default 1m,3m,ytd,1y,5y; see
+// pricestats.ParseWindows) from one daily series, with beta against
+// ?benchmark= (default I:SPX, "none" to skip).
+func (h *Handler) GetPriceStats(c *fiber.Ctx) error {
+	stocksTicker := strings.ToUpper(param(c, "stocksTicker"))
+	if stocksTicker == "" {
+		return c.Status(400).JSON(fiber.Map{"error": "stocksTicker is required"})
+	}
+	market := marketLocation()
+	now := time.Now().In(market)
+	windows, err := pricestats.ParseWindows(query(c, "window", defaultStatsWindows), now)
+	if err != nil {
+		return c.Status(400).JSON(fiber.Map{"error": err.Error()})
+	}
+	benchmark := strings.ToUpper(query(c, "benchmark", defaultBenchmark))
+	if benchmark == "NONE" {
+		benchmark = ""
+	}
 
-			high, _ := bar["h"].(float64)
-			low, _ := bar["l"].(float64)
-			timestamp, _ := bar["t"].(float64)
-
-			if i == 0 {
-				weekHigh = high
-				weekLow = low
-				highDate = int64(timestamp)
-				lowDate = int64(timestamp)
-			} else {
-				if high > weekHigh {
-					weekHigh = high
-					highDate = int64(timestamp)
-				}
-				if low < weekLow {
-					weekLow = low
-					lowDate = int64(timestamp)
-				}
-			}
+	names := make([]string, len(windows))
+	for i, w := range windows {
+		names[i] = w.Name
+	}
+	cacheKey := fmt.Sprintf("stats:%s:%s:%s:%s", stocksTicker, strings.Join(names, ","), benchmark, now.Format("2006-01-02"))
+
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		from := pricestats.Earliest(windows)
+
+		var bench []massive.Agg
+		var benchErr error
+		var wg sync.WaitGroup
+		if benchmark != "" {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				bench, benchErr = h.dailyBars(ctx, benchmark, from, now)
+			}()
 		}
-
-		lastBar := results[len(results)-1].(map[string]interface{})
-		currentPrice, _ := lastBar["c"].(float64)
-
-		var highPercent, lowPercent float64
-		if weekHigh > 0 {
-			highPercent = ((currentPrice - weekHigh) / weekHigh) * 100
+		bars, err := h.dailyBars(ctx, stocksTicker, from, now)
+		wg.Wait()
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch bars: %w", err)
 		}
-		if weekLow > 0 {
-			lowPercent = ((currentPrice - weekLow) / weekLow) * 100
+		if len(bars) == 0 {
+			return nil, fmt.Errorf("no data available for %s", stocksTicker)
+		}
+		if benchErr != nil {
+			// the other stats are still worth returning
+			log.Printf("[Stats] benchmark %s: %v", benchmark, benchErr)
+			bench = nil
 		}
 
-		return map[string]interface{}{
-			"ticker":             stocksTicker,
-			"current_price":      currentPrice,
-			"week_52_high":       weekHigh,
-			"week_52_low":        weekLow,
-			"week_52_high_date":  time.Unix(highDate/1000, 0).Format("2006-01-02"),
-			"week_52_low_date":   time.Unix(lowDate/1000, 0).Format("2006-01-02"),
-			"percent_from_high":  highPercent,
-			"percent_from_low":   lowPercent,
-			"range_position":     ((currentPrice - weekLow) / (weekHigh - weekLow)) * 100,
-			"total_trading_days": len(results),
-			"status":             "OK",
+		// A window with no bars yet (1d on a Monday, ytd on January 1st) is
+		// left out.
+		stats := make([]pricestats.Stats, 0, len(windows))
+		for _, w := range windows {
+			if s, ok := pricestats.Compute(bars, bench, w, market); ok {
+				stats = append(stats, s)
+			}
+		}
+		return fiber.Map{
+			"ticker":    stocksTicker,
+			"benchmark": benchmark,
+			"as_of":     time.UnixMilli(bars[len(bars)-1].Timestamp).In(market).Format("2006-01-02"),
+			"windows":   stats,
 		}, nil
 	})
 }
 
+// dailyBars fetches adjusted daily bars from a few days before from, so
+// that the first window has the close before it to measure returns from.
+func (h *Handler) dailyBars(ctx context.Context, ticker string, from, to time.Time) ([]massive.Agg, error) {
+	return h.massive.Aggregates(ctx, ticker, 1, "day", from.AddDate(0, 0, -10).Format("2006-01-02"), to.Format("2006-01-02"), true)
+}
+
+func marketLocation() *time.Location {
+	market, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		return time.UTC
+	}
+	return market
+}
+
 func (h *Handler) GetTickerSnapshot(c *fiber.Ctx) error {
-	stocksTicker := c.Params("stocksTicker")
+	stocksTicker := param(c, "stocksTicker")
 	if stocksTicker == "" {
 		return c.Status(400).JSON(fiber.Map{"error": "stocksTicker is required"})
 	}
 
 	cacheKey := fmt.Sprintf("snapshot:ticker:%s", stocksTicker)
 
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetTickerSnapshot(stocksTicker)
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetTickerSnapshot(ctx, stocksTicker)
 	})
 }
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,35 +10,37 @@ import (
 func (h *Handler) GetShortInterest(c *fiber.Ctx) error {
 	// ...existing code for short interest parameters...
 	extra := map[string]string{
-		"ticker":        c.Query("ticker", ""),
-		"ticker.any_of": c.Query("ticker.any_of", ""),
+		"ticker":        query(c, "ticker"),
+		"ticker.any_of": query(c, "ticker.any_of"),
 		// ...all other parameters from original implementation...
-		"limit": c.Query("limit", ""),
-		"sort":  c.Query("sort", ""),
+		"limit": query(c, "limit"),
+		"sort":  query(c, "sort"),
 	}
 
+	page, pageKey := paging(c)
 	cacheKey := fmt.Sprintf("short-interest:t=%s:tany=%s:limit=%s:sort=%s",
-		extra["ticker"], extra["ticker.any_of"], extra["limit"], extra["sort"])
+		extra["ticker"], extra["ticker.any_of"], extra["limit"], extra["sort"]) + pageKey
 
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetShortInterest(extra)
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetShortInterest(ctx, extra, page)
 	})
 }
 
 func (h *Handler) GetShortVolume(c *fiber.Ctx) error {
 	// ...existing code for short volume parameters...
 	extra := map[string]string{
-		"ticker":        c.Query("ticker", ""),
-		"ticker.any_of": c.Query("ticker.any_of", ""),
+		"ticker":        query(c, "ticker"),
+		"ticker.any_of": query(c, "ticker.any_of"),
 		// ...all other parameters from original implementation...
-		"limit": c.Query("limit", ""),
-		"sort":  c.Query("sort", ""),
+		"limit": query(c, "limit"),
+		"sort":  query(c, "sort"),
 	}
 
+	page, pageKey := paging(c)
 	cacheKey := fmt.Sprintf("short-volume:t=%s:tany=%s:limit=%s:sort=%s",
-		extra["ticker"], extra["ticker.any_of"], extra["limit"], extra["sort"])
+		extra["ticker"], extra["ticker.any_of"], extra["limit"], extra["sort"]) + pageKey
 
-	return h.cachedJSON(c, cacheKey, func() (interface{}, error) {
-		return h.massive.GetShortVolume(extra)
+	return h.cachedJSON(c, cacheKey, func(ctx context.Context) (interface{}, error) {
+		return h.massive.GetShortVolume(ctx, extra, page)
 	})
 }
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/breaker"
+	"github.com/dnhan1707/trader/internal/eodhd"
+	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequestContext gives each request a UserContext that ends when the
+// handler returns or after timeout, so upstream calls made for it stop
+// there too.
+func RequestContext(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithTimeout(c.UserContext(), timeout)
+		defer cancel()
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
+
+// upstreamStatus maps an error from the Massive or EODHD client to our
+// status: the caller's mistakes (bad parameters, unknown ticker) pass
+// through, our credentials or quota and the provider's own failures
+// (including not reaching it at all) are 502 / 503, and a timeout is 504.
+// Anything else is a 500.
+func upstreamStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	if err == massive.ErrInvalidCursor {
+		return http.StatusBadRequest
+	}
+	if errors.Is(err, breaker.ErrOpen) {
+		return http.StatusServiceUnavailable
+	}
+	var mn *massive.NetError
+	var en *eodhd.NetError
+	if errors.As(err, &mn) || errors.As(err, &en) {
+		return http.StatusBadGateway
+	}
+	var ee *eodhd.Error
+	if errors.As(err, &ee) {
+		switch {
+		case ee.StatusCode == http.StatusTooManyRequests:
+			return http.StatusServiceUnavailable
+		case ee.StatusCode >= 500:
+			return http.StatusBadGateway
+		}
+		return http.StatusInternalServerError
+	}
+	switch status := massive.StatusOf(err); {
+	case status == 0:
+		return http.StatusInternalServerError
+	case status == http.StatusNotFound:
+		return http.StatusNotFound
+	case status == http.StatusTooManyRequests:
+		return http.StatusServiceUnavailable
+	case status == http.StatusBadRequest || status == http.StatusUnprocessableEntity:
+		return http.StatusBadRequest
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// upstreamError writes err with upstreamStatus and, for Massive errors, the
+// request id to quote to their support.
+func upstreamError(c *fiber.Ctx, err error) error {
+	body := fiber.Map{"error": err.Error()}
+	var e *massive.Error
+	if errors.As(err, &e) {
+		body["error"] = e.Message
+		if e.RequestID != "" {
+			body["request_id"] = e.RequestID
+		}
+	}
+	return c.Status(upstreamStatus(err)).JSON(body)
+}
+
+// stale returns the last good copy of cacheKey when err says the upstream
+// is down (or its circuit open), to serve past the TTL.
+func (h *Handler) stale(cacheKey string, err error) (string, time.Time, bool) {
+	if upstreamStatus(err) < http.StatusBadGateway {
+		return "", time.Time{}, false
+	}
+	v, savedAt, lerr := h.cache.LastGood(cacheKey)
+	if lerr != nil {
+		return "", time.Time{}, false
+	}
+	return v, savedAt, true
+}
+
+// markStale flags a response served from a last good copy, with its age
+// in seconds.
+func markStale(c *fiber.Ctx, savedAt time.Time) {
+	c.Set("X-Data-Stale", "true")
+	c.Set("Age", strconv.Itoa(int(time.Since(savedAt).Seconds())))
+}
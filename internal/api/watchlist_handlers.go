@@ -0,0 +1,315 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/dnhan1707/trader/internal/quotes"
+	"github.com/dnhan1707/trader/internal/services"
+	"github.com/gofiber/fiber/v2"
+)
+
+// WatchlistHandler serves /api/watchlists. Opening a watchlist on the
+// stream is done on /api/ws with {"action":"open_watchlist","watchlist_id":N}.
+type WatchlistHandler struct {
+	watchlistService *services.WatchlistService
+	dmService        *services.DMService
+	quotes           *quotes.Cache
+}
+
+func NewWatchlistHandler(watchlistService *services.WatchlistService, dmService *services.DMService, q *quotes.Cache) *WatchlistHandler {
+	return &WatchlistHandler{watchlistService: watchlistService, dmService: dmService, quotes: q}
+}
+
+type createWatchlistRequest struct {
+	Name    string   `json:"name"`
+	Tickers []string `json:"tickers"`
+}
+
+type updateWatchlistRequest struct {
+	Name    *string   `json:"name"`
+	Tickers *[]string `json:"tickers"`
+}
+
+type addTickerRequest struct {
+	Ticker string `json:"ticker"`
+}
+
+// shareWatchlistRequest names the user by id (as returned by
+// /api/chat/users/search) or by exact username.
+type shareWatchlistRequest struct {
+	UserID   string `json:"user_id"`
+	Username string `json:"username"`
+}
+
+func watchlistID(ctx *fiber.Ctx) (int64, bool) {
+	id, err := strconv.ParseInt(ctx.Params("id"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// watchlistError maps service errors to responses; fallback is the 500
+// message.
+func watchlistError(ctx *fiber.Ctx, err error, fallback string) error {
+	switch e := err.(type) {
+	case services.ValidationError:
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": e.Error()})
+	}
+	switch err {
+	case sql.ErrNoRows:
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "watchlist not found"})
+	case services.ErrNotOwner:
+		return ctx.Status(http.StatusForbidden).JSON(fiber.Map{"error": err.Error()})
+	}
+	return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": fallback})
+}
+
+// ListWatchlists returns the user's watchlists followed by the ones shared
+// with them (read_only).
+func (h *WatchlistHandler) ListWatchlists(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	lists, err := h.watchlistService.ListWatchlists(context.Background(), currentUserID)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not list watchlists"})
+	}
+	return ctx.JSON(lists)
+}
+
+func (h *WatchlistHandler) CreateWatchlist(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+
+	var req createWatchlistRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+	w, err := h.watchlistService.CreateWatchlist(context.Background(), currentUserID, req.Name, req.Tickers)
+	if err != nil {
+		return watchlistError(ctx, err, "could not create watchlist")
+	}
+	return ctx.Status(http.StatusCreated).JSON(w)
+}
+
+func (h *WatchlistHandler) GetWatchlist(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := watchlistID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid watchlist id"})
+	}
+
+	w, err := h.watchlistService.GetWatchlist(context.Background(), currentUserID, id)
+	if err != nil {
+		return watchlistError(ctx, err, "could not load watchlist")
+	}
+	return ctx.JSON(w)
+}
+
+// UpdateWatchlist renames the watchlist and/or replaces its tickers (in the
+// given order).
+func (h *WatchlistHandler) UpdateWatchlist(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := watchlistID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid watchlist id"})
+	}
+
+	var req updateWatchlistRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+	var tickers []string
+	if req.Tickers != nil {
+		tickers = append([]string{}, *req.Tickers...)
+	}
+
+	w, err := h.watchlistService.UpdateWatchlist(context.Background(), currentUserID, id, req.Name, tickers)
+	if err != nil {
+		return watchlistError(ctx, err, "could not update watchlist")
+	}
+	return ctx.JSON(w)
+}
+
+func (h *WatchlistHandler) DeleteWatchlist(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := watchlistID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid watchlist id"})
+	}
+
+	if err := h.watchlistService.DeleteWatchlist(context.Background(), currentUserID, id); err != nil {
+		return watchlistError(ctx, err, "could not delete watchlist")
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+func (h *WatchlistHandler) AddTicker(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := watchlistID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid watchlist id"})
+	}
+
+	var req addTickerRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+	w, err := h.watchlistService.AddTicker(context.Background(), currentUserID, id, req.Ticker)
+	if err != nil {
+		return watchlistError(ctx, err, "could not add ticker")
+	}
+	return ctx.JSON(w)
+}
+
+func (h *WatchlistHandler) RemoveTicker(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := watchlistID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid watchlist id"})
+	}
+
+	w, err := h.watchlistService.RemoveTicker(context.Background(), currentUserID, id, ctx.Params("ticker"))
+	if err != nil {
+		return watchlistError(ctx, err, "could not remove ticker")
+	}
+	return ctx.JSON(w)
+}
+
+// GetSnapshot returns last price, change and volume for every ticker of the
+// watchlist, in the watchlist's order.
+func (h *WatchlistHandler) GetSnapshot(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := watchlistID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid watchlist id"})
+	}
+
+	w, err := h.watchlistService.GetWatchlist(context.Background(), currentUserID, id)
+	if err != nil {
+		return watchlistError(ctx, err, "could not load watchlist")
+	}
+	return ctx.JSON(fiber.Map{
+		"id":      w.ID,
+		"name":    w.Name,
+		"tickers": h.quotes.Summaries(w.Tickers),
+	})
+}
+
+// ListShares returns who the watchlist is shared with; owner only.
+func (h *WatchlistHandler) ListShares(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := watchlistID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid watchlist id"})
+	}
+
+	w, err := h.watchlistService.GetWatchlist(context.Background(), currentUserID, id)
+	if err != nil {
+		return watchlistError(ctx, err, "could not load watchlist")
+	}
+	if w.ReadOnly {
+		return watchlistError(ctx, services.ErrNotOwner, "")
+	}
+	shares, err := h.watchlistService.ListShares(context.Background(), currentUserID, id)
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not list shares"})
+	}
+	return ctx.JSON(shares)
+}
+
+// ShareWatchlist gives another user read-only access.
+func (h *WatchlistHandler) ShareWatchlist(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := watchlistID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid watchlist id"})
+	}
+
+	var req shareWatchlistRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid body"})
+	}
+
+	target := strings.TrimSpace(req.UserID)
+	if target == "" {
+		username := strings.TrimSpace(req.Username)
+		if username == "" {
+			return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "user_id or username required"})
+		}
+		users, err := h.dmService.SearchUsers(context.Background(), username, 20)
+		if err != nil {
+			return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not search users"})
+		}
+		for _, u := range users {
+			if strings.EqualFold(u.Username, username) {
+				target = u.ID
+				break
+			}
+		}
+		if target == "" {
+			return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "user not found"})
+		}
+	}
+
+	share, err := h.watchlistService.ShareWatchlist(context.Background(), currentUserID, id, target)
+	if err == services.ErrUserNotFound {
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return watchlistError(ctx, err, "could not share watchlist")
+	}
+	return ctx.Status(http.StatusCreated).JSON(share)
+}
+
+// UnshareWatchlist revokes a user's access. The user it is shared with may
+// also remove themselves.
+func (h *WatchlistHandler) UnshareWatchlist(ctx *fiber.Ctx) error {
+	currentUserID, ok := ctx.Locals("userID").(string)
+	if !ok || currentUserID == "" {
+		return ctx.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "unauthorized"})
+	}
+	id, ok := watchlistID(ctx)
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "invalid watchlist id"})
+	}
+
+	err := h.watchlistService.UnshareWatchlist(context.Background(), currentUserID, id, ctx.Params("userID"))
+	if err == sql.ErrNoRows {
+		return ctx.Status(http.StatusNotFound).JSON(fiber.Map{"error": "share not found"})
+	}
+	if err != nil {
+		return ctx.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "could not remove share"})
+	}
+	return ctx.SendStatus(http.StatusNoContent)
+}
@@ -0,0 +1,229 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/dnhan1707/trader/internal/indicators"
+)
+
+// MaxCurvePoints bounds the equity curve in a Result; longer runs keep
+// every n-th point (and the last).
+const MaxCurvePoints = 5000
+
+// Exit reasons.
+const (
+	ExitSignal     = "signal"
+	ExitStopLoss   = "stop_loss"
+	ExitTakeProfit = "take_profit"
+	ExitEndOfData  = "end_of_data"
+)
+
+// Costs model the broker. Commission is per order, PerShare per share and
+// Percent a fraction of the notional; all three add up. Slippage moves
+// every fill against us by SlippageBps basis points.
+type Costs struct {
+	Commission  float64 `json:"commission"`
+	PerShare    float64 `json:"commission_per_share"`
+	Percent     float64 `json:"commission_pct"`
+	SlippageBps float64 `json:"slippage_bps"`
+}
+
+func (c Costs) fee(qty, price float64) float64 {
+	return c.Commission + c.PerShare*qty + c.Percent*qty*price
+}
+
+// Config is a strategy with the money side of a run. PositionSize is the
+// fraction of equity put into each entry (default 1, all in). Positions
+// are whole shares.
+type Config struct {
+	Strategy
+	Costs
+	InitialCash  float64 `json:"initial_cash"`
+	PositionSize float64 `json:"position_size"`
+}
+
+// Normalize fills in defaults and reports the first invalid field.
+func (c *Config) Normalize() error {
+	if c.InitialCash == 0 {
+		c.InitialCash = 100000
+	}
+	if c.PositionSize == 0 {
+		c.PositionSize = 1
+	}
+	switch {
+	case c.InitialCash < 0 || c.InitialCash > 1e12:
+		return fmt.Errorf("initial_cash must be positive")
+	case c.PositionSize < 0 || c.PositionSize > 1:
+		return fmt.Errorf("position_size must be in (0, 1]")
+	case c.Commission < 0 || c.PerShare < 0 || c.Percent < 0 || c.SlippageBps < 0:
+		return fmt.Errorf("costs must not be negative")
+	case c.Percent >= 1 || c.SlippageBps >= 10000:
+		return fmt.Errorf("commission_pct and slippage_bps are too large")
+	}
+	return c.Strategy.Validate()
+}
+
+// Trade is one round trip.
+type Trade struct {
+	EntryTime  int64   `json:"entry_time"` // unix ms, the fill bar's start
+	EntryPrice float64 `json:"entry_price"`
+	ExitTime   int64   `json:"exit_time"`
+	ExitPrice  float64 `json:"exit_price"`
+	Qty        float64 `json:"qty"`
+	Fees       float64 `json:"fees"`
+	PnL        float64 `json:"pnl"`    // after fees
+	Return     float64 `json:"return"` // PnL over what the entry cost
+	Bars       int     `json:"bars"`
+	ExitReason string  `json:"exit_reason"`
+}
+
+// Point is the equity at a bar's close. Benchmark is buying and holding
+// with the same initial cash, without costs.
+type Point struct {
+	Time      int64   `json:"t"`
+	Equity    float64 `json:"equity"`
+	Drawdown  float64 `json:"drawdown"`
+	Benchmark float64 `json:"benchmark"`
+}
+
+// Result is a completed run.
+type Result struct {
+	Stats  Stats   `json:"stats"`
+	Trades []Trade `json:"trades"`
+	Equity []Point `json:"equity"`
+}
+
+// Run tests cfg over bars[start:]; the bars before start only warm up the
+// indicators. cfg must have been normalized.
+func Run(cfg Config, bars []indicators.Bar, start int, opts indicators.Options) (*Result, error) {
+	refs, err := cfg.Strategy.series()
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 || start >= len(bars) {
+		return nil, fmt.Errorf("no bars in the test period")
+	}
+	prog := compile(refs, bars, opts)
+	slip := cfg.SlippageBps / 10000
+
+	var (
+		cash     = cfg.InitialCash
+		qty      float64
+		open     *Trade
+		cost     float64 // what the open position cost, fees included
+		entryAt  int
+		pending  string // "buy" or "sell", filled at the next open
+		inMarket int
+		peak     = cfg.InitialCash
+		base     = bars[start].Close
+	)
+	res := &Result{Trades: []Trade{}}
+	curve := make([]Point, 0, len(bars)-start)
+
+	buy := func(i int, price float64) {
+		price *= 1 + slip
+		// Flat, so the cash is all the equity.
+		q := math.Floor((cash*cfg.PositionSize - cfg.Commission) / (price*(1+cfg.Percent) + cfg.PerShare))
+		if q < 1 {
+			return
+		}
+		fee := cfg.fee(q, price)
+		cash -= q*price + fee
+		qty, cost, entryAt = q, q*price+fee, i
+		open = &Trade{EntryTime: bars[i].Time, EntryPrice: price, Qty: q, Fees: fee}
+	}
+	sell := func(i int, price float64, reason string) {
+		price *= 1 - slip
+		fee := cfg.fee(qty, price)
+		cash += qty*price - fee
+		t := *open
+		t.ExitTime, t.ExitPrice, t.ExitReason = bars[i].Time, price, reason
+		t.Fees += fee
+		t.PnL = qty*price - fee - cost
+		if cost > 0 {
+			t.Return = t.PnL / cost
+		}
+		t.Bars = i - entryAt + 1
+		res.Trades = append(res.Trades, t)
+		qty, cost, open = 0, 0, nil
+	}
+
+	for i := start; i < len(bars); i++ {
+		b := bars[i]
+		switch {
+		case pending == "buy" && open == nil:
+			buy(i, b.Open)
+		case pending == "sell" && open != nil:
+			sell(i, b.Open, ExitSignal)
+		}
+		pending = ""
+
+		// Stops are checked against the bar's range; a gap through the
+		// level fills at the open. If both could have hit, assume the stop
+		// did.
+		if open != nil && cfg.StopLoss > 0 {
+			stop := open.EntryPrice * (1 - cfg.StopLoss)
+			if b.Open <= stop {
+				sell(i, b.Open, ExitStopLoss)
+			} else if b.Low <= stop {
+				sell(i, stop, ExitStopLoss)
+			}
+		}
+		if open != nil && cfg.TakeProfit > 0 {
+			target := open.EntryPrice * (1 + cfg.TakeProfit)
+			if b.Open >= target {
+				sell(i, b.Open, ExitTakeProfit)
+			} else if b.High >= target {
+				sell(i, target, ExitTakeProfit)
+			}
+		}
+
+		if open != nil {
+			inMarket++
+		}
+		last := i == len(bars)-1
+		switch {
+		case last && open != nil:
+			sell(i, b.Close, ExitEndOfData)
+		case last:
+		case open == nil && prog.fires(cfg.Entry, i):
+			pending = "buy"
+		case open != nil && prog.fires(cfg.Exit, i):
+			pending = "sell"
+		}
+
+		equity := cash + qty*b.Close
+		if equity > peak {
+			peak = equity
+		}
+		p := Point{Time: b.Time, Equity: equity}
+		if peak > 0 {
+			p.Drawdown = (peak - equity) / peak
+		}
+		if base > 0 {
+			p.Benchmark = cfg.InitialCash * b.Close / base
+		}
+		curve = append(curve, p)
+	}
+
+	res.Stats = summarize(cfg, curve, res.Trades, inMarket)
+	res.Equity = thin(curve, MaxCurvePoints)
+	return res, nil
+}
+
+// thin keeps at most max points, evenly spaced, always with the last one.
+func thin(points []Point, max int) []Point {
+	if len(points) <= max {
+		return points
+	}
+	step := (len(points) + max - 1) / max
+	out := make([]Point, 0, max+1)
+	for i := 0; i < len(points); i += step {
+		out = append(out, points[i])
+	}
+	if out[len(out)-1].Time != points[len(points)-1].Time {
+		out = append(out, points[len(points)-1])
+	}
+	return out
+}
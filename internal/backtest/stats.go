@@ -0,0 +1,98 @@
+package backtest
+
+import (
+	"math"
+	"time"
+)
+
+// Stats summarizes a run. Ratios are fractions (0.1 = 10%). The pointer
+// fields are nil when they can't be computed (e.g. no losing trades for
+// the profit factor).
+type Stats struct {
+	InitialCash     float64  `json:"initial_cash"`
+	FinalEquity     float64  `json:"final_equity"`
+	TotalReturn     float64  `json:"total_return"`
+	CAGR            *float64 `json:"cagr"`
+	MaxDrawdown     float64  `json:"max_drawdown"`
+	Sharpe          *float64 `json:"sharpe"` // annualized, risk-free rate 0
+	Trades          int      `json:"trades"`
+	WinRate         *float64 `json:"win_rate"`
+	AvgTradeReturn  *float64 `json:"avg_trade_return"`
+	ProfitFactor    *float64 `json:"profit_factor"`
+	Fees            float64  `json:"fees"`
+	Exposure        float64  `json:"exposure"` // share of bars spent in a position
+	BenchmarkReturn float64  `json:"benchmark_return"`
+	Bars            int      `json:"bars"`
+}
+
+func summarize(cfg Config, curve []Point, trades []Trade, inMarket int) Stats {
+	s := Stats{InitialCash: cfg.InitialCash, Trades: len(trades), Bars: len(curve)}
+	if len(curve) == 0 {
+		return s
+	}
+	first, last := curve[0], curve[len(curve)-1]
+	s.FinalEquity = last.Equity
+	s.TotalReturn = last.Equity/cfg.InitialCash - 1
+	s.BenchmarkReturn = last.Benchmark/cfg.InitialCash - 1
+	s.Exposure = float64(inMarket) / float64(len(curve))
+	for _, p := range curve {
+		if p.Drawdown > s.MaxDrawdown {
+			s.MaxDrawdown = p.Drawdown
+		}
+	}
+
+	// Annualize by the bars actually seen per year, which works the same
+	// for daily and intraday (extended hours included) bars.
+	years := time.UnixMilli(last.Time).Sub(time.UnixMilli(first.Time)).Hours() / (24 * 365.25)
+	if years > 0 && last.Equity > 0 {
+		cagr := math.Pow(last.Equity/cfg.InitialCash, 1/years) - 1
+		s.CAGR = &cagr
+	}
+	if years > 0 && len(curve) > 2 {
+		prev := cfg.InitialCash
+		returns := make([]float64, len(curve))
+		var mean float64
+		for i, p := range curve {
+			if prev > 0 {
+				returns[i] = p.Equity/prev - 1
+			}
+			prev = p.Equity
+			mean += returns[i]
+		}
+		mean /= float64(len(returns))
+		var variance float64
+		for _, r := range returns {
+			variance += (r - mean) * (r - mean)
+		}
+		std := math.Sqrt(variance / float64(len(returns)-1))
+		if std > 0 {
+			perYear := float64(len(curve)-1) / years
+			sharpe := mean / std * math.Sqrt(perYear)
+			s.Sharpe = &sharpe
+		}
+	}
+
+	if len(trades) == 0 {
+		return s
+	}
+	var wins int
+	var sumReturn, grossWin, grossLoss float64
+	for _, t := range trades {
+		s.Fees += t.Fees
+		sumReturn += t.Return
+		if t.PnL > 0 {
+			wins++
+			grossWin += t.PnL
+		} else {
+			grossLoss -= t.PnL
+		}
+	}
+	winRate := float64(wins) / float64(len(trades))
+	avg := sumReturn / float64(len(trades))
+	s.WinRate, s.AvgTradeReturn = &winRate, &avg
+	if grossLoss > 0 {
+		pf := grossWin / grossLoss
+		s.ProfitFactor = &pf
+	}
+	return s
+}
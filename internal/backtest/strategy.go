@@ -0,0 +1,340 @@
+/*
+Package backtest runs long-only trading rules over historical bars. A
+Strategy is declarative: entry and exit rules made of conditions over price
+and indicator series (see internal/indicators), e.g.
+
+	{"entry": {"all": [{"left": "rsi:14", "op": "<", "right": 30}]},
+	 "exit":  {"all": [{"left": "rsi:14", "op": ">", "right": 70}]}}
+
+Signals are taken on a bar's close and filled at the next bar's open, so a
+rule never trades on prices it could not have seen.
+*/
+
+package backtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/dnhan1707/trader/internal/indicators"
+)
+
+// Condition operators.
+const (
+	OpLT           = "<"
+	OpLE           = "<="
+	OpGT           = ">"
+	OpGE           = ">="
+	OpCrossesAbove = "crosses_above"
+	OpCrossesBelow = "crosses_below"
+)
+
+// MaxConditions bounds one rule.
+const MaxConditions = 10
+
+// Operand is either a series ("close", "sma:50", "bb:20:2.lower") or a
+// constant. In JSON it's a string or a number.
+type Operand struct {
+	Series string
+	Value  float64
+}
+
+func (o *Operand) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		o.Series, o.Value = strings.ToLower(strings.TrimSpace(s)), 0
+		if o.Series == "" {
+			return fmt.Errorf("empty operand")
+		}
+		return nil
+	}
+	var v float64
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("an operand is a series name or a number")
+	}
+	o.Series, o.Value = "", v
+	return nil
+}
+
+func (o Operand) MarshalJSON() ([]byte, error) {
+	if o.Series != "" {
+		return json.Marshal(o.Series)
+	}
+	return json.Marshal(o.Value)
+}
+
+func (o Operand) String() string {
+	if o.Series != "" {
+		return o.Series
+	}
+	return fmt.Sprint(o.Value)
+}
+
+// Condition compares two operands on a bar. crosses_above holds on the bar
+// where left goes from at or below right to above it (crosses_below the
+// other way round).
+type Condition struct {
+	Left  Operand `json:"left"`
+	Op    string  `json:"op"`
+	Right Operand `json:"right"`
+}
+
+// Rule fires when every All condition holds and, if Any is set, at least
+// one of Any does.
+type Rule struct {
+	All []Condition `json:"all,omitempty"`
+	Any []Condition `json:"any,omitempty"`
+}
+
+func (r Rule) empty() bool {
+	return len(r.All) == 0 && len(r.Any) == 0
+}
+
+// Strategy is what to trade on. StopLoss and TakeProfit are fractions of
+// the entry price (0.05 = 5%); 0 turns them off.
+type Strategy struct {
+	Entry      Rule    `json:"entry"`
+	Exit       Rule    `json:"exit"`
+	StopLoss   float64 `json:"stop_loss,omitempty"`
+	TakeProfit float64 `json:"take_profit,omitempty"`
+}
+
+// Validate reports the first problem with the strategy.
+func (s Strategy) Validate() error {
+	_, err := s.series()
+	return err
+}
+
+// Warmup is how many bars before the test period should be loaded for the
+// strategy's indicators to have settled.
+func (s Strategy) Warmup() int {
+	refs, err := s.series()
+	if err != nil {
+		return 0
+	}
+	w := 1 // crossovers look one bar back
+	for _, r := range refs {
+		if r.spec != nil && r.spec.Warmup() > w {
+			w = r.spec.Warmup()
+		}
+	}
+	return w
+}
+
+// ref is a parsed series operand.
+type ref struct {
+	price string           // open, high, low, close or volume
+	spec  *indicators.Spec // or an indicator
+	line  string           // and one of its lines
+}
+
+var priceSeries = map[string]bool{"open": true, "high": true, "low": true, "close": true, "volume": true}
+
+// series parses every series the strategy uses, keyed by operand.
+func (s Strategy) series() (map[string]ref, error) {
+	if s.Entry.empty() {
+		return nil, fmt.Errorf("entry rule has no conditions")
+	}
+	if s.Exit.empty() && s.StopLoss == 0 && s.TakeProfit == 0 {
+		return nil, fmt.Errorf("exit rule has no conditions and there is no stop_loss or take_profit")
+	}
+	if s.StopLoss < 0 || s.StopLoss >= 1 {
+		return nil, fmt.Errorf("stop_loss must be in [0, 1)")
+	}
+	if s.TakeProfit < 0 {
+		return nil, fmt.Errorf("take_profit must not be negative")
+	}
+
+	refs := make(map[string]ref)
+	for name, r := range map[string]Rule{"entry": s.Entry, "exit": s.Exit} {
+		if len(r.All)+len(r.Any) > MaxConditions {
+			return nil, fmt.Errorf("%s rule has more than %d conditions", name, MaxConditions)
+		}
+		for _, c := range append(append([]Condition(nil), r.All...), r.Any...) {
+			switch c.Op {
+			case OpLT, OpLE, OpGT, OpGE, OpCrossesAbove, OpCrossesBelow:
+			default:
+				return nil, fmt.Errorf("%s rule: unknown op %q", name, c.Op)
+			}
+			if c.Left.Series == "" && c.Right.Series == "" {
+				return nil, fmt.Errorf("%s rule: %s %s %s compares two constants", name, c.Left, c.Op, c.Right)
+			}
+			for _, o := range []Operand{c.Left, c.Right} {
+				if o.Series == "" {
+					continue
+				}
+				if _, ok := refs[o.Series]; ok {
+					continue
+				}
+				r, err := parseRef(o.Series)
+				if err != nil {
+					return nil, fmt.Errorf("%s rule: %v", name, err)
+				}
+				refs[o.Series] = r
+			}
+		}
+	}
+
+	specs := make(map[string]bool)
+	for _, r := range refs {
+		if r.spec != nil {
+			specs[r.spec.Key()] = true
+		}
+	}
+	if len(specs) > indicators.MaxSpecs {
+		return nil, fmt.Errorf("at most %d indicators per strategy", indicators.MaxSpecs)
+	}
+	return refs, nil
+}
+
+// parseRef parses "close", "rsi:14" or "macd:12:26:9.signal". The line may
+// be left out when the indicator has one, or one named after it.
+func parseRef(s string) (ref, error) {
+	if priceSeries[s] {
+		return ref{price: s}, nil
+	}
+	// The line follows the last dot, unless that's a decimal point as in
+	// "bb:20:2.5".
+	item, line := s, ""
+	if i := strings.LastIndex(s, "."); i >= 0 && i+1 < len(s) && (s[i+1] < '0' || s[i+1] > '9') {
+		item, line = s[:i], s[i+1:]
+	}
+	spec, err := indicators.ParseSpec(item)
+	if err != nil {
+		return ref{}, err
+	}
+	names := lineNames(spec)
+	switch {
+	case line != "":
+	case len(names) == 1:
+		line = names[0]
+	default:
+		line = spec.Kind
+	}
+	if lookAhead[spec.Kind+"."+line] {
+		return ref{}, fmt.Errorf("%s: %s.%s is plotted from later bars and can't be used in a rule", s, item, line)
+	}
+	for _, n := range names {
+		if n == line {
+			return ref{spec: &spec, line: line}, nil
+		}
+	}
+	return ref{}, fmt.Errorf("%s: %s has the lines %s, pick one as in %s.%s", s, item, strings.Join(names, ", "), item, names[0])
+}
+
+// lookAhead lists the indicator lines whose value on a bar comes from later
+// bars, such as Ichimoku's lagging span (the close displacement bars on).
+var lookAhead = map[string]bool{
+	"ichimoku.chikou": true,
+}
+
+func lineNames(spec indicators.Spec) []string {
+	var names []string
+	for n := range spec.Compute(nil, indicators.Options{}) {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// compiled holds every series a run needs, aligned to the bars.
+type compiled struct {
+	series map[string]indicators.Line
+}
+
+func compile(refs map[string]ref, bars []indicators.Bar, opts indicators.Options) compiled {
+	c := compiled{series: make(map[string]indicators.Line, len(refs))}
+	computed := make(map[string]map[string]indicators.Line)
+	for key, r := range refs {
+		if r.spec == nil {
+			line := make(indicators.Line, len(bars))
+			for i, b := range bars {
+				switch r.price {
+				case "open":
+					line[i] = b.Open
+				case "high":
+					line[i] = b.High
+				case "low":
+					line[i] = b.Low
+				case "close":
+					line[i] = b.Close
+				case "volume":
+					line[i] = b.Volume
+				}
+			}
+			c.series[key] = line
+			continue
+		}
+		lines, ok := computed[r.spec.Key()]
+		if !ok {
+			lines = r.spec.Compute(bars, opts)
+			computed[r.spec.Key()] = lines
+		}
+		c.series[key] = lines[r.line]
+	}
+	return c
+}
+
+func (c compiled) value(o Operand, i int) float64 {
+	if o.Series == "" {
+		return o.Value
+	}
+	if i < 0 {
+		return math.NaN()
+	}
+	return c.series[o.Series][i]
+}
+
+// holds evaluates cond on bar i. Missing (warm-up) values never hold.
+func (c compiled) holds(cond Condition, i int) bool {
+	l, r := c.value(cond.Left, i), c.value(cond.Right, i)
+	if math.IsNaN(l) || math.IsNaN(r) {
+		return false
+	}
+	switch cond.Op {
+	case OpLT:
+		return l < r
+	case OpLE:
+		return l <= r
+	case OpGT:
+		return l > r
+	case OpGE:
+		return l >= r
+	}
+	pl, pr := c.value(cond.Left, i-1), c.value(cond.Right, i-1)
+	if math.IsNaN(pl) || math.IsNaN(pr) {
+		return false
+	}
+	if cond.Op == OpCrossesAbove {
+		return pl <= pr && l > r
+	}
+	return pl >= pr && l < r
+}
+
+func (c compiled) fires(r Rule, i int) bool {
+	if r.empty() {
+		return false
+	}
+	for _, cond := range r.All {
+		if !c.holds(cond, i) {
+			return false
+		}
+	}
+	if len(r.Any) == 0 {
+		return true
+	}
+	for _, cond := range r.Any {
+		if c.holds(cond, i) {
+			return true
+		}
+	}
+	return false
+}
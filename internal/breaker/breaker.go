@@ -0,0 +1,102 @@
+/*
+Package breaker is a per-endpoint circuit breaker for the upstream REST
+clients. After Threshold failures in a row an endpoint's circuit opens and
+calls fail fast with ErrOpen for Cooldown; then a single probe is let
+through, and its outcome closes the circuit or opens it again.
+*/
+
+package breaker
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned, wrapped, for a call refused by an open circuit.
+var ErrOpen = errors.New("circuit open")
+
+type state int
+
+const (
+	closed state = iota
+	open
+	halfOpen // the probe is in flight
+)
+
+type circuit struct {
+	state    state
+	failures int
+	openedAt time.Time
+}
+
+// Set holds the circuits of one upstream, by endpoint.
+type Set struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+// NewSet names the upstream for the logs.
+func NewSet(name string, threshold int, cooldown time.Duration) *Set {
+	return &Set{name: name, threshold: threshold, cooldown: cooldown, circuits: make(map[string]*circuit)}
+}
+
+// Allow returns ErrOpen if a call to endpoint must not be made now. Every
+// nil return must be followed by Report, or by nothing when the call was
+// abandoned by its caller.
+func (s *Set) Allow(endpoint string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.circuits[endpoint]
+	if !ok {
+		return nil
+	}
+	switch c.state {
+	case open:
+		if time.Since(c.openedAt) < s.cooldown {
+			return ErrOpen
+		}
+		c.state = halfOpen
+		c.openedAt = time.Now()
+		return nil
+	case halfOpen:
+		// an abandoned probe doesn't keep the circuit shut forever
+		if time.Since(c.openedAt) < s.cooldown {
+			return ErrOpen
+		}
+		c.openedAt = time.Now()
+	}
+	return nil
+}
+
+// Report records the outcome of an allowed call; ok is false when the
+// upstream failed (as opposed to rejecting the request).
+func (s *Set) Report(endpoint string, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, found := s.circuits[endpoint]
+	if ok {
+		if found && c.state != closed {
+			log.Printf("[Breaker] %s %s closed", s.name, endpoint)
+		}
+		delete(s.circuits, endpoint)
+		return
+	}
+	if !found {
+		c = &circuit{}
+		s.circuits[endpoint] = c
+	}
+	c.failures++
+	if c.state == halfOpen || (c.state == closed && c.failures >= s.threshold) {
+		if c.state == closed {
+			log.Printf("[Breaker] %s %s open after %d failures", s.name, endpoint, c.failures)
+		}
+		c.state = open
+		c.openedAt = time.Now()
+	}
+}
@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Lock helpers for leader election. The value identifies the owner so only
+// the instance holding the lock can extend or release it.
+
+var refreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// TryLock takes key for owner if nobody holds it.
+func (c *Cache) TryLock(key, owner string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(c.ctx, key, owner, ttl).Result()
+}
+
+// RefreshLock extends the lock; false means owner no longer holds it.
+func (c *Cache) RefreshLock(key, owner string, ttl time.Duration) (bool, error) {
+	n, err := refreshScript.Run(c.ctx, c.client, []string{key}, owner, ttl.Milliseconds()).Int()
+	return n == 1, err
+}
+
+func (c *Cache) ReleaseLock(key, owner string) error {
+	return releaseScript.Run(c.ctx, c.client, []string{key}, owner).Err()
+}
+
+// Pub/sub and set helpers used to fan the quote stream out across instances.
+
+func (c *Cache) Publish(channel string, payload []byte) error {
+	return c.client.Publish(c.ctx, channel, payload).Err()
+}
+
+// Subscribe opens a dedicated pub/sub connection. Callers close it.
+func (c *Cache) Subscribe(ctx context.Context, channels ...string) *redis.PubSub {
+	return c.client.Subscribe(ctx, channels...)
+}
+
+func (c *Cache) SetAdd(key string, members ...string) error {
+	return c.client.SAdd(c.ctx, key, toArgs(members)...).Err()
+}
+
+func (c *Cache) SetRemove(key string, members ...string) error {
+	return c.client.SRem(c.ctx, key, toArgs(members)...).Err()
+}
+
+func (c *Cache) SetMembers(key string) ([]string, error) {
+	return c.client.SMembers(c.ctx, key).Result()
+}
+
+func (c *Cache) Expire(key string, ttl time.Duration) error {
+	return c.client.Expire(c.ctx, key, ttl).Err()
+}
+
+// ReplaceSet atomically swaps the members of key and sets its TTL. An empty
+// members list deletes the key.
+func (c *Cache) ReplaceSet(key string, members []string, ttl time.Duration) error {
+	_, err := c.client.TxPipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(c.ctx, key)
+		if len(members) > 0 {
+			pipe.SAdd(c.ctx, key, toArgs(members)...)
+			pipe.Expire(c.ctx, key, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// Keys lists keys matching pattern using SCAN, so it never blocks Redis.
+func (c *Cache) Keys(pattern string) ([]string, error) {
+	var keys []string
+	iter := c.client.Scan(c.ctx, 0, pattern, 100).Iterator()
+	for iter.Next(c.ctx) {
+		keys = append(keys, iter.Val())
+	}
+	return keys, iter.Err()
+}
+
+func toArgs(s []string) []interface{} {
+	args := make([]interface{}, len(s))
+	for i, v := range s {
+		args[i] = v
+	}
+	return args
+}
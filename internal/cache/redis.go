@@ -2,28 +2,56 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
 type Cache struct {
-	client *redis.Client
-	ttl    time.Duration
-	ctx    context.Context
+	client      *redis.Client
+	ttl         time.Duration // soft: how long a value is fresh
+	hardTTL     time.Duration // how long it is kept, stale, after that
+	lastGoodTTL time.Duration
+	lastGoodMax int // bytes; larger values get no last good copy
+	ctx         context.Context
 }
 
-func New(addr, pass string, db int, ttlSeconds int) *Cache {
+// freshPrefix keys the marker that a value is still within its soft TTL.
+const freshPrefix = "fresh:"
+
+// lastGoodPrefix keys the long-lived copy Set keeps of every value up to
+// lastGoodMax bytes, for serving past the TTL while the upstream is down.
+// Big values (long bar ranges, all=true lists) would cost more memory than
+// an outage is worth.
+const lastGoodPrefix = "lastgood:"
+
+type lastGood struct {
+	SavedAt int64  `json:"saved_at"` // unix ms
+	Value   string `json:"value"`
+}
+
+// New caches values fresh for ttlSeconds and keeps them, stale, up to
+// hardTTLSeconds; last good copies of values up to lastGoodMaxKB are kept
+// for lastGoodHours (0 for none).
+func New(addr, pass string, db int, ttlSeconds, hardTTLSeconds int, lastGoodHours, lastGoodMaxKB int) *Cache {
 	rdb := redis.NewClient(&redis.Options{
 		Addr:     addr,
 		Password: pass,
 		DB:       db,
 	})
 
+	if hardTTLSeconds < ttlSeconds {
+		hardTTLSeconds = ttlSeconds
+	}
+
 	return &Cache{
-		client: rdb,
-		ttl:    time.Duration(ttlSeconds) * time.Second,
-		ctx:    context.Background(),
+		client:      rdb,
+		ttl:         time.Duration(ttlSeconds) * time.Second,
+		hardTTL:     time.Duration(hardTTLSeconds) * time.Second,
+		lastGoodTTL: time.Duration(lastGoodHours) * time.Hour,
+		lastGoodMax: lastGoodMaxKB * 1024,
+		ctx:         context.Background(),
 	}
 }
 
@@ -31,8 +59,54 @@ func (c *Cache) Get(key string) (string, error) {
 	return c.client.Get(c.ctx, key).Result()
 }
 
+// Lookup is Get that also says whether the value is past its soft TTL.
+func (c *Cache) Lookup(key string) (value string, stale bool, err error) {
+	var get *redis.StringCmd
+	var fresh *redis.IntCmd
+	_, err = c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(c.ctx, key)
+		fresh = pipe.Exists(c.ctx, freshPrefix+key)
+		return nil
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return get.Val(), fresh.Val() == 0, nil
+}
+
+// Set stores value, fresh for the soft TTL and kept until the hard one,
+// and its last good copy.
 func (c *Cache) Set(key string, value string) error {
-	return c.client.SetEX(c.ctx, key, value, c.ttl).Err()
+	var lg []byte
+	if c.lastGoodTTL > 0 && len(value) <= c.lastGoodMax {
+		var err error
+		if lg, err = json.Marshal(lastGood{SavedAt: time.Now().UnixMilli(), Value: value}); err != nil {
+			return err
+		}
+	}
+	_, err := c.client.Pipelined(c.ctx, func(pipe redis.Pipeliner) error {
+		pipe.SetEX(c.ctx, key, value, c.hardTTL)
+		pipe.SetEX(c.ctx, freshPrefix+key, "1", c.ttl)
+		if lg != nil {
+			pipe.SetEX(c.ctx, lastGoodPrefix+key, lg, c.lastGoodTTL)
+		}
+		return nil
+	})
+	return err
+}
+
+// LastGood returns the last value Set stored under key, however old,
+// and when.
+func (c *Cache) LastGood(key string) (string, time.Time, error) {
+	raw, err := c.client.Get(c.ctx, lastGoodPrefix+key).Bytes()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	var lg lastGood
+	if err := json.Unmarshal(raw, &lg); err != nil {
+		return "", time.Time{}, err
+	}
+	return lg.Value, time.UnixMilli(lg.SavedAt), nil
 }
 
 func (c *Cache) Close() error {
@@ -0,0 +1,242 @@
+/*
+Package candles builds OHLCV bars locally from the typed trade stream
+(stocks trades and index values), at intervals Massive doesn't push.
+Closed bars are kept in a rolling per-ticker history and published to the
+ticker's subscribers as "bar" events.
+*/
+
+package candles
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive/stream"
+)
+
+// EventType is the "ev" of a bar-close event sent to clients.
+const EventType = "bar"
+
+// Interval is a supported bar size.
+type Interval struct {
+	Name     string
+	Duration time.Duration
+}
+
+var Intervals = []Interval{
+	{"5s", 5 * time.Second},
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+	{"1h", time.Hour},
+}
+
+// ParseInterval looks up one of Intervals by name.
+func ParseInterval(name string) (Interval, bool) {
+	for _, iv := range Intervals {
+		if iv.Name == name {
+			return iv, true
+		}
+	}
+	return Interval{}, false
+}
+
+// Bar is one candle. Start/End are unix ms, End exclusive.
+type Bar struct {
+	Interval string  `json:"interval"`
+	Open     float64 `json:"o"`
+	High     float64 `json:"h"`
+	Low      float64 `json:"l"`
+	Close    float64 `json:"c"`
+	Volume   float64 `json:"v"`
+	VWAP     float64 `json:"vw"`
+	Trades   int     `json:"n"`
+	Start    int64   `json:"s"`
+	End      int64   `json:"e"`
+}
+
+const (
+	// DefaultHistory is how many closed bars are kept per ticker and interval.
+	DefaultHistory = 500
+
+	// How often open bars are checked for closing, and how long after a
+	// bar's end we wait for late prints before closing it.
+	sweepEvery = time.Second
+	lateGrace  = 2 * time.Second
+)
+
+type series struct {
+	current *Bar
+	closed  []Bar // oldest first, at most Aggregator.history long
+}
+
+type Aggregator struct {
+	mu      sync.Mutex
+	tickers map[string]map[string]*series // ticker -> interval -> series
+	history int
+
+	// The stream clock: the newest event time we saw and when we saw it.
+	// Bars close on stream time, so a replay at any speed closes them in
+	// the same places as the live feed did.
+	lastEvent   int64
+	lastEventAt time.Time
+
+	out chan<- []stream.Event // usually hub.Publish
+}
+
+func New(out chan<- []stream.Event, history int) *Aggregator {
+	if history <= 0 {
+		history = DefaultHistory
+	}
+	return &Aggregator{
+		tickers: make(map[string]map[string]*series),
+		history: history,
+		out:     out,
+	}
+}
+
+// Run consumes frames (a hub tap) until the channel is closed.
+func (a *Aggregator) Run(frames <-chan []stream.Event) {
+	sweep := time.NewTicker(sweepEvery)
+	defer sweep.Stop()
+
+	for {
+		select {
+		case events, ok := <-frames:
+			if !ok {
+				return
+			}
+			a.publish(a.add(events))
+		case now := <-sweep.C:
+			a.publish(a.sweep(now))
+		}
+	}
+}
+
+// History returns up to limit closed bars (oldest first) and the open bar,
+// if any, for ticker at interval.
+func (a *Aggregator) History(ticker, interval string, limit int) ([]Bar, *Bar) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	s := a.tickers[ticker][interval]
+	if s == nil {
+		return []Bar{}, nil
+	}
+	closed := s.closed
+	if limit > 0 && len(closed) > limit {
+		closed = closed[len(closed)-limit:]
+	}
+	out := append([]Bar{}, closed...)
+	if s.current == nil {
+		return out, nil
+	}
+	current := *s.current
+	return out, &current
+}
+
+// add folds trades and index values into the open bars and returns the
+// events for any bars that closed along the way.
+func (a *Aggregator) add(events []stream.Event) []stream.Event {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var closed []stream.Event
+	for _, e := range events {
+		var price, size float64
+		var ts int64
+		switch {
+		case e.Trade != nil:
+			price, size, ts = e.Trade.Price, e.Trade.Size, e.Trade.Timestamp
+		case e.Index != nil:
+			price, ts = e.Index.Value, e.Index.Timestamp
+		default:
+			continue
+		}
+		if price <= 0 || ts <= 0 {
+			continue
+		}
+		if ts > a.lastEvent {
+			a.lastEvent, a.lastEventAt = ts, time.Now()
+		}
+
+		byInterval := a.tickers[e.Symbol]
+		if byInterval == nil {
+			byInterval = make(map[string]*series)
+			a.tickers[e.Symbol] = byInterval
+		}
+		for _, iv := range Intervals {
+			s := byInterval[iv.Name]
+			if s == nil {
+				s = &series{}
+				byInterval[iv.Name] = s
+			}
+			step := iv.Duration.Milliseconds()
+			start := ts - ts%step
+
+			if s.current != nil && start > s.current.Start {
+				closed = append(closed, a.close(e.Symbol, s))
+			}
+			if s.current == nil {
+				// A print for a bucket we already closed is too late to use.
+				if n := len(s.closed); n > 0 && start < s.closed[n-1].End {
+					continue
+				}
+				s.current = &Bar{Interval: iv.Name, Open: price, High: price, Low: price, Start: start, End: start + step}
+			} else if start < s.current.Start {
+				continue
+			}
+
+			b := s.current
+			b.High = math.Max(b.High, price)
+			b.Low = math.Min(b.Low, price)
+			b.Close = price
+			if size > 0 {
+				b.VWAP = (b.VWAP*b.Volume + price*size) / (b.Volume + size)
+				b.Volume += size
+			}
+			b.Trades++
+		}
+	}
+	return closed
+}
+
+// sweep closes bars that ended a grace period ago on the stream clock, so
+// quiet tickers still get their bar-close events.
+func (a *Aggregator) sweep(now time.Time) []stream.Event {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.lastEvent == 0 {
+		return nil
+	}
+	clock := a.lastEvent + now.Sub(a.lastEventAt).Milliseconds()
+
+	var closed []stream.Event
+	for ticker, byInterval := range a.tickers {
+		for _, s := range byInterval {
+			if s.current != nil && clock >= s.current.End+lateGrace.Milliseconds() {
+				closed = append(closed, a.close(ticker, s))
+			}
+		}
+	}
+	return closed
+}
+
+// close moves the open bar into the history. Called with a.mu held.
+func (a *Aggregator) close(ticker string, s *series) stream.Event {
+	b := *s.current
+	s.current = nil
+	s.closed = append(s.closed, b)
+	if len(s.closed) > a.history {
+		s.closed = s.closed[len(s.closed)-a.history:]
+	}
+	return stream.NewCustom(EventType, ticker, b)
+}
+
+func (a *Aggregator) publish(events []stream.Event) {
+	if len(events) > 0 && a.out != nil {
+		a.out <- events
+	}
+}
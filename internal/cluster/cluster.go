@@ -0,0 +1,419 @@
+/*
+Package cluster lets several server instances share one upstream feed.
+
+One instance wins a Redis lock and becomes the leader: it runs the feed
+source and publishes every frame to a per-ticker Redis channel. Every
+instance (the leader included) subscribes to the channels its own clients
+need and pushes the frames into its local hub, so taps such as the quote
+cache and the candle builder work unchanged.
+
+Each instance advertises the tickers it needs in a Redis set that expires
+unless refreshed. The leader subscribes the source to the union of those
+sets and drops tickers nobody needs anymore.
+*/
+
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/cache"
+	"github.com/dnhan1707/trader/internal/feed"
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/ws"
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis keys and channels
+const (
+	leaderKey      = "quotes:leader"
+	nodeKeyPrefix  = "quotes:node:"   // + node id, set of tickers that node needs
+	tickerPrefix   = "quotes:ticker:" // + ticker, frames for that ticker
+	statusChannel  = "quotes:status"  // upstream status events
+	controlChannel = "quotes:control" // "a node changed its ticker set"
+	userChannel    = "quotes:user"    // frames for one user, see SendToUser
+)
+
+const (
+	// The leader lock expires unless refreshed every electEvery.
+	leaderTTL  = 15 * time.Second
+	electEvery = 5 * time.Second
+
+	// A node's ticker set expires if the node stops refreshing it,
+	// so a crashed instance doesn't pin tickers forever.
+	nodeTTL        = 30 * time.Second
+	heartbeatEvery = 10 * time.Second
+
+	// The leader re-reads every node's set at least this often, in case a
+	// control message was missed.
+	reconcileEvery = 10 * time.Second
+)
+
+// Status is the admin view of one node.
+type Status struct {
+	Node     string   `json:"node"`
+	Leader   bool     `json:"leader"`
+	LeaderID string   `json:"leader_id"`
+	Local    []string `json:"local"`
+	Upstream []string `json:"upstream,omitempty"` // leader only
+}
+
+// Node is this instance's membership in the cluster.
+type Node struct {
+	ID string
+
+	cache  *cache.Cache
+	source feed.Source
+	hub    *ws.Hub
+
+	ctx    context.Context
+	pubsub *redis.PubSub
+
+	mu       sync.Mutex
+	leader   bool
+	local    map[string]bool // tickers this instance's clients need
+	upstream map[string]bool // tickers the source streams (leader only)
+
+	stockSubs chan ws.SubRequest // to the source (leader only)
+	indexSubs chan ws.SubRequest
+	stepDown  context.CancelFunc // stops the source (leader only)
+	wake      chan struct{}
+}
+
+// New creates a node. An empty id defaults to hostname-pid.
+func New(id string, c *cache.Cache, source feed.Source, hub *ws.Hub) *Node {
+	if id == "" {
+		host, _ := os.Hostname()
+		id = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return &Node{
+		ID:       id,
+		cache:    c,
+		source:   source,
+		hub:      hub,
+		ctx:      context.Background(),
+		local:    make(map[string]bool),
+		upstream: make(map[string]bool),
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Run takes the local subscription requests (from ws.SubscriptionManager)
+// and never returns.
+func (n *Node) Run(stockSubs, indexSubs <-chan ws.SubRequest) {
+	n.pubsub = n.cache.Subscribe(n.ctx, statusChannel, userChannel)
+	go n.receive()
+	go n.handleLocal(stockSubs)
+	go n.handleLocal(indexSubs)
+	go n.heartbeat()
+
+	log.Printf("[Cluster] Node %s started", n.ID)
+	n.elect()
+	ticker := time.NewTicker(electEvery)
+	defer ticker.Stop()
+	for range ticker.C {
+		n.elect()
+	}
+}
+
+// Status reports the node's view of the cluster.
+func (n *Node) Status() Status {
+	leaderID, _ := n.cache.Get(leaderKey)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	s := Status{
+		Node:     n.ID,
+		Leader:   n.leader,
+		LeaderID: leaderID,
+		Local:    sortedKeys(n.local),
+	}
+	if n.leader {
+		s.Upstream = sortedKeys(n.upstream)
+	}
+	return s
+}
+
+// receive pushes frames published by the leader into the local hub and
+// hands user frames to the local connections of that user.
+func (n *Node) receive() {
+	for msg := range n.pubsub.Channel() {
+		if msg.Channel == userChannel {
+			var um ws.UserMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &um); err != nil {
+				log.Printf("[Cluster] bad user frame: %v", err)
+				continue
+			}
+			n.hub.SendToUser(um.UserID, um.Data)
+			continue
+		}
+		if events := stream.Decode("Cluster", []byte(msg.Payload)); len(events) > 0 {
+			n.hub.Broadcast <- events
+		}
+	}
+}
+
+// SendToUser delivers a frame to userID's connections on every instance.
+// It has the same shape as ws.Hub.SendToUser so callers can take either.
+func (n *Node) SendToUser(userID string, data []byte) {
+	payload, _ := json.Marshal(ws.UserMessage{UserID: userID, Data: data})
+	if err := n.cache.Publish(userChannel, payload); err != nil {
+		// Better late than never: at least the local connections get it.
+		log.Printf("[Cluster] publish user frame: %v", err)
+		n.hub.SendToUser(userID, data)
+	}
+}
+
+// handleLocal mirrors this instance's refcounted subscriptions into its
+// Redis set and its pub/sub connection, then pokes the leader.
+func (n *Node) handleLocal(subRequests <-chan ws.SubRequest) {
+	key := nodeKeyPrefix + n.ID
+	for req := range subRequests {
+		channel := tickerPrefix + req.Ticker
+
+		var err error
+		if req.Action == ws.ActionUnsubscribe {
+			n.setLocal(req.Ticker, false)
+			if err = n.pubsub.Unsubscribe(n.ctx, channel); err == nil {
+				err = n.cache.SetRemove(key, req.Ticker)
+			}
+		} else {
+			n.setLocal(req.Ticker, true)
+			if err = n.pubsub.Subscribe(n.ctx, channel); err == nil {
+				if err = n.cache.SetAdd(key, req.Ticker); err == nil {
+					err = n.cache.Expire(key, nodeTTL)
+				}
+			}
+		}
+
+		if err != nil {
+			// The heartbeat rewrites the whole set, so Redis catches up
+			// once it is reachable again.
+			log.Printf("[Cluster] %s %s: %v", req.Action, req.Ticker, err)
+			continue
+		}
+		if err := n.cache.Publish(controlChannel, []byte(n.ID)); err != nil {
+			log.Printf("[Cluster] notify leader: %v", err)
+		}
+	}
+}
+
+func (n *Node) setLocal(ticker string, on bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if on {
+		n.local[ticker] = true
+	} else {
+		delete(n.local, ticker)
+	}
+}
+
+// heartbeat rewrites this node's ticker set and keeps it alive.
+func (n *Node) heartbeat() {
+	key := nodeKeyPrefix + n.ID
+	ticker := time.NewTicker(heartbeatEvery)
+	defer ticker.Stop()
+	for range ticker.C {
+		n.mu.Lock()
+		tickers := sortedKeys(n.local)
+		n.mu.Unlock()
+
+		if err := n.cache.ReplaceSet(key, tickers, nodeTTL); err != nil {
+			log.Printf("[Cluster] heartbeat: %v", err)
+		}
+	}
+}
+
+// elect takes or refreshes the leader lock.
+func (n *Node) elect() {
+	n.mu.Lock()
+	leader := n.leader
+	n.mu.Unlock()
+
+	if leader {
+		ok, err := n.cache.RefreshLock(leaderKey, n.ID, leaderTTL)
+		if err != nil {
+			log.Printf("[Cluster] refresh leader lock: %v", err)
+			return
+		}
+		if ok {
+			return
+		}
+		// The lock expired (e.g. Redis was unreachable). Take it back if
+		// nobody else did in the meantime.
+		if ok, err = n.cache.TryLock(leaderKey, n.ID, leaderTTL); err == nil && ok {
+			return
+		}
+		// Two leaders would mean two upstream connections: stop ours and
+		// carry on as a follower, standing for election again next round.
+		n.follow()
+		return
+	}
+
+	ok, err := n.cache.TryLock(leaderKey, n.ID, leaderTTL)
+	if err != nil {
+		log.Printf("[Cluster] leader election: %v", err)
+		return
+	}
+	if ok {
+		n.lead()
+	}
+}
+
+// lead starts the source and the publisher on this node.
+func (n *Node) lead() {
+	log.Printf("[Cluster] Node %s is now the leader", n.ID)
+
+	ctx, cancel := context.WithCancel(n.ctx)
+	n.mu.Lock()
+	n.leader = true
+	n.upstream = make(map[string]bool)
+	n.stockSubs = make(chan ws.SubRequest, 256)
+	n.indexSubs = make(chan ws.SubRequest, 256)
+	n.stepDown = cancel
+	n.mu.Unlock()
+
+	frames := make(chan []stream.Event, 1024)
+	go n.source.Run(ctx, frames, n.stockSubs, n.indexSubs)
+	go n.publish(ctx, frames)
+
+	control := n.cache.Subscribe(ctx, controlChannel)
+	context.AfterFunc(ctx, func() { control.Close() })
+	go func() {
+		for range control.Channel() {
+			select {
+			case n.wake <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	go n.reconcileLoop(ctx)
+}
+
+// follow stops the source, the publisher and the reconciler after the
+// leader lock was lost.
+func (n *Node) follow() {
+	log.Printf("[Cluster] Node %s lost leadership, stepping down", n.ID)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.stepDown != nil {
+		n.stepDown()
+	}
+	n.leader = false
+	n.upstream = make(map[string]bool)
+	n.stepDown = nil
+}
+
+// publish fans the source's frames out to the per-ticker channels.
+func (n *Node) publish(ctx context.Context, frames <-chan []stream.Event) {
+	for {
+		var events []stream.Event
+		select {
+		case events = <-frames:
+		case <-ctx.Done():
+			return
+		}
+
+		var order []string
+		bySymbol := make(map[string][]stream.Event)
+		for _, e := range events {
+			if _, seen := bySymbol[e.Symbol]; !seen {
+				order = append(order, e.Symbol)
+			}
+			bySymbol[e.Symbol] = append(bySymbol[e.Symbol], e)
+		}
+
+		for _, sym := range order {
+			channel := statusChannel
+			if sym != "" {
+				channel = tickerPrefix + sym
+			}
+			if err := n.cache.Publish(channel, stream.Encode(bySymbol[sym])); err != nil {
+				log.Printf("[Cluster] publish %s: %v", channel, err)
+			}
+		}
+	}
+}
+
+func (n *Node) reconcileLoop(ctx context.Context) {
+	ticker := time.NewTicker(reconcileEvery)
+	defer ticker.Stop()
+	for {
+		n.reconcile(ctx)
+		select {
+		case <-n.wake:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// reconcile subscribes the source to the union of every node's tickers.
+func (n *Node) reconcile(ctx context.Context) {
+	keys, err := n.cache.Keys(nodeKeyPrefix + "*")
+	if err != nil {
+		log.Printf("[Cluster] reconcile: %v", err)
+		return
+	}
+	wanted := make(map[string]bool)
+	for _, key := range keys {
+		tickers, err := n.cache.SetMembers(key)
+		if err != nil {
+			log.Printf("[Cluster] reconcile: %v", err)
+			return
+		}
+		for _, t := range tickers {
+			wanted[t] = true
+		}
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if ctx.Err() != nil {
+		// stepped down while reading the sets
+		return
+	}
+	for t := range wanted {
+		if !n.upstream[t] {
+			n.upstream[t] = true
+			n.send(ctx, ws.SubRequest{Action: ws.ActionSubscribe, Ticker: t})
+		}
+	}
+	for t := range n.upstream {
+		if !wanted[t] {
+			delete(n.upstream, t)
+			n.send(ctx, ws.SubRequest{Action: ws.ActionUnsubscribe, Ticker: t})
+		}
+	}
+}
+
+// send routes a request to the right cluster, mirroring
+// ws.SubscriptionManager. Callers hold n.mu; ctx is the leadership's.
+func (n *Node) send(ctx context.Context, req ws.SubRequest) {
+	subs := n.stockSubs
+	if ws.IsIndex(req.Ticker) {
+		subs = n.indexSubs
+	}
+	select {
+	case subs <- req:
+	case <-ctx.Done():
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -11,17 +12,49 @@ import (
 type Config struct {
 	MassiveKey    string
 	MassiveBase   string
+	MassiveRate   int // requests per minute, 0 for no limit
 	RedisAddr     string
 	RedisPass     string
 	RedisDB       int
 	Port          string
-	CacheTTL      int
+	CacheTTL      int // seconds a value is fresh
+	CacheHardTTL  int // seconds it is still served, stale, while it refreshes
+	CacheLastGood int // hours a last good copy is kept for outages
 	DB_USER       string
 	DB_PASSWORD   string
 	EODHD_API_KEY string
 	EODHD_BASE    string
 	JwtSecret     string
 	JwtExpiresIn  string
+	RecordDir     string
+
+	// Values larger than this get no last good copy
+	CacheLastGoodMaxKB int
+
+	// Websocket / SSE client queue size and slow-consumer policy
+	WsSendBuffer   int
+	WsSlowConsumer string
+
+	// Live data source: massive, simulator or replay
+	FeedSource      string
+	SimModel        string
+	SimDrift        float64
+	SimVolatility   float64
+	SimTickInterval time.Duration
+	SimSeed         uint64
+	ReplayPath      string
+	ReplaySpeed     float64
+
+	// Paper trading
+	PaperStartingCash float64
+
+	// Share one upstream feed across instances through Redis
+	ClusterMode   bool
+	ClusterNodeID string
+
+	// Screener universe refresh; 0 turns it off
+	ScreenerRefresh     time.Duration
+	ScreenerHistoryDays int
 }
 
 func Load() *Config {
@@ -29,24 +62,65 @@ func Load() *Config {
 
 	db, _ := strconv.Atoi(getenv("REDIS_DB", "0"))
 	ttl, _ := strconv.Atoi(getenv("CACHE_TTL_SECONDS", "1"))
+	hardTTL, _ := strconv.Atoi(getenv("CACHE_HARD_TTL_SECONDS", "60"))
+	lastGood, _ := strconv.Atoi(getenv("CACHE_LAST_GOOD_HOURS", "24"))
+	lastGoodMaxKB, _ := strconv.Atoi(getenv("CACHE_LAST_GOOD_MAX_KB", "64"))
+	simDrift, _ := strconv.ParseFloat(getenv("SIM_DRIFT", "0.05"), 64)
+	simVol, _ := strconv.ParseFloat(getenv("SIM_VOLATILITY", "0.3"), 64)
+	simTickMs, _ := strconv.Atoi(getenv("SIM_TICK_MS", "250"))
+	simSeed, _ := strconv.ParseUint(getenv("SIM_SEED", "0"), 10, 64)
+	replaySpeed, _ := strconv.ParseFloat(getenv("REPLAY_SPEED", "1"), 64)
+	wsSendBuffer, _ := strconv.Atoi(getenv("WS_SEND_BUFFER", "256"))
+	paperCash, _ := strconv.ParseFloat(getenv("PAPER_STARTING_CASH", "100000"), 64)
+	clusterMode, _ := strconv.ParseBool(getenv("CLUSTER_MODE", "false"))
+	screenerMinutes, _ := strconv.Atoi(getenv("SCREENER_REFRESH_MINUTES", "360"))
+	screenerDays, _ := strconv.Atoi(getenv("SCREENER_HISTORY_DAYS", "300"))
+	// Massive's free plan allows 5 a minute; paid ones ask to stay under 100 a second
+	massiveRate, _ := strconv.Atoi(getenv("MASSIVE_REQUESTS_PER_MINUTE", "6000"))
 
 	c := &Config{
 		MassiveKey:    getenv("MASSIVE_API_KEY", ""),
 		MassiveBase:   getenv("MASSIVE_BASE", "https://api.massive.com/v1"),
+		MassiveRate:   massiveRate,
 		RedisAddr:     getenv("REDIS_ADDR", "localhost:6379"),
 		RedisPass:     getenv("REDIS_PASSWORD", ""),
 		RedisDB:       db,
 		Port:          getenv("PORT", "8080"),
 		CacheTTL:      ttl,
+		CacheHardTTL:  hardTTL,
+		CacheLastGood: lastGood,
 		DB_USER:       getenv("DB_USER", ""),
 		DB_PASSWORD:   getenv("DB_PASSWORD", ""),
 		EODHD_API_KEY: getenv("EODHD_API_KEY", ""),
 		EODHD_BASE:    getenv("EODHD_BASE", ""),
 		JwtSecret:     getenv("JWT_SECRET", "dev-secret-change-me"),
 		JwtExpiresIn:  getenv("JWT_EXPIRES_IN", "1"),
+		RecordDir:     getenv("RECORD_DIR", ""),
+
+		CacheLastGoodMaxKB: lastGoodMaxKB,
+
+		WsSendBuffer:   wsSendBuffer,
+		WsSlowConsumer: getenv("WS_SLOW_CONSUMER", "disconnect"),
+
+		FeedSource:      getenv("FEED_SOURCE", "massive"),
+		SimModel:        getenv("SIM_MODEL", "gbm"),
+		SimDrift:        simDrift,
+		SimVolatility:   simVol,
+		SimTickInterval: time.Duration(simTickMs) * time.Millisecond,
+		SimSeed:         simSeed,
+		ReplayPath:      getenv("REPLAY_PATH", "recordings"),
+		ReplaySpeed:     replaySpeed,
+
+		PaperStartingCash: paperCash,
+
+		ClusterMode:   clusterMode,
+		ClusterNodeID: getenv("CLUSTER_NODE_ID", ""),
+
+		ScreenerRefresh:     time.Duration(screenerMinutes) * time.Minute,
+		ScreenerHistoryDays: screenerDays,
 	}
 
-	if c.MassiveKey == "" {
+	if c.MassiveKey == "" && c.FeedSource == "massive" {
 		log.Println("WARNING: MASSIVE_API_KEY not set")
 	}
 	return c
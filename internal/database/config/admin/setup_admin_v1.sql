@@ -0,0 +1,4 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'user';
+
+-- Grant admin by hand:
+-- UPDATE users SET role = 'admin' WHERE username = '...';
@@ -0,0 +1,47 @@
+-- User-owned price alerts, evaluated against the live trade stream
+CREATE TABLE IF NOT EXISTS price_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ticker TEXT NOT NULL,
+    condition TEXT NOT NULL CHECK (condition IN (
+        'price_above', 'price_below', 'pct_from_open',
+        'cross_52w_high', 'cross_52w_low', 'volume_spike'
+    )),
+    threshold DOUBLE PRECISION NOT NULL DEFAULT 0,
+    -- once: deactivated after firing; rearm: fires again once the condition
+    -- went false and the cooldown passed
+    mode TEXT NOT NULL DEFAULT 'once' CHECK (mode IN ('once', 'rearm')),
+    cooldown_seconds INT NOT NULL DEFAULT 300,
+    note TEXT NOT NULL DEFAULT '',
+    webhook_url TEXT NOT NULL DEFAULT '',
+    webhook_secret TEXT NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    armed BOOLEAN NOT NULL DEFAULT TRUE,
+    trigger_count INT NOT NULL DEFAULT 0,
+    last_triggered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One row per firing, with the outcome of the webhook call
+CREATE TABLE IF NOT EXISTS price_alert_events (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id BIGINT NOT NULL REFERENCES price_alerts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ticker TEXT NOT NULL,
+    price DOUBLE PRECISION NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    message TEXT NOT NULL,
+    webhook_status TEXT NOT NULL DEFAULT '',
+    triggered_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_price_alerts_user
+    ON price_alerts (user_id, created_at DESC);
+
+-- The engine loads every active alert on a timer
+CREATE INDEX IF NOT EXISTS idx_price_alerts_active
+    ON price_alerts (ticker) WHERE active;
+
+CREATE INDEX IF NOT EXISTS idx_price_alert_events_alert
+    ON price_alert_events (alert_id, triggered_at DESC);
@@ -0,0 +1,20 @@
+-- Saved backtest runs. config is the request as run; result holds the
+-- trades and equity curve, stats is kept apart so runs can be listed and
+-- compared without loading every curve.
+CREATE TABLE IF NOT EXISTS backtests (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL DEFAULT '',
+    ticker TEXT NOT NULL,
+    timespan TEXT NOT NULL,
+    multiplier INT NOT NULL,
+    from_date DATE NOT NULL,
+    to_date DATE NOT NULL,
+    config JSONB NOT NULL,
+    stats JSONB NOT NULL,
+    result JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_backtests_user_created
+    ON backtests (user_id, created_at DESC);
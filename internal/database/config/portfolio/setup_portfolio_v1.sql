@@ -0,0 +1,17 @@
+-- Manually entered trades (e.g. from a real brokerage account). Paper
+-- trading fills come from paper_fills; the portfolio endpoints merge both.
+CREATE TABLE IF NOT EXISTS portfolio_transactions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ticker TEXT NOT NULL,
+    side TEXT NOT NULL CHECK (side IN ('buy', 'sell')),
+    qty NUMERIC(18, 4) NOT NULL CHECK (qty > 0),
+    price NUMERIC(18, 4) NOT NULL CHECK (price >= 0),
+    fees NUMERIC(18, 4) NOT NULL DEFAULT 0,
+    traded_at TIMESTAMPTZ NOT NULL,
+    note TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_portfolio_transactions_user_traded
+    ON portfolio_transactions (user_id, traded_at);
@@ -0,0 +1,74 @@
+-- Screener universe, rebuilt by internal/screener on every refresh. Every
+-- metric is nullable: not every ticker has ratios, short interest or
+-- enough history for the technicals. Percentages are in percent.
+CREATE TABLE IF NOT EXISTS screener_universe (
+    ticker TEXT PRIMARY KEY,
+    name TEXT NOT NULL DEFAULT '',
+    type TEXT NOT NULL DEFAULT '',
+    exchange TEXT NOT NULL DEFAULT '',
+
+    -- snapshot
+    price DOUBLE PRECISION,
+    change_pct DOUBLE PRECISION,
+    volume DOUBLE PRECISION,
+    prev_close DOUBLE PRECISION,
+
+    -- ratios
+    market_cap DOUBLE PRECISION,
+    pe DOUBLE PRECISION,
+    pb DOUBLE PRECISION,
+    ps DOUBLE PRECISION,
+    ev_ebitda DOUBLE PRECISION,
+    eps DOUBLE PRECISION,
+    dividend_yield DOUBLE PRECISION,
+    roe DOUBLE PRECISION,
+    roa DOUBLE PRECISION,
+    debt_to_equity DOUBLE PRECISION,
+    current_ratio DOUBLE PRECISION,
+    free_cash_flow DOUBLE PRECISION,
+    avg_volume DOUBLE PRECISION,
+
+    -- technicals, from screener_closes
+    rsi_14 DOUBLE PRECISION,
+    sma_20 DOUBLE PRECISION,
+    sma_50 DOUBLE PRECISION,
+    sma_200 DOUBLE PRECISION,
+    return_1m DOUBLE PRECISION,
+    return_3m DOUBLE PRECISION,
+
+    -- short interest, latest settlement and the one before
+    short_interest DOUBLE PRECISION,
+    short_interest_prev DOUBLE PRECISION,
+    short_interest_change DOUBLE PRECISION,
+    days_to_cover DOUBLE PRECISION,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Daily closes of the whole market (grouped daily bars) for the technicals
+CREATE TABLE IF NOT EXISTS screener_closes (
+    ticker TEXT NOT NULL,
+    day DATE NOT NULL,
+    close DOUBLE PRECISION NOT NULL,
+    PRIMARY KEY (ticker, day)
+);
+
+CREATE INDEX IF NOT EXISTS idx_screener_closes_day ON screener_closes (day);
+
+-- Days already loaded into screener_closes, holidays included, so they
+-- aren't asked for again
+CREATE TABLE IF NOT EXISTS screener_close_days (
+    day DATE PRIMARY KEY,
+    tickers INT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS screener_screens (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    filter TEXT NOT NULL,
+    sort TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
@@ -0,0 +1,5 @@
+-- The day a close was fetched. Grouped daily bars are split-adjusted as of
+-- that day, so a ticker whose closes predate one of its splits is fetched
+-- again. NULL for rows stored before this column existed.
+ALTER TABLE screener_closes ADD COLUMN IF NOT EXISTS fetched_on DATE;
+ALTER TABLE screener_closes ALTER COLUMN fetched_on SET DEFAULT CURRENT_DATE;
@@ -0,0 +1,65 @@
+-- Paper trading: one cash account per user
+CREATE TABLE IF NOT EXISTS paper_accounts (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL UNIQUE REFERENCES users(id) ON DELETE CASCADE,
+    cash NUMERIC(18, 4) NOT NULL,
+    starting_cash NUMERIC(18, 4) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS paper_orders (
+    id BIGSERIAL PRIMARY KEY,
+    account_id BIGINT NOT NULL REFERENCES paper_accounts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ticker TEXT NOT NULL,
+    side TEXT NOT NULL CHECK (side IN ('buy', 'sell')),
+    type TEXT NOT NULL CHECK (type IN ('market', 'limit', 'stop', 'stop_limit')),
+    qty NUMERIC(18, 4) NOT NULL CHECK (qty > 0),
+    limit_price NUMERIC(18, 4),
+    stop_price NUMERIC(18, 4),
+    time_in_force TEXT NOT NULL DEFAULT 'day' CHECK (time_in_force IN ('day', 'gtc')),
+    -- open -> (triggered) -> filled | cancelled | expired | rejected
+    status TEXT NOT NULL DEFAULT 'open' CHECK (status IN (
+        'open', 'triggered', 'filled', 'cancelled', 'expired', 'rejected'
+    )),
+    filled_qty NUMERIC(18, 4) NOT NULL DEFAULT 0,
+    avg_fill_price NUMERIC(18, 4),
+    reject_reason TEXT NOT NULL DEFAULT '',
+    expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    filled_at TIMESTAMPTZ
+);
+
+-- One row per execution; the position and P&L history is derived from these
+CREATE TABLE IF NOT EXISTS paper_fills (
+    id BIGSERIAL PRIMARY KEY,
+    order_id BIGINT NOT NULL REFERENCES paper_orders(id) ON DELETE CASCADE,
+    account_id BIGINT NOT NULL REFERENCES paper_accounts(id) ON DELETE CASCADE,
+    ticker TEXT NOT NULL,
+    side TEXT NOT NULL CHECK (side IN ('buy', 'sell')),
+    qty NUMERIC(18, 4) NOT NULL,
+    price NUMERIC(18, 4) NOT NULL,
+    filled_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS paper_positions (
+    account_id BIGINT NOT NULL REFERENCES paper_accounts(id) ON DELETE CASCADE,
+    ticker TEXT NOT NULL,
+    qty NUMERIC(18, 4) NOT NULL DEFAULT 0,
+    avg_cost NUMERIC(18, 4) NOT NULL DEFAULT 0,
+    realized_pnl NUMERIC(18, 4) NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (account_id, ticker)
+);
+
+CREATE INDEX IF NOT EXISTS idx_paper_orders_user_created
+    ON paper_orders (user_id, created_at DESC);
+
+-- The fill engine loads every working order on a timer
+CREATE INDEX IF NOT EXISTS idx_paper_orders_working
+    ON paper_orders (ticker) WHERE status IN ('open', 'triggered');
+
+CREATE INDEX IF NOT EXISTS idx_paper_fills_account_filled
+    ON paper_fills (account_id, filled_at);
@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS watchlists (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+-- position keeps the user's ordering
+CREATE TABLE IF NOT EXISTS watchlist_items (
+    watchlist_id BIGINT NOT NULL REFERENCES watchlists(id) ON DELETE CASCADE,
+    ticker TEXT NOT NULL,
+    position INT NOT NULL,
+    added_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (watchlist_id, ticker)
+);
+
+-- Read-only access for other users
+CREATE TABLE IF NOT EXISTS watchlist_shares (
+    watchlist_id BIGINT NOT NULL REFERENCES watchlists(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (watchlist_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_watchlist_shares_user
+    ON watchlist_shares (user_id);
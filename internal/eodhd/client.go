@@ -2,17 +2,46 @@ package eodhd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
+
+	"github.com/dnhan1707/trader/internal/breaker"
+)
+
+// Like massive.Client, an endpoint's circuit opens after breakerThreshold
+// failures in a row.
+const (
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
 )
 
+// Error is a failed response from EODHD.
+type Error struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *Error) Error() string { return "EODHD error: " + e.Body }
+
+// NetError is a request that got no (complete) response from EODHD.
+type NetError struct {
+	Err error
+}
+
+func (e *NetError) Error() string { return "EODHD: " + e.Err.Error() }
+
+func (e *NetError) Unwrap() error { return e.Err }
+
 type Client struct {
-	baseURL string
-	apiKey  string
-	http    *http.Client
+	baseURL  string
+	apiKey   string
+	http     *http.Client
+	breakers *breaker.Set
 }
 
 func New(baseURL, apiKey string) *Client {
@@ -22,25 +51,46 @@ func New(baseURL, apiKey string) *Client {
 		http: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		breakers: breaker.NewSet("eodhd", breakerThreshold, breakerCooldown),
 	}
 }
 
-func (c *Client) GetCusipByTicker(ticker string) (string, error) {
-	fullURL := fmt.Sprintf("%s/id-mapping?filter[symbol]=%s.US&page[limit]=1&page[offset]=0&api_token=%s&fmt=json", c.baseURL, ticker, c.apiKey)
+// get fetches fullURL through the circuit of endpoint. Network errors,
+// 429s and 5xxs count against it.
+func (c *Client) get(endpoint, fullURL string) ([]byte, error) {
+	if err := c.breakers.Allow(endpoint); err != nil {
+		return nil, fmt.Errorf("EODHD %s: %w", endpoint, err)
+	}
 	req, _ := http.NewRequest("GET", fullURL, nil)
 
 	resp, err := c.http.Do(req)
 	if err != nil {
-		return "", err
+		c.breakers.Report(endpoint, false)
+		// *url.Error would repeat the URL, api_token included
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return nil, &NetError{Err: err}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
+	c.breakers.Report(endpoint, err == nil && resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500)
 	if err != nil {
-		return "", err
+		return nil, &NetError{Err: err}
 	}
 	if resp.StatusCode >= 400 {
-		return "", fmt.Errorf("EODHD error: %s", string(body))
+		return nil, &Error{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return body, nil
+}
+
+func (c *Client) GetCusipByTicker(ticker string) (string, error) {
+	fullURL := fmt.Sprintf("%s/id-mapping?filter[symbol]=%s.US&page[limit]=1&page[offset]=0&api_token=%s&fmt=json", c.baseURL, ticker, c.apiKey)
+	body, err := c.get("id-mapping", fullURL)
+	if err != nil {
+		return "", err
 	}
 
 	var result map[string]interface{}
@@ -0,0 +1,57 @@
+/*
+Package feed selects where live market data comes from: the Massive sockets,
+the synthetic simulator or a recording.
+*/
+
+package feed
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/dnhan1707/trader/internal/config"
+	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/recorder"
+	"github.com/dnhan1707/trader/internal/simulator"
+	"github.com/dnhan1707/trader/internal/ws"
+)
+
+// Source names accepted in FEED_SOURCE
+const (
+	SourceMassive   = "massive"
+	SourceSimulator = "simulator"
+	SourceReplay    = "replay"
+)
+
+// Source produces decoded frames into out (usually hub.Broadcast) for the
+// tickers requested on stockSubs / indexSubs. Run blocks for as long as the
+// source is alive and returns, with its connections closed, once ctx is
+// done.
+type Source interface {
+	Run(ctx context.Context, out chan<- []stream.Event, stockSubs, indexSubs <-chan ws.SubRequest)
+}
+
+// New returns the source selected by cfg.FeedSource.
+func New(cfg *config.Config) (Source, error) {
+	switch cfg.FeedSource {
+	case "", SourceMassive:
+		return &massive.Stream{APIKey: cfg.MassiveKey}, nil
+	case SourceSimulator:
+		model, err := simulator.ParseModel(cfg.SimModel)
+		if err != nil {
+			return nil, err
+		}
+		return simulator.New(simulator.Config{
+			Model:        model,
+			Drift:        cfg.SimDrift,
+			Volatility:   cfg.SimVolatility,
+			TickInterval: cfg.SimTickInterval,
+			Seed:         cfg.SimSeed,
+		}), nil
+	case SourceReplay:
+		return &recorder.Player{Path: cfg.ReplayPath, Speed: cfg.ReplaySpeed, Loop: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown FEED_SOURCE %q (want %s, %s or %s)", cfg.FeedSource, SourceMassive, SourceSimulator, SourceReplay)
+	}
+}
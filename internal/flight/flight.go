@@ -0,0 +1,107 @@
+/*
+Package flight coalesces concurrent loads of the same key in this process:
+callers that come in while a load runs get its result instead of starting
+their own. A load runs on its own context, bounded by the group's timeout,
+and is cancelled once every caller waiting on it has gone.
+*/
+
+package flight
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Group runs one load per key at a time.
+type Group struct {
+	timeout time.Duration
+
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+type call struct {
+	done chan struct{}
+	val  string
+	err  error
+
+	cancel   context.CancelFunc
+	waiters  int  // callers of Do still waiting
+	detached bool // started by Start, runs to completion
+}
+
+// New makes a group whose loads are cancelled after timeout.
+func New(timeout time.Duration) *Group {
+	return &Group{timeout: timeout, calls: make(map[string]*call)}
+}
+
+// Do runs fn for key unless a call for it is already in flight, and waits
+// for the result. ctx only bounds this caller's wait; fn's context is
+// cancelled once every caller waiting on it has gone.
+func (g *Group) Do(ctx context.Context, key string, fn func(ctx context.Context) (string, error)) (string, error) {
+	cl, fctx, leader := g.join(key, false)
+	if leader {
+		go g.run(fctx, key, cl, fn)
+	}
+	select {
+	case <-cl.done:
+		return cl.val, cl.err
+	case <-ctx.Done():
+		g.leave(key, cl)
+		return "", ctx.Err()
+	}
+}
+
+// Start runs fn for key in the background unless a call is in flight.
+// Nobody waits on it, so it's only bounded by the timeout.
+func (g *Group) Start(key string, fn func(ctx context.Context) (string, error)) {
+	if cl, fctx, leader := g.join(key, true); leader {
+		go g.run(fctx, key, cl, fn)
+	}
+}
+
+func (g *Group) join(key string, detached bool) (*call, context.Context, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cl, ok := g.calls[key]; ok {
+		if !detached {
+			cl.waiters++
+		}
+		return cl, nil, false
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), g.timeout)
+	cl := &call{done: make(chan struct{}), cancel: cancel, detached: detached}
+	if !detached {
+		cl.waiters = 1
+	}
+	g.calls[key] = cl
+	return cl, ctx, true
+}
+
+// leave drops a waiter that gave up. The last one out cancels the call and
+// forgets it, so the next caller starts afresh.
+func (g *Group) leave(key string, cl *call) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if cl.waiters--; cl.waiters > 0 || cl.detached {
+		return
+	}
+	cl.cancel()
+	if g.calls[key] == cl {
+		delete(g.calls, key)
+	}
+}
+
+func (g *Group) run(ctx context.Context, key string, cl *call, fn func(ctx context.Context) (string, error)) {
+	defer func() {
+		g.mu.Lock()
+		if g.calls[key] == cl {
+			delete(g.calls, key)
+		}
+		g.mu.Unlock()
+		cl.cancel()
+		close(cl.done)
+	}()
+	cl.val, cl.err = fn(ctx)
+}
@@ -0,0 +1,142 @@
+/*
+Package indicators computes technical indicators from OHLCV bars, locally,
+instead of asking Massive for each one. Every function returns series of the
+same length as its input, aligned bar for bar; values that can't be computed
+yet (the warm-up at the start) are NaN and encode as JSON null.
+*/
+
+package indicators
+
+import (
+	"math"
+	"strconv"
+)
+
+// Bar is one OHLCV bar. Time is unix ms, the bar start.
+type Bar struct {
+	Time   int64
+	Open   float64
+	High   float64
+	Low    float64
+	Close  float64
+	Volume float64
+	VWAP   float64 // the bar's own volume-weighted price, 0 if unknown
+}
+
+// Line is one series aligned to the bars. NaN marks a missing value.
+type Line []float64
+
+// MarshalJSON writes NaN (and ±Inf) as null.
+func (l Line) MarshalJSON() ([]byte, error) {
+	buf := make([]byte, 0, 2+len(l)*8)
+	buf = append(buf, '[')
+	for i, v := range l {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			buf = append(buf, "null"...)
+			continue
+		}
+		buf = strconv.AppendFloat(buf, v, 'f', -1, 64)
+	}
+	return append(buf, ']'), nil
+}
+
+func nanLine(n int) Line {
+	l := make(Line, n)
+	for i := range l {
+		l[i] = math.NaN()
+	}
+	return l
+}
+
+func closes(bars []Bar) Line {
+	l := make(Line, len(bars))
+	for i, b := range bars {
+		l[i] = b.Close
+	}
+	return l
+}
+
+// highest is the highest high of the n bars ending at each bar.
+func highest(bars []Bar, n int) Line {
+	out := nanLine(len(bars))
+	for i := n - 1; i < len(bars); i++ {
+		h := bars[i].High
+		for j := i - n + 1; j < i; j++ {
+			h = math.Max(h, bars[j].High)
+		}
+		out[i] = h
+	}
+	return out
+}
+
+// lowest is the lowest low of the n bars ending at each bar.
+func lowest(bars []Bar, n int) Line {
+	out := nanLine(len(bars))
+	for i := n - 1; i < len(bars); i++ {
+		l := bars[i].Low
+		for j := i - n + 1; j < i; j++ {
+			l = math.Min(l, bars[j].Low)
+		}
+		out[i] = l
+	}
+	return out
+}
+
+// midpoint is (highest + lowest) / 2 over n bars, used by Ichimoku.
+func midpoint(bars []Bar, n int) Line {
+	hi, lo := highest(bars, n), lowest(bars, n)
+	out := make(Line, len(bars))
+	for i := range out {
+		out[i] = (hi[i] + lo[i]) / 2
+	}
+	return out
+}
+
+// trueRange is max(high, prev close) - min(low, prev close); the first bar
+// has no previous close and uses high - low.
+func trueRange(bars []Bar) Line {
+	out := make(Line, len(bars))
+	for i, b := range bars {
+		if i == 0 {
+			out[i] = b.High - b.Low
+			continue
+		}
+		pc := bars[i-1].Close
+		out[i] = math.Max(b.High, pc) - math.Min(b.Low, pc)
+	}
+	return out
+}
+
+// firstValid is the index of the first non-NaN value, or len(src).
+func firstValid(src Line) int {
+	for i, v := range src {
+		if !math.IsNaN(v) {
+			return i
+		}
+	}
+	return len(src)
+}
+
+// wilder is Wilder's smoothing (an EMA with alpha 1/n) seeded with the
+// mean of the first n values, starting from the first valid one.
+func wilder(src Line, n int) Line {
+	out := nanLine(len(src))
+	start := firstValid(src)
+	if start+n > len(src) {
+		return out
+	}
+	var sum float64
+	for i := start; i < start+n; i++ {
+		sum += src[i]
+	}
+	prev := sum / float64(n)
+	out[start+n-1] = prev
+	for i := start + n; i < len(src); i++ {
+		prev = (prev*float64(n-1) + src[i]) / float64(n)
+		out[i] = prev
+	}
+	return out
+}
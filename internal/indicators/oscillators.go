@@ -0,0 +1,48 @@
+package indicators
+
+import "math"
+
+// RSI is Wilder's relative strength index over n changes.
+func RSI(src Line, n int) Line {
+	size := len(src)
+	gains, losses := nanLine(size), nanLine(size)
+	for i := 1; i < size; i++ {
+		ch := src[i] - src[i-1]
+		gains[i], losses[i] = math.Max(ch, 0), math.Max(-ch, 0)
+	}
+	avgGain, avgLoss := wilder(gains, n), wilder(losses, n)
+
+	out := nanLine(size)
+	for i := range out {
+		g, l := avgGain[i], avgLoss[i]
+		switch {
+		case math.IsNaN(g) || math.IsNaN(l):
+		case l == 0 && g == 0:
+			out[i] = 50
+		case l == 0:
+			out[i] = 100
+		default:
+			out[i] = 100 - 100/(1+g/l)
+		}
+	}
+	return out
+}
+
+// Stochastic is the slow stochastic oscillator: the raw %K over n bars
+// smoothed over smoothK bars, and %D its SMA over d bars.
+func Stochastic(bars []Bar, n, smoothK, d int) (k, dLine Line) {
+	hi, lo := highest(bars, n), lowest(bars, n)
+	raw := nanLine(len(bars))
+	for i, b := range bars {
+		if math.IsNaN(hi[i]) {
+			continue
+		}
+		if rng := hi[i] - lo[i]; rng > 0 {
+			raw[i] = 100 * (b.Close - lo[i]) / rng
+		} else {
+			raw[i] = 50
+		}
+	}
+	k = SMA(raw, smoothK)
+	return k, SMA(k, d)
+}
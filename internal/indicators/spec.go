@@ -0,0 +1,239 @@
+package indicators
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxSpecs bounds one request.
+const MaxSpecs = 20
+
+const maxPeriod = 1000
+
+// Spec is one requested indicator, e.g. "bb:20:2".
+type Spec struct {
+	Kind   string
+	Params []float64
+}
+
+type kind struct {
+	defaults []float64
+	// integer params are bar counts; the rest (bb's k) are plain numbers
+	integer []bool
+	// warmup is how many bars it takes for the values to settle
+	warmup func(p []float64) int
+	// compute returns the output lines by name
+	compute func(bars []Bar, p []float64, opts Options) map[string]Line
+}
+
+// Options carries what some indicators need beyond the bars.
+type Options struct {
+	// NewSession makes VWAP start over, e.g. on every trading day for
+	// intraday bars. nil anchors it at the first bar.
+	NewSession func(prev, cur Bar) bool
+}
+
+var kinds = map[string]kind{
+	"sma": {
+		defaults: []float64{20}, integer: []bool{true},
+		warmup: func(p []float64) int { return int(p[0]) },
+		compute: func(bars []Bar, p []float64, _ Options) map[string]Line {
+			return map[string]Line{"sma": SMA(closes(bars), int(p[0]))}
+		},
+	},
+	"ema": {
+		defaults: []float64{20}, integer: []bool{true},
+		warmup: func(p []float64) int { return 3 * int(p[0]) },
+		compute: func(bars []Bar, p []float64, _ Options) map[string]Line {
+			return map[string]Line{"ema": EMA(closes(bars), int(p[0]))}
+		},
+	},
+	"rsi": {
+		defaults: []float64{14}, integer: []bool{true},
+		warmup: func(p []float64) int { return 3*int(p[0]) + 1 },
+		compute: func(bars []Bar, p []float64, _ Options) map[string]Line {
+			return map[string]Line{"rsi": RSI(closes(bars), int(p[0]))}
+		},
+	},
+	"macd": {
+		defaults: []float64{12, 26, 9}, integer: []bool{true, true, true},
+		warmup: func(p []float64) int { return 3*int(p[1]) + int(p[2]) },
+		compute: func(bars []Bar, p []float64, _ Options) map[string]Line {
+			m, s, h := MACD(closes(bars), int(p[0]), int(p[1]), int(p[2]))
+			return map[string]Line{"macd": m, "signal": s, "histogram": h}
+		},
+	},
+	"bb": {
+		defaults: []float64{20, 2}, integer: []bool{true, false},
+		warmup: func(p []float64) int { return int(p[0]) },
+		compute: func(bars []Bar, p []float64, _ Options) map[string]Line {
+			u, m, l := Bollinger(closes(bars), int(p[0]), p[1])
+			return map[string]Line{"upper": u, "middle": m, "lower": l}
+		},
+	},
+	"atr": {
+		defaults: []float64{14}, integer: []bool{true},
+		warmup: func(p []float64) int { return 3 * int(p[0]) },
+		compute: func(bars []Bar, p []float64, _ Options) map[string]Line {
+			return map[string]Line{"atr": ATR(bars, int(p[0]))}
+		},
+	},
+	"stoch": {
+		defaults: []float64{14, 3, 3}, integer: []bool{true, true, true},
+		warmup: func(p []float64) int { return int(p[0]) + int(p[1]) + int(p[2]) },
+		compute: func(bars []Bar, p []float64, _ Options) map[string]Line {
+			k, d := Stochastic(bars, int(p[0]), int(p[1]), int(p[2]))
+			return map[string]Line{"k": k, "d": d}
+		},
+	},
+	"vwap": {
+		warmup: func([]float64) int { return 0 },
+		compute: func(bars []Bar, _ []float64, opts Options) map[string]Line {
+			return map[string]Line{"vwap": VWAP(bars, opts.NewSession)}
+		},
+	},
+	"obv": {
+		warmup: func([]float64) int { return 0 },
+		compute: func(bars []Bar, _ []float64, _ Options) map[string]Line {
+			return map[string]Line{"obv": OBV(bars)}
+		},
+	},
+	"adx": {
+		defaults: []float64{14}, integer: []bool{true},
+		warmup: func(p []float64) int { return 6 * int(p[0]) },
+		compute: func(bars []Bar, p []float64, _ Options) map[string]Line {
+			a, plus, minus := ADX(bars, int(p[0]))
+			return map[string]Line{"adx": a, "plus_di": plus, "minus_di": minus}
+		},
+	},
+	"ichimoku": {
+		defaults: []float64{9, 26, 52}, integer: []bool{true, true, true},
+		warmup: func(p []float64) int { return int(p[2]) + int(p[1]) },
+		compute: func(bars []Bar, p []float64, _ Options) map[string]Line {
+			t, k, a, b, c := Ichimoku(bars, int(p[0]), int(p[1]), int(p[2]), int(p[1]))
+			return map[string]Line{"tenkan": t, "kijun": k, "senkou_a": a, "senkou_b": b, "chikou": c}
+		},
+	},
+	"donchian": {
+		defaults: []float64{20}, integer: []bool{true},
+		warmup: func(p []float64) int { return int(p[0]) },
+		compute: func(bars []Bar, p []float64, _ Options) map[string]Line {
+			u, m, l := Donchian(bars, int(p[0]))
+			return map[string]Line{"upper": u, "middle": m, "lower": l}
+		},
+	},
+}
+
+// ParseSet parses a comma-separated list such as "rsi:14,bb:20:2,atr".
+// Missing parameters take their defaults.
+func ParseSet(set string) ([]Spec, error) {
+	var specs []Spec
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(set, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		spec, err := ParseSpec(item)
+		if err != nil {
+			return nil, err
+		}
+		if seen[spec.Key()] {
+			continue
+		}
+		seen[spec.Key()] = true
+		specs = append(specs, spec)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no indicators requested")
+	}
+	if len(specs) > MaxSpecs {
+		return nil, fmt.Errorf("at most %d indicators per request", MaxSpecs)
+	}
+	return specs, nil
+}
+
+// ParseSpec parses one "name:param:param" item.
+func ParseSpec(item string) (Spec, error) {
+	parts := strings.Split(strings.ToLower(item), ":")
+	k, ok := kinds[parts[0]]
+	if !ok {
+		return Spec{}, fmt.Errorf("unknown indicator %q", parts[0])
+	}
+	args := parts[1:]
+	if len(args) > len(k.defaults) {
+		return Spec{}, fmt.Errorf("%s takes at most %d parameters", parts[0], len(k.defaults))
+	}
+
+	params := append([]float64(nil), k.defaults...)
+	for i, a := range args {
+		v, err := strconv.ParseFloat(a, 64)
+		if err != nil {
+			return Spec{}, fmt.Errorf("%s: invalid parameter %q", item, a)
+		}
+		if k.integer[i] {
+			if v != float64(int(v)) || v < 1 || v > maxPeriod {
+				return Spec{}, fmt.Errorf("%s: period must be a whole number between 1 and %d", item, maxPeriod)
+			}
+		} else if v <= 0 || v > 10 {
+			return Spec{}, fmt.Errorf("%s: multiplier must be in (0, 10]", item)
+		}
+		params[i] = v
+	}
+	if parts[0] == "macd" && params[0] >= params[1] {
+		return Spec{}, fmt.Errorf("%s: the fast period must be shorter than the slow one", item)
+	}
+	return Spec{Kind: parts[0], Params: params}, nil
+}
+
+// Key is the spec with every parameter spelled out, e.g. "rsi:14".
+func (s Spec) Key() string {
+	var b strings.Builder
+	b.WriteString(s.Kind)
+	for _, p := range s.Params {
+		b.WriteByte(':')
+		b.WriteString(strconv.FormatFloat(p, 'f', -1, 64))
+	}
+	return b.String()
+}
+
+// Warmup is how many bars before the first wanted one should be loaded for
+// the values to have settled.
+func (s Spec) Warmup() int {
+	return kinds[s.Kind].warmup(s.Params)
+}
+
+// Compute runs the spec over bars and returns its lines by name (e.g.
+// "upper", "middle" and "lower" for bb).
+func (s Spec) Compute(bars []Bar, opts Options) map[string]Line {
+	return kinds[s.Kind].compute(bars, s.Params, opts)
+}
+
+// LookbackStart moves from back far enough, in calendar days, to load bars
+// more bars of multiplier x timespan (weekends and nights included), so the
+// indicators have warmed up by from.
+func LookbackStart(from time.Time, timespan string, multiplier, bars int) time.Time {
+	if bars == 0 {
+		return from
+	}
+	n := float64(bars * multiplier)
+	var days float64
+	switch timespan {
+	case "minute":
+		days = n/390*7/5 + 3
+	case "hour":
+		days = n/6.5*7/5 + 3
+	case "day":
+		days = n*7/5 + 5
+	case "week":
+		days = n*7 + 7
+	case "month":
+		days = n*31 + 31
+	case "quarter":
+		days = n*92 + 92
+	}
+	return from.AddDate(0, 0, -int(math.Ceil(days)))
+}
@@ -0,0 +1,127 @@
+package indicators
+
+import "math"
+
+// SMA is the simple moving average over n values. A window that contains a
+// missing value is missing.
+func SMA(src Line, n int) Line {
+	out := nanLine(len(src))
+	var sum float64
+	missing := 0
+	for i, v := range src {
+		if math.IsNaN(v) {
+			missing++
+		} else {
+			sum += v
+		}
+		if i >= n {
+			if old := src[i-n]; math.IsNaN(old) {
+				missing--
+			} else {
+				sum -= old
+			}
+		}
+		if i >= n-1 && missing == 0 {
+			out[i] = sum / float64(n)
+		}
+	}
+	return out
+}
+
+// EMA is the exponential moving average (alpha 2/(n+1)), seeded with the
+// SMA of the first n valid values.
+func EMA(src Line, n int) Line {
+	out := nanLine(len(src))
+	start := firstValid(src)
+	if start+n > len(src) {
+		return out
+	}
+	var sum float64
+	for i := start; i < start+n; i++ {
+		sum += src[i]
+	}
+	prev := sum / float64(n)
+	out[start+n-1] = prev
+
+	alpha := 2 / float64(n+1)
+	for i := start + n; i < len(src); i++ {
+		prev += alpha * (src[i] - prev)
+		out[i] = prev
+	}
+	return out
+}
+
+// MACD returns the MACD line (fast EMA - slow EMA), its signal EMA and the
+// histogram.
+func MACD(src Line, fast, slow, signal int) (macd, sig, hist Line) {
+	f, s := EMA(src, fast), EMA(src, slow)
+	macd = make(Line, len(src))
+	for i := range src {
+		macd[i] = f[i] - s[i]
+	}
+	sig = EMA(macd, signal)
+	hist = make(Line, len(src))
+	for i := range src {
+		hist[i] = macd[i] - sig[i]
+	}
+	return macd, sig, hist
+}
+
+// ADX is Wilder's average directional index with the +DI and -DI lines.
+func ADX(bars []Bar, n int) (adx, plusDI, minusDI Line) {
+	size := len(bars)
+	plusDM, minusDM := nanLine(size), nanLine(size)
+	tr := trueRange(bars)
+	if size > 0 {
+		tr[0] = math.NaN() // no directional movement on the first bar
+	}
+	for i := 1; i < size; i++ {
+		up := bars[i].High - bars[i-1].High
+		down := bars[i-1].Low - bars[i].Low
+		plusDM[i], minusDM[i] = 0, 0
+		if up > down && up > 0 {
+			plusDM[i] = up
+		}
+		if down > up && down > 0 {
+			minusDM[i] = down
+		}
+	}
+
+	atr, sp, sm := wilder(tr, n), wilder(plusDM, n), wilder(minusDM, n)
+	plusDI, minusDI = nanLine(size), nanLine(size)
+	dx := nanLine(size)
+	for i := 0; i < size; i++ {
+		if math.IsNaN(atr[i]) || atr[i] == 0 {
+			continue
+		}
+		plusDI[i] = 100 * sp[i] / atr[i]
+		minusDI[i] = 100 * sm[i] / atr[i]
+		if sum := plusDI[i] + minusDI[i]; sum > 0 {
+			dx[i] = 100 * math.Abs(plusDI[i]-minusDI[i]) / sum
+		} else {
+			dx[i] = 0
+		}
+	}
+	return wilder(dx, n), plusDI, minusDI
+}
+
+// Ichimoku returns the five lines of the Ichimoku cloud. The leading spans
+// are plotted displacement bars ahead, so the value on a bar is the one
+// computed displacement bars earlier; the projection past the last bar is
+// not returned. The lagging span on a bar is the close displacement bars
+// later.
+func Ichimoku(bars []Bar, conversion, base, spanB, displacement int) (tenkan, kijun, senkouA, senkouB, chikou Line) {
+	size := len(bars)
+	tenkan, kijun = midpoint(bars, conversion), midpoint(bars, base)
+	b := midpoint(bars, spanB)
+
+	senkouA, senkouB, chikou = nanLine(size), nanLine(size), nanLine(size)
+	for i := displacement; i < size; i++ {
+		senkouA[i] = (tenkan[i-displacement] + kijun[i-displacement]) / 2
+		senkouB[i] = b[i-displacement]
+	}
+	for i := 0; i+displacement < size; i++ {
+		chikou[i] = bars[i+displacement].Close
+	}
+	return tenkan, kijun, senkouA, senkouB, chikou
+}
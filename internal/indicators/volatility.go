@@ -0,0 +1,39 @@
+package indicators
+
+import "math"
+
+// Bollinger returns the SMA over n and the bands k population standard
+// deviations above and below it.
+func Bollinger(src Line, n int, k float64) (upper, middle, lower Line) {
+	middle = SMA(src, n)
+	upper, lower = nanLine(len(src)), nanLine(len(src))
+	for i := range src {
+		if math.IsNaN(middle[i]) {
+			continue
+		}
+		var variance float64
+		for j := i - n + 1; j <= i; j++ {
+			d := src[j] - middle[i]
+			variance += d * d
+		}
+		sd := math.Sqrt(variance / float64(n))
+		upper[i], lower[i] = middle[i]+k*sd, middle[i]-k*sd
+	}
+	return upper, middle, lower
+}
+
+// ATR is Wilder's average true range over n bars.
+func ATR(bars []Bar, n int) Line {
+	return wilder(trueRange(bars), n)
+}
+
+// Donchian returns the highest high, lowest low and their midpoint over n
+// bars.
+func Donchian(bars []Bar, n int) (upper, middle, lower Line) {
+	upper, lower = highest(bars, n), lowest(bars, n)
+	middle = make(Line, len(bars))
+	for i := range middle {
+		middle[i] = (upper[i] + lower[i]) / 2
+	}
+	return upper, middle, lower
+}
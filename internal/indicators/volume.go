@@ -0,0 +1,41 @@
+package indicators
+
+// VWAP is the running volume-weighted average price. It starts over on
+// every bar for which newSession returns true (nil: never, i.e. anchored
+// at the first bar). A bar's own VWAP is used as its price when known,
+// its typical price (H+L+C)/3 otherwise.
+func VWAP(bars []Bar, newSession func(prev, cur Bar) bool) Line {
+	out := nanLine(len(bars))
+	var pv, vol float64
+	for i, b := range bars {
+		if i > 0 && newSession != nil && newSession(bars[i-1], b) {
+			pv, vol = 0, 0
+		}
+		price := b.VWAP
+		if price <= 0 {
+			price = (b.High + b.Low + b.Close) / 3
+		}
+		pv += price * b.Volume
+		vol += b.Volume
+		if vol > 0 {
+			out[i] = pv / vol
+		}
+	}
+	return out
+}
+
+// OBV is on-balance volume, starting at 0 on the first bar.
+func OBV(bars []Bar) Line {
+	out := make(Line, len(bars))
+	for i := 1; i < len(bars); i++ {
+		switch {
+		case bars[i].Close > bars[i-1].Close:
+			out[i] = out[i-1] + bars[i].Volume
+		case bars[i].Close < bars[i-1].Close:
+			out[i] = out[i-1] - bars[i].Volume
+		default:
+			out[i] = out[i-1]
+		}
+	}
+	return out
+}
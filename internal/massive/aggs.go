@@ -0,0 +1,66 @@
+package massive
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Agg is one bar from the aggregates (custom bars) endpoint.
+type Agg struct {
+	Open         float64 `json:"o"`
+	High         float64 `json:"h"`
+	Low          float64 `json:"l"`
+	Close        float64 `json:"c"`
+	Volume       float64 `json:"v"`
+	VWAP         float64 `json:"vw"`
+	Transactions int64   `json:"n"`
+	Timestamp    int64   `json:"t"` // unix ms, bar start
+}
+
+// MaxAggBars caps what Aggregates collects across pages.
+const MaxAggBars = 200000
+
+// ErrTooManyBars is returned when a range has more than MaxAggBars bars.
+var ErrTooManyBars = fmt.Errorf("more than %d bars in range, narrow it or use a larger timespan", MaxAggBars)
+
+// Aggregates is the typed form of GetCustomBars, oldest bar first. Massive
+// caps each response, so it follows next_url until the range is complete.
+func (c *Client) Aggregates(ctx context.Context, stocksTicker string, multiplier int, timespan, from, to string, adjusted bool) ([]Agg, error) {
+	full := c.buildURL(
+		fmt.Sprintf("/v2/aggs/ticker/%s/range/%d/%s/%s/%s", stocksTicker, multiplier, timespan, from, to),
+		map[string]string{
+			"adjusted": strconv.FormatBool(adjusted),
+			"sort":     "asc",
+			"limit":    "50000",
+		},
+	)
+
+	bars, err := fetchPages[Agg](ctx, c, full, MaxAggBars)
+	if err == errTooMany {
+		return nil, ErrTooManyBars
+	}
+	return bars, err
+}
+
+// GroupedAgg is one ticker's bar in a grouped daily response.
+type GroupedAgg struct {
+	Ticker string `json:"T"`
+	Agg
+}
+
+// GroupedDaily returns the daily bar of every stock for date (YYYY-MM-DD).
+// Market holidays come back empty.
+func (c *Client) GroupedDaily(ctx context.Context, date string, adjusted bool) ([]GroupedAgg, error) {
+	var resp struct {
+		Status  string       `json:"status"`
+		Results []GroupedAgg `json:"results"`
+	}
+	full := c.buildURL("/v2/aggs/grouped/locale/us/market/stocks/"+date, map[string]string{
+		"adjusted": strconv.FormatBool(adjusted),
+	})
+	if err := c.fetchInto(ctx, full, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
@@ -4,86 +4,68 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
+
+	"github.com/dnhan1707/trader/internal/breaker"
 )
 
 type Client struct {
-	baseURL string
-	apiKey  string
-	http    *http.Client
+	baseURL  string
+	apiKey   string
+	http     *http.Client
+	limiter  *limiter
+	breakers *breaker.Set
 }
 
-func New(baseURL, apiKey string) *Client {
+// New makes a client allowed perMinute requests a minute (0 for no limit).
+// Massive's plans are rate limited per key, so this is shared by everything
+// in the process that talks to the REST API.
+func New(baseURL, apiKey string, perMinute int) *Client {
 	return &Client{
 		baseURL: strings.TrimRight(baseURL, "/"),
 		apiKey:  apiKey,
 		http: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		limiter:  newLimiter(perMinute),
+		breakers: breaker.NewSet("massive", breakerThreshold, breakerCooldown),
 	}
 }
 
-func (c *Client) fetchRaw(fullURL string) (map[string]interface{}, error) {
-	req, _ := http.NewRequestWithContext(context.Background(), "GET", fullURL, nil)
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-
-	resp, err := c.http.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("massive error: %s", string(body))
-	}
-
+func (c *Client) fetchRaw(ctx context.Context, fullURL string) (map[string]interface{}, error) {
 	var result map[string]interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
+	if err := c.fetchInto(ctx, fullURL, &result); err != nil {
 		return nil, err
 	}
 	return result, nil
 }
 
-func (c *Client) fetchAny(fullURL string) (interface{}, error) {
-	req, _ := http.NewRequestWithContext(context.Background(), "GET", fullURL, nil)
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
-
-	resp, err := c.http.Do(req)
-	if err != nil {
+func (c *Client) fetchAny(ctx context.Context, fullURL string) (interface{}, error) {
+	var result interface{}
+	if err := c.fetchInto(ctx, fullURL, &result); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return result, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+// fetchInto decodes the response body into v (a pointer to a typed
+// response). 429s, 5xxs and network errors are retried, see do.
+func (c *Client) fetchInto(ctx context.Context, fullURL string, v interface{}) error {
+	body, err := c.do(ctx, fullURL)
 	if err != nil {
-		return nil, err
-	}
-	if resp.StatusCode >= 400 {
-		return nil, fmt.Errorf("massive error: %s", string(body))
-	}
-
-	var result interface{}
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
+		return err
 	}
-	return result, nil
+	return json.Unmarshal(body, v)
 }
 
-// buildURL attaches apiKey and extra query params to a base path
+// buildURL attaches extra query params to a base path. The apiKey goes in
+// the Authorization header only, see get.
 func (c *Client) buildURL(path string, extra map[string]string) string {
 	u := c.baseURL + path
 	q := url.Values{}
-	if c.apiKey != "" {
-		q.Set("apiKey", c.apiKey)
-	}
 	for k, v := range extra {
 		if v != "" {
 			q.Set(k, v)
@@ -95,96 +77,111 @@ func (c *Client) buildURL(path string, extra map[string]string) string {
 	return u
 }
 
-func (c *Client) GetTickerDetails(symbol string) (map[string]interface{}, error) {
+// nextURL checks a next_url from a paginated response before it is
+// followed. A next_url on another host is ignored so the Authorization
+// header never leaves for it, and an apiKey in it is dropped.
+func (c *Client) nextURL(next string) string {
+	u, err := url.Parse(next)
+	if err != nil {
+		return ""
+	}
+	if base, err := url.Parse(c.baseURL); err != nil || u.Host != base.Host {
+		return ""
+	}
+	if q := u.Query(); q.Has("apiKey") {
+		q.Del("apiKey")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// The Get* methods pass Massive's JSON through untouched for the proxy
+// handlers. Everything that reads the data uses the typed forms (Details,
+// Aggregates, ListDividends...). The list endpoints take a Paging and
+// answer with a next_cursor instead of next_url, see fetchList.
+
+func (c *Client) GetTickerDetails(ctx context.Context, symbol string) (map[string]interface{}, error) {
 	full := c.buildURL(fmt.Sprintf("/v3/reference/tickers/%s", symbol), nil)
-	return c.fetchRaw(full)
+	return c.fetchRaw(ctx, full)
 }
 
-func (c *Client) GetCustomBars(stocksTicker, multiplier, timespan, from, to string, extra map[string]string) (map[string]interface{}, error) {
+func (c *Client) GetCustomBars(ctx context.Context, stocksTicker, multiplier, timespan, from, to string, extra map[string]string) (map[string]interface{}, error) {
 	full := c.buildURL(
 		fmt.Sprintf("/v2/aggs/ticker/%s/range/%s/%s/%s/%s", stocksTicker, multiplier, timespan, from, to),
 		extra,
 	)
-	return c.fetchRaw(full)
+	return c.fetchRaw(ctx, full)
 }
 
-func (c *Client) GetSMA(stocksTicker string, extra map[string]string) (map[string]interface{}, error) {
+func (c *Client) GetSMA(ctx context.Context, stocksTicker string, extra map[string]string) (map[string]interface{}, error) {
 	full := c.buildURL(fmt.Sprintf("/v1/indicators/sma/%s", stocksTicker), extra)
-	return c.fetchRaw(full)
+	return c.fetchRaw(ctx, full)
 }
 
-func (c *Client) GetEMA(stocksTicker string, extra map[string]string) (map[string]interface{}, error) {
+func (c *Client) GetEMA(ctx context.Context, stocksTicker string, extra map[string]string) (map[string]interface{}, error) {
 	full := c.buildURL(fmt.Sprintf("/v1/indicators/ema/%s", stocksTicker), extra)
-	return c.fetchRaw(full)
+	return c.fetchRaw(ctx, full)
 }
 
-func (c *Client) GetMACD(stocksTicker string, extra map[string]string) (map[string]interface{}, error) {
+func (c *Client) GetMACD(ctx context.Context, stocksTicker string, extra map[string]string) (map[string]interface{}, error) {
 	full := c.buildURL(fmt.Sprintf("/v1/indicators/macd/%s", stocksTicker), extra)
-	return c.fetchRaw(full)
+	return c.fetchRaw(ctx, full)
 }
 
-func (c *Client) GetRSI(stocksTicker string, extra map[string]string) (map[string]interface{}, error) {
+func (c *Client) GetRSI(ctx context.Context, stocksTicker string, extra map[string]string) (map[string]interface{}, error) {
 	full := c.buildURL(fmt.Sprintf("/v1/indicators/rsi/%s", stocksTicker), extra)
-	return c.fetchRaw(full)
+	return c.fetchRaw(ctx, full)
 }
 
-func (c *Client) GetExchanges(extra map[string]string) (map[string]interface{}, error) {
+func (c *Client) GetExchanges(ctx context.Context, extra map[string]string) (map[string]interface{}, error) {
 	full := c.buildURL("/v3/reference/exchanges", extra)
-	return c.fetchRaw(full)
+	return c.fetchRaw(ctx, full)
 }
 
-func (c *Client) GetMarketHolidays() (interface{}, error) {
+func (c *Client) GetMarketHolidays(ctx context.Context) (interface{}, error) {
 	full := c.buildURL("/v1/marketstatus/upcoming", nil)
-	return c.fetchAny(full)
+	return c.fetchAny(ctx, full)
 }
 
-func (c *Client) GetMarketStatus() (map[string]interface{}, error) {
+func (c *Client) GetMarketStatus(ctx context.Context) (map[string]interface{}, error) {
 	full := c.buildURL("/v1/marketstatus/now", nil)
-	return c.fetchRaw(full)
+	return c.fetchRaw(ctx, full)
 }
 
-func (c *Client) GetConditions(extra map[string]string) (map[string]interface{}, error) {
-	full := c.buildURL("/v3/reference/conditions", extra)
-	return c.fetchRaw(full)
+func (c *Client) GetConditions(ctx context.Context, extra map[string]string, p Paging) (map[string]interface{}, error) {
+	return c.fetchList(ctx, "/v3/reference/conditions", extra, p)
 }
 
-func (c *Client) GetIPOs(extra map[string]string) (map[string]interface{}, error) {
+func (c *Client) GetIPOs(ctx context.Context, extra map[string]string, p Paging) (map[string]interface{}, error) {
 	// Reference endpoints live under v3
-	full := c.buildURL("/v3/reference/ipos", extra)
-	return c.fetchRaw(full)
+	return c.fetchList(ctx, "/v3/reference/ipos", extra, p)
 }
 
-func (c *Client) GetDividends(extra map[string]string) (map[string]interface{}, error) {
-	full := c.buildURL("/v3/reference/dividends", extra)
-	return c.fetchRaw(full)
+func (c *Client) GetDividends(ctx context.Context, extra map[string]string, p Paging) (map[string]interface{}, error) {
+	return c.fetchList(ctx, "/v3/reference/dividends", extra, p)
 }
 
-func (c *Client) GetShortInterest(extra map[string]string) (map[string]interface{}, error) {
-	full := c.buildURL("/stocks/v1/short-interest", extra)
-	return c.fetchRaw(full)
+func (c *Client) GetShortInterest(ctx context.Context, extra map[string]string, p Paging) (map[string]interface{}, error) {
+	return c.fetchList(ctx, "/stocks/v1/short-interest", extra, p)
 }
 
-func (c *Client) GetShortVolume(extra map[string]string) (map[string]interface{}, error) {
-	full := c.buildURL("/stocks/v1/short-volume", extra)
-	return c.fetchRaw(full)
+func (c *Client) GetShortVolume(ctx context.Context, extra map[string]string, p Paging) (map[string]interface{}, error) {
+	return c.fetchList(ctx, "/stocks/v1/short-volume", extra, p)
 }
 
-func (c *Client) GetNews(extra map[string]string) (map[string]interface{}, error) {
-	full := c.buildURL("/v2/reference/news", extra)
-	return c.fetchRaw(full)
+func (c *Client) GetNews(ctx context.Context, extra map[string]string, p Paging) (map[string]interface{}, error) {
+	return c.fetchList(ctx, "/v2/reference/news", extra, p)
 }
 
-func (c *Client) GetRatios(extra map[string]string) (map[string]interface{}, error) {
-	full := c.buildURL("/stocks/financials/v1/ratios", extra)
-	return c.fetchRaw(full)
+func (c *Client) GetRatios(ctx context.Context, extra map[string]string, p Paging) (map[string]interface{}, error) {
+	return c.fetchList(ctx, "/stocks/financials/v1/ratios", extra, p)
 }
 
-func (c *Client) GetTickerSnapshot(stocksTicker string) (map[string]interface{}, error) {
+func (c *Client) GetTickerSnapshot(ctx context.Context, stocksTicker string) (map[string]interface{}, error) {
 	full := c.buildURL(fmt.Sprintf("/v2/snapshot/locale/us/markets/stocks/tickers/%s", stocksTicker), nil)
-	return c.fetchRaw(full)
+	return c.fetchRaw(ctx, full)
 }
 
-func (c *Client) GetIncomeStatements(extra map[string]string) (map[string]interface{}, error) {
-	full := c.buildURL("/stocks/financials/v1/income-statements", extra)
-	return c.fetchRaw(full)
+func (c *Client) GetIncomeStatements(ctx context.Context, extra map[string]string, p Paging) (map[string]interface{}, error) {
+	return c.fetchList(ctx, "/stocks/financials/v1/income-statements", extra, p)
 }
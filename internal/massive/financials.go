@@ -0,0 +1,149 @@
+package massive
+
+import (
+	"context"
+	"fmt"
+)
+
+// Ratio is a ticker's latest valuation and financial ratios. Missing
+// values are nil.
+type Ratio struct {
+	Ticker              string   `json:"ticker"`
+	Date                string   `json:"date"`
+	Price               *float64 `json:"price"`
+	AverageVolume       *float64 `json:"average_volume"`
+	MarketCap           *float64 `json:"market_cap"`
+	EarningsPerShare    *float64 `json:"earnings_per_share"`
+	PriceToEarnings     *float64 `json:"price_to_earnings"`
+	PriceToBook         *float64 `json:"price_to_book"`
+	PriceToSales        *float64 `json:"price_to_sales"`
+	PriceToCashFlow     *float64 `json:"price_to_cash_flow"`
+	PriceToFreeCashFlow *float64 `json:"price_to_free_cash_flow"`
+	DividendYield       *float64 `json:"dividend_yield"`
+	ReturnOnAssets      *float64 `json:"return_on_assets"`
+	ReturnOnEquity      *float64 `json:"return_on_equity"`
+	DebtToEquity        *float64 `json:"debt_to_equity"`
+	Current             *float64 `json:"current"`
+	Quick               *float64 `json:"quick"`
+	EVToSales           *float64 `json:"ev_to_sales"`
+	EVToEBITDA          *float64 `json:"ev_to_ebitda"`
+	EnterpriseValue     *float64 `json:"enterprise_value"`
+	FreeCashFlow        *float64 `json:"free_cash_flow"`
+}
+
+// MaxListResults caps the whole-market lists below.
+const MaxListResults = 500000
+
+// AllRatios is the typed form of GetRatios for every ticker, all pages.
+func (c *Client) AllRatios(ctx context.Context) ([]Ratio, error) {
+	full := c.buildURL("/stocks/financials/v1/ratios", map[string]string{"limit": "50000"})
+	ratios, err := fetchPages[Ratio](ctx, c, full, MaxListResults)
+	if err == errTooMany {
+		return nil, fmt.Errorf("more than %d ratios", MaxListResults)
+	}
+	return ratios, err
+}
+
+// ShortInterest is one settlement-date report.
+type ShortInterest struct {
+	Ticker         string  `json:"ticker"`
+	SettlementDate string  `json:"settlement_date"`
+	ShortInterest  float64 `json:"short_interest"`
+	AvgDailyVolume float64 `json:"avg_daily_volume"`
+	DaysToCover    float64 `json:"days_to_cover"`
+}
+
+// ShortInterestSince is the typed form of GetShortInterest for every
+// ticker settled on or after date (YYYY-MM-DD), newest first, all pages.
+func (c *Client) ShortInterestSince(ctx context.Context, date string) ([]ShortInterest, error) {
+	full := c.buildURL("/stocks/v1/short-interest", map[string]string{
+		"settlement_date.gte": date,
+		"sort":                "settlement_date.desc",
+		"limit":               "50000",
+	})
+	reports, err := fetchPages[ShortInterest](ctx, c, full, MaxListResults)
+	if err == errTooMany {
+		return nil, fmt.Errorf("more than %d short interest reports", MaxListResults)
+	}
+	return reports, err
+}
+
+// ListRatios is the typed form of GetRatios: one page.
+func (c *Client) ListRatios(ctx context.Context, p ListParams) (*Page[Ratio], error) {
+	return fetchPage[Ratio](ctx, c, "/stocks/financials/v1/ratios", p)
+}
+
+// IterRatios walks every page of ListRatios, up to max results.
+func (c *Client) IterRatios(p ListParams, max int) *Iter[Ratio] {
+	return iterList[Ratio](c, "/stocks/financials/v1/ratios", p, max)
+}
+
+// IncomeStatement is one reported period. Missing line items are nil.
+type IncomeStatement struct {
+	CIK                      string   `json:"cik"`
+	Tickers                  []string `json:"tickers"`
+	Timeframe                string   `json:"timeframe"` // quarterly, annual or trailing_twelve_months
+	FiscalYear               int      `json:"fiscal_year"`
+	FiscalQuarter            int      `json:"fiscal_quarter"`
+	PeriodEnd                string   `json:"period_end"`
+	FilingDate               string   `json:"filing_date"`
+	Revenue                  *float64 `json:"revenue"`
+	CostOfRevenue            *float64 `json:"cost_of_revenue"`
+	GrossProfit              *float64 `json:"gross_profit"`
+	ResearchDevelopment      *float64 `json:"research_development"`
+	SellingGeneralAdmin      *float64 `json:"selling_general_administrative"`
+	TotalOperatingExpenses   *float64 `json:"total_operating_expenses"`
+	OperatingIncome          *float64 `json:"operating_income"`
+	InterestExpense          *float64 `json:"interest_expense"`
+	IncomeBeforeIncomeTaxes  *float64 `json:"income_before_income_taxes"`
+	IncomeTaxes              *float64 `json:"income_taxes"`
+	ConsolidatedNetIncome    *float64 `json:"consolidated_net_income_loss"`
+	NetIncomeToCommon        *float64 `json:"net_income_loss_attributable_common_shareholders"`
+	BasicEPS                 *float64 `json:"basic_earnings_per_share"`
+	DilutedEPS               *float64 `json:"diluted_earnings_per_share"`
+	BasicSharesOutstanding   *float64 `json:"basic_shares_outstanding"`
+	DilutedSharesOutstanding *float64 `json:"diluted_shares_outstanding"`
+	EBITDA                   *float64 `json:"ebitda"`
+}
+
+// ListIncomeStatements is the typed form of GetIncomeStatements: one
+// page. Filters takes timeframe, fiscal_year, period_end and the like.
+func (c *Client) ListIncomeStatements(ctx context.Context, p ListParams) (*Page[IncomeStatement], error) {
+	return fetchPage[IncomeStatement](ctx, c, "/stocks/financials/v1/income-statements", p)
+}
+
+// IterIncomeStatements walks every page of ListIncomeStatements, up to max results.
+func (c *Client) IterIncomeStatements(p ListParams, max int) *Iter[IncomeStatement] {
+	return iterList[IncomeStatement](c, "/stocks/financials/v1/income-statements", p, max)
+}
+
+// ListShortInterest is the typed form of GetShortInterest: one page.
+func (c *Client) ListShortInterest(ctx context.Context, p ListParams) (*Page[ShortInterest], error) {
+	return fetchPage[ShortInterest](ctx, c, "/stocks/v1/short-interest", p)
+}
+
+// IterShortInterest walks every page of ListShortInterest, up to max results.
+func (c *Client) IterShortInterest(p ListParams, max int) *Iter[ShortInterest] {
+	return iterList[ShortInterest](c, "/stocks/v1/short-interest", p, max)
+}
+
+// ShortVolume is one day's off-exchange short sale volume.
+type ShortVolume struct {
+	Ticker           string  `json:"ticker"`
+	Date             string  `json:"date"`
+	ShortVolume      float64 `json:"short_volume"`
+	ShortVolumeRatio float64 `json:"short_volume_ratio"` // percent of TotalVolume
+	TotalVolume      float64 `json:"total_volume"`
+	ExemptVolume     float64 `json:"exempt_volume"`
+	NonExemptVolume  float64 `json:"non_exempt_volume"`
+}
+
+// ListShortVolume is the typed form of GetShortVolume: one page.
+func (c *Client) ListShortVolume(ctx context.Context, p ListParams) (*Page[ShortVolume], error) {
+	return fetchPage[ShortVolume](ctx, c, "/stocks/v1/short-volume", p)
+}
+
+// IterShortVolume walks every page of ListShortVolume, up to max results.
+func (c *Client) IterShortVolume(p ListParams, max int) *Iter[ShortVolume] {
+	return iterList[ShortVolume](c, "/stocks/v1/short-volume", p, max)
+}
@@ -0,0 +1,110 @@
+package massive
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// IndicatorParams selects the bars an indicator is computed on. Zero
+// values leave Massive's defaults (daily, adjusted closes).
+type IndicatorParams struct {
+	Timespan   string // minute, hour, day, week, month, quarter or year
+	Window     int
+	SeriesType string // open, high, low or close
+	Adjusted   *bool
+	From, To   string // timestamp.gte / timestamp.lte, YYYY-MM-DD or unix ms
+	Order      string // asc or desc
+	Limit      int
+}
+
+func (p IndicatorParams) query() map[string]string {
+	q := map[string]string{
+		"timespan":      p.Timespan,
+		"series_type":   p.SeriesType,
+		"timestamp.gte": p.From,
+		"timestamp.lte": p.To,
+		"order":         p.Order,
+	}
+	if p.Window > 0 {
+		q["window"] = strconv.Itoa(p.Window)
+	}
+	if p.Adjusted != nil {
+		q["adjusted"] = strconv.FormatBool(*p.Adjusted)
+	}
+	if p.Limit > 0 {
+		q["limit"] = strconv.Itoa(p.Limit)
+	}
+	return q
+}
+
+// IndicatorValue is one point of a single-line indicator.
+type IndicatorValue struct {
+	Timestamp int64   `json:"timestamp"` // unix ms
+	Value     float64 `json:"value"`
+}
+
+// MACDValue is one point of MACD.
+type MACDValue struct {
+	Timestamp int64   `json:"timestamp"` // unix ms
+	Value     float64 `json:"value"`
+	Signal    float64 `json:"signal"`
+	Histogram float64 `json:"histogram"`
+}
+
+// Indicator is an indicator's values, in Order.
+type Indicator[V any] struct {
+	Values  []V    `json:"values"`
+	NextURL string `json:"next_url,omitempty"`
+}
+
+func fetchIndicator[V any](ctx context.Context, c *Client, name, stocksTicker string, q map[string]string) (*Indicator[V], error) {
+	var resp struct {
+		Status  string `json:"status"`
+		NextURL string `json:"next_url"`
+		Results struct {
+			Values []V `json:"values"`
+		} `json:"results"`
+	}
+	full := c.buildURL(fmt.Sprintf("/v1/indicators/%s/%s", name, stocksTicker), q)
+	if err := c.fetchInto(ctx, full, &resp); err != nil {
+		return nil, err
+	}
+	return &Indicator[V]{Values: resp.Results.Values, NextURL: resp.NextURL}, nil
+}
+
+// SMA is the typed form of GetSMA.
+func (c *Client) SMA(ctx context.Context, stocksTicker string, p IndicatorParams) (*Indicator[IndicatorValue], error) {
+	return fetchIndicator[IndicatorValue](ctx, c, "sma", stocksTicker, p.query())
+}
+
+// EMA is the typed form of GetEMA.
+func (c *Client) EMA(ctx context.Context, stocksTicker string, p IndicatorParams) (*Indicator[IndicatorValue], error) {
+	return fetchIndicator[IndicatorValue](ctx, c, "ema", stocksTicker, p.query())
+}
+
+// RSI is the typed form of GetRSI.
+func (c *Client) RSI(ctx context.Context, stocksTicker string, p IndicatorParams) (*Indicator[IndicatorValue], error) {
+	return fetchIndicator[IndicatorValue](ctx, c, "rsi", stocksTicker, p.query())
+}
+
+// MACDParams adds MACD's windows to IndicatorParams, whose Window is
+// unused.
+type MACDParams struct {
+	IndicatorParams
+	ShortWindow  int
+	LongWindow   int
+	SignalWindow int
+}
+
+// MACD is the typed form of GetMACD.
+func (c *Client) MACD(ctx context.Context, stocksTicker string, p MACDParams) (*Indicator[MACDValue], error) {
+	q := p.query()
+	delete(q, "window")
+	for k, v := range map[string]int{"short_window": p.ShortWindow, "long_window": p.LongWindow, "signal_window": p.SignalWindow} {
+		if v > 0 {
+			q[k] = strconv.Itoa(v)
+		}
+	}
+	return fetchIndicator[MACDValue](ctx, c, "macd", stocksTicker, q)
+}
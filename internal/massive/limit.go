@@ -0,0 +1,68 @@
+package massive
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// limiter is a token bucket: perMinute tokens a minute, at most a
+// second's worth (and never less than one) banked while idle.
+type limiter struct {
+	mu       sync.Mutex
+	interval time.Duration // between tokens
+	burst    float64
+	tokens   float64
+	last     time.Time
+}
+
+// newLimiter returns nil, which never waits, for perMinute <= 0.
+func newLimiter(perMinute int) *limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	burst := float64(perMinute) / 60
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{
+		interval: time.Minute / time.Duration(perMinute),
+		burst:    burst,
+		tokens:   burst,
+		last:     time.Now(),
+	}
+}
+
+// wait takes a token, sleeping until one is due or ctx ends.
+func (l *limiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += float64(now.Sub(l.last)) / float64(l.interval)
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	// Taking the token up front queues callers in arrival order
+	l.tokens--
+	delay := time.Duration(-l.tokens * float64(l.interval))
+	l.mu.Unlock()
+	if delay <= 0 {
+		return nil
+	}
+
+	t := time.NewTimer(delay)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		// give the token back
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,45 @@
+package massive
+
+import "context"
+
+// MarketStatus is the trading status right now. Exchanges, Currencies and
+// IndicesGroups map a name to a status such as "open", "closed" or
+// "extended-hours".
+type MarketStatus struct {
+	Market        string            `json:"market"`
+	ServerTime    string            `json:"serverTime"`
+	EarlyHours    bool              `json:"earlyHours"`
+	AfterHours    bool              `json:"afterHours"`
+	Exchanges     map[string]string `json:"exchanges"`
+	Currencies    map[string]string `json:"currencies"`
+	IndicesGroups map[string]string `json:"indicesGroups"`
+}
+
+// MarketStatusNow is the typed form of GetMarketStatus.
+func (c *Client) MarketStatusNow(ctx context.Context) (*MarketStatus, error) {
+	var status MarketStatus
+	if err := c.fetchInto(ctx, c.buildURL("/v1/marketstatus/now", nil), &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// MarketHoliday is one upcoming closure or early close of an exchange.
+// Open and Close are only set on early closes.
+type MarketHoliday struct {
+	Exchange string `json:"exchange"`
+	Name     string `json:"name"`
+	Date     string `json:"date"`   // YYYY-MM-DD
+	Status   string `json:"status"` // closed or early-close
+	Open     string `json:"open,omitempty"`
+	Close    string `json:"close,omitempty"`
+}
+
+// UpcomingHolidays is the typed form of GetMarketHolidays.
+func (c *Client) UpcomingHolidays(ctx context.Context) ([]MarketHoliday, error) {
+	var holidays []MarketHoliday
+	if err := c.fetchInto(ctx, c.buildURL("/v1/marketstatus/upcoming", nil), &holidays); err != nil {
+		return nil, err
+	}
+	return holidays, nil
+}
@@ -0,0 +1,42 @@
+package massive
+
+import "context"
+
+// NewsArticle is one article from the news reference.
+type NewsArticle struct {
+	ID           string   `json:"id"`
+	Title        string   `json:"title"`
+	Author       string   `json:"author"`
+	Description  string   `json:"description"`
+	PublishedUTC string   `json:"published_utc"` // RFC 3339
+	ArticleURL   string   `json:"article_url"`
+	AMPURL       string   `json:"amp_url"`
+	ImageURL     string   `json:"image_url"`
+	Tickers      []string `json:"tickers"`
+	Keywords     []string `json:"keywords"`
+	Publisher    struct {
+		Name        string `json:"name"`
+		HomepageURL string `json:"homepage_url"`
+		LogoURL     string `json:"logo_url"`
+		FaviconURL  string `json:"favicon_url"`
+	} `json:"publisher"`
+	Insights []NewsInsight `json:"insights"`
+}
+
+// NewsInsight is the sentiment of an article towards one ticker.
+type NewsInsight struct {
+	Ticker             string `json:"ticker"`
+	Sentiment          string `json:"sentiment"` // positive, neutral or negative
+	SentimentReasoning string `json:"sentiment_reasoning"`
+}
+
+// ListNews is the typed form of GetNews: one page. Filters takes
+// published_utc and its .gte / .lte variants.
+func (c *Client) ListNews(ctx context.Context, p ListParams) (*Page[NewsArticle], error) {
+	return fetchPage[NewsArticle](ctx, c, "/v2/reference/news", p)
+}
+
+// IterNews walks every page of ListNews, up to max results.
+func (c *Client) IterNews(p ListParams, max int) *Iter[NewsArticle] {
+	return iterList[NewsArticle](c, "/v2/reference/news", p, max)
+}
@@ -0,0 +1,236 @@
+package massive
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+var errTooMany = errors.New("too many results")
+
+// Page is one page of a list endpoint. NextURL is Massive's link to the
+// next page, without the apiKey.
+type Page[T any] struct {
+	Status    string `json:"status"`
+	RequestID string `json:"request_id"`
+	Count     int    `json:"count"`
+	NextURL   string `json:"next_url,omitempty"`
+	Results   []T    `json:"results"`
+}
+
+// ListParams filters a list endpoint. Filters holds the endpoint's own
+// query parameters, e.g. "ex_dividend_date.gte" for dividends.
+type ListParams struct {
+	Ticker  string
+	Order   string // asc or desc
+	Sort    string
+	Limit   int
+	Filters map[string]string
+}
+
+func (p ListParams) query() map[string]string {
+	q := make(map[string]string, len(p.Filters)+4)
+	for k, v := range p.Filters {
+		q[k] = v
+	}
+	q["ticker"] = p.Ticker
+	q["order"] = p.Order
+	q["sort"] = p.Sort
+	if p.Limit > 0 {
+		q["limit"] = strconv.Itoa(p.Limit)
+	}
+	return q
+}
+
+// fetchPage fetches one page of path.
+func fetchPage[T any](ctx context.Context, c *Client, path string, p ListParams) (*Page[T], error) {
+	var page Page[T]
+	if err := c.fetchInto(ctx, c.buildURL(path, p.query()), &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// Iter walks a list endpoint result by result, fetching the pages behind
+// next_url as it goes:
+//
+//	it := c.IterDividends(massive.ListParams{Ticker: "AAPL"}, 5000)
+//	for it.Next(ctx) {
+//		d := it.Item()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type Iter[T any] struct {
+	c    *Client
+	next string // full URL of the next page, "" when there is none
+	page []T
+	i    int
+	n    int
+	max  int
+	err  error
+}
+
+func newIter[T any](c *Client, full string, max int) *Iter[T] {
+	return &Iter[T]{c: c, next: full, i: -1, max: max}
+}
+
+// Next advances to the next result. It returns false at the end, on an
+// error, or when a result past max comes in (Err is then ErrTooManyItems).
+func (it *Iter[T]) Next(ctx context.Context) bool {
+	for it.i+1 >= len(it.page) {
+		if it.err != nil || it.next == "" {
+			return false
+		}
+		var page Page[T]
+		if err := it.c.fetchInto(ctx, it.next, &page); err != nil {
+			it.err = err
+			return false
+		}
+		it.page, it.i = page.Results, -1
+		it.next = ""
+		if page.NextURL != "" && len(page.Results) > 0 {
+			it.next = it.c.nextURL(page.NextURL)
+		}
+	}
+	if it.n == it.max {
+		it.err = ErrTooManyItems
+		return false
+	}
+	it.i++
+	it.n++
+	return true
+}
+
+// Item is the current result.
+func (it *Iter[T]) Item() T { return it.page[it.i] }
+
+func (it *Iter[T]) Err() error { return it.err }
+
+// ErrTooManyItems stops an Iter at its max.
+var ErrTooManyItems = errors.New("massive: too many results")
+
+// iterList iterates path from the first page for p.
+func iterList[T any](c *Client, path string, p ListParams, max int) *Iter[T] {
+	return newIter[T](c, c.buildURL(path, p.query()), max)
+}
+
+// fetchPages collects the results of full and every page after it
+// (next_url). It stops with errTooMany once more than max have come in.
+func fetchPages[T any](ctx context.Context, c *Client, full string, max int) ([]T, error) {
+	it := newIter[T](c, full, max)
+	var all []T
+	for it.Next(ctx) {
+		all = append(all, it.Item())
+	}
+	if it.Err() == ErrTooManyItems {
+		return nil, errTooMany
+	}
+	return all, it.Err()
+}
+
+// MaxAllItems caps a raw list fetched with Paging.All.
+const MaxAllItems = 10000
+
+// Paging picks what a raw list call returns: the first page (zero value),
+// the page at Cursor, or with All the pages from there on until at least
+// MaxAllItems results are in.
+type Paging struct {
+	Cursor string
+	All    bool
+}
+
+// ErrInvalidCursor is returned for a cursor that isn't one of ours or
+// belongs to another endpoint.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor turns a next_url into an opaque cursor for our own
+// clients: its path and query, minus any apiKey, base64url encoded.
+func encodeCursor(next string) string {
+	u, err := url.Parse(next)
+	if err != nil {
+		return ""
+	}
+	q := u.Query()
+	q.Del("apiKey")
+	return base64.RawURLEncoding.EncodeToString([]byte(u.Path + "?" + q.Encode()))
+}
+
+// cursorURL is the full URL a cursor from encodeCursor stands for; it has
+// to be a page of path.
+func (c *Client) cursorURL(path, cursor string) (string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", ErrInvalidCursor
+	}
+	u, err := url.Parse(string(raw))
+	if err != nil || u.Host != "" || !strings.HasSuffix(u.Path, path) {
+		return "", ErrInvalidCursor
+	}
+	base, err := url.Parse(c.baseURL)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme, u.Host = base.Scheme, base.Host
+	full := c.nextURL(u.String())
+	if full == "" {
+		return "", ErrInvalidCursor
+	}
+	return full, nil
+}
+
+// fetchList fetches a raw list response of path as Paging asks. Massive's
+// next_url is replaced by next_cursor, present while there are more pages.
+func (c *Client) fetchList(ctx context.Context, path string, extra map[string]string, p Paging) (map[string]interface{}, error) {
+	full := c.buildURL(path, extra)
+	if p.Cursor != "" {
+		var err error
+		if full, err = c.cursorURL(path, p.Cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	if !p.All {
+		resp, err := c.fetchRaw(ctx, full)
+		if err != nil {
+			return nil, err
+		}
+		next, _ := resp["next_url"].(string)
+		delete(resp, "next_url")
+		if next != "" {
+			resp["next_cursor"] = encodeCursor(next)
+		}
+		return resp, nil
+	}
+
+	results := []json.RawMessage{}
+	var requestID, next string
+	for full != "" && len(results) < MaxAllItems {
+		var page Page[json.RawMessage]
+		if err := c.fetchInto(ctx, full, &page); err != nil {
+			return nil, err
+		}
+		if requestID == "" {
+			requestID = page.RequestID
+		}
+		results = append(results, page.Results...)
+		full, next = "", ""
+		if page.NextURL != "" && len(page.Results) > 0 {
+			if full = c.nextURL(page.NextURL); full != "" {
+				next = page.NextURL
+			}
+		}
+	}
+	resp := map[string]interface{}{
+		"status":     "OK",
+		"request_id": requestID,
+		"count":      len(results),
+		"results":    results,
+	}
+	if next != "" {
+		resp["next_cursor"] = encodeCursor(next)
+	}
+	return resp, nil
+}
@@ -0,0 +1,244 @@
+package massive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// TickerDetails is a ticker's reference data. Share counts come as JSON
+// numbers of any shape, so they are float64 like MarketCap.
+type TickerDetails struct {
+	Ticker                      string  `json:"ticker"`
+	Name                        string  `json:"name"`
+	Market                      string  `json:"market"`
+	Locale                      string  `json:"locale"`
+	Type                        string  `json:"type"`
+	Active                      bool    `json:"active"`
+	PrimaryExchange             string  `json:"primary_exchange"`
+	CurrencyName                string  `json:"currency_name"`
+	CIK                         string  `json:"cik"`
+	CompositeFIGI               string  `json:"composite_figi"`
+	ShareClassFIGI              string  `json:"share_class_figi"`
+	MarketCap                   float64 `json:"market_cap"`
+	WeightedSharesOutstanding   float64 `json:"weighted_shares_outstanding"`
+	ShareClassSharesOutstanding float64 `json:"share_class_shares_outstanding"`
+	SICCode                     string  `json:"sic_code"`
+	SICDescription              string  `json:"sic_description"`
+	Description                 string  `json:"description"`
+	HomepageURL                 string  `json:"homepage_url"`
+	ListDate                    string  `json:"list_date"`
+	TotalEmployees              int64   `json:"total_employees"`
+	PhoneNumber                 string  `json:"phone_number"`
+	Address                     struct {
+		Address1   string `json:"address1"`
+		City       string `json:"city"`
+		State      string `json:"state"`
+		PostalCode string `json:"postal_code"`
+	} `json:"address"`
+	Branding struct {
+		LogoURL string `json:"logo_url"`
+		IconURL string `json:"icon_url"`
+	} `json:"branding"`
+}
+
+// Details is the typed form of GetTickerDetails.
+func (c *Client) Details(ctx context.Context, symbol string) (*TickerDetails, error) {
+	var resp struct {
+		Status  string         `json:"status"`
+		Results *TickerDetails `json:"results"`
+	}
+	full := c.buildURL(fmt.Sprintf("/v3/reference/tickers/%s", symbol), nil)
+	if err := c.fetchInto(ctx, full, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Results == nil {
+		return nil, fmt.Errorf("no details for %s", symbol)
+	}
+	return resp.Results, nil
+}
+
+// Dividend is one cash distribution. Dates are YYYY-MM-DD.
+type Dividend struct {
+	Ticker          string  `json:"ticker"`
+	CashAmount      float64 `json:"cash_amount"`
+	Currency        string  `json:"currency"`
+	DeclarationDate string  `json:"declaration_date"`
+	ExDividendDate  string  `json:"ex_dividend_date"`
+	RecordDate      string  `json:"record_date"`
+	PayDate         string  `json:"pay_date"`
+	Frequency       int     `json:"frequency"`
+	DividendType    string  `json:"dividend_type"`
+}
+
+// Dividends is the typed form of GetDividends for one ticker, with an
+// ex-dividend date on or after exDateFrom, oldest first.
+func (c *Client) Dividends(ctx context.Context, ticker, exDateFrom string) ([]Dividend, error) {
+	full := c.buildURL("/v3/reference/dividends", map[string]string{
+		"ticker":               ticker,
+		"ex_dividend_date.gte": exDateFrom,
+		"order":                "asc",
+		"sort":                 "ex_dividend_date",
+		"limit":                "1000",
+	})
+	divs, err := fetchPages[Dividend](ctx, c, full, MaxListResults)
+	if err == errTooMany {
+		return nil, fmt.Errorf("more than %d dividends for %s", MaxListResults, ticker)
+	}
+	return divs, err
+}
+
+// ListDividends is the typed form of GetDividends: one page.
+func (c *Client) ListDividends(ctx context.Context, p ListParams) (*Page[Dividend], error) {
+	return fetchPage[Dividend](ctx, c, "/v3/reference/dividends", p)
+}
+
+// IterDividends walks every page of ListDividends, up to max results.
+func (c *Client) IterDividends(p ListParams, max int) *Iter[Dividend] {
+	return iterList[Dividend](c, "/v3/reference/dividends", p, max)
+}
+
+// Split is one stock split: split_from old shares became split_to new ones
+// on ExecutionDate (YYYY-MM-DD).
+type Split struct {
+	ID            string  `json:"id"`
+	Ticker        string  `json:"ticker"`
+	ExecutionDate string  `json:"execution_date"`
+	SplitFrom     float64 `json:"split_from"`
+	SplitTo       float64 `json:"split_to"`
+}
+
+// SplitsSince returns the splits of every ticker executed on or after date
+// (YYYY-MM-DD), oldest first, all pages.
+func (c *Client) SplitsSince(ctx context.Context, date string) ([]Split, error) {
+	full := c.buildURL("/v3/reference/splits", map[string]string{
+		"execution_date.gte": date,
+		"order":              "asc",
+		"sort":               "execution_date",
+		"limit":              "1000",
+	})
+	splits, err := fetchPages[Split](ctx, c, full, MaxListResults)
+	if err == errTooMany {
+		return nil, fmt.Errorf("more than %d splits since %s", MaxListResults, date)
+	}
+	return splits, err
+}
+
+// IPO is one listing, upcoming or past. Dates are YYYY-MM-DD.
+type IPO struct {
+	Ticker            string   `json:"ticker"`
+	IssuerName        string   `json:"issuer_name"`
+	SecurityType      string   `json:"security_type"`
+	SecurityDesc      string   `json:"security_description"`
+	IPOStatus         string   `json:"ipo_status"`
+	AnnouncedDate     string   `json:"announced_date"`
+	ListingDate       string   `json:"listing_date"`
+	IssueStartDate    string   `json:"issue_start_date"`
+	IssueEndDate      string   `json:"issue_end_date"`
+	LastUpdated       string   `json:"last_updated"`
+	PrimaryExchange   string   `json:"primary_exchange"`
+	CurrencyCode      string   `json:"currency_code"`
+	ISIN              string   `json:"isin"`
+	USCode            string   `json:"us_code"`
+	FinalIssuePrice   *float64 `json:"final_issue_price"`
+	LowestOfferPrice  *float64 `json:"lowest_offer_price"`
+	HighestOfferPrice *float64 `json:"highest_offer_price"`
+	MinSharesOffered  *float64 `json:"min_shares_offered"`
+	MaxSharesOffered  *float64 `json:"max_shares_offered"`
+	TotalOfferSize    *float64 `json:"total_offer_size"`
+	SharesOutstanding *float64 `json:"shares_outstanding"`
+	LotSize           *float64 `json:"lot_size"`
+}
+
+// ListIPOs is the typed form of GetIPOs: one page.
+func (c *Client) ListIPOs(ctx context.Context, p ListParams) (*Page[IPO], error) {
+	return fetchPage[IPO](ctx, c, "/v3/reference/ipos", p)
+}
+
+// IterIPOs walks every page of ListIPOs, up to max results.
+func (c *Client) IterIPOs(p ListParams, max int) *Iter[IPO] {
+	return iterList[IPO](c, "/v3/reference/ipos", p, max)
+}
+
+// Exchange is one venue from the exchanges reference.
+type Exchange struct {
+	ID            int    `json:"id"`
+	Type          string `json:"type"`
+	AssetClass    string `json:"asset_class"`
+	Locale        string `json:"locale"`
+	Name          string `json:"name"`
+	Acronym       string `json:"acronym"`
+	MIC           string `json:"mic"`
+	OperatingMIC  string `json:"operating_mic"`
+	ParticipantID string `json:"participant_id"`
+	URL           string `json:"url"`
+}
+
+// ListExchanges is the typed form of GetExchanges; assetClass and locale
+// may be empty.
+func (c *Client) ListExchanges(ctx context.Context, assetClass, locale string) ([]Exchange, error) {
+	var resp Page[Exchange]
+	full := c.buildURL("/v3/reference/exchanges", map[string]string{
+		"asset_class": assetClass,
+		"locale":      locale,
+	})
+	if err := c.fetchInto(ctx, full, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+// Condition is one trade or quote condition code.
+type Condition struct {
+	ID           int               `json:"id"`
+	Type         string            `json:"type"`
+	Name         string            `json:"name"`
+	Abbreviation string            `json:"abbreviation"`
+	Description  string            `json:"description"`
+	AssetClass   string            `json:"asset_class"`
+	DataTypes    []string          `json:"data_types"`
+	Legacy       bool              `json:"legacy"`
+	SIPMapping   map[string]string `json:"sip_mapping"`
+	UpdateRules  json.RawMessage   `json:"update_rules,omitempty"`
+}
+
+// ListConditions is the typed form of GetConditions: one page. Filters
+// takes asset_class, data_type, id and sip.
+func (c *Client) ListConditions(ctx context.Context, p ListParams) (*Page[Condition], error) {
+	return fetchPage[Condition](ctx, c, "/v3/reference/conditions", p)
+}
+
+// IterConditions walks every page of ListConditions, up to max results.
+func (c *Client) IterConditions(p ListParams, max int) *Iter[Condition] {
+	return iterList[Condition](c, "/v3/reference/conditions", p, max)
+}
+
+// TickerRef is one row of the ticker reference list.
+type TickerRef struct {
+	Ticker          string `json:"ticker"`
+	Name            string `json:"name"`
+	Market          string `json:"market"`
+	Type            string `json:"type"`
+	PrimaryExchange string `json:"primary_exchange"`
+	Active          bool   `json:"active"`
+}
+
+// MaxTickers caps ListTickers.
+const MaxTickers = 100000
+
+// ListTickers returns every active ticker of market (e.g. "stocks"), all
+// pages.
+func (c *Client) ListTickers(ctx context.Context, market string) ([]TickerRef, error) {
+	full := c.buildURL("/v3/reference/tickers", map[string]string{
+		"market": market,
+		"active": "true",
+		"order":  "asc",
+		"sort":   "ticker",
+		"limit":  "1000",
+	})
+	refs, err := fetchPages[TickerRef](ctx, c, full, MaxTickers)
+	if err == errTooMany {
+		return nil, fmt.Errorf("more than %d %s tickers", MaxTickers, market)
+	}
+	return refs, err
+}
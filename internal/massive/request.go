@@ -0,0 +1,213 @@
+package massive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Retries of one request and the backoff before the first; it doubles
+// after each. A Retry-After from Massive replaces the backoff, up to
+// maxRetryWait.
+const (
+	maxRetries   = 3
+	retryBackoff = 500 * time.Millisecond
+	maxRetryWait = 30 * time.Second
+)
+
+// An endpoint's circuit opens after breakerThreshold failed requests in a
+// row (each after its retries) and is probed again after breakerCooldown.
+const (
+	breakerThreshold = 5
+	breakerCooldown  = 30 * time.Second
+)
+
+// Error is a failed response from Massive.
+type Error struct {
+	StatusCode int
+	Message    string // Massive's error or message field, else the body
+	RequestID  string
+	RetryAfter time.Duration // from the Retry-After header, if any
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("massive: %d %s", e.StatusCode, e.Message)
+	if e.RequestID != "" {
+		msg += " (request " + e.RequestID + ")"
+	}
+	return msg
+}
+
+// Temporary reports whether the same request may succeed later.
+func (e *Error) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// NetError is a request that got no (complete) response from Massive: a
+// DNS, dial, TLS or connection failure.
+type NetError struct {
+	Err error
+}
+
+func (e *NetError) Error() string { return "massive: " + e.Err.Error() }
+
+func (e *NetError) Unwrap() error { return e.Err }
+
+// StatusOf is the HTTP status of a Massive error, 0 for other errors.
+func StatusOf(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+	return 0
+}
+
+func newError(resp *http.Response, body []byte) *Error {
+	e := &Error{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+	var parsed struct {
+		Error     string `json:"error"`
+		Message   string `json:"message"`
+		RequestID string `json:"request_id"`
+	}
+	if json.Unmarshal(body, &parsed) == nil {
+		e.RequestID = parsed.RequestID
+		e.Message = parsed.Error
+		if e.Message == "" {
+			e.Message = parsed.Message
+		}
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+		if len(body) > 0 && len(body) <= 200 {
+			e.Message = string(body)
+		}
+	}
+	return e
+}
+
+// retryAfter parses a Retry-After of seconds or an HTTP date.
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+// do GETs fullURL and returns the body of a 2xx, through the circuit of
+// its endpoint.
+func (c *Client) do(ctx context.Context, fullURL string) ([]byte, error) {
+	endpoint := endpointOf(fullURL)
+	if err := c.breakers.Allow(endpoint); err != nil {
+		return nil, fmt.Errorf("massive %s: %w", endpoint, err)
+	}
+	body, err := c.retry(ctx, fullURL)
+	// A request its caller gave up on says nothing about Massive
+	if ctx.Err() == nil {
+		var e *Error
+		failed := err != nil && (!errors.As(err, &e) || e.Temporary())
+		c.breakers.Report(endpoint, !failed)
+	}
+	return body, err
+}
+
+// endpointOf names the endpoint of a URL for its circuit: the path up to
+// the first parameter (a ticker, a date...), e.g. /v2/aggs/ticker.
+func endpointOf(fullURL string) string {
+	u, err := url.Parse(fullURL)
+	if err != nil {
+		return fullURL
+	}
+	var path string
+	for _, seg := range strings.Split(strings.Trim(u.Path, "/"), "/") {
+		if strings.IndexFunc(seg, func(r rune) bool { return unicode.IsUpper(r) || unicode.IsDigit(r) }) >= 0 &&
+			strings.IndexFunc(seg, unicode.IsLower) < 0 {
+			break
+		}
+		path += "/" + seg
+	}
+	return path
+}
+
+// retry makes the request, retrying rate limits, 5xxs and network errors
+// with backoff while ctx allows.
+func (c *Client) retry(ctx context.Context, fullURL string) ([]byte, error) {
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		body, err := c.get(ctx, fullURL)
+		if err == nil || attempt == maxRetries || ctx.Err() != nil {
+			return body, err
+		}
+
+		wait := withJitter(backoff)
+		var e *Error
+		if errors.As(err, &e) {
+			if !e.Temporary() {
+				return nil, err
+			}
+			if e.RetryAfter > 0 {
+				wait = e.RetryAfter
+			}
+		}
+		if wait > maxRetryWait {
+			return nil, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, err
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// get makes one attempt.
+func (c *Client) get(ctx context.Context, fullURL string) ([]byte, error) {
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fullURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		// *url.Error would repeat the URL
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			err = ue.Err
+		}
+		return nil, &NetError{Err: err}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &NetError{Err: err}
+	}
+	if resp.StatusCode >= 400 {
+		return nil, newError(resp, body)
+	}
+	return body, nil
+}
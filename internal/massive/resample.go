@@ -0,0 +1,191 @@
+package massive
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timespans in increasing size, as used in the aggregates URL.
+var Timespans = []string{"second", "minute", "hour", "day", "week", "month", "quarter", "year"}
+
+func timespanRank(ts string) int {
+	for i, t := range Timespans {
+		if t == ts {
+			return i
+		}
+	}
+	return -1
+}
+
+// ValidTimespan reports whether ts is one of Timespans.
+func ValidTimespan(ts string) bool {
+	return timespanRank(ts) >= 0
+}
+
+// Period is a resampling target such as "week" or "15minute".
+type Period struct {
+	Multiplier int
+	Timespan   string
+}
+
+func (p Period) String() string {
+	if p.Multiplier == 1 {
+		return p.Timespan
+	}
+	return strconv.Itoa(p.Multiplier) + p.Timespan
+}
+
+// ParsePeriod parses "<n><timespan>", n defaulting to 1.
+func ParsePeriod(s string) (Period, error) {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	p := Period{Multiplier: 1, Timespan: strings.ToLower(s[i:])}
+	if i > 0 {
+		n, err := strconv.Atoi(s[:i])
+		if err != nil || n < 1 || n > 1000 {
+			return Period{}, fmt.Errorf("invalid resample multiplier in %q", s)
+		}
+		p.Multiplier = n
+	}
+	if !ValidTimespan(p.Timespan) || p.Timespan == "second" {
+		return Period{}, fmt.Errorf("invalid resample timespan in %q", s)
+	}
+	return p, nil
+}
+
+// CoarserThan reports whether bars of size (multiplier, timespan) can be
+// resampled into p: p must be longer and a whole number of those bars, so
+// every bar falls into exactly one bucket.
+func (p Period) CoarserThan(multiplier int, timespan string) bool {
+	if multiplier < 1 || !ValidTimespan(timespan) {
+		return false
+	}
+	pd, pm := length(p.Multiplier, p.Timespan)
+	sd, sm := length(multiplier, timespan)
+	switch {
+	case pm > 0 && sm > 0:
+		return pm > sm && pm%sm == 0
+	case pm > 0:
+		// Months are whole days, so the bars must tile a day.
+		return sd <= 24*time.Hour && (24*time.Hour)%sd == 0
+	case sm > 0:
+		return false
+	}
+	return pd > sd && pd%sd == 0
+}
+
+// length is the size of n timespans: a duration for second through week,
+// or a number of months for month, quarter and year.
+func length(n int, timespan string) (time.Duration, int) {
+	switch timespan {
+	case "second":
+		return time.Duration(n) * time.Second, 0
+	case "minute":
+		return time.Duration(n) * time.Minute, 0
+	case "hour":
+		return time.Duration(n) * time.Hour, 0
+	case "day":
+		return time.Duration(n) * 24 * time.Hour, 0
+	case "week":
+		return time.Duration(n) * 7 * 24 * time.Hour, 0
+	case "month":
+		return 0, n
+	case "quarter":
+		return 0, 3 * n
+	}
+	return 0, 12 * n
+}
+
+// Resample merges time-ordered bars into p-sized buckets in the loc time
+// zone. Intraday buckets never span two days; weeks start on Monday. A
+// bucket's time is its start.
+func Resample(bars []Agg, p Period, loc *time.Location) []Agg {
+	out := make([]Agg, 0, len(bars)/2+1)
+	var cur *Agg
+	var curKey int64
+	var pv float64 // price * volume of the current bucket, for its VWAP
+
+	for _, b := range bars {
+		key, start := bucket(time.UnixMilli(b.Timestamp).In(loc), p)
+		if cur == nil || key != curKey {
+			if cur != nil {
+				finish(cur, pv)
+			}
+			out = append(out, Agg{Open: b.Open, High: b.High, Low: b.Low, Timestamp: start.UnixMilli()})
+			cur, curKey, pv = &out[len(out)-1], key, 0
+		}
+		if b.High > cur.High {
+			cur.High = b.High
+		}
+		if b.Low < cur.Low {
+			cur.Low = b.Low
+		}
+		cur.Close = b.Close
+		cur.Volume += b.Volume
+		cur.Transactions += b.Transactions
+		price := b.VWAP
+		if price <= 0 {
+			price = b.Close
+		}
+		pv += price * b.Volume
+	}
+	if cur != nil {
+		finish(cur, pv)
+	}
+	return out
+}
+
+func finish(a *Agg, pv float64) {
+	if a.Volume > 0 {
+		a.VWAP = pv / a.Volume
+	}
+}
+
+// bucket returns an id for the p-sized bucket containing t and its start.
+func bucket(t time.Time, p Period) (int64, time.Time) {
+	n := p.Multiplier
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	// days since 1970-01-01 by the calendar, whatever the zone's offset
+	days := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC).Unix() / 86400
+
+	switch p.Timespan {
+	case "minute", "hour":
+		span := time.Duration(n) * time.Minute
+		if p.Timespan == "hour" {
+			span = time.Duration(n) * time.Hour
+		}
+		k := int64(t.Sub(day) / span)
+		start := day.Add(time.Duration(k) * span)
+		return start.UnixMilli(), start
+
+	case "day":
+		k := days / int64(n)
+		return k, day.AddDate(0, 0, -int(days%int64(n)))
+
+	case "week":
+		// 1970-01-05 was a Monday
+		back := (int(day.Weekday()) + 6) % 7
+		monday := day.AddDate(0, 0, -back)
+		weeks := (days - int64(back) - 4) / 7
+		k := weeks / int64(n)
+		return k, monday.AddDate(0, 0, -7*int(weeks%int64(n)))
+
+	case "month", "quarter":
+		months := n
+		if p.Timespan == "quarter" {
+			months = 3 * n
+		}
+		m := int64(t.Year())*12 + int64(t.Month()) - 1
+		k := m / int64(months)
+		first := k * int64(months)
+		return k, time.Date(int(first/12), time.Month(first%12+1), 1, 0, 0, 0, 0, t.Location())
+
+	default: // year
+		k := int64(t.Year()) / int64(n)
+		return k, time.Date(int(k)*n, 1, 1, 0, 0, 0, 0, t.Location())
+	}
+}
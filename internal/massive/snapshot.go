@@ -0,0 +1,74 @@
+package massive
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SnapshotBar is a day / minute / previous-day bar inside a ticker snapshot.
+type SnapshotBar struct {
+	Open              float64 `json:"o"`
+	High              float64 `json:"h"`
+	Low               float64 `json:"l"`
+	Close             float64 `json:"c"`
+	Volume            float64 `json:"v"`
+	VWAP              float64 `json:"vw"`
+	AccumulatedVolume float64 `json:"av"`
+	Timestamp         int64   `json:"t"` // unix ms, minute bars only
+}
+
+// SnapshotTrade is the last trade inside a ticker snapshot.
+type SnapshotTrade struct {
+	Conditions []int   `json:"c"`
+	ID         string  `json:"i"`
+	Price      float64 `json:"p"`
+	Size       float64 `json:"s"`
+	Timestamp  int64   `json:"t"` // unix ns
+	Exchange   int     `json:"x"`
+}
+
+// TickerSnapshot is one stock's snapshot.
+type TickerSnapshot struct {
+	Ticker           string        `json:"ticker"`
+	TodaysChange     float64       `json:"todaysChange"`
+	TodaysChangePerc float64       `json:"todaysChangePerc"`
+	Updated          int64         `json:"updated"` // unix ns
+	Day              SnapshotBar   `json:"day"`
+	Min              SnapshotBar   `json:"min"`
+	PrevDay          SnapshotBar   `json:"prevDay"`
+	LastTrade        SnapshotTrade `json:"lastTrade"`
+}
+
+// StockSnapshot is the typed form of GetTickerSnapshot.
+func (c *Client) StockSnapshot(ctx context.Context, stocksTicker string) (*TickerSnapshot, error) {
+	var resp struct {
+		Status string          `json:"status"`
+		Ticker *TickerSnapshot `json:"ticker"`
+	}
+	full := c.buildURL(fmt.Sprintf("/v2/snapshot/locale/us/markets/stocks/tickers/%s", stocksTicker), nil)
+	if err := c.fetchInto(ctx, full, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Ticker == nil {
+		return nil, fmt.Errorf("no snapshot for %s", stocksTicker)
+	}
+	return resp.Ticker, nil
+}
+
+// StockSnapshots fetches the snapshots of several stocks in one request,
+// or of the whole market when stocksTickers is empty. Tickers without data
+// are simply missing from the result.
+func (c *Client) StockSnapshots(ctx context.Context, stocksTickers []string) ([]TickerSnapshot, error) {
+	var resp struct {
+		Status  string           `json:"status"`
+		Tickers []TickerSnapshot `json:"tickers"`
+	}
+	full := c.buildURL("/v2/snapshot/locale/us/markets/stocks/tickers", map[string]string{
+		"tickers": strings.Join(stocksTickers, ","),
+	})
+	if err := c.fetchInto(ctx, full, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Tickers, nil
+}
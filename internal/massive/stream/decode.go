@@ -0,0 +1,136 @@
+package stream
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync/atomic"
+)
+
+// Stats are process-wide decode counters.
+type Stats struct {
+	Frames          int64 `json:"frames"`
+	Events          int64 `json:"events"`
+	MalformedFrames int64 `json:"malformed_frames"`
+	MalformedEvents int64 `json:"malformed_events"`
+}
+
+var (
+	framesTotal     atomic.Int64
+	eventsTotal     atomic.Int64
+	malformedFrames atomic.Int64
+	malformedEvents atomic.Int64
+)
+
+// Counters returns the decode counters since process start.
+func Counters() Stats {
+	return Stats{
+		Frames:          framesTotal.Load(),
+		Events:          eventsTotal.Load(),
+		MalformedFrames: malformedFrames.Load(),
+		MalformedEvents: malformedEvents.Load(),
+	}
+}
+
+// Parse decodes a frame without touching the counters. Events that decode are
+// returned even when others in the same frame are malformed; err describes
+// the first problem.
+func Parse(frame []byte) ([]Event, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(frame, &raws); err != nil {
+		return nil, fmt.Errorf("frame is not a JSON array: %w", err)
+	}
+
+	events := make([]Event, 0, len(raws))
+	var firstErr error
+	for _, raw := range raws {
+		e, err := parseEvent(raw)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, firstErr
+}
+
+// Decode parses a frame from source (a cluster name used in logs) and keeps
+// the counters. Malformed data is logged and dropped, never forwarded.
+func Decode(source string, frame []byte) []Event {
+	framesTotal.Add(1)
+
+	var raws []json.RawMessage
+	if err := json.Unmarshal(frame, &raws); err != nil {
+		malformedFrames.Add(1)
+		log.Printf("[%s] Dropping malformed frame: %v", source, err)
+		return nil
+	}
+
+	events := make([]Event, 0, len(raws))
+	for _, raw := range raws {
+		e, err := parseEvent(raw)
+		if err != nil {
+			malformedEvents.Add(1)
+			log.Printf("[%s] Dropping malformed event: %v", source, err)
+			continue
+		}
+		events = append(events, e)
+	}
+	eventsTotal.Add(int64(len(events)))
+	return events
+}
+
+func parseEvent(raw json.RawMessage) (Event, error) {
+	var head struct {
+		Ev string `json:"ev"`
+	}
+	if err := json.Unmarshal(raw, &head); err != nil {
+		return Event{}, fmt.Errorf("event is not an object: %w", err)
+	}
+
+	e := Event{Type: head.Ev, Raw: raw}
+	switch head.Ev {
+	case TypeTrade:
+		var t Trade
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return Event{}, fmt.Errorf("trade: %w", err)
+		}
+		e.Trade, e.Symbol = &t, t.Symbol
+
+	case TypeMinuteAgg, TypeSecondAgg:
+		var a Aggregate
+		if err := json.Unmarshal(raw, &a); err != nil {
+			return Event{}, fmt.Errorf("aggregate: %w", err)
+		}
+		e.Aggregate, e.Symbol = &a, a.Symbol
+
+	case TypeIndexValue:
+		var v IndexValue
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return Event{}, fmt.Errorf("index value: %w", err)
+		}
+		e.Index, e.Symbol = &v, v.Symbol
+
+	case TypeStatus:
+		var s Status
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return Event{}, fmt.Errorf("status: %w", err)
+		}
+		e.Status = &s
+		return e, nil
+
+	case "":
+		return Event{}, errors.New("event has no ev field")
+
+	default:
+		return Event{}, fmt.Errorf("unknown event type %q", head.Ev)
+	}
+
+	if e.Symbol == "" {
+		return Event{}, fmt.Errorf("%s event has no symbol", head.Ev)
+	}
+	return e, nil
+}
@@ -0,0 +1,168 @@
+/*
+Package stream is the typed model of the Massive websocket feed.
+Frames are JSON arrays of events; Decode turns them into Events so downstream
+code (hub routing, recording, alerts...) never has to re-parse "ev", "sym", "p".
+*/
+
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// Event types as sent in the "ev" field.
+const (
+	TypeTrade      = "T"
+	TypeMinuteAgg  = "AM"
+	TypeSecondAgg  = "A"
+	TypeIndexValue = "V"
+	TypeStatus     = "status"
+)
+
+// Trade is a stocks trade (ev "T").
+type Trade struct {
+	Symbol     string  `json:"sym"`
+	Exchange   int     `json:"x"`
+	ID         string  `json:"i"`
+	Tape       int     `json:"z"`
+	Price      float64 `json:"p"`
+	Size       float64 `json:"s"`
+	Conditions []int   `json:"c,omitempty"`
+	Timestamp  int64   `json:"t"` // unix ms
+	Sequence   int64   `json:"q"`
+}
+
+// Aggregate is a minute (ev "AM") or second (ev "A") bar. Stocks and indices
+// share the shape; indices leave the volume fields empty.
+type Aggregate struct {
+	Symbol            string  `json:"sym"`
+	Volume            float64 `json:"v"`
+	AccumulatedVolume float64 `json:"av"`
+	OfficialOpen      float64 `json:"op"`
+	VWAP              float64 `json:"vw"`
+	Open              float64 `json:"o"`
+	Close             float64 `json:"c"`
+	High              float64 `json:"h"`
+	Low               float64 `json:"l"`
+	DayVWAP           float64 `json:"a"`
+	AverageSize       float64 `json:"z"`
+	Start             int64   `json:"s"` // unix ms
+	End               int64   `json:"e"` // unix ms
+}
+
+// IndexValue is an index tick (ev "V"). Massive puts the ticker in "T".
+type IndexValue struct {
+	Symbol    string  `json:"T"`
+	Value     float64 `json:"val"`
+	Timestamp int64   `json:"t"` // unix ms
+}
+
+// Statuses our own upstream supervisors emit. These are the only status
+// events clients see: Massive's (auth, subscribe acks...) describe the
+// shared connection, including other users' tickers.
+const (
+	StatusUpstreamConnected    = "upstream_connected"
+	StatusUpstreamDisconnected = "upstream_disconnected"
+)
+
+// Status is a control message from Massive (auth, subscribe acks...) or from
+// our own upstream supervisor.
+type Status struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// Event is one decoded element of a frame. Exactly one of the typed pointers
+// is set, matching Type.
+type Event struct {
+	Type   string
+	Symbol string
+
+	Trade     *Trade
+	Aggregate *Aggregate
+	Index     *IndexValue
+	Status    *Status
+
+	// Raw is the event exactly as received so it can be relayed to clients
+	// without re-encoding.
+	Raw json.RawMessage
+}
+
+// Time returns the event's own timestamp in unix ms (bar start for
+// aggregates), or 0 for status events.
+func (e Event) Time() int64 {
+	switch {
+	case e.Trade != nil:
+		return e.Trade.Timestamp
+	case e.Aggregate != nil:
+		return e.Aggregate.Start
+	case e.Index != nil:
+		return e.Index.Timestamp
+	}
+	return 0
+}
+
+// NewTrade builds a trade event as Massive would send it.
+func NewTrade(t Trade) Event {
+	return Event{Type: TypeTrade, Symbol: t.Symbol, Trade: &t, Raw: withEv(TypeTrade, t)}
+}
+
+// NewAggregate builds a minute (TypeMinuteAgg) or second (TypeSecondAgg) bar event.
+func NewAggregate(typ string, a Aggregate) Event {
+	return Event{Type: typ, Symbol: a.Symbol, Aggregate: &a, Raw: withEv(typ, a)}
+}
+
+// NewIndexValue builds an index value event.
+func NewIndexValue(v IndexValue) Event {
+	return Event{Type: TypeIndexValue, Symbol: v.Symbol, Index: &v, Raw: withEv(TypeIndexValue, v)}
+}
+
+// NewStatus builds a status event in the same shape Massive uses.
+func NewStatus(status, cluster, message string) Event {
+	s := Status{Status: status, Message: message, Cluster: cluster}
+	return Event{Type: TypeStatus, Status: &s, Raw: withEv(TypeStatus, s)}
+}
+
+// NewCustom builds a server-generated event (e.g. a bar close) that is routed
+// to symbol's subscribers like any upstream event. payload must encode to a
+// JSON object with at least one field; "ev" and "sym" are added in front.
+func NewCustom(typ, symbol string, payload any) Event {
+	return Event{Type: typ, Symbol: symbol, Raw: withHead(typ, symbol, payload)}
+}
+
+// withEv encodes v (a struct with at least one field) with "ev" as its first key.
+func withEv(typ string, v any) json.RawMessage {
+	return withHead(typ, "", v)
+}
+
+func withHead(typ, symbol string, v any) json.RawMessage {
+	body, _ := json.Marshal(v)
+	ev, _ := json.Marshal(typ)
+
+	raw := make([]byte, 0, len(body)+len(ev)+len(symbol)+16)
+	raw = append(raw, `{"ev":`...)
+	raw = append(raw, ev...)
+	if symbol != "" {
+		sym, _ := json.Marshal(symbol)
+		raw = append(raw, `,"sym":`...)
+		raw = append(raw, sym...)
+	}
+	raw = append(raw, ',')
+	return append(raw, body[1:]...)
+}
+
+// Encode joins events back into a frame (a JSON array).
+func Encode(events []Event) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, e := range events {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.Write(e.Raw)
+	}
+	buf.WriteByte(']')
+	return buf.Bytes()
+}
@@ -1,8 +1,15 @@
 package massive
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/dnhan1707/trader/internal/massive/stream"
 	"github.com/dnhan1707/trader/internal/ws"
 	"github.com/gorilla/websocket"
 )
@@ -13,66 +20,268 @@ const (
 	urlIndices = "wss://socket.massive.com/indices"
 )
 
+const (
+	// Reconnect backoff bounds. The delay doubles after every failed attempt.
+	minBackoff = 1 * time.Second
+	maxBackoff = 60 * time.Second
+
+	// A connection that stayed up at least this long resets the backoff.
+	stableAfter = 30 * time.Second
+
+	// Time allowed for a single write (auth / subscribe) to the upstream.
+	upstreamWriteWait = 10 * time.Second
+
+	// Time allowed for Massive to answer the auth action.
+	authWait = 10 * time.Second
+)
+
+// Stream is the live Massive feed as a feed.Source.
+type Stream struct {
+	APIKey string
+}
+
+// Run connects both clusters and returns once ctx is done.
+func (s *Stream) Run(ctx context.Context, out chan<- []stream.Event, stockSubs, indexSubs <-chan ws.SubRequest) {
+	go ListenIndices(ctx, s.APIKey, out, indexSubs)
+	ListenStocks(ctx, s.APIKey, out, stockSubs)
+}
+
 // ListenStocks handles the Stocks Cluster
-func ListenStocks(apiKey string, hub *ws.Hub, subRequests chan string) {
-	connectAndListen(apiKey, urlStocks, hub, subRequests, "Stocks")
+func ListenStocks(ctx context.Context, apiKey string, out chan<- []stream.Event, subRequests <-chan ws.SubRequest) {
+	connectAndListen(ctx, apiKey, urlStocks, out, subRequests, "Stocks")
 }
 
 // ListenIndices handles the Indices Cluster
-func ListenIndices(apiKey string, hub *ws.Hub, subRequests chan string) {
-	connectAndListen(apiKey, urlIndices, hub, subRequests, "Indices")
+func ListenIndices(ctx context.Context, apiKey string, out chan<- []stream.Event, subRequests <-chan ws.SubRequest) {
+	connectAndListen(ctx, apiKey, urlIndices, out, subRequests, "Indices")
+}
+
+// upstream supervises one cluster connection. It remembers every active
+// subscription so they can be replayed after a reconnect.
+type upstream struct {
+	ctx    context.Context
+	name   string
+	url    string
+	apiKey string
+	out    chan<- []stream.Event // usually hub.Broadcast
+
+	mu   sync.Mutex
+	conn *websocket.Conn // nil while disconnected
+	subs map[string]bool // active channel params, e.g. "T.AAPL"
+}
+
+// Shared logic to avoid code duplication.
+// It runs until ctx is done: a dropped connection is re-dialed with
+// exponential backoff, re-authenticated and re-subscribed.
+func connectAndListen(ctx context.Context, apiKey, url string, out chan<- []stream.Event, subRequests <-chan ws.SubRequest, name string) {
+	u := &upstream{
+		ctx:    ctx,
+		name:   name,
+		url:    url,
+		apiKey: apiKey,
+		out:    out,
+		subs:   make(map[string]bool),
+	}
+
+	// Subscribe requests are accepted even while we are reconnecting,
+	// so the ws clients never block on a dead upstream.
+	go u.handleSubscriptions(subRequests)
+
+	backoff := minBackoff
+	for {
+		connectedAt := time.Now()
+		err := u.session()
+		if ctx.Err() != nil {
+			log.Printf("[%s] Upstream stopped", name)
+			return
+		}
+		if time.Since(connectedAt) >= stableAfter {
+			backoff = minBackoff
+		}
+
+		delay := withJitter(backoff)
+		log.Printf("[%s] Upstream lost: %v (reconnecting in %s)", name, err, delay.Round(time.Millisecond))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			log.Printf("[%s] Upstream stopped", name)
+			return
+		}
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
 }
 
-// Shared logic to avoid code duplication
-func connectAndListen(apiKey, url string, hub *ws.Hub, subRequests chan string, name string) {
-	log.Printf("[%s] Connecting...", name)
-	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+// session dials, authenticates, replays subscriptions and pumps frames into
+// out until the connection fails. It always returns a non-nil error.
+func (u *upstream) session() error {
+	log.Printf("[%s] Connecting...", u.name)
+	conn, _, err := websocket.DefaultDialer.DialContext(u.ctx, u.url, nil)
 	if err != nil {
-		log.Printf("[%s] Connection failed: %v", name, err)
-		return
+		return fmt.Errorf("dial: %w", err)
 	}
 	defer conn.Close()
+	// Closing the connection ends the read pump when we are stopped.
+	stop := context.AfterFunc(u.ctx, func() { conn.Close() })
+	defer stop()
 
-	// Auth
-	if err := conn.WriteJSON(map[string]string{"action": "auth", "params": apiKey}); err != nil {
-		log.Printf("[%s] Auth failed: %v", name, err)
-		return
+	if err := u.authenticate(conn); err != nil {
+		return err
 	}
-	log.Printf("[%s] Authenticated!", name)
-
-	// Read Pump (Background)
-	go func() {
-		for {
-			_, message, err := conn.ReadMessage()
-			if err != nil {
-				log.Printf("[%s] Read error: %v", name, err)
-				return
+	log.Printf("[%s] Authenticated!", u.name)
+
+	// Publish the connection and replay whatever was active before it dropped.
+	u.mu.Lock()
+	u.conn = conn
+	params := make([]string, 0, len(u.subs))
+	for p := range u.subs {
+		params = append(params, p)
+	}
+	var replayErr error
+	if len(params) > 0 {
+		log.Printf("[%s] Resubscribing to %d channels", u.name, len(params))
+		replayErr = u.write(conn, map[string]string{"action": "subscribe", "params": strings.Join(params, ",")})
+	}
+	u.mu.Unlock()
+
+	defer func() {
+		u.mu.Lock()
+		u.conn = nil
+		u.mu.Unlock()
+		u.emitStatus(stream.StatusUpstreamDisconnected, u.name+" feed disconnected")
+	}()
+
+	if replayErr != nil {
+		return fmt.Errorf("resubscribe: %w", replayErr)
+	}
+	u.emitStatus(stream.StatusUpstreamConnected, u.name+" feed connected")
+
+	// Read Pump
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("read: %w", err)
+		}
+		// Push typed events downstream; malformed data stops here.
+		if events := stream.Decode(u.name, message); len(events) > 0 {
+			if !u.send(events) {
+				return u.ctx.Err()
 			}
-			// Push data to the shared Hub
-			hub.Broadcast <- message
 		}
-	}()
+	}
+}
 
-	// Write Loop (Responds to Subscribe Requests)
-	for ticker := range subRequests {
-		log.Printf("[%s] Subscribing to %s", name, ticker)
+// authenticate sends the auth action and waits for Massive to confirm it.
+func (u *upstream) authenticate(conn *websocket.Conn) error {
+	if err := u.write(conn, map[string]string{"action": "auth", "params": u.apiKey}); err != nil {
+		return fmt.Errorf("auth: %w", err)
+	}
 
-		// Determine the channels based on the cluster
-		var params string
-		if name == "Indices" {
-			// Indices: V = Value, A = Aggregates (Per Second)
-			// Example: "V.I:SPX,A.I:SPX"
-			params = "V." + ticker + ",A." + ticker
-		} else {
-			// Stocks: T = Trades, AM = Aggregates (Minute)
-			// Example: "T.AAPL,AM.AAPL"
-			params = "T." + ticker + ",AM." + ticker
+	conn.SetReadDeadline(time.Now().Add(authWait))
+	defer conn.SetReadDeadline(time.Time{})
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return fmt.Errorf("auth: %w", err)
 		}
 
-		msg := map[string]string{
-			"action": "subscribe",
-			"params": params,
+		events, _ := stream.Parse(message)
+		for _, e := range events {
+			if e.Status == nil {
+				continue
+			}
+			switch e.Status.Status {
+			case "auth_success":
+				return nil
+			case "auth_failed":
+				return fmt.Errorf("auth failed: %s", e.Status.Message)
+			}
 		}
-		conn.WriteJSON(msg)
 	}
 }
+
+// Write Loop (Responds to Subscribe / Unsubscribe Requests)
+// The ws.SubscriptionManager only sends the first subscribe and the last
+// unsubscribe for a ticker, so every request here changes upstream state.
+func (u *upstream) handleSubscriptions(subRequests <-chan ws.SubRequest) {
+	for {
+		var req ws.SubRequest
+		select {
+		case r, ok := <-subRequests:
+			if !ok {
+				return
+			}
+			req = r
+		case <-u.ctx.Done():
+			return
+		}
+		params := channelsFor(u.name, req.Ticker)
+
+		u.mu.Lock()
+		for _, p := range params {
+			if req.Action == ws.ActionUnsubscribe {
+				delete(u.subs, p)
+			} else {
+				u.subs[p] = true
+			}
+		}
+		conn := u.conn
+		if conn != nil {
+			log.Printf("[%s] %s %s", u.name, req.Action, req.Ticker)
+			if err := u.write(conn, map[string]string{"action": req.Action, "params": strings.Join(params, ",")}); err != nil {
+				// The read pump will notice the broken connection and the
+				// active set is replayed on reconnect.
+				log.Printf("[%s] %s %s failed: %v", u.name, req.Action, req.Ticker, err)
+			}
+		} else {
+			log.Printf("[%s] Queued %s %s until reconnect", u.name, req.Action, req.Ticker)
+		}
+		u.mu.Unlock()
+	}
+}
+
+// write sends one JSON action. Callers serialize writes through u.mu or by
+// owning the connection exclusively.
+func (u *upstream) write(conn *websocket.Conn, msg map[string]string) error {
+	conn.SetWriteDeadline(time.Now().Add(upstreamWriteWait))
+	return conn.WriteJSON(msg)
+}
+
+// emitStatus tells downstream (and so the ws clients) about the upstream state using the
+// same shape as Massive's own status events.
+func (u *upstream) emitStatus(status, message string) {
+	u.send([]stream.Event{stream.NewStatus(status, strings.ToLower(u.name), message)})
+}
+
+// send pushes events downstream; false means we were stopped first.
+func (u *upstream) send(events []stream.Event) bool {
+	select {
+	case u.out <- events:
+		return true
+	case <-u.ctx.Done():
+		return false
+	}
+}
+
+// channelsFor determines the channels based on the cluster
+func channelsFor(cluster, ticker string) []string {
+	if cluster == "Indices" {
+		// Indices: V = Value, A = Aggregates (Per Second)
+		// Example: "V.I:SPX,A.I:SPX"
+		return []string{"V." + ticker, "A." + ticker}
+	}
+	// Stocks: T = Trades, AM = Aggregates (Minute)
+	// Example: "T.AAPL,AM.AAPL"
+	return []string{"T." + ticker, "AM." + ticker}
+}
+
+// withJitter spreads reconnects over [d/2, d) so that both clusters (and
+// several server instances) don't hammer Massive in lockstep.
+func withJitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + rand.N(half)
+}
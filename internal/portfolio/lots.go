@@ -0,0 +1,207 @@
+/*
+Package portfolio turns a user's trades (paper fills and manually entered
+transactions) into positions, P&L and performance figures. It does no I/O:
+callers load the trades, prices and dividends and pass them in.
+*/
+
+package portfolio
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Method is the lot-relief method used when selling.
+type Method string
+
+const (
+	FIFO    Method = "fifo"
+	LIFO    Method = "lifo"
+	Average Method = "avg"
+)
+
+// ParseMethod accepts fifo, lifo or avg; empty means FIFO.
+func ParseMethod(s string) (Method, bool) {
+	switch Method(s) {
+	case "", FIFO:
+		return FIFO, true
+	case LIFO, Average:
+		return Method(s), true
+	}
+	return "", false
+}
+
+// Trade sources
+const (
+	SourcePaper  = "paper"
+	SourceManual = "manual"
+)
+
+// Trade is one buy or sell.
+type Trade struct {
+	Source string    `json:"source"`
+	ID     int64     `json:"id"`
+	Ticker string    `json:"ticker"`
+	Side   string    `json:"side"` // buy | sell
+	Qty    float64   `json:"qty"`
+	Price  float64   `json:"price"`
+	Fees   float64   `json:"fees"`
+	Time   time.Time `json:"time"`
+}
+
+// Lot is an open purchase. Price includes the buy fees per share.
+type Lot struct {
+	Qty    float64   `json:"qty"`
+	Price  float64   `json:"price"`
+	Opened time.Time `json:"opened"`
+}
+
+// Position is the state of one ticker after replaying its trades. The mark
+// fields are set by MarkAt.
+type Position struct {
+	Ticker      string  `json:"ticker"`
+	Qty         float64 `json:"qty"`
+	CostBasis   float64 `json:"cost_basis"`
+	AvgCost     float64 `json:"avg_cost"`
+	RealizedPnL float64 `json:"realized_pnl"`
+	Lots        []Lot   `json:"lots"`
+
+	Mark          *float64 `json:"mark"`
+	MarketValue   *float64 `json:"market_value"`
+	UnrealizedPnL *float64 `json:"unrealized_pnl"`
+	Sector        string   `json:"sector,omitempty"`
+}
+
+// qtyEpsilon absorbs NUMERIC -> float rounding when a position is closed.
+const qtyEpsilon = 1e-9
+
+// SortTrades orders trades by time, buys before sells at the same instant.
+func SortTrades(trades []Trade) {
+	sort.SliceStable(trades, func(i, j int) bool {
+		if !trades[i].Time.Equal(trades[j].Time) {
+			return trades[i].Time.Before(trades[j].Time)
+		}
+		return trades[i].Side == "buy" && trades[j].Side != "buy"
+	})
+}
+
+// Book replays time-ordered trades and returns every ticker that was ever
+// traded, closed positions included (for their realized P&L). Selling more
+// than is held is an error.
+func Book(trades []Trade, method Method) (map[string]*Position, error) {
+	positions := make(map[string]*Position)
+	for _, t := range trades {
+		p := positions[t.Ticker]
+		if p == nil {
+			p = &Position{Ticker: t.Ticker, Lots: []Lot{}}
+			positions[t.Ticker] = p
+		}
+
+		switch t.Side {
+		case "buy":
+			lot := Lot{Qty: t.Qty, Price: t.Price + t.Fees/t.Qty, Opened: t.Time}
+			if method == Average && len(p.Lots) > 0 {
+				// A single running lot at the average cost.
+				merged := &p.Lots[0]
+				total := merged.Qty + lot.Qty
+				merged.Price = (merged.Qty*merged.Price + lot.Qty*lot.Price) / total
+				merged.Qty = total
+			} else {
+				p.Lots = append(p.Lots, lot)
+			}
+
+		case "sell":
+			if t.Qty > p.qty()+qtyEpsilon {
+				return nil, fmt.Errorf("%s: selling %g on %s but only %g held",
+					t.Ticker, t.Qty, t.Time.Format("2006-01-02"), p.qty())
+			}
+			cost := p.relieve(t.Qty, method)
+			p.RealizedPnL += t.Qty*t.Price - t.Fees - cost
+
+		default:
+			return nil, fmt.Errorf("%s: unknown side %q", t.Ticker, t.Side)
+		}
+	}
+
+	for _, p := range positions {
+		p.Qty, p.CostBasis = 0, 0
+		for _, l := range p.Lots {
+			p.Qty += l.Qty
+			p.CostBasis += l.Qty * l.Price
+		}
+		if p.Qty > qtyEpsilon {
+			p.AvgCost = p.CostBasis / p.Qty
+		} else {
+			p.Qty, p.CostBasis = 0, 0
+		}
+	}
+	return positions, nil
+}
+
+func (p *Position) qty() float64 {
+	var q float64
+	for _, l := range p.Lots {
+		q += l.Qty
+	}
+	return q
+}
+
+// relieve removes qty from the lots and returns the cost of what was removed.
+func (p *Position) relieve(qty float64, method Method) float64 {
+	var cost float64
+	for qty > qtyEpsilon && len(p.Lots) > 0 {
+		i := 0
+		if method == LIFO {
+			i = len(p.Lots) - 1
+		}
+		lot := &p.Lots[i]
+
+		take := qty
+		if lot.Qty < take {
+			take = lot.Qty
+		}
+		cost += take * lot.Price
+		lot.Qty -= take
+		qty -= take
+
+		if lot.Qty <= qtyEpsilon {
+			p.Lots = append(p.Lots[:i], p.Lots[i+1:]...)
+		}
+	}
+	return cost
+}
+
+// MarkAt values the position at price.
+func (p *Position) MarkAt(price float64) {
+	value := p.Qty * price
+	pnl := value - p.CostBasis
+	p.Mark, p.MarketValue, p.UnrealizedPnL = &price, &value, &pnl
+}
+
+// Value is the market value, or the cost basis while there is no mark.
+func (p *Position) Value() float64 {
+	if p.MarketValue != nil {
+		return *p.MarketValue
+	}
+	return p.CostBasis
+}
+
+// HeldBefore is the quantity of ticker held just before t.
+func HeldBefore(trades []Trade, ticker string, t time.Time) float64 {
+	var q float64
+	for _, tr := range trades {
+		if tr.Ticker != ticker || !tr.Time.Before(t) {
+			continue
+		}
+		if tr.Side == "buy" {
+			q += tr.Qty
+		} else {
+			q -= tr.Qty
+		}
+	}
+	if q < qtyEpsilon {
+		return 0
+	}
+	return q
+}
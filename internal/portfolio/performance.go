@@ -0,0 +1,329 @@
+package portfolio
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive"
+)
+
+const tradingDaysPerYear = 252
+
+// DividendCredit is a cash dividend on the shares held before the ex date.
+type DividendCredit struct {
+	Ticker   string  `json:"ticker"`
+	ExDate   string  `json:"ex_dividend_date"`
+	PayDate  string  `json:"pay_date"`
+	PerShare float64 `json:"cash_amount"`
+	Qty      float64 `json:"qty"`
+	Amount   float64 `json:"amount"`
+	Paid     bool    `json:"paid"` // false until the pay date
+}
+
+// CreditDividends matches dividends against the trades. Dates are
+// YYYY-MM-DD in loc (the market's time zone).
+func CreditDividends(trades []Trade, divs []massive.Dividend, loc *time.Location, today string) []DividendCredit {
+	credits := []DividendCredit{}
+	for _, d := range divs {
+		if d.CashAmount <= 0 || d.ExDividendDate == "" || d.ExDividendDate > today {
+			continue
+		}
+		ex, err := time.ParseInLocation("2006-01-02", d.ExDividendDate, loc)
+		if err != nil {
+			continue
+		}
+		qty := HeldBefore(trades, d.Ticker, ex)
+		if qty == 0 {
+			continue
+		}
+		pay := d.PayDate
+		if pay == "" {
+			pay = d.ExDividendDate
+		}
+		credits = append(credits, DividendCredit{
+			Ticker:   d.Ticker,
+			ExDate:   d.ExDividendDate,
+			PayDate:  pay,
+			PerShare: d.CashAmount,
+			Qty:      qty,
+			Amount:   qty * d.CashAmount,
+			Paid:     pay <= today,
+		})
+	}
+	sort.SliceStable(credits, func(i, j int) bool { return credits[i].PayDate < credits[j].PayDate })
+	return credits
+}
+
+// Prices feeds EquityCurve.
+type Prices struct {
+	Closes map[string]map[string]float64 // ticker -> YYYY-MM-DD -> close
+	Marks  map[string]float64            // live prices, used for Today
+	Today  string
+	Loc    *time.Location
+}
+
+// Account is the paper cash account, if the user has one.
+type Account struct {
+	StartingCash float64
+	Opened       string // YYYY-MM-DD
+}
+
+// EquityPoint is the portfolio at the close of one day.
+type EquityPoint struct {
+	Date     string  `json:"date"`
+	Cash     float64 `json:"cash"`
+	Holdings float64 `json:"holdings"`
+	Value    float64 `json:"value"`
+	Flow     float64 `json:"flow"`   // money added (+) or taken out (-) that day
+	Return   float64 `json:"return"` // the day's flow-adjusted return
+	Index    float64 `json:"index"`  // growth of 1 since the first day
+}
+
+// EquityCurve values the portfolio on every day with a price or an event.
+//
+// Paper fills move money between the paper account's cash and its holdings.
+// Manual transactions have no cash account, so a manual buy counts as money
+// added and a manual sell as money taken out. Paid dividends are credited to
+// cash. Returns are flow-adjusted (flows at the start of the day), so
+// chaining them gives the time-weighted return.
+func EquityCurve(trades []Trade, credits []DividendCredit, acct *Account, px Prices) []EquityPoint {
+	day := func(t time.Time) string { return t.In(px.Loc).Format("2006-01-02") }
+
+	first := px.Today
+	if len(trades) > 0 {
+		first = day(trades[0].Time)
+	}
+	if acct != nil && acct.Opened < first {
+		first = acct.Opened
+	}
+
+	dateSet := map[string]bool{px.Today: true}
+	for _, closes := range px.Closes {
+		for d := range closes {
+			dateSet[d] = true
+		}
+	}
+	for _, t := range trades {
+		dateSet[day(t.Time)] = true
+	}
+	for _, c := range credits {
+		if c.Paid {
+			dateSet[c.PayDate] = true
+		}
+	}
+	if acct != nil {
+		dateSet[acct.Opened] = true
+	}
+	var dates []string
+	for d := range dateSet {
+		if d >= first && d <= px.Today {
+			dates = append(dates, d)
+		}
+	}
+	sort.Strings(dates)
+
+	var (
+		points    = make([]EquityPoint, 0, len(dates))
+		cash      float64
+		opened    bool
+		next      int // next trade to apply
+		nextDiv   int
+		qty       = make(map[string]float64)
+		lastPrice = make(map[string]float64)
+		prevValue float64
+		index     = 1.0
+	)
+
+	for _, d := range dates {
+		var flow float64
+
+		if acct != nil && !opened && acct.Opened <= d {
+			cash += acct.StartingCash
+			flow += acct.StartingCash
+			opened = true
+		}
+
+		for ; next < len(trades) && day(trades[next].Time) <= d; next++ {
+			t := trades[next]
+			notional := t.Qty * t.Price
+			if t.Side == "buy" {
+				qty[t.Ticker] += t.Qty
+				if t.Source == SourcePaper {
+					cash -= notional + t.Fees
+				} else {
+					flow += notional + t.Fees
+				}
+			} else {
+				qty[t.Ticker] -= t.Qty
+				if t.Source == SourcePaper {
+					cash += notional - t.Fees
+				} else {
+					flow -= notional - t.Fees
+				}
+			}
+			lastPrice[t.Ticker] = t.Price
+		}
+
+		for ; nextDiv < len(credits) && credits[nextDiv].PayDate <= d; nextDiv++ {
+			if credits[nextDiv].Paid {
+				cash += credits[nextDiv].Amount
+			}
+		}
+
+		var holdings float64
+		for ticker, q := range qty {
+			if c, ok := px.Closes[ticker][d]; ok {
+				lastPrice[ticker] = c
+			}
+			if d == px.Today {
+				if m, ok := px.Marks[ticker]; ok {
+					lastPrice[ticker] = m
+				}
+			}
+			if q > qtyEpsilon {
+				holdings += q * lastPrice[ticker]
+			}
+		}
+
+		value := cash + holdings
+		var ret float64
+		if prevValue+flow > 0 {
+			ret = value/(prevValue+flow) - 1
+		}
+		index *= 1 + ret
+		prevValue = value
+
+		points = append(points, EquityPoint{
+			Date:     d,
+			Cash:     cash,
+			Holdings: holdings,
+			Value:    value,
+			Flow:     flow,
+			Return:   ret,
+			Index:    index,
+		})
+	}
+	return points
+}
+
+// Stats summarizes an equity curve.
+type Stats struct {
+	StartValue     float64  `json:"start_value"`
+	EndValue       float64  `json:"end_value"`
+	NetFlows       float64  `json:"net_flows"`
+	TWR            float64  `json:"time_weighted_return"`
+	MaxDrawdown    float64  `json:"max_drawdown"` // fraction of the peak, >= 0
+	DrawdownPeak   string   `json:"max_drawdown_peak,omitempty"`
+	DrawdownTrough string   `json:"max_drawdown_trough,omitempty"`
+	Sharpe         *float64 `json:"sharpe"` // annualized; nil with fewer than two returns
+	Days           int      `json:"days"`
+}
+
+// Summarize computes the time-weighted return, max drawdown (on the
+// flow-adjusted index, so deposits don't look like gains) and the annualized
+// Sharpe ratio of the daily returns over riskFree (annual rate).
+func Summarize(points []EquityPoint, riskFree float64) Stats {
+	s := Stats{Days: len(points)}
+	if len(points) == 0 {
+		return s
+	}
+	s.StartValue = points[0].Value
+	s.EndValue = points[len(points)-1].Value
+	s.TWR = points[len(points)-1].Index - 1
+
+	peak, peakDate := points[0].Index, points[0].Date
+	for _, p := range points {
+		s.NetFlows += p.Flow
+		if p.Index > peak {
+			peak, peakDate = p.Index, p.Date
+		}
+		if peak > 0 {
+			if dd := (peak - p.Index) / peak; dd > s.MaxDrawdown {
+				s.MaxDrawdown, s.DrawdownPeak, s.DrawdownTrough = dd, peakDate, p.Date
+			}
+		}
+	}
+
+	returns := make([]float64, 0, len(points))
+	for _, p := range points {
+		returns = append(returns, p.Return-riskFree/tradingDaysPerYear)
+	}
+	if len(returns) >= 2 {
+		var mean float64
+		for _, r := range returns {
+			mean += r
+		}
+		mean /= float64(len(returns))
+		var variance float64
+		for _, r := range returns {
+			variance += (r - mean) * (r - mean)
+		}
+		std := math.Sqrt(variance / float64(len(returns)-1))
+		if std > 0 {
+			sharpe := mean / std * math.Sqrt(tradingDaysPerYear)
+			s.Sharpe = &sharpe
+		}
+	}
+	return s
+}
+
+// SectorExposure is the open market value in one sector.
+type SectorExposure struct {
+	Sector  string   `json:"sector"`
+	Value   float64  `json:"value"`
+	Weight  float64  `json:"weight"`
+	Tickers []string `json:"tickers"`
+}
+
+// Exposure groups open positions by Sector ("Unknown" when empty).
+func Exposure(positions []*Position) []SectorExposure {
+	bySector := make(map[string]*SectorExposure)
+	var total float64
+	for _, p := range positions {
+		if p.Qty <= 0 {
+			continue
+		}
+		sector := p.Sector
+		if sector == "" {
+			sector = "Unknown"
+		}
+		e := bySector[sector]
+		if e == nil {
+			e = &SectorExposure{Sector: sector}
+			bySector[sector] = e
+		}
+		e.Value += p.Value()
+		e.Tickers = append(e.Tickers, p.Ticker)
+		total += p.Value()
+	}
+
+	out := make([]SectorExposure, 0, len(bySector))
+	for _, e := range bySector {
+		if total > 0 {
+			e.Weight = e.Value / total
+		}
+		sort.Strings(e.Tickers)
+		out = append(out, *e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Value > out[j].Value })
+	return out
+}
+
+// Since drops the points before from and rebases the index to 1 on the first
+// remaining day.
+func Since(points []EquityPoint, from string) []EquityPoint {
+	i := sort.Search(len(points), func(i int) bool { return points[i].Date >= from })
+	out := append([]EquityPoint(nil), points[i:]...)
+	if len(out) == 0 {
+		return out
+	}
+	base := out[0].Index
+	out[0].Return = 0
+	for j := range out {
+		if base > 0 {
+			out[j].Index /= base
+		}
+	}
+	return out
+}
@@ -0,0 +1,272 @@
+/*
+Package pricestats summarizes a daily bar series over trailing windows
+(1m, ytd, 1y...): range, returns, volatility, drawdown and beta against a
+benchmark. All windows are computed from one series, fetched once for the
+longest of them.
+*/
+
+package pricestats
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive"
+)
+
+const tradingDaysPerYear = 252
+
+// MaxWindows bounds one request; MaxYears the longest window.
+const (
+	MaxWindows = 10
+	MaxYears   = 20
+)
+
+// Window is a trailing period ending today.
+type Window struct {
+	Name  string
+	Start time.Time // midnight in the market's zone, inclusive
+}
+
+// ParseWindows parses a comma-separated list such as "1m,3m,ytd,1y,5y".
+// A window is ytd, mtd or a count of d(ays), w(eeks), m(onths) or y(ears).
+func ParseWindows(list string, now time.Time) ([]Window, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var windows []Window
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		var start time.Time
+		switch name {
+		case "ytd":
+			start = time.Date(now.Year(), 1, 1, 0, 0, 0, 0, now.Location())
+		case "mtd":
+			start = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		default:
+			n, err := strconv.Atoi(name[:len(name)-1])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid window %q", name)
+			}
+			switch name[len(name)-1] {
+			case 'd':
+				start = today.AddDate(0, 0, -n)
+			case 'w':
+				start = today.AddDate(0, 0, -7*n)
+			case 'm':
+				start = today.AddDate(0, -n, 0)
+			case 'y':
+				start = today.AddDate(-n, 0, 0)
+			default:
+				return nil, fmt.Errorf("invalid window %q", name)
+			}
+		}
+		if start.Before(today.AddDate(-MaxYears, 0, 0)) {
+			return nil, fmt.Errorf("window %q is longer than %d years", name, MaxYears)
+		}
+		windows = append(windows, Window{Name: name, Start: start})
+	}
+	if len(windows) == 0 {
+		return nil, fmt.Errorf("no windows requested")
+	}
+	if len(windows) > MaxWindows {
+		return nil, fmt.Errorf("at most %d windows per request", MaxWindows)
+	}
+	return windows, nil
+}
+
+// Earliest is the start of the longest window.
+func Earliest(windows []Window) time.Time {
+	first := windows[0].Start
+	for _, w := range windows[1:] {
+		if w.Start.Before(first) {
+			first = w.Start
+		}
+	}
+	return first
+}
+
+// Stats describes one window. Percentages are in percent; the pointer
+// fields are nil when they can't be computed (too few bars, a flat range,
+// no benchmark).
+type Stats struct {
+	Window          string   `json:"window"`
+	From            string   `json:"from"`
+	To              string   `json:"to"`
+	Bars            int      `json:"bars"`
+	Close           float64  `json:"close"`
+	High            float64  `json:"high"`
+	HighDate        string   `json:"high_date"`
+	Low             float64  `json:"low"`
+	LowDate         string   `json:"low_date"`
+	PctFromHigh     *float64 `json:"percent_from_high"`
+	PctFromLow      *float64 `json:"percent_from_low"`
+	RangePosition   *float64 `json:"range_position"` // where close sits between low (0) and high (100)
+	TotalReturn     *float64 `json:"total_return"`
+	Volatility      *float64 `json:"volatility"` // annualized, of daily log returns
+	AvgVolume       float64  `json:"avg_daily_volume"`
+	MaxDrawdown     float64  `json:"max_drawdown"`
+	DrawdownPeak    string   `json:"max_drawdown_peak,omitempty"`
+	DrawdownTrough  string   `json:"max_drawdown_trough,omitempty"`
+	Beta            *float64 `json:"beta"`
+	BenchmarkReturn *float64 `json:"benchmark_return"`
+}
+
+// Compute summarizes bars (daily, oldest first) over w. bench is the
+// benchmark's daily bars for beta, nil to skip it. Dates are formatted in
+// loc. ok is false when the window has no bars.
+func Compute(bars, bench []massive.Agg, w Window, loc *time.Location) (s Stats, ok bool) {
+	s.Window = w.Name
+	first := sort.Search(len(bars), func(i int) bool { return bars[i].Timestamp >= w.Start.UnixMilli() })
+	in := bars[first:]
+	if len(in) == 0 {
+		return s, false
+	}
+	date := func(ms int64) string { return time.UnixMilli(ms).In(loc).Format("2006-01-02") }
+
+	last := in[len(in)-1]
+	s.From, s.To, s.Bars, s.Close = date(in[0].Timestamp), date(last.Timestamp), len(in), last.Close
+	s.High, s.Low = in[0].High, in[0].Low
+	s.HighDate, s.LowDate = s.From, s.From
+	var volume float64
+	for _, b := range in {
+		if b.High > s.High {
+			s.High, s.HighDate = b.High, date(b.Timestamp)
+		}
+		if b.Low < s.Low {
+			s.Low, s.LowDate = b.Low, date(b.Timestamp)
+		}
+		volume += b.Volume
+	}
+	s.AvgVolume = volume / float64(len(in))
+	if s.High > 0 {
+		s.PctFromHigh = pct(s.Close/s.High - 1)
+	}
+	if s.Low > 0 {
+		s.PctFromLow = pct(s.Close/s.Low - 1)
+	}
+	if s.High > s.Low {
+		s.RangePosition = pct((s.Close - s.Low) / (s.High - s.Low))
+	}
+
+	// The return runs from the close before the window when there is one
+	base := in[0].Open
+	if first > 0 {
+		base = bars[first-1].Close
+	}
+	if base > 0 {
+		s.TotalReturn = pct(s.Close/base - 1)
+	}
+
+	var logs []float64
+	for i := 1; i < len(in); i++ {
+		if in[i-1].Close > 0 && in[i].Close > 0 {
+			logs = append(logs, math.Log(in[i].Close/in[i-1].Close))
+		}
+	}
+	if len(logs) >= 2 {
+		s.Volatility = pct(stddev(logs) * math.Sqrt(tradingDaysPerYear))
+	}
+
+	peak, peakDate := in[0].Close, s.From
+	for _, b := range in {
+		if b.Close > peak {
+			peak, peakDate = b.Close, date(b.Timestamp)
+		}
+		if peak > 0 {
+			if dd := (peak - b.Close) / peak * 100; dd > s.MaxDrawdown {
+				s.MaxDrawdown, s.DrawdownPeak, s.DrawdownTrough = dd, peakDate, date(b.Timestamp)
+			}
+		}
+	}
+
+	if bench != nil {
+		s.Beta, s.BenchmarkReturn = beta(bars, bench, first, w, loc)
+	}
+	return s, true
+}
+
+// beta regresses the daily returns of bars[first:] on the benchmark's,
+// matched by date.
+func beta(bars, bench []massive.Agg, first int, w Window, loc *time.Location) (*float64, *float64) {
+	day := func(ms int64) string { return time.UnixMilli(ms).In(loc).Format("2006-01-02") }
+	benchRet := make(map[string]float64, len(bench))
+	for i := 1; i < len(bench); i++ {
+		if bench[i-1].Close > 0 {
+			benchRet[day(bench[i].Timestamp)] = bench[i].Close/bench[i-1].Close - 1
+		}
+	}
+
+	var xs, ys []float64
+	from := first
+	if from == 0 {
+		from = 1
+	}
+	for i := from; i < len(bars); i++ {
+		if bars[i-1].Close <= 0 {
+			continue
+		}
+		if br, ok := benchRet[day(bars[i].Timestamp)]; ok {
+			xs = append(xs, br)
+			ys = append(ys, bars[i].Close/bars[i-1].Close-1)
+		}
+	}
+
+	var benchReturn *float64
+	bf := sort.Search(len(bench), func(i int) bool { return bench[i].Timestamp >= w.Start.UnixMilli() })
+	if bf < len(bench) {
+		base := bench[bf].Open
+		if bf > 0 {
+			base = bench[bf-1].Close
+		}
+		if base > 0 {
+			benchReturn = pct(bench[len(bench)-1].Close/base - 1)
+		}
+	}
+
+	if len(xs) < 2 {
+		return nil, benchReturn
+	}
+	mx, my := mean(xs), mean(ys)
+	var cov, varX float64
+	for i := range xs {
+		cov += (xs[i] - mx) * (ys[i] - my)
+		varX += (xs[i] - mx) * (xs[i] - mx)
+	}
+	if varX == 0 {
+		return nil, benchReturn
+	}
+	b := cov / varX
+	return &b, benchReturn
+}
+
+func pct(v float64) *float64 {
+	v *= 100
+	return &v
+}
+
+func mean(xs []float64) float64 {
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// stddev is the sample standard deviation.
+func stddev(xs []float64) float64 {
+	m := mean(xs)
+	var v float64
+	for _, x := range xs {
+		v += (x - m) * (x - m)
+	}
+	return math.Sqrt(v / float64(len(xs)-1))
+}
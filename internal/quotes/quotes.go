@@ -0,0 +1,367 @@
+/*
+Package quotes is the in-memory last-value cache of the live feed: the last
+trade (or index value), the minute bar being built and the day OHLC for every
+ticker the hub has seen. It answers "what is AAPL right now" without waiting
+for the next trade.
+*/
+
+package quotes
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+	_ "time/tzdata" // trading days are in America/New_York
+
+	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/ws"
+)
+
+// EventType is the "ev" of the snapshot sent to a client when it subscribes.
+const EventType = "snapshot"
+
+// Where a snapshot came from
+const (
+	SourceLive = "live"
+	SourceREST = "rest"
+)
+
+// restTimeout bounds a snapshot fallback, which runs on the hub's and
+// handlers' paths.
+const restTimeout = 5 * time.Second
+
+// Quote is the latest known state of one ticker. Bars use the stream
+// aggregate shape so clients parse them like AM events.
+type Quote struct {
+	Source    string             `json:"source"`
+	LastTrade *stream.Trade      `json:"last_trade,omitempty"`
+	LastValue *stream.IndexValue `json:"last_value,omitempty"`
+	Minute    *stream.Aggregate  `json:"minute,omitempty"`
+	Day       *stream.Aggregate  `json:"day,omitempty"`
+	Updated   int64              `json:"updated"` // unix ms of the newest event
+
+	seeded int64 // Day.Start of the day bar last seeded from REST
+}
+
+// Price is the last trade price or index value.
+func (q Quote) Price() float64 {
+	switch {
+	case q.LastTrade != nil:
+		return q.LastTrade.Price
+	case q.LastValue != nil:
+		return q.LastValue.Value
+	}
+	return 0
+}
+
+type Cache struct {
+	mu     sync.RWMutex
+	quotes map[string]*Quote
+
+	// REST day bars of tickers without a live one yet, merged into it on
+	// the first trade
+	days map[string]stream.Aggregate
+
+	// REST fallback for tickers we have no live data for yet; may be nil.
+	massive *massive.Client
+	market  *time.Location
+}
+
+func New(m *massive.Client) *Cache {
+	market, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		market = time.UTC
+	}
+	return &Cache{quotes: make(map[string]*Quote), days: make(map[string]stream.Aggregate), massive: m, market: market}
+}
+
+// Run consumes frames (a hub tap) until the channel is closed.
+func (c *Cache) Run(frames <-chan []stream.Event) {
+	for events := range frames {
+		c.add(events)
+	}
+}
+
+// Get returns a copy of the live quote for ticker.
+func (c *Cache) Get(ticker string) (Quote, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	q, ok := c.quotes[ticker]
+	if !ok {
+		return Quote{}, false
+	}
+	return q.copy(), true
+}
+
+// Lookup returns the live quote, or falls back to the Massive snapshot API.
+func (c *Cache) Lookup(ticker string) (Quote, bool) {
+	q, ok := c.lookup([]string{ticker})[ticker]
+	return q, ok
+}
+
+// Snapshots implements ws.Snapshotter.
+func (c *Cache) Snapshots(tickers []string) map[string]stream.Event {
+	quotes := c.lookup(tickers)
+	out := make(map[string]stream.Event, len(quotes))
+	for t, q := range quotes {
+		out[t] = stream.NewCustom(EventType, t, q)
+	}
+	return out
+}
+
+// lookup returns the quotes it has for tickers. One batched snapshot
+// request covers the stocks without a live quote, and those whose day bar
+// hasn't been seeded from REST today.
+func (c *Cache) lookup(tickers []string) map[string]Quote {
+	out := make(map[string]Quote, len(tickers))
+	var fetch []string
+	for _, t := range tickers {
+		q, ok, seed := c.claim(t)
+		if ok {
+			out[t] = q
+		}
+		// The stocks snapshot endpoint has no indices.
+		if seed && !ws.IsIndex(t) {
+			fetch = append(fetch, t)
+		}
+	}
+	if c.massive == nil || len(fetch) == 0 {
+		return out
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), restTimeout)
+	defer cancel()
+	snaps, err := c.massive.StockSnapshots(ctx, fetch)
+	if err != nil {
+		log.Printf("[Quotes] snapshot %v: %v", fetch, err)
+		return out
+	}
+	for i := range snaps {
+		s := &snaps[i]
+		c.seed(s)
+		if _, ok := out[s.Ticker]; !ok {
+			out[s.Ticker] = fromSnapshot(s)
+		} else if q, ok := c.Get(s.Ticker); ok {
+			out[s.Ticker] = q
+		}
+	}
+	return out
+}
+
+// claim returns a copy of the live quote for ticker and whether it needs a
+// REST snapshot: it has none, or its day bar isn't seeded yet. Seeding a
+// live day bar is only tried once per day.
+func (c *Cache) claim(ticker string) (q Quote, ok, seed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	lq, ok := c.quotes[ticker]
+	if !ok {
+		return Quote{}, false, true
+	}
+	if lq.Day == nil || lq.seeded == lq.Day.Start {
+		return lq.copy(), true, false
+	}
+	lq.seeded = lq.Day.Start
+	return lq.copy(), true, true
+}
+
+// seed merges the day bar of a REST snapshot into the live one, which
+// otherwise only covers trades since we started streaming. A ticker with no
+// live day bar yet keeps it for its first trade.
+func (c *Cache) seed(s *massive.TickerSnapshot) {
+	if s.Day.Close <= 0 || s.Updated <= 0 {
+		return
+	}
+	start := c.dayStart(s.Updated / int64(time.Millisecond))
+	day := stream.Aggregate{
+		Symbol: s.Ticker,
+		Open:   s.Day.Open,
+		High:   s.Day.High,
+		Low:    s.Day.Low,
+		Close:  s.Day.Close,
+		Volume: s.Day.Volume,
+		VWAP:   s.Day.VWAP,
+		Start:  start,
+		End:    start + 24*time.Hour.Milliseconds(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	q, ok := c.quotes[s.Ticker]
+	if !ok || q.Day == nil {
+		c.days[s.Ticker] = day
+		return
+	}
+	if q.Day.Start == start {
+		mergeDay(q.Day, day)
+		q.seeded = start
+	}
+}
+
+// mergeDay folds a REST day bar into a live one. The live close is newer.
+func mergeDay(live *stream.Aggregate, rest stream.Aggregate) {
+	live.Open = rest.Open
+	live.High = math.Max(live.High, rest.High)
+	live.Low = math.Min(live.Low, rest.Low)
+	if rest.Volume > live.Volume {
+		live.Volume = rest.Volume
+		if rest.VWAP > 0 {
+			live.VWAP = rest.VWAP
+		}
+	}
+}
+
+func (c *Cache) dayStart(ts int64) int64 {
+	at := time.UnixMilli(ts).In(c.market)
+	return time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, c.market).UnixMilli()
+}
+
+func (c *Cache) add(events []stream.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range events {
+		if e.Symbol == "" {
+			continue
+		}
+		q, ok := c.quotes[e.Symbol]
+		if !ok {
+			q = &Quote{Source: SourceLive}
+			c.quotes[e.Symbol] = q
+		}
+
+		switch {
+		case e.Trade != nil:
+			t := *e.Trade
+			if q.LastTrade != nil && t.Timestamp < q.LastTrade.Timestamp {
+				continue // out-of-order print
+			}
+			q.LastTrade = &t
+			c.update(q, e.Symbol, t.Price, t.Size, t.Timestamp)
+
+		case e.Index != nil:
+			v := *e.Index
+			q.LastValue = &v
+			c.update(q, e.Symbol, v.Value, 0, v.Timestamp)
+
+		case e.Type == stream.TypeMinuteAgg:
+			// Massive's own minute bar carries the official day totals.
+			if q.Day != nil && e.Aggregate.Start >= q.Day.Start {
+				if e.Aggregate.OfficialOpen > 0 {
+					q.Day.Open = e.Aggregate.OfficialOpen
+				}
+				if e.Aggregate.AccumulatedVolume > q.Day.Volume {
+					q.Day.Volume = e.Aggregate.AccumulatedVolume
+				}
+				if e.Aggregate.DayVWAP > 0 {
+					q.Day.VWAP = e.Aggregate.DayVWAP
+				}
+			}
+		}
+	}
+}
+
+// update folds one price into the minute and day bars. Called with c.mu held.
+func (c *Cache) update(q *Quote, symbol string, price, size float64, ts int64) {
+	if ts > q.Updated {
+		q.Updated = ts
+	}
+
+	dayStart := c.dayStart(ts)
+	if q.Day == nil || q.Day.Start < dayStart {
+		q.Day = &stream.Aggregate{Symbol: symbol, Open: price, High: price, Low: price, Start: dayStart, End: dayStart + 24*time.Hour.Milliseconds()}
+		if d, ok := c.days[symbol]; ok {
+			delete(c.days, symbol)
+			if d.Start == dayStart {
+				mergeDay(q.Day, d)
+				q.seeded = dayStart
+			}
+		}
+	}
+	addTo(q.Day, price, size)
+
+	minute := ts - ts%time.Minute.Milliseconds()
+	if q.Minute == nil || q.Minute.Start < minute {
+		q.Minute = &stream.Aggregate{Symbol: symbol, Open: price, High: price, Low: price, Start: minute, End: minute + time.Minute.Milliseconds()}
+	}
+	if ts >= q.Minute.Start {
+		addTo(q.Minute, price, size)
+	}
+}
+
+func addTo(b *stream.Aggregate, price, size float64) {
+	b.High = math.Max(b.High, price)
+	b.Low = math.Min(b.Low, price)
+	b.Close = price
+	if size > 0 {
+		b.VWAP = (b.VWAP*b.Volume + price*size) / (b.Volume + size)
+		b.Volume += size
+	}
+}
+
+func (q *Quote) copy() Quote {
+	out := Quote{Source: q.Source, Updated: q.Updated}
+	if q.LastTrade != nil {
+		t := *q.LastTrade
+		out.LastTrade = &t
+	}
+	if q.LastValue != nil {
+		v := *q.LastValue
+		out.LastValue = &v
+	}
+	if q.Minute != nil {
+		m := *q.Minute
+		out.Minute = &m
+	}
+	if q.Day != nil {
+		d := *q.Day
+		out.Day = &d
+	}
+	return out
+}
+
+func fromSnapshot(s *massive.TickerSnapshot) Quote {
+	q := Quote{Source: SourceREST, Updated: s.Updated / int64(time.Millisecond)}
+	if s.LastTrade.Price > 0 {
+		q.LastTrade = &stream.Trade{
+			Symbol:     s.Ticker,
+			Exchange:   s.LastTrade.Exchange,
+			ID:         s.LastTrade.ID,
+			Price:      s.LastTrade.Price,
+			Size:       s.LastTrade.Size,
+			Conditions: s.LastTrade.Conditions,
+			Timestamp:  s.LastTrade.Timestamp / int64(time.Millisecond),
+		}
+	}
+	if s.Min.Close > 0 {
+		q.Minute = &stream.Aggregate{
+			Symbol:            s.Ticker,
+			Open:              s.Min.Open,
+			High:              s.Min.High,
+			Low:               s.Min.Low,
+			Close:             s.Min.Close,
+			Volume:            s.Min.Volume,
+			VWAP:              s.Min.VWAP,
+			AccumulatedVolume: s.Min.AccumulatedVolume,
+			Start:             s.Min.Timestamp,
+			End:               s.Min.Timestamp + time.Minute.Milliseconds(),
+		}
+	}
+	if s.Day.Close > 0 {
+		q.Day = &stream.Aggregate{
+			Symbol: s.Ticker,
+			Open:   s.Day.Open,
+			High:   s.Day.High,
+			Low:    s.Day.Low,
+			Close:  s.Day.Close,
+			Volume: s.Day.Volume,
+			VWAP:   s.Day.VWAP,
+		}
+	}
+	return q
+}
@@ -0,0 +1,88 @@
+package quotes
+
+import (
+	"context"
+	"log"
+
+	"github.com/dnhan1707/trader/internal/ws"
+)
+
+// Summary is the one-line view of a ticker used by watchlists.
+type Summary struct {
+	Ticker    string   `json:"ticker"`
+	Price     *float64 `json:"price"`
+	PrevClose *float64 `json:"prev_close"`
+	Change    *float64 `json:"change"`
+	ChangePct *float64 `json:"change_pct"`
+	Volume    *float64 `json:"volume"`
+	Source    string   `json:"source,omitempty"` // where the price came from
+	Updated   int64    `json:"updated,omitempty"`
+}
+
+// Summaries returns a summary per ticker, in order. Stocks get the previous
+// close and day volume from one batched snapshot request; the price is the
+// live one when the stream has it. Indices only have live data.
+func (c *Cache) Summaries(tickers []string) []Summary {
+	var stocks []string
+	for _, t := range tickers {
+		if !ws.IsIndex(t) {
+			stocks = append(stocks, t)
+		}
+	}
+
+	rest := make(map[string]Quote)
+	prevClose := make(map[string]float64)
+	restVolume := make(map[string]float64)
+	if c.massive != nil && len(stocks) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), restTimeout)
+		snaps, err := c.massive.StockSnapshots(ctx, stocks)
+		cancel()
+		if err != nil {
+			log.Printf("[Quotes] batch snapshot: %v", err)
+		}
+		for i := range snaps {
+			s := &snaps[i]
+			c.seed(s)
+			rest[s.Ticker] = fromSnapshot(s)
+			if s.PrevDay.Close > 0 {
+				prevClose[s.Ticker] = s.PrevDay.Close
+			}
+			if s.Day.Volume > 0 {
+				restVolume[s.Ticker] = s.Day.Volume
+			}
+		}
+	}
+
+	out := make([]Summary, 0, len(tickers))
+	for _, t := range tickers {
+		s := Summary{Ticker: t}
+		q, ok := c.Get(t)
+		if !ok || q.Price() <= 0 {
+			q, ok = rest[t]
+		}
+		if ok && q.Price() > 0 {
+			price := q.Price()
+			s.Price, s.Source, s.Updated = &price, q.Source, q.Updated
+		}
+
+		// The live day bar only counts trades since we started streaming,
+		// so the snapshot's volume wins when there is one.
+		if v, ok := restVolume[t]; ok {
+			s.Volume = &v
+		} else if q.Day != nil && q.Day.Volume > 0 {
+			v := q.Day.Volume
+			s.Volume = &v
+		}
+
+		if pc, ok := prevClose[t]; ok {
+			s.PrevClose = &pc
+			if s.Price != nil {
+				change := *s.Price - pc
+				pct := change / pc * 100
+				s.Change, s.ChangePct = &change, &pct
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
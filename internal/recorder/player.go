@@ -0,0 +1,230 @@
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/ws"
+)
+
+// Frames can be large (a busy second of trades), so allow long lines.
+const maxLineSize = 16 * 1024 * 1024
+
+// Player feeds a recording into a hub in place of the live upstream.
+type Player struct {
+	// Path is a single recording file or a directory of them.
+	Path string
+
+	// Speed is the playback rate: 1 is real time, 10 is ten times faster and
+	// 0 (or less) sends frames as fast as the hub accepts them.
+	Speed float64
+
+	// Loop restarts the recording when it ends.
+	Loop bool
+}
+
+// Run plays the recording as a feed.Source. Subscription requests are drained
+// and ignored: the recording has what it has, and the hub only forwards the
+// tickers each client asked for.
+func (p *Player) Run(ctx context.Context, out chan<- []stream.Event, stockSubs, indexSubs <-chan ws.SubRequest) {
+	go drain(ctx, stockSubs)
+	go drain(ctx, indexSubs)
+
+	if err := p.Play(ctx, out); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("[Replay] %v", err)
+		}
+		return
+	}
+	log.Println("[Replay] Recording finished")
+}
+
+// Play pushes every recorded frame into out (usually hub.Broadcast), keeping
+// the recorded spacing between frames (scaled by Speed). Frames are decoded
+// with the same rules as the live feed. It stops early with ctx's error.
+func (p *Player) Play(ctx context.Context, out chan<- []stream.Event) error {
+	files, err := recordingFiles(p.Path)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no recordings found in %s", p.Path)
+	}
+
+	for {
+		for _, f := range files {
+			if err := p.playFile(ctx, f, out); err != nil {
+				return fmt.Errorf("%s: %w", f, err)
+			}
+		}
+		if !p.Loop {
+			return nil
+		}
+	}
+}
+
+// playFile plays path member by member. A member that doesn't decode to
+// the end, such as the last one of a process that died without closing
+// its file, is played as far as it goes; playback then resumes at the next
+// gzip header, if any.
+func (p *Player) playFile(ctx context.Context, path string, out chan<- []stream.Event) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var c clock
+	for {
+		start, err := f.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return err
+		}
+		start -= int64(br.Buffered())
+
+		gz, err := gzip.NewReader(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			gz.Multistream(false)
+			err = p.playMember(ctx, gz, &c, out)
+		}
+		if err == nil {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		log.Printf("[Replay] %s: damaged gzip member at byte %d: %v", path, start, err)
+		next, err := nextMember(f, start+1)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := f.Seek(next, io.SeekStart); err != nil {
+			return err
+		}
+		br.Reset(f)
+	}
+}
+
+// clock paces playback to the recorded spacing between frames.
+type clock struct {
+	firstRecorded int64     // t of the first line
+	startedAt     time.Time // wall clock when the first line was played
+}
+
+func (p *Player) playMember(ctx context.Context, gz *gzip.Reader, c *clock, out chan<- []stream.Event) error {
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		var l line
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return fmt.Errorf("bad line: %w", err)
+		}
+
+		if p.Speed > 0 {
+			if c.startedAt.IsZero() {
+				c.firstRecorded, c.startedAt = l.Time, time.Now()
+			}
+			offset := time.Duration(float64(l.Time-c.firstRecorded) / p.Speed * float64(time.Millisecond))
+			if wait := time.Until(c.startedAt.Add(offset)); wait > 0 {
+				select {
+				case <-time.After(wait):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		}
+
+		if events := stream.Decode("Replay", l.Frame); len(events) > 0 {
+			select {
+			case out <- events:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// gzipMagic starts every gzip member (ID1, ID2, CM=deflate).
+var gzipMagic = []byte{0x1f, 0x8b, 0x08}
+
+// nextMember finds the offset of the next gzip header at or after from.
+func nextMember(f *os.File, from int64) (int64, error) {
+	r := bufio.NewReader(io.NewSectionReader(f, from, 1<<62))
+	matched := 0
+	for off := from; ; off++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case b == gzipMagic[matched]:
+			matched++
+		case b == gzipMagic[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+		if matched == len(gzipMagic) {
+			return off - int64(len(gzipMagic)) + 1, nil
+		}
+	}
+}
+
+// recordingFiles returns path itself, or the recordings inside it in
+// chronological (= lexical) order.
+func recordingFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), fileExt) {
+			files = append(files, filepath.Join(path, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func drain(ctx context.Context, subs <-chan ws.SubRequest) {
+	for {
+		select {
+		case _, ok := <-subs:
+			if !ok {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,168 @@
+/*
+Package recorder captures the upstream market-data stream to disk and plays it
+back into a ws.Hub.
+
+A recording is a directory of per-day segments (UTC), one per process run:
+YYYY-MM-DD-<unix ms when opened>.ndjson.gz, so a restart never appends to
+a file an earlier run may have left unterminated. Each line is one upstream
+frame with the time we received it:
+
+	{"t":1700000000123,"frame":[{"ev":"T","sym":"AAPL",...}]}
+*/
+
+package recorder
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive/stream"
+)
+
+const (
+	fileExt = ".ndjson.gz"
+
+	// How often buffered lines are flushed to disk. A crash loses at most
+	// this much data.
+	flushEvery = time.Second
+)
+
+// line is one recorded frame.
+type line struct {
+	Time  int64           `json:"t"` // unix ms when the frame was received
+	Frame json.RawMessage `json:"frame"`
+}
+
+type Recorder struct {
+	// Now stamps frames as they are received and picks their segment's
+	// day. nil means time.Now; set it before Run.
+	Now func() time.Time
+
+	dir string
+
+	day  string // day of the open file, "" when none is open
+	file *os.File
+	gz   *gzip.Writer
+	buf  *bufio.Writer
+
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+func New(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create recording dir: %w", err)
+	}
+	return &Recorder{dir: dir, stop: make(chan struct{}), stopped: make(chan struct{})}, nil
+}
+
+// Run writes every frame received on frames until the channel is closed or
+// Close is called. Use it with a hub tap: go rec.Run(hub.Tap(4096)).
+func (r *Recorder) Run(frames <-chan []stream.Event) {
+	flush := time.NewTicker(flushEvery)
+	defer flush.Stop()
+	defer close(r.stopped)
+	defer r.close()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case events, ok := <-frames:
+			if !ok {
+				return
+			}
+			if err := r.write(r.now(), events); err != nil {
+				log.Printf("[Recorder] write failed: %v", err)
+			}
+		case <-flush.C:
+			if err := r.flush(); err != nil {
+				log.Printf("[Recorder] flush failed: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Recorder) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+// Close stops Run and finishes the open segment, so it reads back as
+// complete gzip. Call it, once Run has started, before the process exits.
+func (r *Recorder) Close() {
+	r.closeOnce.Do(func() { close(r.stop) })
+	<-r.stopped
+}
+
+func (r *Recorder) write(at time.Time, events []stream.Event) error {
+	day := at.UTC().Format("2006-01-02")
+	if day != r.day {
+		if err := r.rotate(day); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(line{Time: at.UnixMilli(), Frame: stream.Encode(events)})
+	if err != nil {
+		return err
+	}
+	if _, err := r.buf.Write(data); err != nil {
+		return err
+	}
+	return r.buf.WriteByte('\n')
+}
+
+// rotate closes the current segment and starts a new one for day.
+func (r *Recorder) rotate(day string) error {
+	r.close()
+
+	path := filepath.Join(r.dir, fmt.Sprintf("%s-%d%s", day, time.Now().UnixMilli(), fileExt))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", path, err)
+	}
+	log.Printf("[Recorder] Recording to %s", path)
+
+	r.day = day
+	r.file = f
+	r.gz = gzip.NewWriter(f)
+	r.buf = bufio.NewWriter(r.gz)
+	return nil
+}
+
+func (r *Recorder) flush() error {
+	if r.file == nil {
+		return nil
+	}
+	if err := r.buf.Flush(); err != nil {
+		return err
+	}
+	return r.gz.Flush()
+}
+
+func (r *Recorder) close() {
+	if r.file == nil {
+		return
+	}
+	if err := r.buf.Flush(); err != nil {
+		log.Printf("[Recorder] flush failed: %v", err)
+	}
+	if err := r.gz.Close(); err != nil {
+		log.Printf("[Recorder] close gzip failed: %v", err)
+	}
+	if err := r.file.Close(); err != nil {
+		log.Printf("[Recorder] close file failed: %v", err)
+	}
+	r.day, r.file, r.gz, r.buf = "", nil, nil, nil
+}
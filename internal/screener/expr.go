@@ -0,0 +1,370 @@
+package screener
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MaxComparisons and MaxFilterLength bound one filter.
+const (
+	MaxComparisons  = 30
+	MaxFilterLength = 2000
+)
+
+// A filter is comparisons joined with and / or / not and parentheses:
+//
+//	rsi_14 < 30 and (market_cap > 10B or pe < 15) and not type = 'ETF'
+//
+// A comparison is field op value or field op field, with op one of
+// < <= > >= = != (== and <> work too). Numbers take K, M, B and T
+// suffixes; text fields compare (case-insensitively) against quoted
+// strings with = and != only. A comparison on a missing value is false.
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokAnd
+	tokOr
+	tokNot
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+var suffixes = map[byte]float64{'k': 1e3, 'm': 1e6, 'b': 1e9, 't': 1e12}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == '&' || c == '|':
+			if i+1 >= len(s) || s[i+1] != c {
+				return nil, fmt.Errorf("unexpected %q at %d", c, i)
+			}
+			kind := tokAnd
+			if c == '|' {
+				kind = tokOr
+			}
+			toks = append(toks, token{kind: kind, text: s[i : i+2], pos: i})
+			i += 2
+		case strings.IndexByte("<>=!", c) >= 0:
+			j := i + 1
+			if j < len(s) && (s[j] == '=' || (c == '<' && s[j] == '>')) {
+				j++
+			}
+			op := s[i:j]
+			switch op {
+			case "==":
+				op = "="
+			case "<>":
+				op = "!="
+			case "!":
+				toks = append(toks, token{kind: tokNot, text: op, pos: i})
+				i = j
+				continue
+			}
+			toks = append(toks, token{kind: tokOp, text: op, pos: i})
+			i = j
+		case c == '\'' || c == '"':
+			j := strings.IndexByte(s[i+1:], c)
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			toks = append(toks, token{kind: tokString, text: s[i+1 : i+1+j], pos: i})
+			i += j + 2
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(s) && (s[j] == '.' || (s[j] >= '0' && s[j] <= '9') ||
+				((s[j] == 'e' || s[j] == 'E') && j+1 < len(s) && (s[j+1] == '-' || s[j+1] == '+' || (s[j+1] >= '0' && s[j+1] <= '9')))) {
+				if s[j] == 'e' || s[j] == 'E' {
+					j++
+				}
+				j++
+			}
+			v, err := strconv.ParseFloat(s[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at %d", s[i:j], i)
+			}
+			if j < len(s) {
+				if m, ok := suffixes[byte(unicode.ToLower(rune(s[j])))]; ok && (j+1 == len(s) || !isIdent(s[j+1])) {
+					v *= m
+					j++
+				}
+			}
+			toks = append(toks, token{kind: tokNumber, text: s[i:j], num: v, pos: i})
+			i = j
+		case isIdent(c):
+			j := i
+			for j < len(s) && isIdent(s[j]) {
+				j++
+			}
+			word := strings.ToLower(s[i:j])
+			kind := tokIdent
+			switch word {
+			case "and":
+				kind = tokAnd
+			case "or":
+				kind = tokOr
+			case "not":
+				kind = tokNot
+			}
+			toks = append(toks, token{kind: kind, text: word, pos: i})
+			i = j
+		default:
+			return nil, fmt.Errorf("unexpected %q at %d", c, i)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(s)}), nil
+}
+
+func isIdent(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// Filter is a parsed filter expression, ready to become a WHERE clause.
+type Filter struct {
+	where string
+	args  []interface{}
+}
+
+// Where is the SQL condition ("TRUE" for an empty filter); its
+// placeholders are $1..$n for Args.
+func (f *Filter) Where() string { return f.where }
+
+func (f *Filter) Args() []interface{} { return f.args }
+
+type parser struct {
+	toks  []token
+	i     int
+	f     *Filter
+	count int
+}
+
+// ParseFilter checks expr against Fields and compiles it.
+func ParseFilter(expr string) (*Filter, error) {
+	f := &Filter{where: "TRUE"}
+	if strings.TrimSpace(expr) == "" {
+		return f, nil
+	}
+	if len(expr) > MaxFilterLength {
+		return nil, fmt.Errorf("filter is longer than %d characters", MaxFilterLength)
+	}
+	toks, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks, f: f}
+	where, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	f.where = where
+	return f, nil
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *parser) or() (string, error) {
+	left, err := p.and()
+	if err != nil {
+		return "", err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.and()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " OR " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *parser) and() (string, error) {
+	left, err := p.unary()
+	if err != nil {
+		return "", err
+	}
+	for p.peek().kind == tokAnd {
+		p.next()
+		right, err := p.unary()
+		if err != nil {
+			return "", err
+		}
+		left = "(" + left + " AND " + right + ")"
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (string, error) {
+	switch t := p.peek(); t.kind {
+	case tokNot:
+		p.next()
+		inner, err := p.unary()
+		if err != nil {
+			return "", err
+		}
+		// NOT of a comparison on a missing value stays false
+		return "(NOT (" + inner + ")) IS TRUE", nil
+	case tokLParen:
+		p.next()
+		inner, err := p.or()
+		if err != nil {
+			return "", err
+		}
+		if t := p.next(); t.kind != tokRParen {
+			return "", fmt.Errorf("expected ) at %d", t.pos)
+		}
+		return inner, nil
+	}
+	return p.comparison()
+}
+
+// operand is one side of a comparison: a field or a constant.
+type operand struct {
+	field *Field
+	tok   token
+}
+
+func (p *parser) operand() (operand, error) {
+	t := p.next()
+	switch t.kind {
+	case tokIdent:
+		f, ok := fieldsByName[t.text]
+		if !ok {
+			return operand{}, fmt.Errorf("unknown field %q", t.text)
+		}
+		return operand{field: &f, tok: t}, nil
+	case tokNumber, tokString:
+		return operand{tok: t}, nil
+	case tokEOF:
+		return operand{}, fmt.Errorf("unexpected end of filter")
+	}
+	return operand{}, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+}
+
+func (o operand) kind() string {
+	switch {
+	case o.field != nil:
+		return o.field.Kind
+	case o.tok.kind == tokString:
+		return KindText
+	}
+	return KindNumber
+}
+
+func (p *parser) comparison() (string, error) {
+	left, err := p.operand()
+	if err != nil {
+		return "", err
+	}
+	opTok := p.next()
+	if opTok.kind != tokOp {
+		return "", fmt.Errorf("expected a comparison after %q at %d", left.tok.text, opTok.pos)
+	}
+	right, err := p.operand()
+	if err != nil {
+		return "", err
+	}
+
+	if left.field == nil && right.field == nil {
+		return "", fmt.Errorf("%s %s %s compares two constants", left.tok.text, opTok.text, right.tok.text)
+	}
+	if left.kind() != right.kind() {
+		return "", fmt.Errorf("cannot compare %s with %s", left.tok.text, right.tok.text)
+	}
+	if left.kind() == KindText && opTok.text != "=" && opTok.text != "!=" {
+		return "", fmt.Errorf("%s: text fields only support = and !=", left.tok.text)
+	}
+	if p.count++; p.count > MaxComparisons {
+		return "", fmt.Errorf("at most %d comparisons per filter", MaxComparisons)
+	}
+	op := opTok.text
+	if op == "!=" {
+		op = "<>"
+	}
+	return p.sql(left) + " " + op + " " + p.sql(right), nil
+}
+
+func (p *parser) sql(o operand) string {
+	if o.field != nil {
+		if o.field.Kind == KindText {
+			return "lower(" + o.field.Name + ")"
+		}
+		return o.field.Name
+	}
+	if o.tok.kind == tokString {
+		p.f.args = append(p.f.args, strings.ToLower(o.tok.text))
+	} else {
+		p.f.args = append(p.f.args, o.tok.num)
+	}
+	return fmt.Sprintf("$%d", len(p.f.args))
+}
+
+// ParseSort parses a comma-separated list of fields, each optionally
+// prefixed with - for descending, into an ORDER BY list. Missing values
+// sort last and ticker breaks ties.
+func ParseSort(sort string) (string, error) {
+	var parts []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(sort, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		dir := "ASC"
+		if item[0] == '-' || item[0] == '+' {
+			if item[0] == '-' {
+				dir = "DESC"
+			}
+			item = item[1:]
+		}
+		f, ok := fieldsByName[item]
+		if !ok {
+			return "", fmt.Errorf("unknown sort field %q", item)
+		}
+		if seen[f.Name] {
+			continue
+		}
+		seen[f.Name] = true
+		parts = append(parts, f.Name+" "+dir+" NULLS LAST")
+	}
+	if !seen["ticker"] {
+		parts = append(parts, "ticker ASC")
+	}
+	return strings.Join(parts, ", "), nil
+}
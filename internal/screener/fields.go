@@ -0,0 +1,73 @@
+/*
+Package screener keeps a snapshot of the stock universe in Postgres
+(screener_universe) and turns filter expressions such as
+
+	rsi_14 < 30 and market_cap > 10B and short_interest_change > 0
+
+into SQL over it. The universe is rebuilt periodically by a Refresher from
+Massive's ticker list, the whole-market snapshot, ratios, short interest
+and daily closes.
+*/
+
+package screener
+
+// Field kinds.
+const (
+	KindNumber = "number"
+	KindText   = "text"
+)
+
+// Field is a column of screener_universe that filters and sorts can use.
+type Field struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Group       string `json:"group"`
+	Description string `json:"description"`
+}
+
+// Fields lists every field, in display order. Names are the column names.
+var Fields = []Field{
+	{"ticker", KindText, "reference", "Ticker symbol"},
+	{"name", KindText, "reference", "Company name"},
+	{"type", KindText, "reference", "Security type (CS, ETF, ADRC...)"},
+	{"exchange", KindText, "reference", "Primary exchange MIC"},
+
+	{"price", KindNumber, "snapshot", "Last price"},
+	{"change_pct", KindNumber, "snapshot", "Change on the day, percent"},
+	{"volume", KindNumber, "snapshot", "Volume on the day"},
+	{"prev_close", KindNumber, "snapshot", "Previous close"},
+
+	{"market_cap", KindNumber, "ratios", "Market capitalization, USD"},
+	{"pe", KindNumber, "ratios", "Price to earnings"},
+	{"pb", KindNumber, "ratios", "Price to book"},
+	{"ps", KindNumber, "ratios", "Price to sales"},
+	{"ev_ebitda", KindNumber, "ratios", "Enterprise value to EBITDA"},
+	{"eps", KindNumber, "ratios", "Earnings per share"},
+	{"dividend_yield", KindNumber, "ratios", "Dividend yield, percent"},
+	{"roe", KindNumber, "ratios", "Return on equity, percent"},
+	{"roa", KindNumber, "ratios", "Return on assets, percent"},
+	{"debt_to_equity", KindNumber, "ratios", "Debt to equity"},
+	{"current_ratio", KindNumber, "ratios", "Current assets to current liabilities"},
+	{"free_cash_flow", KindNumber, "ratios", "Free cash flow, USD"},
+	{"avg_volume", KindNumber, "ratios", "Average daily volume"},
+
+	{"rsi_14", KindNumber, "technicals", "RSI(14) of daily closes"},
+	{"sma_20", KindNumber, "technicals", "20-day simple moving average"},
+	{"sma_50", KindNumber, "technicals", "50-day simple moving average"},
+	{"sma_200", KindNumber, "technicals", "200-day simple moving average"},
+	{"return_1m", KindNumber, "technicals", "Return over 21 trading days, percent"},
+	{"return_3m", KindNumber, "technicals", "Return over 63 trading days, percent"},
+
+	{"short_interest", KindNumber, "short_interest", "Shares sold short, latest settlement"},
+	{"short_interest_prev", KindNumber, "short_interest", "Shares sold short, previous settlement"},
+	{"short_interest_change", KindNumber, "short_interest", "Change between the two settlements, percent"},
+	{"days_to_cover", KindNumber, "short_interest", "Short interest over average daily volume"},
+}
+
+var fieldsByName = func() map[string]Field {
+	m := make(map[string]Field, len(Fields))
+	for _, f := range Fields {
+		m[f.Name] = f
+	}
+	return m
+}()
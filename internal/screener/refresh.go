@@ -0,0 +1,320 @@
+package screener
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/indicators"
+	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/dnhan1707/trader/internal/services"
+)
+
+const (
+	refreshTimeout = time.Hour
+
+	// Short interest settles twice a month; this covers the last two.
+	shortInterestLookback = 75 * 24 * time.Hour
+
+	// Trading days behind return_1m and return_3m
+	monthBars   = 21
+	quarterBars = 63
+)
+
+// Refresher rebuilds screener_universe every interval. Daily closes are
+// kept in screener_closes and only missing days are fetched, so after the
+// first run a refresh costs a handful of requests, plus one per ticker
+// that split since its closes were stored.
+type Refresher struct {
+	service     *services.ScreenerService
+	massive     *massive.Client
+	market      *time.Location
+	every       time.Duration
+	historyDays int
+}
+
+// NewRefresher keeps historyDays calendar days of closes, which must cover
+// the 200 trading days of sma_200.
+func NewRefresher(service *services.ScreenerService, m *massive.Client, every time.Duration, historyDays int) *Refresher {
+	market, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		market = time.UTC
+	}
+	return &Refresher{service: service, massive: m, market: market, every: every, historyDays: historyDays}
+}
+
+// Run refreshes now and then every interval; it never returns.
+func (r *Refresher) Run() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		start := time.Now()
+		ran, err := r.service.WithRefreshLock(ctx, func() error { return r.refresh(ctx) })
+		cancel()
+		switch {
+		case err != nil:
+			log.Printf("[Screener] refresh failed: %v", err)
+		case ran:
+			log.Printf("[Screener] universe refreshed in %s", time.Since(start).Round(time.Second))
+		default:
+			log.Printf("[Screener] another instance is refreshing, skipped")
+		}
+		time.Sleep(r.every)
+	}
+}
+
+func (r *Refresher) refresh(ctx context.Context) error {
+	today := time.Now().In(r.market)
+	since := today.AddDate(0, 0, -r.historyDays).Format("2006-01-02")
+	if err := r.loadCloses(ctx, today, since); err != nil {
+		return err
+	}
+	if err := r.adjustSplits(ctx, today, since); err != nil {
+		return err
+	}
+	closes, err := r.service.LoadCloses(ctx, since)
+	if err != nil {
+		return err
+	}
+
+	refs, err := r.massive.ListTickers(ctx, "stocks")
+	if err != nil {
+		return err
+	}
+	snapshots, err := r.massive.StockSnapshots(ctx, nil)
+	if err != nil {
+		return err
+	}
+	snapByTicker := make(map[string]massive.TickerSnapshot, len(snapshots))
+	for _, s := range snapshots {
+		snapByTicker[s.Ticker] = s
+	}
+
+	ratios, err := r.massive.AllRatios(ctx)
+	if err != nil {
+		return err
+	}
+	ratioByTicker := make(map[string]massive.Ratio, len(ratios))
+	for _, rt := range ratios {
+		if cur, ok := ratioByTicker[rt.Ticker]; !ok || rt.Date > cur.Date {
+			ratioByTicker[rt.Ticker] = rt
+		}
+	}
+
+	reports, err := r.massive.ShortInterestSince(ctx, today.Add(-shortInterestLookback).Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	shorts := make(map[string][]massive.ShortInterest)
+	for _, rep := range reports {
+		shorts[rep.Ticker] = append(shorts[rep.Ticker], rep)
+	}
+
+	rows := make([]services.ScreenerRow, 0, len(refs))
+	for _, ref := range refs {
+		row := services.ScreenerRow{Ticker: ref.Ticker, Name: ref.Name, Type: ref.Type, Exchange: ref.PrimaryExchange}
+		if s, ok := snapByTicker[ref.Ticker]; ok {
+			setSnapshot(&row, s)
+		}
+		if rt, ok := ratioByTicker[ref.Ticker]; ok {
+			setRatios(&row, rt)
+		}
+		if list, ok := shorts[ref.Ticker]; ok {
+			setShortInterest(&row, list)
+		}
+		setTechnicals(&row, closes[ref.Ticker])
+		rows = append(rows, row)
+	}
+	return r.service.ReplaceUniverse(ctx, rows)
+}
+
+// loadCloses fetches the grouped daily bars of every weekday since since
+// that isn't stored yet, up to yesterday, and drops older ones.
+func (r *Refresher) loadCloses(ctx context.Context, today time.Time, since string) error {
+	have, err := r.service.CloseDays(ctx)
+	if err != nil {
+		return err
+	}
+	first, _ := time.ParseInLocation("2006-01-02", since, r.market)
+	end := today.Format("2006-01-02")
+	loaded := 0
+	for d := first; d.Format("2006-01-02") < end; d = d.AddDate(0, 0, 1) {
+		day := d.Format("2006-01-02")
+		if have[day] || d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		bars, err := r.massive.GroupedDaily(ctx, day, true)
+		if err != nil {
+			// try again on the next refresh
+			log.Printf("[Screener] grouped daily %s: %v", day, err)
+			continue
+		}
+		tickers := make([]string, 0, len(bars))
+		closes := make([]float64, 0, len(bars))
+		for _, b := range bars {
+			if b.Ticker != "" && b.Close > 0 {
+				tickers = append(tickers, b.Ticker)
+				closes = append(closes, b.Close)
+			}
+		}
+		if err := r.service.SaveCloses(ctx, day, tickers, closes); err != nil {
+			return err
+		}
+		if loaded++; loaded%20 == 0 {
+			log.Printf("[Screener] loaded closes up to %s", day)
+		}
+	}
+	return r.service.PruneCloses(ctx, since)
+}
+
+// adjustSplits fetches again the closes of every ticker that split after
+// some of them were stored: grouped daily bars are only adjusted for the
+// splits known on the day they were fetched.
+func (r *Refresher) adjustSplits(ctx context.Context, today time.Time, since string) error {
+	splits, err := r.massive.SplitsSince(ctx, since)
+	if err != nil {
+		// try again on the next refresh
+		log.Printf("[Screener] splits since %s: %v", since, err)
+		return nil
+	}
+	end := today.Format("2006-01-02")
+	yesterday := today.AddDate(0, 0, -1).Format("2006-01-02")
+	done := make(map[string]bool)
+	for _, sp := range splits {
+		if sp.ExecutionDate > end || done[sp.Ticker] {
+			continue
+		}
+		stale, err := r.service.ClosesBefore(ctx, sp.Ticker, sp.ExecutionDate)
+		if err != nil {
+			return err
+		}
+		if !stale {
+			continue
+		}
+		done[sp.Ticker] = true
+		bars, err := r.massive.Aggregates(ctx, sp.Ticker, 1, "day", since, yesterday, true)
+		if err != nil {
+			// still stale, so it's picked up on the next refresh
+			log.Printf("[Screener] daily bars of %s after its %s split: %v", sp.Ticker, sp.ExecutionDate, err)
+			continue
+		}
+		days := make([]string, 0, len(bars))
+		closes := make([]float64, 0, len(bars))
+		for _, b := range bars {
+			if b.Close > 0 {
+				days = append(days, time.UnixMilli(b.Timestamp).In(r.market).Format("2006-01-02"))
+				closes = append(closes, b.Close)
+			}
+		}
+		if err := r.service.ReplaceTickerCloses(ctx, sp.Ticker, days, closes); err != nil {
+			return err
+		}
+		log.Printf("[Screener] reloaded %d closes of %s after its %s split", len(closes), sp.Ticker, sp.ExecutionDate)
+	}
+	return nil
+}
+
+// positive returns &v, or nil for 0 (Massive's "unknown" in snapshots).
+func positive(v float64) *float64 {
+	if v <= 0 {
+		return nil
+	}
+	return &v
+}
+
+// percent scales a ratio reported as a fraction.
+func percent(v *float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	p := *v * 100
+	return &p
+}
+
+func setSnapshot(row *services.ScreenerRow, s massive.TickerSnapshot) {
+	price := s.Day.Close
+	if price <= 0 {
+		price = s.LastTrade.Price
+	}
+	if price <= 0 {
+		price = s.PrevDay.Close
+	}
+	row.Price = positive(price)
+	row.PrevClose = positive(s.PrevDay.Close)
+	row.Volume = positive(s.Day.Volume)
+	if s.PrevDay.Close > 0 {
+		pct := s.TodaysChangePerc
+		row.ChangePct = &pct
+	}
+}
+
+func setRatios(row *services.ScreenerRow, rt massive.Ratio) {
+	row.MarketCap = rt.MarketCap
+	row.PE = rt.PriceToEarnings
+	row.PB = rt.PriceToBook
+	row.PS = rt.PriceToSales
+	row.EVEBITDA = rt.EVToEBITDA
+	row.EPS = rt.EarningsPerShare
+	row.DividendYield = percent(rt.DividendYield)
+	row.ROE = percent(rt.ReturnOnEquity)
+	row.ROA = percent(rt.ReturnOnAssets)
+	row.DebtToEquity = rt.DebtToEquity
+	row.CurrentRatio = rt.Current
+	row.FreeCashFlow = rt.FreeCashFlow
+	row.AvgVolume = rt.AverageVolume
+}
+
+// setShortInterest uses the latest settlement and the one before it.
+func setShortInterest(row *services.ScreenerRow, list []massive.ShortInterest) {
+	sort.Slice(list, func(i, j int) bool { return list[i].SettlementDate > list[j].SettlementDate })
+	latest := list[0]
+	row.ShortInterest = &latest.ShortInterest
+	row.DaysToCover = &latest.DaysToCover
+	for _, prev := range list[1:] {
+		if prev.SettlementDate == latest.SettlementDate {
+			continue
+		}
+		row.ShortInterestPrev = &prev.ShortInterest
+		if prev.ShortInterest > 0 {
+			pct := (latest.ShortInterest/prev.ShortInterest - 1) * 100
+			row.ShortInterestChange = &pct
+		}
+		break
+	}
+}
+
+// setTechnicals computes the technical fields from daily closes, oldest
+// first. Fields without enough history stay nil.
+func setTechnicals(row *services.ScreenerRow, closes []float64) {
+	if len(closes) == 0 {
+		return
+	}
+	line := indicators.Line(closes)
+	row.RSI14 = lastValue(indicators.RSI(line, 14))
+	row.SMA20 = lastValue(indicators.SMA(line, 20))
+	row.SMA50 = lastValue(indicators.SMA(line, 50))
+	row.SMA200 = lastValue(indicators.SMA(line, 200))
+	row.Return1M = change(closes, monthBars)
+	row.Return3M = change(closes, quarterBars)
+}
+
+func lastValue(l indicators.Line) *float64 {
+	v := l[len(l)-1]
+	if math.IsNaN(v) {
+		return nil
+	}
+	return &v
+}
+
+// change is the percent change over the last n bars.
+func change(closes []float64, n int) *float64 {
+	if len(closes) <= n || closes[len(closes)-1-n] <= 0 {
+		return nil
+	}
+	v := (closes[len(closes)-1]/closes[len(closes)-1-n] - 1) * 100
+	return &v
+}
@@ -0,0 +1,395 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Alert conditions
+const (
+	AlertPriceAbove   = "price_above"   // last price >= threshold
+	AlertPriceBelow   = "price_below"   // last price <= threshold
+	AlertPctFromOpen  = "pct_from_open" // move from today's open, in percent; negative for drops
+	AlertCross52wHigh = "cross_52w_high"
+	AlertCross52wLow  = "cross_52w_low"
+	AlertVolumeSpike  = "volume_spike" // minute volume >= threshold x the recent average
+)
+
+// Alert modes
+const (
+	AlertModeOnce  = "once"
+	AlertModeRearm = "rearm"
+)
+
+type PriceAlert struct {
+	ID              int64      `json:"id"`
+	UserID          string     `json:"user_id"`
+	Ticker          string     `json:"ticker"`
+	Condition       string     `json:"condition"`
+	Threshold       float64    `json:"threshold"`
+	Mode            string     `json:"mode"`
+	CooldownSeconds int        `json:"cooldown_seconds"`
+	Note            string     `json:"note"`
+	WebhookURL      string     `json:"webhook_url,omitempty"`
+	WebhookSecret   string     `json:"webhook_secret,omitempty"`
+	Active          bool       `json:"active"`
+	Armed           bool       `json:"armed"`
+	TriggerCount    int        `json:"trigger_count"`
+	LastTriggeredAt *time.Time `json:"last_triggered_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type PriceAlertEvent struct {
+	ID            int64     `json:"id"`
+	AlertID       int64     `json:"alert_id"`
+	UserID        string    `json:"user_id"`
+	Ticker        string    `json:"ticker"`
+	Price         float64   `json:"price"`
+	Value         float64   `json:"value"` // what the condition compared, e.g. % move or volume ratio
+	Message       string    `json:"message"`
+	WebhookStatus string    `json:"webhook_status"`
+	TriggeredAt   time.Time `json:"triggered_at"`
+}
+
+// PriceAlertUpdate is a partial update; nil fields are left alone.
+type PriceAlertUpdate struct {
+	Threshold       *float64 `json:"threshold"`
+	Mode            *string  `json:"mode"`
+	CooldownSeconds *int     `json:"cooldown_seconds"`
+	Note            *string  `json:"note"`
+	WebhookURL      *string  `json:"webhook_url"`
+	Active          *bool    `json:"active"`
+}
+
+type AlertService struct {
+	db *sql.DB
+}
+
+func NewAlertService(db *sql.DB) *AlertService {
+	return &AlertService{db: db}
+}
+
+const alertColumns = `id, user_id, ticker, condition, threshold, mode, cooldown_seconds, note,
+        webhook_url, webhook_secret, active, armed, trigger_count, last_triggered_at,
+        created_at, updated_at`
+
+func scanAlert(row interface{ Scan(...any) error }) (*PriceAlert, error) {
+	var a PriceAlert
+	var last sql.NullTime
+	err := row.Scan(&a.ID, &a.UserID, &a.Ticker, &a.Condition, &a.Threshold, &a.Mode,
+		&a.CooldownSeconds, &a.Note, &a.WebhookURL, &a.WebhookSecret, &a.Active, &a.Armed,
+		&a.TriggerCount, &last, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if last.Valid {
+		a.LastTriggeredAt = &last.Time
+	}
+	return &a, nil
+}
+
+func (s *AlertService) queryAlerts(ctx context.Context, query string, args ...any) ([]PriceAlert, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []PriceAlert{}
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *a)
+	}
+	return res, rows.Err()
+}
+
+// ValidationError is a bad field in a request (alert, order...), reported
+// to the client as is.
+type ValidationError string
+
+func (e ValidationError) Error() string { return string(e) }
+
+// ValidateAlert normalizes a new alert and reports the first invalid field.
+func ValidateAlert(a *PriceAlert) error {
+	a.Ticker = strings.ToUpper(strings.TrimSpace(a.Ticker))
+	if a.Ticker == "" {
+		return ValidationError("ticker required")
+	}
+	switch a.Condition {
+	case AlertPriceAbove, AlertPriceBelow:
+		if a.Threshold <= 0 {
+			return ValidationError("threshold must be a positive price")
+		}
+	case AlertPctFromOpen:
+		if a.Threshold == 0 {
+			return ValidationError("threshold must be a non-zero percent")
+		}
+	case AlertVolumeSpike:
+		if a.Threshold <= 1 {
+			return ValidationError("threshold must be a volume multiple above 1")
+		}
+	case AlertCross52wHigh, AlertCross52wLow:
+		// the level comes from the daily bars
+	default:
+		return ValidationError(fmt.Sprintf("unknown condition %q", a.Condition))
+	}
+	if a.Mode == "" {
+		a.Mode = AlertModeOnce
+	}
+	if a.Mode != AlertModeOnce && a.Mode != AlertModeRearm {
+		return ValidationError(fmt.Sprintf("mode must be %s or %s", AlertModeOnce, AlertModeRearm))
+	}
+	if a.CooldownSeconds < 0 {
+		return ValidationError("cooldown_seconds must not be negative")
+	}
+	return validateWebhookURL(a.WebhookURL)
+}
+
+// webhookResolveTimeout bounds the DNS lookup of a webhook host.
+const webhookResolveTimeout = 5 * time.Second
+
+// validateWebhookURL checks the URL and that its host resolves to public
+// addresses only, so a webhook can't reach the metadata endpoint, our
+// Redis or anything else on the private network. The sender checks again
+// when it dials, as DNS can change in between.
+func validateWebhookURL(raw string) error {
+	if raw == "" {
+		return nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ValidationError("webhook_url must be an http(s) URL")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookResolveTimeout)
+	defer cancel()
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return ValidationError("webhook_url host does not resolve")
+	}
+	for _, a := range addrs {
+		if !WebhookIPAllowed(a.IP) {
+			return ValidationError("webhook_url must point to a public address")
+		}
+	}
+	return nil
+}
+
+// WebhookIPAllowed is false for loopback, private, link-local, multicast
+// and unspecified addresses.
+func WebhookIPAllowed(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !ip.IsLoopback()
+}
+
+// newWebhookSecret returns the per-alert key used to sign webhook bodies.
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CreateAlert stores a validated alert. Alerts with a webhook get a signing
+// secret, returned to the owner with the alert.
+func (s *AlertService) CreateAlert(ctx context.Context, a PriceAlert) (*PriceAlert, error) {
+	if a.WebhookURL != "" {
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		a.WebhookSecret = secret
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+        INSERT INTO price_alerts (user_id, ticker, condition, threshold, mode, cooldown_seconds, note, webhook_url, webhook_secret)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        RETURNING `+alertColumns,
+		a.UserID, a.Ticker, a.Condition, a.Threshold, a.Mode, a.CooldownSeconds, a.Note, a.WebhookURL, a.WebhookSecret,
+	)
+	return scanAlert(row)
+}
+
+func (s *AlertService) GetAlert(ctx context.Context, userID string, id int64) (*PriceAlert, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+alertColumns+` FROM price_alerts WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	)
+	return scanAlert(row)
+}
+
+func (s *AlertService) ListAlerts(ctx context.Context, userID string) ([]PriceAlert, error) {
+	return s.queryAlerts(ctx,
+		`SELECT `+alertColumns+` FROM price_alerts WHERE user_id = $1 ORDER BY created_at DESC`,
+		userID,
+	)
+}
+
+// ListActiveAlerts returns every alert the engine has to watch.
+func (s *AlertService) ListActiveAlerts(ctx context.Context) ([]PriceAlert, error) {
+	return s.queryAlerts(ctx,
+		`SELECT `+alertColumns+` FROM price_alerts WHERE active ORDER BY ticker, id`,
+	)
+}
+
+// UpdateAlert applies a partial update. Re-activating an alert also re-arms
+// it. Returns sql.ErrNoRows when the alert isn't the user's.
+func (s *AlertService) UpdateAlert(ctx context.Context, userID string, id int64, u PriceAlertUpdate) (*PriceAlert, error) {
+	a, err := s.GetAlert(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Threshold != nil {
+		a.Threshold = *u.Threshold
+	}
+	if u.Mode != nil {
+		a.Mode = *u.Mode
+	}
+	if u.CooldownSeconds != nil {
+		a.CooldownSeconds = *u.CooldownSeconds
+	}
+	if u.Note != nil {
+		a.Note = *u.Note
+	}
+	if u.WebhookURL != nil {
+		a.WebhookURL = *u.WebhookURL
+	}
+	if err := ValidateAlert(a); err != nil {
+		return nil, err
+	}
+	if a.WebhookURL != "" && a.WebhookSecret == "" {
+		if a.WebhookSecret, err = newWebhookSecret(); err != nil {
+			return nil, err
+		}
+	}
+	active := a.Active
+	if u.Active != nil {
+		active = *u.Active
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+        UPDATE price_alerts
+        SET threshold = $3, mode = $4, cooldown_seconds = $5, note = $6,
+            webhook_url = $7, webhook_secret = $8,
+            armed = armed OR ($9 AND NOT active),
+            active = $9,
+            updated_at = NOW()
+        WHERE id = $1 AND user_id = $2
+        RETURNING `+alertColumns,
+		id, userID, a.Threshold, a.Mode, a.CooldownSeconds, a.Note, a.WebhookURL, a.WebhookSecret, active,
+	)
+	return scanAlert(row)
+}
+
+// DeleteAlert removes the alert and its history. Returns sql.ErrNoRows when
+// the alert isn't the user's.
+func (s *AlertService) DeleteAlert(ctx context.Context, userID string, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM price_alerts WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ClaimAlert marks an armed alert as fired. Only one caller wins, so several
+// instances evaluating the same stream never fire twice. One-shot alerts are
+// deactivated, re-arming ones are disarmed until RearmAlert.
+func (s *AlertService) ClaimAlert(ctx context.Context, id int64) (*PriceAlert, bool, error) {
+	row := s.db.QueryRowContext(ctx, `
+        UPDATE price_alerts
+        SET armed = FALSE,
+            active = (mode = 'rearm'),
+            trigger_count = trigger_count + 1,
+            last_triggered_at = NOW(),
+            updated_at = NOW()
+        WHERE id = $1 AND active AND armed
+          AND (last_triggered_at IS NULL
+               OR last_triggered_at + make_interval(secs => cooldown_seconds) <= NOW())
+        RETURNING `+alertColumns,
+		id,
+	)
+	a, err := scanAlert(row)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return a, true, nil
+}
+
+// RearmAlert lets a re-arming alert fire again once its cooldown is over.
+func (s *AlertService) RearmAlert(ctx context.Context, id int64) error {
+	_, err := s.db.ExecContext(ctx, `
+        UPDATE price_alerts
+        SET armed = TRUE, updated_at = NOW()
+        WHERE id = $1 AND active AND NOT armed AND mode = 'rearm'
+          AND (last_triggered_at IS NULL
+               OR last_triggered_at + make_interval(secs => cooldown_seconds) <= NOW())
+    `, id)
+	return err
+}
+
+func (s *AlertService) RecordAlertEvent(ctx context.Context, e PriceAlertEvent) (*PriceAlertEvent, error) {
+	err := s.db.QueryRowContext(ctx, `
+        INSERT INTO price_alert_events (alert_id, user_id, ticker, price, value, message)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, triggered_at
+    `, e.AlertID, e.UserID, e.Ticker, e.Price, e.Value, e.Message).Scan(&e.ID, &e.TriggeredAt)
+	if err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+func (s *AlertService) SetWebhookStatus(ctx context.Context, eventID int64, status string) error {
+	_, err := s.db.ExecContext(ctx,
+		`UPDATE price_alert_events SET webhook_status = $2 WHERE id = $1`,
+		eventID, status,
+	)
+	return err
+}
+
+// ListAlertEvents returns the latest firings of one of the user's alerts.
+func (s *AlertService) ListAlertEvents(ctx context.Context, userID string, alertID int64, limit int) ([]PriceAlertEvent, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT id, alert_id, user_id, ticker, price, value, message, webhook_status, triggered_at
+        FROM price_alert_events
+        WHERE alert_id = $1 AND user_id = $2
+        ORDER BY triggered_at DESC
+        LIMIT $3
+    `, alertID, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []PriceAlertEvent{}
+	for rows.Next() {
+		var e PriceAlertEvent
+		if err := rows.Scan(&e.ID, &e.AlertID, &e.UserID, &e.Ticker, &e.Price, &e.Value,
+			&e.Message, &e.WebhookStatus, &e.TriggeredAt); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, rows.Err()
+}
@@ -56,3 +56,21 @@ func (s *AuthService) CreateUser(ctx context.Context, username, password string)
 func (s *AuthService) CheckPassword(u *User, password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password))
 }
+
+// IsAdmin reports whether the user has the admin role. It's read on every
+// admin request so a revoked role takes effect without waiting for the
+// user's token to expire.
+func (s *AuthService) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	var role string
+	err := s.db.QueryRowContext(ctx,
+		`SELECT role FROM users WHERE id = $1`,
+		userID,
+	).Scan(&role)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return role == "admin", nil
+}
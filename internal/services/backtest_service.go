@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backtest is a saved run. Config, Stats and Result are stored as given
+// (see internal/backtest); Result is left out of listings.
+type Backtest struct {
+	ID         int64           `json:"id"`
+	UserID     string          `json:"user_id"`
+	Name       string          `json:"name"`
+	Ticker     string          `json:"ticker"`
+	Timespan   string          `json:"timespan"`
+	Multiplier int             `json:"multiplier"`
+	From       string          `json:"from"`
+	To         string          `json:"to"`
+	Config     json.RawMessage `json:"config"`
+	Stats      json.RawMessage `json:"stats"`
+	Result     json.RawMessage `json:"result,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type BacktestService struct {
+	db *sql.DB
+}
+
+func NewBacktestService(db *sql.DB) *BacktestService {
+	return &BacktestService{db: db}
+}
+
+func (s *BacktestService) CreateBacktest(ctx context.Context, b Backtest) (*Backtest, error) {
+	err := s.db.QueryRowContext(ctx, `
+        INSERT INTO backtests (user_id, name, ticker, timespan, multiplier, from_date, to_date, config, stats, result)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING id, created_at
+    `, b.UserID, b.Name, b.Ticker, b.Timespan, b.Multiplier, b.From, b.To,
+		string(b.Config), string(b.Stats), string(b.Result)).Scan(&b.ID, &b.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// GetBacktest returns sql.ErrNoRows when the run isn't the user's.
+func (s *BacktestService) GetBacktest(ctx context.Context, userID string, id int64) (*Backtest, error) {
+	var b Backtest
+	var config, stats, result string
+	err := s.db.QueryRowContext(ctx, `
+        SELECT id, user_id, name, ticker, timespan, multiplier,
+               to_char(from_date, 'YYYY-MM-DD'), to_char(to_date, 'YYYY-MM-DD'),
+               config, stats, result, created_at
+        FROM backtests
+        WHERE id = $1 AND user_id = $2
+    `, id, userID).Scan(&b.ID, &b.UserID, &b.Name, &b.Ticker, &b.Timespan, &b.Multiplier,
+		&b.From, &b.To, &config, &stats, &result, &b.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	b.Config, b.Stats, b.Result = json.RawMessage(config), json.RawMessage(stats), json.RawMessage(result)
+	return &b, nil
+}
+
+// ListBacktests returns the user's runs without their results, newest
+// first. ids and ticker narrow the list when set, e.g. to compare a few
+// runs side by side.
+func (s *BacktestService) ListBacktests(ctx context.Context, userID string, ids []int64, ticker string) ([]Backtest, error) {
+	idList := make([]string, len(ids))
+	for i, id := range ids {
+		idList[i] = strconv.FormatInt(id, 10)
+	}
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT id, user_id, name, ticker, timespan, multiplier,
+               to_char(from_date, 'YYYY-MM-DD'), to_char(to_date, 'YYYY-MM-DD'),
+               config, stats, created_at
+        FROM backtests
+        WHERE user_id = $1
+          AND ($2 = '' OR id = ANY(string_to_array($2, ',')::bigint[]))
+          AND ($3 = '' OR ticker = $3)
+        ORDER BY created_at DESC, id DESC
+    `, userID, strings.Join(idList, ","), ticker)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []Backtest{}
+	for rows.Next() {
+		var b Backtest
+		var config, stats string
+		if err := rows.Scan(&b.ID, &b.UserID, &b.Name, &b.Ticker, &b.Timespan, &b.Multiplier,
+			&b.From, &b.To, &config, &stats, &b.CreatedAt); err != nil {
+			return nil, err
+		}
+		b.Config, b.Stats = json.RawMessage(config), json.RawMessage(stats)
+		res = append(res, b)
+	}
+	return res, rows.Err()
+}
+
+// DeleteBacktest returns sql.ErrNoRows when the run isn't the user's.
+func (s *BacktestService) DeleteBacktest(ctx context.Context, userID string, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM backtests WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
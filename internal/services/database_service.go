@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"sort"
@@ -137,57 +138,29 @@ func (s *InstitutionalOwnershipService) GetTopOwnersByName(companyName string, l
 // func (s *InstitutionalOwnershipService) GetTopOwnerByCusip(cusip string) ()
 
 func (s *InstitutionalOwnershipService) GetCompanyByTicker(ticker string) (*CompanyDetail, error) {
-	tickerDetail, err := s.massive.GetTickerDetails(ticker)
+	details, err := s.massive.Details(context.Background(), ticker)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ticker details for %s: %w", ticker, err)
 	}
+	return companyDetail(details)
+}
 
-	// Access the results from the map
-	results, ok := tickerDetail["results"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid response format for ticker %s", ticker)
-	}
-
-	// Extract name
-	name, ok := results["name"].(string)
-	if !ok || name == "" {
-		return nil, fmt.Errorf("company name not found for ticker %s", ticker)
-	}
-
-	// Handle different possible types for weighted_shares_outstanding
-	var weightedSharesOutstanding int64
-	switch v := results["weighted_shares_outstanding"].(type) {
-	case int64:
-		weightedSharesOutstanding = v
-	case float64:
-		weightedSharesOutstanding = int64(v)
-	case int:
-		weightedSharesOutstanding = int64(v)
-	default:
-		return nil, fmt.Errorf("invalid weighted_shares_outstanding type for ticker %s", ticker)
-	}
-
-	// Handle different possible types for market_cap
-	var marketCap float64
-	switch v := results["market_cap"].(type) {
-	case float64:
-		marketCap = v
-	case int64:
-		marketCap = float64(v)
-	case int:
-		marketCap = float64(v)
-	default:
-		return nil, fmt.Errorf("invalid market_cap type for ticker %s", ticker)
+// companyDetail derives the share price from the market cap and the
+// weighted shares outstanding.
+func companyDetail(d *massive.TickerDetails) (*CompanyDetail, error) {
+	if d.Name == "" {
+		return nil, fmt.Errorf("company name not found for ticker %s", d.Ticker)
 	}
 
+	sharesOutstanding := int64(d.WeightedSharesOutstanding)
 	var pricePerShare float64
-	if weightedSharesOutstanding > 0 {
-		pricePerShare = marketCap / float64(weightedSharesOutstanding)
+	if sharesOutstanding > 0 {
+		pricePerShare = d.MarketCap / float64(sharesOutstanding)
 	}
 
 	return &CompanyDetail{
-		Name:              name,
-		SharesOutstanding: weightedSharesOutstanding,
+		Name:              d.Name,
+		SharesOutstanding: sharesOutstanding,
 		PricePerShare:     pricePerShare,
 	}, nil
 }
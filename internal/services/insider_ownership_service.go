@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"strconv"
@@ -140,126 +141,34 @@ func (s *InsiderOwnershipService) GetTopInsidersFiltered(ticker string, startYea
 
 // Helper function to get company details by ticker (reused from institutional service pattern)
 func (s *InsiderOwnershipService) getCompanyByTicker(ticker string) (*CompanyDetail, error) {
-	tickerDetail, err := s.massive.GetTickerDetails(ticker)
+	details, err := s.massive.Details(context.Background(), ticker)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ticker details for %s: %w", ticker, err)
 	}
-
-	// Access the results from the map
-	results, ok := tickerDetail["results"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid response format for ticker %s", ticker)
-	}
-
-	// Extract name
-	name, ok := results["name"].(string)
-	if !ok || name == "" {
-		return nil, fmt.Errorf("company name not found for ticker %s", ticker)
-	}
-
-	// Handle different possible types for weighted_shares_outstanding
-	var weightedSharesOutstanding int64
-	switch v := results["weighted_shares_outstanding"].(type) {
-	case int64:
-		weightedSharesOutstanding = v
-	case float64:
-		weightedSharesOutstanding = int64(v)
-	case int:
-		weightedSharesOutstanding = int64(v)
-	default:
-		return nil, fmt.Errorf("invalid weighted_shares_outstanding type for ticker %s", ticker)
-	}
-
-	// Handle different possible types for market_cap
-	var marketCap float64
-	switch v := results["market_cap"].(type) {
-	case float64:
-		marketCap = v
-	case int64:
-		marketCap = float64(v)
-	case int:
-		marketCap = float64(v)
-	default:
-		return nil, fmt.Errorf("invalid market_cap type for ticker %s", ticker)
-	}
-
-	var pricePerShare float64
-	if weightedSharesOutstanding > 0 {
-		pricePerShare = marketCap / float64(weightedSharesOutstanding)
-	}
-
-	return &CompanyDetail{
-		Name:              name,
-		SharesOutstanding: weightedSharesOutstanding,
-		PricePerShare:     pricePerShare,
-	}, nil
+	return companyDetail(details)
 }
 
 // Helper function to get company details with CIK by ticker
 func (s *InsiderOwnershipService) getCompanyByTickerWithCIK(ticker string) (*CompanyDetailWithCIK, error) {
-	tickerDetail, err := s.massive.GetTickerDetails(ticker)
+	details, err := s.massive.Details(context.Background(), ticker)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get ticker details for %s: %w", ticker, err)
 	}
-
-	// Access the results from the map
-	results, ok := tickerDetail["results"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid response format for ticker %s", ticker)
-	}
-
-	// Extract name
-	name, ok := results["name"].(string)
-	if !ok || name == "" {
-		return nil, fmt.Errorf("company name not found for ticker %s", ticker)
+	company, err := companyDetail(details)
+	if err != nil {
+		return nil, err
 	}
-
-	// Extract CIK
-	cik, ok := results["cik"].(string)
-	if !ok || cik == "" {
+	if details.CIK == "" {
 		return nil, fmt.Errorf("CIK not found for ticker %s", ticker)
 	}
 
 	// Strip leading zeros from CIK to match database format
 	// CIK from API might be "0001018724" but database stores "1018724"
-	normalizedCIK := stripLeadingZeros(cik)
-
-	// Handle different possible types for weighted_shares_outstanding
-	var weightedSharesOutstanding int64
-	switch v := results["weighted_shares_outstanding"].(type) {
-	case int64:
-		weightedSharesOutstanding = v
-	case float64:
-		weightedSharesOutstanding = int64(v)
-	case int:
-		weightedSharesOutstanding = int64(v)
-	default:
-		return nil, fmt.Errorf("invalid weighted_shares_outstanding type for ticker %s", ticker)
-	}
-
-	// Handle different possible types for market_cap
-	var marketCap float64
-	switch v := results["market_cap"].(type) {
-	case float64:
-		marketCap = v
-	case int64:
-		marketCap = float64(v)
-	case int:
-		marketCap = float64(v)
-	default:
-		return nil, fmt.Errorf("invalid market_cap type for ticker %s", ticker)
-	}
-
-	var pricePerShare float64
-	if weightedSharesOutstanding > 0 {
-		pricePerShare = marketCap / float64(weightedSharesOutstanding)
-	}
-
 	return &CompanyDetailWithCIK{
-		Name:              name,
-		SharesOutstanding: weightedSharesOutstanding,
-		PricePerShare:     pricePerShare,
-		CIK:               normalizedCIK,
+		Name:              company.Name,
+		SharesOutstanding: company.SharesOutstanding,
+		PricePerShare:     company.PricePerShare,
+		CIK:               stripLeadingZeros(details.CIK),
 	}, nil
 }
 
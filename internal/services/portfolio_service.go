@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// PortfolioTransaction is a manually entered trade.
+type PortfolioTransaction struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	Ticker    string    `json:"ticker"`
+	Side      string    `json:"side"`
+	Qty       float64   `json:"qty"`
+	Price     float64   `json:"price"`
+	Fees      float64   `json:"fees"`
+	TradedAt  time.Time `json:"traded_at"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PortfolioService struct {
+	db *sql.DB
+}
+
+func NewPortfolioService(db *sql.DB) *PortfolioService {
+	return &PortfolioService{db: db}
+}
+
+// ValidateTransaction normalizes a manual trade and reports the first
+// invalid field.
+func ValidateTransaction(t *PortfolioTransaction) error {
+	t.Ticker = strings.ToUpper(strings.TrimSpace(t.Ticker))
+	if t.Ticker == "" {
+		return ValidationError("ticker required")
+	}
+	if t.Side != SideBuy && t.Side != SideSell {
+		return ValidationError("side must be buy or sell")
+	}
+	if t.Qty <= 0 {
+		return ValidationError("qty must be positive")
+	}
+	if t.Price < 0 || t.Fees < 0 {
+		return ValidationError("price and fees must not be negative")
+	}
+	if t.TradedAt.IsZero() {
+		t.TradedAt = time.Now()
+	}
+	if t.TradedAt.After(time.Now().Add(time.Minute)) {
+		return ValidationError("traded_at is in the future")
+	}
+	return nil
+}
+
+func (s *PortfolioService) CreateTransaction(ctx context.Context, t PortfolioTransaction) (*PortfolioTransaction, error) {
+	err := s.db.QueryRowContext(ctx, `
+        INSERT INTO portfolio_transactions (user_id, ticker, side, qty, price, fees, traded_at, note)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, created_at
+    `, t.UserID, t.Ticker, t.Side, t.Qty, t.Price, t.Fees, t.TradedAt, t.Note).Scan(&t.ID, &t.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// ListTransactions returns the user's manual trades, oldest first.
+func (s *PortfolioService) ListTransactions(ctx context.Context, userID string) ([]PortfolioTransaction, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT id, user_id, ticker, side, qty, price, fees, traded_at, note, created_at
+        FROM portfolio_transactions
+        WHERE user_id = $1
+        ORDER BY traded_at, id
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []PortfolioTransaction{}
+	for rows.Next() {
+		var t PortfolioTransaction
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Ticker, &t.Side, &t.Qty, &t.Price, &t.Fees,
+			&t.TradedAt, &t.Note, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, t)
+	}
+	return res, rows.Err()
+}
+
+// DeleteTransaction returns sql.ErrNoRows when the trade isn't the user's.
+func (s *PortfolioService) DeleteTransaction(ctx context.Context, userID string, id int64) error {
+	res, err := s.db.ExecContext(ctx,
+		`DELETE FROM portfolio_transactions WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListPaperFills returns every paper-trading fill of the user, oldest first.
+func (s *PortfolioService) ListPaperFills(ctx context.Context, userID string) ([]PaperFill, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT f.id, f.order_id, f.account_id, f.ticker, f.side, f.qty, f.price, f.filled_at
+        FROM paper_fills f
+        JOIN paper_accounts a ON a.id = f.account_id
+        WHERE a.user_id = $1
+        ORDER BY f.filled_at, f.id
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []PaperFill{}
+	for rows.Next() {
+		var f PaperFill
+		if err := rows.Scan(&f.ID, &f.OrderID, &f.AccountID, &f.Ticker, &f.Side, &f.Qty, &f.Price, &f.FilledAt); err != nil {
+			return nil, err
+		}
+		res = append(res, f)
+	}
+	return res, rows.Err()
+}
+
+// GetPaperAccount returns the user's paper account without opening one.
+// Returns sql.ErrNoRows when the user never traded on paper.
+func (s *PortfolioService) GetPaperAccount(ctx context.Context, userID string) (*PaperAccount, error) {
+	var a PaperAccount
+	err := s.db.QueryRowContext(ctx, `
+        SELECT id, user_id, cash, starting_cash, created_at, updated_at
+        FROM paper_accounts WHERE user_id = $1
+    `, userID).Scan(&a.ID, &a.UserID, &a.Cash, &a.StartingCash, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
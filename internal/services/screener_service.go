@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ScreenerRow is one ticker of screener_universe. Missing metrics are nil.
+type ScreenerRow struct {
+	Ticker   string `json:"ticker"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Exchange string `json:"exchange"`
+
+	Price     *float64 `json:"price"`
+	ChangePct *float64 `json:"change_pct"`
+	Volume    *float64 `json:"volume"`
+	PrevClose *float64 `json:"prev_close"`
+
+	MarketCap     *float64 `json:"market_cap"`
+	PE            *float64 `json:"pe"`
+	PB            *float64 `json:"pb"`
+	PS            *float64 `json:"ps"`
+	EVEBITDA      *float64 `json:"ev_ebitda"`
+	EPS           *float64 `json:"eps"`
+	DividendYield *float64 `json:"dividend_yield"`
+	ROE           *float64 `json:"roe"`
+	ROA           *float64 `json:"roa"`
+	DebtToEquity  *float64 `json:"debt_to_equity"`
+	CurrentRatio  *float64 `json:"current_ratio"`
+	FreeCashFlow  *float64 `json:"free_cash_flow"`
+	AvgVolume     *float64 `json:"avg_volume"`
+
+	RSI14    *float64 `json:"rsi_14"`
+	SMA20    *float64 `json:"sma_20"`
+	SMA50    *float64 `json:"sma_50"`
+	SMA200   *float64 `json:"sma_200"`
+	Return1M *float64 `json:"return_1m"`
+	Return3M *float64 `json:"return_3m"`
+
+	ShortInterest       *float64 `json:"short_interest"`
+	ShortInterestPrev   *float64 `json:"short_interest_prev"`
+	ShortInterestChange *float64 `json:"short_interest_change"`
+	DaysToCover         *float64 `json:"days_to_cover"`
+
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// screenerColumns are the screener_universe columns in ScreenerRow order,
+// updated_at aside.
+const screenerColumns = `ticker, name, type, exchange,
+    price, change_pct, volume, prev_close,
+    market_cap, pe, pb, ps, ev_ebitda, eps, dividend_yield, roe, roa,
+    debt_to_equity, current_ratio, free_cash_flow, avg_volume,
+    rsi_14, sma_20, sma_50, sma_200, return_1m, return_3m,
+    short_interest, short_interest_prev, short_interest_change, days_to_cover`
+
+func (r *ScreenerRow) fields() []interface{} {
+	return []interface{}{&r.Ticker, &r.Name, &r.Type, &r.Exchange,
+		&r.Price, &r.ChangePct, &r.Volume, &r.PrevClose,
+		&r.MarketCap, &r.PE, &r.PB, &r.PS, &r.EVEBITDA, &r.EPS, &r.DividendYield, &r.ROE, &r.ROA,
+		&r.DebtToEquity, &r.CurrentRatio, &r.FreeCashFlow, &r.AvgVolume,
+		&r.RSI14, &r.SMA20, &r.SMA50, &r.SMA200, &r.Return1M, &r.Return3M,
+		&r.ShortInterest, &r.ShortInterestPrev, &r.ShortInterestChange, &r.DaysToCover}
+}
+
+// SavedScreen is a user's filter and sort, kept for reuse.
+type SavedScreen struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	Filter    string    `json:"filter"`
+	Sort      string    `json:"sort"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ScreenerService struct {
+	db *sql.DB
+}
+
+func NewScreenerService(db *sql.DB) *ScreenerService {
+	return &ScreenerService{db: db}
+}
+
+// screenerRefreshLock is the advisory lock key held during a refresh.
+const screenerRefreshLock = 7210019
+
+// WithRefreshLock runs fn while holding a Postgres advisory lock, so one
+// instance refreshes the universe at a time. It returns false without
+// running fn when another instance holds the lock.
+func (s *ScreenerService) WithRefreshLock(ctx context.Context, fn func() error) (bool, error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, screenerRefreshLock).Scan(&locked); err != nil {
+		return false, err
+	}
+	if !locked {
+		return false, nil
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, screenerRefreshLock)
+	return true, fn()
+}
+
+// ReplaceUniverse swaps the whole universe for rows in one transaction.
+func (s *ScreenerService) ReplaceUniverse(ctx context.Context, rows []ScreenerRow) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM screener_universe`); err != nil {
+		return err
+	}
+	placeholders := make([]string, strings.Count(screenerColumns, ",")+1)
+	for i := range placeholders {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO screener_universe (`+screenerColumns+`)
+        VALUES (`+strings.Join(placeholders, ", ")+`)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for i := range rows {
+		// database/sql dereferences the pointers; nil metrics become NULL
+		if _, err := stmt.ExecContext(ctx, rows[i].fields()...); err != nil {
+			return fmt.Errorf("insert %s: %w", rows[i].Ticker, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Screen returns one page of the universe rows matching where (with its
+// args), ordered by orderBy, and how many match in total. where and
+// orderBy must come from the screener package, which only lets known
+// columns through.
+func (s *ScreenerService) Screen(ctx context.Context, where string, args []interface{}, orderBy string, limit, offset int) ([]ScreenerRow, int, error) {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+        SELECT %s, updated_at, COUNT(*) OVER ()
+        FROM screener_universe
+        WHERE %s
+        ORDER BY %s
+        LIMIT %d OFFSET %d
+    `, screenerColumns, where, orderBy, limit, offset), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	res := []ScreenerRow{}
+	total := 0
+	for rows.Next() {
+		var r ScreenerRow
+		if err := rows.Scan(append(r.fields(), &r.UpdatedAt, &total)...); err != nil {
+			return nil, 0, err
+		}
+		res = append(res, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+	// Past the last page the window count isn't there
+	if len(res) == 0 && offset > 0 {
+		err = s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM screener_universe WHERE `+where, args...).Scan(&total)
+	}
+	return res, total, err
+}
+
+// UniverseUpdatedAt is when the universe was last rebuilt, zero if never.
+func (s *ScreenerService) UniverseUpdatedAt(ctx context.Context) (time.Time, int, error) {
+	var updated sql.NullTime
+	var count int
+	err := s.db.QueryRowContext(ctx, `SELECT MAX(updated_at), COUNT(*) FROM screener_universe`).Scan(&updated, &count)
+	return updated.Time, count, err
+}
+
+// CloseDays returns the days (YYYY-MM-DD) already in screener_closes.
+func (s *ScreenerService) CloseDays(ctx context.Context) (map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT to_char(day, 'YYYY-MM-DD') FROM screener_close_days`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make(map[string]bool)
+	for rows.Next() {
+		var d string
+		if err := rows.Scan(&d); err != nil {
+			return nil, err
+		}
+		days[d] = true
+	}
+	return days, rows.Err()
+}
+
+// SaveCloses stores one day of closes for the whole market. An empty day
+// (a holiday) is recorded too.
+func (s *ScreenerService) SaveCloses(ctx context.Context, day string, tickers []string, closes []float64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(tickers) > 0 {
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO screener_closes (ticker, day, close)
+            SELECT t, $1::date, c FROM unnest($2::text[], $3::float8[]) AS u(t, c)
+            ON CONFLICT (ticker, day) DO UPDATE SET close = EXCLUDED.close, fetched_on = EXCLUDED.fetched_on
+        `, day, pq.Array(tickers), pq.Array(closes))
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO screener_close_days (day, tickers) VALUES ($1, $2)
+        ON CONFLICT (day) DO UPDATE SET tickers = EXCLUDED.tickers
+    `, day, len(tickers))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ClosesBefore reports whether ticker has closes for days before day that
+// were fetched before day too, i.e. not yet adjusted for a split on day.
+func (s *ScreenerService) ClosesBefore(ctx context.Context, ticker, day string) (bool, error) {
+	var stale bool
+	err := s.db.QueryRowContext(ctx, `
+        SELECT EXISTS (
+            SELECT 1 FROM screener_closes
+            WHERE ticker = $1 AND day < $2 AND (fetched_on IS NULL OR fetched_on < $2)
+        )
+    `, ticker, day).Scan(&stale)
+	return stale, err
+}
+
+// ReplaceTickerCloses swaps all of ticker's closes for days/closes.
+func (s *ScreenerService) ReplaceTickerCloses(ctx context.Context, ticker string, days []string, closes []float64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM screener_closes WHERE ticker = $1`, ticker); err != nil {
+		return err
+	}
+	if len(days) > 0 {
+		_, err = tx.ExecContext(ctx, `
+            INSERT INTO screener_closes (ticker, day, close)
+            SELECT $1, d, c FROM unnest($2::date[], $3::float8[]) AS u(d, c)
+        `, ticker, pq.Array(days), pq.Array(closes))
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PruneCloses drops the closes before day.
+func (s *ScreenerService) PruneCloses(ctx context.Context, day string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM screener_closes WHERE day < $1`, day); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM screener_close_days WHERE day < $1`, day)
+	return err
+}
+
+// LoadCloses returns each ticker's closes since day, oldest first.
+func (s *ScreenerService) LoadCloses(ctx context.Context, day string) (map[string][]float64, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT ticker, close FROM screener_closes
+        WHERE day >= $1
+        ORDER BY ticker, day
+    `, day)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	closes := make(map[string][]float64)
+	for rows.Next() {
+		var ticker string
+		var c float64
+		if err := rows.Scan(&ticker, &c); err != nil {
+			return nil, err
+		}
+		closes[ticker] = append(closes[ticker], c)
+	}
+	return closes, rows.Err()
+}
+
+func validateScreenName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ValidationError("name required")
+	}
+	if len(name) > 100 {
+		return "", ValidationError("name is longer than 100 characters")
+	}
+	return name, nil
+}
+
+// checkScreenNameFree reports a ValidationError when the user has another
+// screen (not except) with that name.
+func (s *ScreenerService) checkScreenNameFree(ctx context.Context, userID, name string, except int64) error {
+	var exists bool
+	err := s.db.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM screener_screens WHERE user_id = $1 AND name = $2 AND id <> $3)
+    `, userID, name, except).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ValidationError("a screen with that name already exists")
+	}
+	return nil
+}
+
+// CreateScreen saves a screen; filter and sort must have been validated.
+func (s *ScreenerService) CreateScreen(ctx context.Context, sc SavedScreen) (*SavedScreen, error) {
+	name, err := validateScreenName(sc.Name)
+	if err != nil {
+		return nil, err
+	}
+	sc.Name = name
+	if err := s.checkScreenNameFree(ctx, sc.UserID, sc.Name, 0); err != nil {
+		return nil, err
+	}
+	err = s.db.QueryRowContext(ctx, `
+        INSERT INTO screener_screens (user_id, name, filter, sort)
+        VALUES ($1, $2, $3, $4)
+        RETURNING id, created_at, updated_at
+    `, sc.UserID, sc.Name, sc.Filter, sc.Sort).Scan(&sc.ID, &sc.CreatedAt, &sc.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+func (s *ScreenerService) ListScreens(ctx context.Context, userID string) ([]SavedScreen, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT id, user_id, name, filter, sort, created_at, updated_at
+        FROM screener_screens
+        WHERE user_id = $1
+        ORDER BY name
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []SavedScreen{}
+	for rows.Next() {
+		var sc SavedScreen
+		if err := rows.Scan(&sc.ID, &sc.UserID, &sc.Name, &sc.Filter, &sc.Sort, &sc.CreatedAt, &sc.UpdatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, sc)
+	}
+	return res, rows.Err()
+}
+
+// GetScreen returns sql.ErrNoRows when the screen isn't the user's.
+func (s *ScreenerService) GetScreen(ctx context.Context, userID string, id int64) (*SavedScreen, error) {
+	var sc SavedScreen
+	err := s.db.QueryRowContext(ctx, `
+        SELECT id, user_id, name, filter, sort, created_at, updated_at
+        FROM screener_screens
+        WHERE id = $1 AND user_id = $2
+    `, id, userID).Scan(&sc.ID, &sc.UserID, &sc.Name, &sc.Filter, &sc.Sort, &sc.CreatedAt, &sc.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// UpdateScreen changes the fields that are set; filter and sort must have
+// been validated. Returns sql.ErrNoRows when the screen isn't the user's.
+func (s *ScreenerService) UpdateScreen(ctx context.Context, userID string, id int64, name, filter, sort *string) (*SavedScreen, error) {
+	if name != nil {
+		n, err := validateScreenName(*name)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkScreenNameFree(ctx, userID, n, id); err != nil {
+			return nil, err
+		}
+		name = &n
+	}
+	var sc SavedScreen
+	err := s.db.QueryRowContext(ctx, `
+        UPDATE screener_screens
+        SET name = COALESCE($3, name),
+            filter = COALESCE($4, filter),
+            sort = COALESCE($5, sort),
+            updated_at = NOW()
+        WHERE id = $1 AND user_id = $2
+        RETURNING id, user_id, name, filter, sort, created_at, updated_at
+    `, id, userID, name, filter, sort).Scan(&sc.ID, &sc.UserID, &sc.Name, &sc.Filter, &sc.Sort, &sc.CreatedAt, &sc.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// DeleteScreen returns sql.ErrNoRows when the screen isn't the user's.
+func (s *ScreenerService) DeleteScreen(ctx context.Context, userID string, id int64) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM screener_screens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,573 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // day orders expire at the New York close
+)
+
+// Order sides, types, time-in-force and statuses
+const (
+	SideBuy  = "buy"
+	SideSell = "sell"
+
+	OrderMarket    = "market"
+	OrderLimit     = "limit"
+	OrderStop      = "stop"
+	OrderStopLimit = "stop_limit"
+
+	TIFDay = "day"
+	TIFGTC = "gtc"
+
+	OrderOpen      = "open"
+	OrderTriggered = "triggered" // a stop-limit whose stop was hit, now working as a limit
+	OrderFilled    = "filled"
+	OrderCancelled = "cancelled"
+	OrderExpired   = "expired"
+	OrderRejected  = "rejected"
+)
+
+// ErrOrderNotWorking is returned when cancelling an order that already
+// filled, expired or was cancelled.
+var ErrOrderNotWorking = errors.New("order is no longer open")
+
+type PaperAccount struct {
+	ID           int64     `json:"id"`
+	UserID       string    `json:"user_id"`
+	Cash         float64   `json:"cash"`
+	StartingCash float64   `json:"starting_cash"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type PaperOrder struct {
+	ID           int64      `json:"id"`
+	AccountID    int64      `json:"account_id"`
+	UserID       string     `json:"user_id"`
+	Ticker       string     `json:"ticker"`
+	Side         string     `json:"side"`
+	Type         string     `json:"type"`
+	Qty          float64    `json:"qty"`
+	LimitPrice   *float64   `json:"limit_price"`
+	StopPrice    *float64   `json:"stop_price"`
+	TimeInForce  string     `json:"time_in_force"`
+	Status       string     `json:"status"`
+	FilledQty    float64    `json:"filled_qty"`
+	AvgFillPrice *float64   `json:"avg_fill_price"`
+	RejectReason string     `json:"reject_reason,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	FilledAt     *time.Time `json:"filled_at"`
+}
+
+// Working reports whether the order can still fill.
+func (o PaperOrder) Working() bool {
+	return o.Status == OrderOpen || o.Status == OrderTriggered
+}
+
+// Match reports whether o fills at price, or (for a stop-limit whose stop
+// was hit but whose limit isn't reached yet) only triggers.
+func (o PaperOrder) Match(price float64) (fill, trigger bool) {
+	buy := o.Side == SideBuy
+
+	limitOK := func() bool {
+		if buy {
+			return price <= *o.LimitPrice
+		}
+		return price >= *o.LimitPrice
+	}
+	stopHit := func() bool {
+		if buy {
+			return price >= *o.StopPrice
+		}
+		return price <= *o.StopPrice
+	}
+
+	switch o.Type {
+	case OrderMarket:
+		return true, false
+	case OrderLimit:
+		return limitOK(), false
+	case OrderStop:
+		return stopHit(), false
+	case OrderStopLimit:
+		if o.Status == OrderTriggered {
+			return limitOK(), false
+		}
+		if !stopHit() {
+			return false, false
+		}
+		return limitOK(), !limitOK()
+	}
+	return false, false
+}
+
+type PaperFill struct {
+	ID        int64     `json:"id"`
+	OrderID   int64     `json:"order_id"`
+	AccountID int64     `json:"account_id"`
+	Ticker    string    `json:"ticker"`
+	Side      string    `json:"side"`
+	Qty       float64   `json:"qty"`
+	Price     float64   `json:"price"`
+	FilledAt  time.Time `json:"filled_at"`
+}
+
+type PaperPosition struct {
+	AccountID   int64     `json:"account_id"`
+	Ticker      string    `json:"ticker"`
+	Qty         float64   `json:"qty"`
+	AvgCost     float64   `json:"avg_cost"`
+	RealizedPnL float64   `json:"realized_pnl"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type TradingService struct {
+	db           *sql.DB
+	startingCash float64
+	market       *time.Location
+}
+
+func NewTradingService(db *sql.DB, startingCash float64) *TradingService {
+	market, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		market = time.UTC
+	}
+	return &TradingService{db: db, startingCash: startingCash, market: market}
+}
+
+const orderColumns = `id, account_id, user_id, ticker, side, type, qty, limit_price, stop_price,
+        time_in_force, status, filled_qty, avg_fill_price, reject_reason, expires_at,
+        created_at, updated_at, filled_at`
+
+func scanOrder(row interface{ Scan(...any) error }) (*PaperOrder, error) {
+	var o PaperOrder
+	var limit, stop, avg sql.NullFloat64
+	var expires, filled sql.NullTime
+	err := row.Scan(&o.ID, &o.AccountID, &o.UserID, &o.Ticker, &o.Side, &o.Type, &o.Qty,
+		&limit, &stop, &o.TimeInForce, &o.Status, &o.FilledQty, &avg, &o.RejectReason,
+		&expires, &o.CreatedAt, &o.UpdatedAt, &filled)
+	if err != nil {
+		return nil, err
+	}
+	if limit.Valid {
+		o.LimitPrice = &limit.Float64
+	}
+	if stop.Valid {
+		o.StopPrice = &stop.Float64
+	}
+	if avg.Valid {
+		o.AvgFillPrice = &avg.Float64
+	}
+	if expires.Valid {
+		o.ExpiresAt = &expires.Time
+	}
+	if filled.Valid {
+		o.FilledAt = &filled.Time
+	}
+	return &o, nil
+}
+
+func (s *TradingService) queryOrders(ctx context.Context, query string, args ...any) ([]PaperOrder, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []PaperOrder{}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, *o)
+	}
+	return res, rows.Err()
+}
+
+// GetOrCreateAccount returns the user's paper account, opening one with the
+// configured starting cash on first use.
+func (s *TradingService) GetOrCreateAccount(ctx context.Context, userID string) (*PaperAccount, error) {
+	var a PaperAccount
+	err := s.db.QueryRowContext(ctx, `
+        INSERT INTO paper_accounts (user_id, cash, starting_cash)
+        VALUES ($1, $2, $2)
+        ON CONFLICT (user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+        RETURNING id, user_id, cash, starting_cash, created_at, updated_at
+    `, userID, s.startingCash).Scan(&a.ID, &a.UserID, &a.Cash, &a.StartingCash, &a.CreatedAt, &a.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// ValidateOrder normalizes a new order and reports the first invalid field.
+func ValidateOrder(o *PaperOrder) error {
+	o.Ticker = strings.ToUpper(strings.TrimSpace(o.Ticker))
+	if o.Ticker == "" {
+		return ValidationError("ticker required")
+	}
+	if strings.HasPrefix(o.Ticker, "I:") {
+		return ValidationError("indices can't be traded")
+	}
+	if o.Side != SideBuy && o.Side != SideSell {
+		return ValidationError(fmt.Sprintf("side must be %s or %s", SideBuy, SideSell))
+	}
+	if o.Qty <= 0 {
+		return ValidationError("qty must be positive")
+	}
+
+	positive := func(p *float64) bool { return p != nil && *p > 0 }
+	switch o.Type {
+	case OrderMarket:
+		o.LimitPrice, o.StopPrice = nil, nil
+	case OrderLimit:
+		if !positive(o.LimitPrice) {
+			return ValidationError("limit_price required for limit orders")
+		}
+		o.StopPrice = nil
+	case OrderStop:
+		if !positive(o.StopPrice) {
+			return ValidationError("stop_price required for stop orders")
+		}
+		o.LimitPrice = nil
+	case OrderStopLimit:
+		if !positive(o.StopPrice) || !positive(o.LimitPrice) {
+			return ValidationError("stop_price and limit_price required for stop_limit orders")
+		}
+	default:
+		return ValidationError(fmt.Sprintf("unknown order type %q", o.Type))
+	}
+
+	if o.TimeInForce == "" {
+		o.TimeInForce = TIFDay
+	}
+	if o.TimeInForce != TIFDay && o.TimeInForce != TIFGTC {
+		return ValidationError(fmt.Sprintf("time_in_force must be %s or %s", TIFDay, TIFGTC))
+	}
+	return nil
+}
+
+// sessionClose is when a day order placed at now expires: today's 16:00 in
+// New York, or the next weekday's once the session is over. Exchange
+// holidays aren't taken into account.
+func (s *TradingService) sessionClose(now time.Time) time.Time {
+	local := now.In(s.market)
+	end := time.Date(local.Year(), local.Month(), local.Day(), 16, 0, 0, 0, s.market)
+	if !local.Before(end) {
+		end = end.AddDate(0, 0, 1)
+	}
+	for end.Weekday() == time.Saturday || end.Weekday() == time.Sunday {
+		end = end.AddDate(0, 0, 1)
+	}
+	return end
+}
+
+// CreateOrder stores a validated order for the user's account. Sells are
+// rejected up front when the position is too small; buying power is checked
+// when the order fills.
+func (s *TradingService) CreateOrder(ctx context.Context, o PaperOrder) (*PaperOrder, error) {
+	acct, err := s.GetOrCreateAccount(ctx, o.UserID)
+	if err != nil {
+		return nil, err
+	}
+	o.AccountID = acct.ID
+
+	if o.Side == SideSell {
+		var held float64
+		err := s.db.QueryRowContext(ctx,
+			`SELECT COALESCE((SELECT qty FROM paper_positions WHERE account_id = $1 AND ticker = $2), 0)`,
+			acct.ID, o.Ticker,
+		).Scan(&held)
+		if err != nil {
+			return nil, err
+		}
+		if held < o.Qty {
+			return nil, ValidationError(fmt.Sprintf("not enough shares: holding %g %s", held, o.Ticker))
+		}
+	}
+
+	var expires *time.Time
+	if o.TimeInForce == TIFDay {
+		t := s.sessionClose(time.Now())
+		expires = &t
+	}
+
+	row := s.db.QueryRowContext(ctx, `
+        INSERT INTO paper_orders (account_id, user_id, ticker, side, type, qty, limit_price, stop_price, time_in_force, expires_at)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+        RETURNING `+orderColumns,
+		o.AccountID, o.UserID, o.Ticker, o.Side, o.Type, o.Qty, o.LimitPrice, o.StopPrice, o.TimeInForce, expires,
+	)
+	return scanOrder(row)
+}
+
+func (s *TradingService) GetOrder(ctx context.Context, userID string, id int64) (*PaperOrder, error) {
+	row := s.db.QueryRowContext(ctx,
+		`SELECT `+orderColumns+` FROM paper_orders WHERE id = $1 AND user_id = $2`,
+		id, userID,
+	)
+	return scanOrder(row)
+}
+
+// ListOrders returns the user's order history, newest first, optionally
+// filtered by status ("working" matches open and triggered).
+func (s *TradingService) ListOrders(ctx context.Context, userID, status string, limit int) ([]PaperOrder, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	switch status {
+	case "":
+		return s.queryOrders(ctx,
+			`SELECT `+orderColumns+` FROM paper_orders WHERE user_id = $1 ORDER BY created_at DESC LIMIT $2`,
+			userID, limit,
+		)
+	case "working":
+		return s.queryOrders(ctx,
+			`SELECT `+orderColumns+` FROM paper_orders
+             WHERE user_id = $1 AND status IN ('open', 'triggered')
+             ORDER BY created_at DESC LIMIT $2`,
+			userID, limit,
+		)
+	default:
+		return s.queryOrders(ctx,
+			`SELECT `+orderColumns+` FROM paper_orders
+             WHERE user_id = $1 AND status = $2
+             ORDER BY created_at DESC LIMIT $3`,
+			userID, status, limit,
+		)
+	}
+}
+
+// ListWorkingOrders returns every order the fill engine has to watch.
+func (s *TradingService) ListWorkingOrders(ctx context.Context) ([]PaperOrder, error) {
+	return s.queryOrders(ctx,
+		`SELECT `+orderColumns+` FROM paper_orders WHERE status IN ('open', 'triggered') ORDER BY ticker, id`,
+	)
+}
+
+// CancelOrder cancels a working order. Returns sql.ErrNoRows when the order
+// isn't the user's and ErrOrderNotWorking when it can't be cancelled anymore.
+func (s *TradingService) CancelOrder(ctx context.Context, userID string, id int64) (*PaperOrder, error) {
+	row := s.db.QueryRowContext(ctx, `
+        UPDATE paper_orders
+        SET status = 'cancelled', updated_at = NOW()
+        WHERE id = $1 AND user_id = $2 AND status IN ('open', 'triggered')
+        RETURNING `+orderColumns,
+		id, userID,
+	)
+	o, err := scanOrder(row)
+	if err == sql.ErrNoRows {
+		if _, err := s.GetOrder(ctx, userID, id); err != nil {
+			return nil, err
+		}
+		return nil, ErrOrderNotWorking
+	}
+	return o, err
+}
+
+// TriggerOrder turns an open stop-limit into a working limit order. ok is
+// false when the order is no longer open.
+func (s *TradingService) TriggerOrder(ctx context.Context, id int64) (*PaperOrder, bool, error) {
+	row := s.db.QueryRowContext(ctx, `
+        UPDATE paper_orders
+        SET status = 'triggered', updated_at = NOW()
+        WHERE id = $1 AND status = 'open' AND type = 'stop_limit'
+        RETURNING `+orderColumns,
+		id,
+	)
+	o, err := scanOrder(row)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return o, true, nil
+}
+
+// ExpireOrders expires the day orders whose session is over.
+func (s *TradingService) ExpireOrders(ctx context.Context) ([]PaperOrder, error) {
+	return s.queryOrders(ctx, `
+        UPDATE paper_orders
+        SET status = 'expired', updated_at = NOW()
+        WHERE status IN ('open', 'triggered') AND expires_at <= NOW()
+        RETURNING `+orderColumns,
+	)
+}
+
+// FillOrder executes a working order in full at price. The order and
+// account rows are locked, so an order fills once even when several
+// instances race on it. An order the account can't afford (or a sell larger
+// than the position) is rejected instead. fill is nil unless it filled, and
+// the order is nil when it was no longer working.
+func (s *TradingService) FillOrder(ctx context.Context, id int64, price float64) (*PaperOrder, *PaperFill, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	o, err := scanOrder(tx.QueryRowContext(ctx,
+		`SELECT `+orderColumns+` FROM paper_orders WHERE id = $1 AND status IN ('open', 'triggered') FOR UPDATE`,
+		id,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cash float64
+	if err := tx.QueryRowContext(ctx,
+		`SELECT cash FROM paper_accounts WHERE id = $1 FOR UPDATE`, o.AccountID,
+	).Scan(&cash); err != nil {
+		return nil, nil, err
+	}
+
+	var held, avgCost float64
+	err = tx.QueryRowContext(ctx,
+		`SELECT qty, avg_cost FROM paper_positions WHERE account_id = $1 AND ticker = $2 FOR UPDATE`,
+		o.AccountID, o.Ticker,
+	).Scan(&held, &avgCost)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, err
+	}
+
+	notional := o.Qty * price
+	reason := ""
+	switch {
+	case o.Side == SideBuy && notional > cash:
+		reason = fmt.Sprintf("insufficient cash: need %.2f, have %.2f", notional, cash)
+	case o.Side == SideSell && o.Qty > held:
+		reason = fmt.Sprintf("insufficient shares: need %g, have %g", o.Qty, held)
+	}
+	if reason != "" {
+		o, err = scanOrder(tx.QueryRowContext(ctx, `
+            UPDATE paper_orders SET status = 'rejected', reject_reason = $2, updated_at = NOW()
+            WHERE id = $1
+            RETURNING `+orderColumns,
+			id, reason,
+		))
+		if err != nil {
+			return nil, nil, err
+		}
+		return o, nil, tx.Commit()
+	}
+
+	var f PaperFill
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO paper_fills (order_id, account_id, ticker, side, qty, price)
+        VALUES ($1, $2, $3, $4, $5, $6)
+        RETURNING id, order_id, account_id, ticker, side, qty, price, filled_at
+    `, o.ID, o.AccountID, o.Ticker, o.Side, o.Qty, price).Scan(
+		&f.ID, &f.OrderID, &f.AccountID, &f.Ticker, &f.Side, &f.Qty, &f.Price, &f.FilledAt)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Average cost only moves on buys; sells realize P&L against it.
+	cashDelta, qtyDelta, realized := -notional, o.Qty, 0.0
+	newAvg := avgCost
+	if o.Side == SideBuy {
+		newAvg = (held*avgCost + notional) / (held + o.Qty)
+	} else {
+		cashDelta, qtyDelta = notional, -o.Qty
+		realized = (price - avgCost) * o.Qty
+	}
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE paper_accounts SET cash = cash + $2, updated_at = NOW() WHERE id = $1`,
+		o.AccountID, cashDelta,
+	); err != nil {
+		return nil, nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+        INSERT INTO paper_positions (account_id, ticker, qty, avg_cost, realized_pnl)
+        VALUES ($1, $2, $3, $4, $5)
+        ON CONFLICT (account_id, ticker) DO UPDATE
+        SET qty = paper_positions.qty + $3,
+            avg_cost = CASE WHEN paper_positions.qty + $3 = 0 THEN 0 ELSE $4 END,
+            realized_pnl = paper_positions.realized_pnl + $5,
+            updated_at = NOW()
+    `, o.AccountID, o.Ticker, qtyDelta, newAvg, realized); err != nil {
+		return nil, nil, err
+	}
+
+	o, err = scanOrder(tx.QueryRowContext(ctx, `
+        UPDATE paper_orders
+        SET status = 'filled', filled_qty = qty, avg_fill_price = $2, filled_at = $3, updated_at = NOW()
+        WHERE id = $1
+        RETURNING `+orderColumns,
+		id, price, f.FilledAt,
+	))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return o, &f, nil
+}
+
+// ListPositions returns the user's open positions.
+func (s *TradingService) ListPositions(ctx context.Context, userID string) ([]PaperPosition, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT p.account_id, p.ticker, p.qty, p.avg_cost, p.realized_pnl, p.updated_at
+        FROM paper_positions p
+        JOIN paper_accounts a ON a.id = p.account_id
+        WHERE a.user_id = $1 AND p.qty <> 0
+        ORDER BY p.ticker
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []PaperPosition{}
+	for rows.Next() {
+		var p PaperPosition
+		if err := rows.Scan(&p.AccountID, &p.Ticker, &p.Qty, &p.AvgCost, &p.RealizedPnL, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, p)
+	}
+	return res, rows.Err()
+}
+
+// ListFills returns the user's executions, newest first, optionally for one
+// ticker.
+func (s *TradingService) ListFills(ctx context.Context, userID, ticker string, limit int) ([]PaperFill, error) {
+	if limit <= 0 {
+		limit = 500
+	}
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT f.id, f.order_id, f.account_id, f.ticker, f.side, f.qty, f.price, f.filled_at
+        FROM paper_fills f
+        JOIN paper_accounts a ON a.id = f.account_id
+        WHERE a.user_id = $1 AND ($2 = '' OR f.ticker = $2)
+        ORDER BY f.filled_at DESC, f.id DESC
+        LIMIT $3
+    `, userID, ticker, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []PaperFill{}
+	for rows.Next() {
+		var f PaperFill
+		if err := rows.Scan(&f.ID, &f.OrderID, &f.AccountID, &f.Ticker, &f.Side, &f.Qty, &f.Price, &f.FilledAt); err != nil {
+			return nil, err
+		}
+		res = append(res, f)
+	}
+	return res, rows.Err()
+}
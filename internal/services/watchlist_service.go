@@ -0,0 +1,488 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxWatchlistTickers bounds a watchlist, which is subscribed in one go.
+const MaxWatchlistTickers = 100
+
+var (
+	// ErrNotOwner is returned when a shared (read-only) watchlist is modified.
+	ErrNotOwner = errors.New("watchlist is shared read-only")
+
+	// ErrUserNotFound is returned when sharing with an unknown user.
+	ErrUserNotFound = errors.New("user not found")
+)
+
+type Watchlist struct {
+	ID            int64     `json:"id"`
+	UserID        string    `json:"user_id"`
+	OwnerUsername string    `json:"owner_username"`
+	Name          string    `json:"name"`
+	Tickers       []string  `json:"tickers"`
+	ReadOnly      bool      `json:"read_only"` // shared with the caller
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// WatchlistShare is a user a watchlist is shared with.
+type WatchlistShare struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type WatchlistService struct {
+	db *sql.DB
+}
+
+func NewWatchlistService(db *sql.DB) *WatchlistService {
+	return &WatchlistService{db: db}
+}
+
+// NormalizeTickers upper-cases, trims and de-duplicates tickers, keeping
+// their order.
+func NormalizeTickers(tickers []string) ([]string, error) {
+	seen := make(map[string]bool, len(tickers))
+	out := make([]string, 0, len(tickers))
+	for _, t := range tickers {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		if t == "" || seen[t] {
+			continue
+		}
+		if len(t) > 16 || strings.ContainsAny(t, " ,/") {
+			return nil, ValidationError(fmt.Sprintf("invalid ticker %q", t))
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	if len(out) > MaxWatchlistTickers {
+		return nil, ValidationError(fmt.Sprintf("at most %d tickers per watchlist", MaxWatchlistTickers))
+	}
+	return out, nil
+}
+
+func validateWatchlistName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", ValidationError("name required")
+	}
+	if len(name) > 100 {
+		return "", ValidationError("name is too long")
+	}
+	return name, nil
+}
+
+func (s *WatchlistService) CreateWatchlist(ctx context.Context, userID, name string, tickers []string) (*Watchlist, error) {
+	name, err := validateWatchlistName(name)
+	if err != nil {
+		return nil, err
+	}
+	if tickers, err = NormalizeTickers(tickers); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := checkNameFree(ctx, tx, userID, name, 0); err != nil {
+		return nil, err
+	}
+	var id int64
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO watchlists (user_id, name) VALUES ($1, $2) RETURNING id
+    `, userID, name).Scan(&id)
+	if err != nil {
+		return nil, err
+	}
+	if err := replaceItems(ctx, tx, id, tickers); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetWatchlist(ctx, userID, id)
+}
+
+// checkNameFree reports a ValidationError when the user has another
+// watchlist (not except) with that name.
+func checkNameFree(ctx context.Context, tx *sql.Tx, userID, name string, except int64) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, `
+        SELECT EXISTS (SELECT 1 FROM watchlists WHERE user_id = $1 AND name = $2 AND id <> $3)
+    `, userID, name, except).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ValidationError("a watchlist with that name already exists")
+	}
+	return nil
+}
+
+func replaceItems(ctx context.Context, tx *sql.Tx, id int64, tickers []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM watchlist_items WHERE watchlist_id = $1`, id); err != nil {
+		return err
+	}
+	for i, t := range tickers {
+		_, err := tx.ExecContext(ctx, `
+            INSERT INTO watchlist_items (watchlist_id, ticker, position) VALUES ($1, $2, $3)
+        `, id, t, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// watchlistColumns selects a watchlist as seen by the user in $1.
+const watchlistColumns = `
+    w.id, w.user_id, u.username, w.name, w.user_id::text <> $1, w.created_at, w.updated_at
+`
+
+func scanWatchlist(row interface{ Scan(...any) error }) (*Watchlist, error) {
+	var w Watchlist
+	if err := row.Scan(&w.ID, &w.UserID, &w.OwnerUsername, &w.Name, &w.ReadOnly, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	w.Tickers = []string{}
+	return &w, nil
+}
+
+// GetWatchlist returns a watchlist the user owns or that is shared with
+// them, sql.ErrNoRows otherwise.
+func (s *WatchlistService) GetWatchlist(ctx context.Context, userID string, id int64) (*Watchlist, error) {
+	w, err := scanWatchlist(s.db.QueryRowContext(ctx, `
+        SELECT `+watchlistColumns+`
+        FROM watchlists w
+        JOIN users u ON u.id = w.user_id
+        WHERE w.id = $2
+          AND (w.user_id::text = $1
+               OR EXISTS (SELECT 1 FROM watchlist_shares s WHERE s.watchlist_id = w.id AND s.user_id::text = $1))
+    `, userID, id))
+	if err != nil {
+		return nil, err
+	}
+	if err := s.loadTickers(ctx, []*Watchlist{w}); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// ListWatchlists returns the user's own watchlists, then the ones shared
+// with them.
+func (s *WatchlistService) ListWatchlists(ctx context.Context, userID string) ([]*Watchlist, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT `+watchlistColumns+`
+        FROM watchlists w
+        JOIN users u ON u.id = w.user_id
+        WHERE w.user_id::text = $1
+           OR EXISTS (SELECT 1 FROM watchlist_shares s WHERE s.watchlist_id = w.id AND s.user_id::text = $1)
+        ORDER BY w.user_id::text <> $1, w.name
+    `, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []*Watchlist{}
+	for rows.Next() {
+		w, err := scanWatchlist(rows)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, w)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.loadTickers(ctx, res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (s *WatchlistService) loadTickers(ctx context.Context, lists []*Watchlist) error {
+	if len(lists) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*Watchlist, len(lists))
+	ids := make([]string, 0, len(lists))
+	for _, w := range lists {
+		byID[w.ID] = w
+		ids = append(ids, fmt.Sprint(w.ID))
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT watchlist_id, ticker
+        FROM watchlist_items
+        WHERE watchlist_id = ANY(string_to_array($1, ',')::bigint[])
+        ORDER BY watchlist_id, position
+    `, strings.Join(ids, ","))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var ticker string
+		if err := rows.Scan(&id, &ticker); err != nil {
+			return err
+		}
+		byID[id].Tickers = append(byID[id].Tickers, ticker)
+	}
+	return rows.Err()
+}
+
+// WatchlistTickers returns the tickers of a watchlist the user can see. It
+// lets the websocket open a watchlist (ws.WatchlistResolver).
+func (s *WatchlistService) WatchlistTickers(ctx context.Context, userID string, id int64) ([]string, error) {
+	w, err := s.GetWatchlist(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return w.Tickers, nil
+}
+
+// ownerTx locks the watchlist for a change by its owner. It returns
+// sql.ErrNoRows when the user can't see it and ErrNotOwner when it is only
+// shared with them.
+func (s *WatchlistService) ownerTx(ctx context.Context, userID string, id int64) (*sql.Tx, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	var owner string
+	err = tx.QueryRowContext(ctx, `
+        SELECT w.user_id::text
+        FROM watchlists w
+        WHERE w.id = $2
+          AND (w.user_id::text = $1
+               OR EXISTS (SELECT 1 FROM watchlist_shares s WHERE s.watchlist_id = w.id AND s.user_id::text = $1))
+        FOR UPDATE OF w
+    `, userID, id).Scan(&owner)
+	if err == nil && owner != userID {
+		err = ErrNotOwner
+	}
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// UpdateWatchlist renames the watchlist and/or replaces its tickers; nil
+// fields are left alone.
+func (s *WatchlistService) UpdateWatchlist(ctx context.Context, userID string, id int64, name *string, tickers []string) (*Watchlist, error) {
+	var err error
+	if name != nil {
+		n, err := validateWatchlistName(*name)
+		if err != nil {
+			return nil, err
+		}
+		name = &n
+	}
+	if tickers != nil {
+		if tickers, err = NormalizeTickers(tickers); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.ownerTx(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if name != nil {
+		if err := checkNameFree(ctx, tx, userID, *name, id); err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE watchlists SET name = $2 WHERE id = $1`, id, *name); err != nil {
+			return nil, err
+		}
+	}
+	if tickers != nil {
+		if err := replaceItems(ctx, tx, id, tickers); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE watchlists SET updated_at = NOW() WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetWatchlist(ctx, userID, id)
+}
+
+// AddTicker appends a ticker to the end of the watchlist.
+func (s *WatchlistService) AddTicker(ctx context.Context, userID string, id int64, ticker string) (*Watchlist, error) {
+	norm, err := NormalizeTickers([]string{ticker})
+	if err != nil {
+		return nil, err
+	}
+	if len(norm) == 0 {
+		return nil, ValidationError("ticker required")
+	}
+
+	tx, err := s.ownerTx(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM watchlist_items WHERE watchlist_id = $1`, id).Scan(&count); err != nil {
+		return nil, err
+	}
+	if count >= MaxWatchlistTickers {
+		return nil, ValidationError(fmt.Sprintf("at most %d tickers per watchlist", MaxWatchlistTickers))
+	}
+	_, err = tx.ExecContext(ctx, `
+        INSERT INTO watchlist_items (watchlist_id, ticker, position)
+        SELECT $1, $2, COALESCE(MAX(position) + 1, 0) FROM watchlist_items WHERE watchlist_id = $1
+        ON CONFLICT (watchlist_id, ticker) DO NOTHING
+    `, id, norm[0])
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE watchlists SET updated_at = NOW() WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetWatchlist(ctx, userID, id)
+}
+
+func (s *WatchlistService) RemoveTicker(ctx context.Context, userID string, id int64, ticker string) (*Watchlist, error) {
+	tx, err := s.ownerTx(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+        DELETE FROM watchlist_items WHERE watchlist_id = $1 AND ticker = $2
+    `, id, strings.ToUpper(strings.TrimSpace(ticker)))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE watchlists SET updated_at = NOW() WHERE id = $1`, id); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetWatchlist(ctx, userID, id)
+}
+
+func (s *WatchlistService) DeleteWatchlist(ctx context.Context, userID string, id int64) error {
+	tx, err := s.ownerTx(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM watchlists WHERE id = $1`, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ShareWatchlist gives targetUserID read-only access. It returns
+// sql.ErrNoRows for an unknown watchlist and ErrUserNotFound for an unknown
+// target user.
+func (s *WatchlistService) ShareWatchlist(ctx context.Context, userID string, id int64, targetUserID string) (*WatchlistShare, error) {
+	if targetUserID == userID {
+		return nil, ValidationError("cannot share a watchlist with yourself")
+	}
+
+	tx, err := s.ownerTx(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var share WatchlistShare
+	// Comparing as text keeps a malformed id a "not found" instead of a
+	// uuid cast error.
+	err = tx.QueryRowContext(ctx, `
+        SELECT id::text, username FROM users WHERE id::text = $1
+    `, targetUserID).Scan(&share.UserID, &share.Username)
+	if err == sql.ErrNoRows {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO watchlist_shares (watchlist_id, user_id) VALUES ($1, $2)
+        ON CONFLICT (watchlist_id, user_id) DO UPDATE SET user_id = EXCLUDED.user_id
+        RETURNING created_at
+    `, id, share.UserID).Scan(&share.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// UnshareWatchlist revokes a share. Owners can revoke anyone; a user the
+// list is shared with can remove themselves.
+func (s *WatchlistService) UnshareWatchlist(ctx context.Context, userID string, id int64, targetUserID string) error {
+	res, err := s.db.ExecContext(ctx, `
+        DELETE FROM watchlist_shares s
+        USING watchlists w
+        WHERE s.watchlist_id = w.id
+          AND s.watchlist_id = $1
+          AND s.user_id::text = $2
+          AND (w.user_id::text = $3 OR s.user_id::text = $3)
+    `, id, targetUserID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListShares returns who the owner shared the watchlist with.
+func (s *WatchlistService) ListShares(ctx context.Context, userID string, id int64) ([]WatchlistShare, error) {
+	rows, err := s.db.QueryContext(ctx, `
+        SELECT u.id::text, u.username, s.created_at
+        FROM watchlist_shares s
+        JOIN watchlists w ON w.id = s.watchlist_id
+        JOIN users u ON u.id = s.user_id
+        WHERE s.watchlist_id = $1 AND w.user_id::text = $2
+        ORDER BY u.username
+    `, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	res := []WatchlistShare{}
+	for rows.Next() {
+		var sh WatchlistShare
+		if err := rows.Scan(&sh.UserID, &sh.Username, &sh.CreatedAt); err != nil {
+			return nil, err
+		}
+		res = append(res, sh)
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,278 @@
+/*
+Package simulator is a synthetic market-data source. It produces Massive-shaped
+frames (T/AM for stocks, V/A for indices) for whatever tickers are subscribed,
+so dev, demo and CI environments can run the full websocket path offline.
+*/
+
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/ws"
+)
+
+// Price models
+const (
+	ModelGBM        = "gbm"
+	ModelRandomWalk = "randomwalk"
+)
+
+// ParseModel validates a model name from config.
+func ParseModel(s string) (string, error) {
+	switch s {
+	case ModelGBM, ModelRandomWalk:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown price model %q (want %s or %s)", s, ModelGBM, ModelRandomWalk)
+}
+
+// One trading year (252 sessions of 6.5h), used to scale annualized drift and
+// volatility down to a single tick.
+const tradingYear = 252 * 6.5 * float64(time.Hour)
+
+type Config struct {
+	// Model is ModelGBM (default) or ModelRandomWalk.
+	Model string
+
+	// Drift and Volatility are annualized, e.g. 0.05 and 0.30.
+	Drift      float64
+	Volatility float64
+
+	// TickInterval is how often prices move. Defaults to 250ms.
+	TickInterval time.Duration
+
+	// Seed makes a run reproducible. 0 picks a random seed.
+	Seed uint64
+}
+
+type Simulator struct {
+	cfg Config
+	rng *rand.Rand
+
+	// ticker -> state, owned by the Run goroutine
+	stocks  map[string]*instrument
+	indices map[string]*instrument
+
+	tradeID  int64
+	sequence int64
+}
+
+// instrument is the simulated state of one ticker.
+type instrument struct {
+	symbol string
+	price  float64
+	base   float64 // starting price, scales the random walk
+
+	// day totals
+	dayOpen     float64
+	dayVolume   float64
+	dayNotional float64
+
+	// bar being built: minute bars for stocks, second bars for indices
+	bar       stream.Aggregate
+	barTrades int
+	barActive bool
+}
+
+func New(cfg Config) *Simulator {
+	if cfg.Model == "" {
+		cfg.Model = ModelGBM
+	}
+	if cfg.TickInterval <= 0 {
+		cfg.TickInterval = 250 * time.Millisecond
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	return &Simulator{
+		cfg:     cfg,
+		rng:     rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15)),
+		stocks:  make(map[string]*instrument),
+		indices: make(map[string]*instrument),
+	}
+}
+
+// Run is the feed.Source implementation. It returns once ctx is done.
+func (s *Simulator) Run(ctx context.Context, out chan<- []stream.Event, stockSubs, indexSubs <-chan ws.SubRequest) {
+	select {
+	case out <- []stream.Event{stream.NewStatus(stream.StatusUpstreamConnected, "simulator", "Simulator feed connected")}:
+	case <-ctx.Done():
+		return
+	}
+
+	ticker := time.NewTicker(s.cfg.TickInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case req := <-stockSubs:
+			s.apply(s.stocks, req, 10, 500)
+		case req := <-indexSubs:
+			s.apply(s.indices, req, 1000, 6000)
+		case now := <-ticker.C:
+			if events := s.tick(now); len(events) > 0 {
+				select {
+				case out <- events:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+func (s *Simulator) apply(set map[string]*instrument, req ws.SubRequest, lo, hi float64) {
+	if req.Action == ws.ActionUnsubscribe {
+		delete(set, req.Ticker)
+		return
+	}
+	if _, ok := set[req.Ticker]; ok {
+		return
+	}
+	// Same ticker, same starting price, so demos look familiar across runs.
+	h := fnv.New32a()
+	h.Write([]byte(req.Ticker))
+	start := math.Round((lo+float64(h.Sum32()%1000)/1000*(hi-lo))*100) / 100
+	set[req.Ticker] = &instrument{symbol: req.Ticker, price: start, base: start, dayOpen: start}
+}
+
+// tick moves every price one step and returns the resulting events.
+// Instruments are visited in ticker order so a seeded run draws the same
+// numbers for the same ticker every time.
+func (s *Simulator) tick(now time.Time) []stream.Event {
+	ms := now.UnixMilli()
+	var events []stream.Event
+
+	for _, in := range inOrder(s.stocks) {
+		// Close the previous minute bar before trading into the next one.
+		minute := now.Truncate(time.Minute).UnixMilli()
+		if in.barActive && in.bar.Start != minute {
+			events = append(events, stream.NewAggregate(stream.TypeMinuteAgg, in.bar))
+			in.barActive = false
+		}
+
+		// Not every name trades on every tick.
+		if s.rng.Float64() < 0.3 {
+			continue
+		}
+		in.price = s.step(in)
+		size := float64(s.tradeSize())
+		in.dayVolume += size
+		in.dayNotional += size * in.price
+		in.addToBar(minute, minute+time.Minute.Milliseconds(), in.price, size)
+
+		s.tradeID++
+		s.sequence++
+		events = append(events, stream.NewTrade(stream.Trade{
+			Symbol:    in.symbol,
+			Exchange:  1 + s.rng.IntN(20),
+			ID:        strconv.FormatInt(s.tradeID, 10),
+			Tape:      1 + s.rng.IntN(3),
+			Price:     in.price,
+			Size:      size,
+			Timestamp: ms,
+			Sequence:  s.sequence,
+		}))
+	}
+
+	for _, in := range inOrder(s.indices) {
+		second := now.Truncate(time.Second).UnixMilli()
+		if in.barActive && in.bar.Start != second {
+			events = append(events, stream.NewAggregate(stream.TypeSecondAgg, in.bar))
+			in.barActive = false
+		}
+
+		in.price = s.step(in)
+		in.addToBar(second, second+time.Second.Milliseconds(), in.price, 0)
+		events = append(events, stream.NewIndexValue(stream.IndexValue{
+			Symbol:    in.symbol,
+			Value:     in.price,
+			Timestamp: ms,
+		}))
+	}
+
+	return events
+}
+
+// inOrder returns the instruments in set sorted by ticker.
+func inOrder(set map[string]*instrument) []*instrument {
+	tickers := make([]string, 0, len(set))
+	for t := range set {
+		tickers = append(tickers, t)
+	}
+	sort.Strings(tickers)
+	out := make([]*instrument, len(tickers))
+	for i, t := range tickers {
+		out[i] = set[t]
+	}
+	return out
+}
+
+// step returns the next price for in under the configured model.
+func (s *Simulator) step(in *instrument) float64 {
+	dt := float64(s.cfg.TickInterval) / tradingYear
+	z := s.rng.NormFloat64()
+
+	var next float64
+	switch s.cfg.Model {
+	case ModelRandomWalk:
+		// Arithmetic walk with a step size fixed at the starting price.
+		next = in.price + s.cfg.Drift*in.base*dt + s.cfg.Volatility*in.base*math.Sqrt(dt)*z
+	default:
+		// Geometric Brownian motion.
+		next = in.price * math.Exp((s.cfg.Drift-s.cfg.Volatility*s.cfg.Volatility/2)*dt+s.cfg.Volatility*math.Sqrt(dt)*z)
+	}
+	return math.Max(0.01, math.Round(next*100)/100)
+}
+
+// tradeSize is mostly round lots with the occasional odd lot or block.
+func (s *Simulator) tradeSize() int {
+	switch r := s.rng.Float64(); {
+	case r < 0.2:
+		return 1 + s.rng.IntN(99)
+	case r < 0.98:
+		return 100 * (1 + s.rng.IntN(10))
+	default:
+		return 1000 * (5 + s.rng.IntN(20))
+	}
+}
+
+func (in *instrument) addToBar(start, end int64, price, size float64) {
+	if !in.barActive {
+		in.bar = stream.Aggregate{
+			Symbol:       in.symbol,
+			OfficialOpen: in.dayOpen,
+			Open:         price,
+			High:         price,
+			Low:          price,
+			Start:        start,
+			End:          end,
+		}
+		in.barTrades = 0
+		in.barActive = true
+	}
+	b := &in.bar
+	b.High = math.Max(b.High, price)
+	b.Low = math.Min(b.Low, price)
+	b.Close = price
+	if size > 0 {
+		b.VWAP = (b.VWAP*b.Volume + price*size) / (b.Volume + size)
+		b.Volume += size
+		in.barTrades++
+		b.AccumulatedVolume = in.dayVolume
+		b.DayVWAP = in.dayNotional / in.dayVolume
+		b.AverageSize = b.Volume / float64(in.barTrades)
+	}
+}
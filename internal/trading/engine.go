@@ -0,0 +1,254 @@
+/*
+Package trading is the paper-trading fill engine. Working orders are kept
+in Postgres; the engine watches their tickers on the live stream (a hub tap)
+and fills them at the traded price. New orders are first checked against
+the latest quote, so a marketable order fills right away.
+
+Fills go through a locked transaction in services.TradingService, so several
+instances can run the engine without double fills.
+*/
+
+package trading
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/quotes"
+	"github.com/dnhan1707/trader/internal/services"
+	"github.com/dnhan1707/trader/internal/ws"
+)
+
+// EventType is the "ev" of an order-status frame sent to the user.
+const EventType = "order"
+
+const (
+	// Working orders are re-read (and day orders expired) this often, so
+	// orders placed on another instance are picked up.
+	reloadEvery = 30 * time.Second
+
+	dbTimeout = 5 * time.Second
+)
+
+type working struct {
+	order   services.PaperOrder
+	pending bool // a fill / trigger is in flight
+}
+
+// result hands the outcome of an execution back to Run.
+type result struct {
+	w     *working
+	order *services.PaperOrder // nil when nothing changed
+}
+
+type Engine struct {
+	svc    *services.TradingService
+	subs   *ws.SubscriptionManager
+	quotes *quotes.Cache // prices for new orders; may be nil
+	notify ws.Notifier
+
+	reload chan struct{}
+	done   chan result
+
+	// owned by Run
+	orders map[string][]*working // ticker -> working orders
+	held   map[string]bool       // tickers acquired from subs
+}
+
+func New(svc *services.TradingService, subs *ws.SubscriptionManager, q *quotes.Cache, notify ws.Notifier) *Engine {
+	return &Engine{
+		svc:    svc,
+		subs:   subs,
+		quotes: q,
+		notify: notify,
+		reload: make(chan struct{}, 1),
+		done:   make(chan result, 256),
+		orders: make(map[string][]*working),
+		held:   make(map[string]bool),
+	}
+}
+
+// Run consumes frames (a hub tap) and never returns.
+func (e *Engine) Run(frames <-chan []stream.Event) {
+	e.expire()
+	e.load()
+	ticker := time.NewTicker(reloadEvery)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case events := <-frames:
+			for _, ev := range events {
+				if ev.Trade != nil {
+					e.match(ev.Symbol, ev.Trade.Price)
+				}
+			}
+		case <-ticker.C:
+			e.expire()
+			e.load()
+		case <-e.reload:
+			e.load()
+		case r := <-e.done:
+			r.w.pending = false
+			if r.order != nil {
+				r.w.order = *r.order
+			}
+		}
+	}
+}
+
+// Submit places an order and fills it at once when the latest quote makes
+// it marketable. It returns the order in its current state.
+func (e *Engine) Submit(ctx context.Context, o services.PaperOrder) (*services.PaperOrder, error) {
+	placed, err := e.svc.CreateOrder(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+	e.publish(*placed)
+	defer e.Reload()
+
+	if e.quotes == nil {
+		return placed, nil
+	}
+	q, ok := e.quotes.Lookup(placed.Ticker)
+	if !ok || q.Price() <= 0 {
+		// No price yet: the order waits for the next trade.
+		return placed, nil
+	}
+
+	if updated := e.execute(ctx, *placed, q.Price()); updated != nil {
+		return updated, nil
+	}
+	return placed, nil
+}
+
+// Cancel cancels one of the user's working orders.
+func (e *Engine) Cancel(ctx context.Context, userID string, id int64) (*services.PaperOrder, error) {
+	o, err := e.svc.CancelOrder(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	e.publish(*o)
+	e.Reload()
+	return o, nil
+}
+
+// Reload asks Run to re-read the working orders.
+func (e *Engine) Reload() {
+	select {
+	case e.reload <- struct{}{}:
+	default:
+	}
+}
+
+// load replaces the watched orders with the working ones from Postgres and
+// keeps their tickers streaming even when no client is watching them.
+func (e *Engine) load() {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	list, err := e.svc.ListWorkingOrders(ctx)
+	if err != nil {
+		log.Printf("[Trading] load: %v", err)
+		return
+	}
+
+	orders := make(map[string][]*working)
+	for _, o := range list {
+		orders[o.Ticker] = append(orders[o.Ticker], &working{order: o})
+	}
+	e.orders = orders
+
+	for t := range orders {
+		if !e.held[t] {
+			e.held[t] = true
+			e.subs.Acquire(t)
+		}
+	}
+	for t := range e.held {
+		if _, ok := orders[t]; !ok {
+			delete(e.held, t)
+			e.subs.Release(t)
+		}
+	}
+}
+
+// match checks the working orders on ticker against a trade.
+func (e *Engine) match(ticker string, price float64) {
+	for _, w := range e.orders[ticker] {
+		if w.pending || !w.order.Working() {
+			continue
+		}
+		fill, trigger := w.order.Match(price)
+		if !fill && !trigger {
+			continue
+		}
+
+		w.pending = true
+		go func(w *working, o services.PaperOrder) {
+			ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+			defer cancel()
+			e.done <- result{w: w, order: e.execute(ctx, o, price)}
+		}(w, w.order)
+	}
+}
+
+// execute fills or triggers o at price and publishes the new state. It
+// returns nil when nothing changed.
+func (e *Engine) execute(ctx context.Context, o services.PaperOrder, price float64) *services.PaperOrder {
+	fill, trigger := o.Match(price)
+
+	switch {
+	case fill:
+		updated, f, err := e.svc.FillOrder(ctx, o.ID, price)
+		if err != nil {
+			log.Printf("[Trading] fill %d: %v", o.ID, err)
+			return nil
+		}
+		if updated == nil {
+			// Cancelled, expired or filled elsewhere in the meantime.
+			return nil
+		}
+		if f != nil {
+			log.Printf("[Trading] order %d %s %g %s @ %.4f filled", o.ID, o.Side, o.Qty, o.Ticker, price)
+		}
+		e.publish(*updated)
+		return updated
+
+	case trigger:
+		updated, ok, err := e.svc.TriggerOrder(ctx, o.ID)
+		if err != nil {
+			log.Printf("[Trading] trigger %d: %v", o.ID, err)
+			return nil
+		}
+		if !ok {
+			return nil
+		}
+		e.publish(*updated)
+		return updated
+	}
+	return nil
+}
+
+// expire ends the day orders whose session is over.
+func (e *Engine) expire() {
+	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+	defer cancel()
+
+	expired, err := e.svc.ExpireOrders(ctx)
+	if err != nil {
+		log.Printf("[Trading] expire: %v", err)
+		return
+	}
+	for _, o := range expired {
+		e.publish(o)
+	}
+}
+
+// publish sends the order's state to the user's connections.
+func (e *Engine) publish(o services.PaperOrder) {
+	frame := stream.Encode([]stream.Event{stream.NewCustom(EventType, o.Ticker, o)})
+	e.notify.SendToUser(o.UserID, frame)
+}
@@ -2,6 +2,8 @@ package ws
 
 import (
 	"encoding/json"
+	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/gofiber/contrib/websocket"
@@ -18,15 +20,72 @@ const (
 	pingPeriod = (pongWait * 9) / 10
 )
 
+var nextClientID atomic.Int64
+
 // Client is a middleman between the websocket connection and the hub.
 type Client struct {
 	hub *Hub
 
-	// The websocket connection.
+	// The websocket connection. nil for SSE clients.
 	conn *websocket.Conn
 
-	// Buffered channel of outbound messages.
-	send chan []byte
+	// Bounded queue of outbound messages, see Hub.SlowConsumer.
+	out *outbox
+
+	// Tickers this client is subscribed to. Owned by the hub goroutine.
+	tickers map[string]bool
+
+	// What the read goroutine asked the hub for. A ticker stays subscribed
+	// while it has a reference: one for an explicit subscribe and one for
+	// each open watchlist that lists it. Owned by ReadPump.
+	refs     map[string]int
+	explicit map[string]bool
+	opened   map[int64][]string // watchlist id -> the tickers it referenced
+
+	// For the admin view.
+	id          int64
+	userID      string
+	transport   string
+	remoteAddr  string
+	connectedAt time.Time
+}
+
+// ClientStats is one row of the per-client report.
+type ClientStats struct {
+	ID          int64     `json:"id"`
+	UserID      string    `json:"user_id"`
+	Transport   string    `json:"transport"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Tickers     []string  `json:"tickers"`
+	Policy      Policy    `json:"policy"`
+	Sent        int64     `json:"sent"`
+	Dropped     int64     `json:"dropped"`
+	Queued      int       `json:"queued"`
+}
+
+func newClient(hub *Hub, conn *websocket.Conn, transport, userID, remoteAddr string) *Client {
+	return &Client{
+		hub:         hub,
+		conn:        conn,
+		out:         newOutbox(hub.SendBuffer, hub.SlowConsumer),
+		tickers:     make(map[string]bool),
+		id:          nextClientID.Add(1),
+		userID:      userID,
+		transport:   transport,
+		remoteAddr:  remoteAddr,
+		connectedAt: time.Now(),
+	}
+}
+
+// clientRequest is what the browser sends us, e.g.
+// {"ticker":"AAPL"} or {"action":"unsubscribe","ticker":"AAPL"}.
+// A missing action means subscribe. Watchlist actions carry watchlist_id
+// instead of a ticker.
+type clientRequest struct {
+	Action      string `json:"action"`
+	Ticker      string `json:"ticker"`
+	WatchlistID int64  `json:"watchlist_id"`
 }
 
 // WritePump pumps messages from the Hub to the websocket connection.
@@ -39,22 +98,31 @@ func (c *Client) WritePump() {
 
 	for {
 		select {
-		// CASE 1: The Hub sent us a Stock Price
-		case message, ok := <-c.send:
-			// Security: Give the write operation 10 seconds to finish.
-			// If the user's internet is so slow that it takes >10s to receive 1kb,
-			// kill the connection so we don't waste server resources.
-			c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		// CASE 1: The Hub queued Stock Prices for us
+		case <-c.out.notify:
+			messages, closed := c.out.drain()
 
-			// Check if the Hub closed the channel (Server shutdown)
-			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+			// Check if the Hub is done with us (kicked, or we unregistered).
+			// The close code tells the browser why.
+			if closed {
+				code, reason := c.out.closeInfo()
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+				c.conn.Close()
 				return
 			}
 
-			// Actually write the JSON to the network
-			if err := c.conn.WriteMessage(websocket.TextMessage, message); err != nil {
-				return
+			for _, message := range messages {
+				// Security: Give the write operation 10 seconds to finish.
+				// If the user's internet is so slow that it takes >10s to receive 1kb,
+				// kill the connection so we don't waste server resources.
+				c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+
+				// Actually write the JSON to the network
+				if err := c.conn.WriteMessage(websocket.TextMessage, message.Data); err != nil {
+					return
+				}
+				c.out.sent.Add(1)
 			}
 
 		// CASE 2: The Ticker ticked (Heartbeat)
@@ -72,7 +140,7 @@ func (c *Client) WritePump() {
 
 // ReadPump pumps messages from the websocket connection to the hub.
 // We need this to handle Disconnects and incoming Subscribe requests.
-func (c *Client) ReadPump(stockSubs chan string, indexSubs chan string) {
+func (c *Client) ReadPump() {
 	// CLEANUP: When this function exits (for any reason),
 	// unregister the user so the Hub stops trying to send them data.
 	defer func() {
@@ -95,27 +163,94 @@ func (c *Client) ReadPump(stockSubs chan string, indexSubs chan string) {
 		return nil
 	})
 
+	c.refs = make(map[string]int)
+	c.explicit = make(map[string]bool)
+	c.opened = make(map[int64][]string)
+
 	for {
 		_, message, err := c.conn.ReadMessage()
 		if err != nil {
 			break
 		}
 
-		var req struct {
-			Ticker string `json:"ticker"`
-		}
+		var req clientRequest
 		if err := json.Unmarshal(message, &req); err != nil {
 			continue
 		}
 
-		// ROUTING LOGIC
-		if req.Ticker != "" {
-			// Indices in Massive always start with "I:"
-			if len(req.Ticker) > 2 && req.Ticker[:2] == "I:" {
-				indexSubs <- req.Ticker
-			} else {
-				stockSubs <- req.Ticker
+		// The hub keeps the upstream refcounts, so we only talk to the hub.
+		switch req.Action {
+		case "", ActionSubscribe:
+			if req.Ticker == "" || c.explicit[req.Ticker] {
+				continue
+			}
+			c.explicit[req.Ticker] = true
+			c.acquire(req.Ticker)
+
+		case ActionUnsubscribe:
+			if !c.explicit[req.Ticker] {
+				continue
 			}
+			delete(c.explicit, req.Ticker)
+			c.release(req.Ticker)
+
+		case ActionOpenWatchlist:
+			c.openWatchlist(req.WatchlistID)
+
+		case ActionCloseWatchlist:
+			for _, t := range c.opened[req.WatchlistID] {
+				c.release(t)
+			}
+			delete(c.opened, req.WatchlistID)
+		}
+	}
+}
+
+// acquire takes a reference on each ticker, subscribing on the first one.
+// The latest known state goes first, then live updates; the snapshots of
+// the new tickers come from one batched lookup.
+func (c *Client) acquire(tickers ...string) {
+	var fresh []string
+	for _, t := range tickers {
+		if c.refs[t] == 0 {
+			fresh = append(fresh, t)
+		}
+	}
+	snaps := c.hub.snapshots(fresh)
+	for _, t := range tickers {
+		if c.refs[t]++; c.refs[t] == 1 {
+			c.hub.Subscribe <- Subscription{Client: c, Ticker: t, Snapshot: snaps[t]}
 		}
 	}
 }
+
+// release drops a reference on ticker, unsubscribing with the last one.
+func (c *Client) release(ticker string) {
+	if c.refs[ticker] == 0 {
+		return
+	}
+	if c.refs[ticker]--; c.refs[ticker] == 0 {
+		delete(c.refs, ticker)
+		c.hub.Unsubscribe <- Subscription{Client: c, Ticker: ticker}
+	}
+}
+
+func (c *Client) stats() ClientStats {
+	tickers := make([]string, 0, len(c.tickers))
+	for t := range c.tickers {
+		tickers = append(tickers, t)
+	}
+	sort.Strings(tickers)
+	return ClientStats{
+		ID:          c.id,
+		UserID:      c.userID,
+		Transport:   c.transport,
+		RemoteAddr:  c.remoteAddr,
+		ConnectedAt: c.connectedAt,
+		Tickers:     tickers,
+		Policy:      c.out.policy,
+		Sent:        c.out.sent.Load(),
+		Dropped:     c.out.dropped.Load(),
+		Queued:      c.out.queued(),
+	}
+}
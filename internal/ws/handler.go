@@ -9,13 +9,20 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func NewHandler(hub *Hub, stockSubs chan string, indexSubs chan string) fiber.Handler {
+func NewHandler(hub *Hub) fiber.Handler {
 	return websocket.New(func(c *websocket.Conn) {
-		client := &Client{hub: hub, conn: c, send: make(chan []byte, 256)}
+		userID, _ := c.Locals("userID").(string)
+		client := newClient(hub, c, "ws", userID, c.RemoteAddr().String())
 		client.hub.Register <- client
 
-		go client.WritePump()
-		// Pass both channels to ReadPump
-		client.ReadPump(stockSubs, indexSubs)
+		// The conn is recycled once this returns, so wait for the writer:
+		// ReadPump unregisters on its way out, which closes the writer.
+		written := make(chan struct{})
+		go func() {
+			client.WritePump()
+			close(written)
+		}()
+		client.ReadPump()
+		<-written
 	})
 }
@@ -0,0 +1,57 @@
+package ws
+
+import "sync"
+
+// How many routed messages the hub remembers for SSE resume.
+const historySize = 4096
+
+// history is a fixed-size ring of the most recent messages. The hub goroutine
+// adds, SSE handlers read.
+type history struct {
+	mu   sync.Mutex
+	buf  []Message
+	next int  // slot the next message goes into
+	full bool // buf has wrapped at least once
+}
+
+func newHistory(size int) *history {
+	return &history{buf: make([]Message, size)}
+}
+
+func (r *history) add(m Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.buf[r.next] = m
+	r.next = (r.next + 1) % len(r.buf)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+func (r *history) since(seq uint64, tickers map[string]bool) (out []Message, ok, reset bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// oldest first
+	ordered := r.buf[:r.next]
+	if r.full {
+		ordered = append(append([]Message{}, r.buf[r.next:]...), r.buf[:r.next]...)
+	}
+	// Unknown future id (e.g. from before a restart): nothing we can replay.
+	if len(ordered) == 0 {
+		return nil, seq == 0, seq > 0
+	}
+	newest := ordered[len(ordered)-1].Seq
+	if seq > newest {
+		return nil, false, true
+	}
+	ok = ordered[0].Seq <= seq+1
+
+	for _, m := range ordered {
+		if m.Seq > seq && (m.Ticker == "" || tickers[m.Ticker]) {
+			out = append(out, m)
+		}
+	}
+	return out, ok, false
+}
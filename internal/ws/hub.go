@@ -1,29 +1,151 @@
 /*
-This is the generic "Chat Room" for our stock data. It doesn't care where the data comes from
+This is the generic "Chat Room" for our stock data. It doesn't care where the data comes from.
+Every ticker is a topic: upstream frames are split by symbol and only delivered
+to the clients subscribed to that symbol.
 */
 
 package ws
 
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive/stream"
+)
+
+// Message is one outbound frame for a client. Seq increases by one for every
+// message the hub routes and is used as the SSE event id.
+type Message struct {
+	Seq    uint64
+	Ticker string // "" for messages that go to everyone (status)
+	Data   []byte
+
+	// Key groups messages that may replace each other under
+	// PolicyConflate: ticker and event type for upstream messages made
+	// only of superseding events (see conflateKey), "" otherwise.
+	Key string
+
+	// User is set for frames addressed to one user (alerts...). They have
+	// no Seq and are never kept in history.
+	User string
+}
+
+// Notifier delivers a frame to every connection of a user. Hub does it for
+// this instance, cluster.Node for every instance.
+type Notifier interface {
+	SendToUser(userID string, data []byte)
+}
+
+// UserMessage is a frame for every connection of one user.
+type UserMessage struct {
+	UserID string
+	Data   []byte
+
+	// client, when set, limits delivery to that one connection (replies
+	// to its own requests).
+	client *Client
+}
+
+// Subscription asks the hub to add or remove one ticker for one client.
+type Subscription struct {
+	Client *Client
+	Ticker string
+
+	// Snapshot, when set, is delivered to the client before any live update
+	// for the ticker.
+	Snapshot []byte
+}
+
+// WatchlistResolver returns the tickers of a watchlist the user may read,
+// for the "open_watchlist" websocket action and SSE ?watchlist=.
+type WatchlistResolver interface {
+	WatchlistTickers(ctx context.Context, userID string, id int64) ([]string, error)
+}
+
+// Snapshotter provides the latest known state of tickers, sent to a client
+// right after it subscribes. Tickers it knows nothing about are left out.
+type Snapshotter interface {
+	Snapshots(tickers []string) map[string]stream.Event
+}
+
 type Hub struct {
 	// registered clients
 	clients map[*Client]bool
 
-	// upstream will push data into this channel
-	Broadcast chan []byte
+	// ticker -> clients subscribed to it
+	topics map[string]map[*Client]bool
+
+	// upstream will push decoded frames into this channel
+	Broadcast chan []stream.Event
+
+	// server-generated events (bar closes...) are routed like Broadcast but
+	// not copied to taps
+	Publish chan []stream.Event
+
+	// frames for one user's connections, see SendToUser
+	Direct chan UserMessage
 
 	// register request from the clients
 	Register chan *Client
 
 	// unregister requests from clients
 	Unregister chan *Client
+
+	// per-ticker subscribe / unsubscribe requests from clients
+	Subscribe   chan Subscription
+	Unsubscribe chan Subscription
+
+	// upstream refcounts, nil when nothing needs to be told (e.g. replay)
+	subs *SubscriptionManager
+
+	// every upstream frame is also copied to these, see Tap
+	taps []chan []stream.Event
+
+	// last routed sequence number and the recent messages, for SSE resume.
+	// epoch is random per hub and prefixes SSE ids, so an id from another
+	// instance or from before a restart is never mistaken for one of ours.
+	seq     uint64
+	epoch   string
+	history *history
+
+	// Snapshots is optional; set it before clients connect.
+	Snapshots Snapshotter
+
+	// Watchlists is optional too; without it watchlists can't be opened.
+	Watchlists WatchlistResolver
+
+	// Per-client queue size and what to do when it fills up. Set before
+	// clients connect.
+	SendBuffer   int
+	SlowConsumer Policy
+
+	// admin requests for per-client stats, answered by Run
+	statsRequests chan chan []ClientStats
 }
 
-func NewHub() *Hub {
+func NewHub(subs *SubscriptionManager) *Hub {
 	return &Hub{
-		Broadcast:  make(chan []byte),
-		Register:   make(chan *Client),
-		Unregister: make(chan *Client),
-		clients:    make(map[*Client]bool),
+		subs:        subs,
+		Broadcast:   make(chan []stream.Event),
+		Publish:     make(chan []stream.Event),
+		Direct:      make(chan UserMessage, 256),
+		Register:    make(chan *Client),
+		Unregister:  make(chan *Client),
+		Subscribe:   make(chan Subscription),
+		Unsubscribe: make(chan Subscription),
+		clients:     make(map[*Client]bool),
+		topics:      make(map[string]map[*Client]bool),
+		epoch:       newEpoch(),
+		history:     newHistory(historySize),
+
+		SendBuffer:    256,
+		SlowConsumer:  PolicyDisconnect,
+		statsRequests: make(chan chan []ClientStats),
 	}
 }
 
@@ -37,22 +159,236 @@ func (h *Hub) Run() {
 		// a user disconnected
 		case client := <-h.Unregister:
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
+				h.removeClient(client, CloseNormal, "")
+			}
+
+		case sub := <-h.Subscribe:
+			// Ignore late requests from clients we already dropped.
+			if !h.clients[sub.Client] {
+				continue
 			}
+			if sub.Client.tickers[sub.Ticker] {
+				continue
+			}
+			// Snapshot first, so live updates always come after it.
+			if sub.Snapshot != nil {
+				h.deliver(sub.Client, Message{Ticker: sub.Ticker, Data: sub.Snapshot})
+				if !h.clients[sub.Client] {
+					continue
+				}
+			}
+			subs, ok := h.topics[sub.Ticker]
+			if !ok {
+				subs = make(map[*Client]bool)
+				h.topics[sub.Ticker] = subs
+			}
+			subs[sub.Client] = true
+			sub.Client.tickers[sub.Ticker] = true
+			if h.subs != nil {
+				h.subs.Acquire(sub.Ticker)
+			}
+
+		case sub := <-h.Unsubscribe:
+			h.unsubscribe(sub.Client, sub.Ticker)
 
 		// data arrived from upstream.go, this is the Fan-Out idea
-		case message := <-h.Broadcast:
+		case events := <-h.Broadcast:
+			h.route(events, true)
+			h.copyToTaps(events)
+
+		case events := <-h.Publish:
+			h.route(events, false)
+
+		case um := <-h.Direct:
+			if um.client != nil {
+				if h.clients[um.client] {
+					h.deliver(um.client, Message{Data: um.Data, User: um.UserID})
+				}
+				continue
+			}
 			for client := range h.clients {
-				select {
-				case client.send <- message:
-				// If the client's buffer is full or connection is dead,
-				// kick them out to prevent blocking the whole server.
-				default:
-					close(client.send)
-					delete(h.clients, client)
+				if client.userID == um.UserID {
+					h.deliver(client, Message{Data: um.Data, User: um.UserID})
 				}
 			}
+
+		case reply := <-h.statsRequests:
+			stats := make([]ClientStats, 0, len(h.clients))
+			for client := range h.clients {
+				stats = append(stats, client.stats())
+			}
+			reply <- stats
+		}
+	}
+}
+
+// route splits an upstream frame by symbol and sends each part to that
+// symbol's subscribers. Upstream connection statuses go to every client;
+// other events without a symbol, such as Massive's subscribe acks, are
+// dropped. Only market data is conflatable; server events such as bar
+// closes must all arrive.
+func (h *Hub) route(events []stream.Event, conflatable bool) {
+	var order []string
+	bySymbol := make(map[string][]stream.Event)
+	for _, e := range events {
+		if e.Symbol == "" && !forEveryone(e) {
+			continue
+		}
+		if _, seen := bySymbol[e.Symbol]; !seen {
+			order = append(order, e.Symbol)
+		}
+		bySymbol[e.Symbol] = append(bySymbol[e.Symbol], e)
+	}
+
+	for _, sym := range order {
+		h.seq++
+		msg := Message{Seq: h.seq, Ticker: sym, Data: stream.Encode(bySymbol[sym])}
+		if conflatable {
+			msg.Key = conflateKey(sym, bySymbol[sym])
+		}
+		h.history.add(msg)
+
+		if sym == "" {
+			for client := range h.clients {
+				h.deliver(client, msg)
+			}
+			continue
+		}
+		for client := range h.topics[sym] {
+			h.deliver(client, msg)
+		}
+	}
+}
+
+// forEveryone reports whether e is a status of our own upstream supervisor.
+func forEveryone(e stream.Event) bool {
+	if e.Status == nil {
+		return false
+	}
+	switch e.Status.Status {
+	case stream.StatusUpstreamConnected, stream.StatusUpstreamDisconnected:
+		return true
+	}
+	return false
+}
+
+// superseding are the event types where a newer event makes an older one
+// for the same ticker worthless to a client that is behind.
+var superseding = map[string]bool{
+	stream.TypeTrade:      true,
+	stream.TypeIndexValue: true,
+}
+
+// conflateKey is the Key of a message of events for sym: "" unless they
+// are all of one superseding type, so bars are never replaced.
+func conflateKey(sym string, events []stream.Event) string {
+	if sym == "" || !superseding[events[0].Type] {
+		return ""
+	}
+	for _, e := range events[1:] {
+		if e.Type != events[0].Type {
+			return ""
+		}
+	}
+	return sym + ":" + events[0].Type
+}
+
+// Tap returns a channel that receives every frame pushed into Broadcast,
+// regardless of client subscriptions. It is meant for server-side consumers
+// such as the recorder. Taps must be created before Run is started and must
+// keep up: when a tap's buffer is full the frame is dropped for that tap.
+func (h *Hub) Tap(buffer int) <-chan []stream.Event {
+	tap := make(chan []stream.Event, buffer)
+	h.taps = append(h.taps, tap)
+	return tap
+}
+
+func (h *Hub) copyToTaps(events []stream.Event) {
+	for i, tap := range h.taps {
+		select {
+		case tap <- events:
+		default:
+			log.Printf("[Hub] tap %d is full, dropping frame", i)
 		}
 	}
 }
+
+// SendToUser delivers a frame to every connection of userID on this
+// instance. Users without a connection simply miss it.
+func (h *Hub) SendToUser(userID string, data []byte) {
+	h.Direct <- UserMessage{UserID: userID, Data: data}
+}
+
+// snapshots fetches the subscribe-time snapshots for tickers as frames. It
+// may call the REST API, so it runs on the client's goroutine, never in Run.
+func (h *Hub) snapshots(tickers []string) map[string][]byte {
+	if h.Snapshots == nil || len(tickers) == 0 {
+		return nil
+	}
+	events := h.Snapshots.Snapshots(tickers)
+	out := make(map[string][]byte, len(events))
+	for t, e := range events {
+		out[t] = stream.Encode([]stream.Event{e})
+	}
+	return out
+}
+
+// Since returns the buffered messages after seq for the given tickers (plus
+// status messages). ok is false when messages after seq already fell out of
+// the buffer, in which case the caller cannot resume without a gap. reset
+// is true when seq is newer than anything this hub has routed. Ids from
+// other instances or earlier runs are told apart by their epoch before
+// Since is called.
+func (h *Hub) Since(seq uint64, tickers map[string]bool) (msgs []Message, ok, reset bool) {
+	return h.history.since(seq, tickers)
+}
+
+// newEpoch returns a short random hub id.
+func newEpoch() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Clients reports per-client counters for the admin endpoint.
+func (h *Hub) Clients() []ClientStats {
+	reply := make(chan []ClientStats, 1)
+	h.statsRequests <- reply
+	stats := <-reply
+	sort.Slice(stats, func(i, j int) bool { return stats[i].ID < stats[j].ID })
+	return stats
+}
+
+func (h *Hub) deliver(client *Client, msg Message) {
+	// If the client's queue is full and the policy says so,
+	// kick them out to prevent blocking the whole server.
+	if !client.out.push(msg) {
+		log.Printf("[Hub] client %d (%s) is too slow, disconnecting", client.id, client.userID)
+		h.removeClient(client, CloseSlowConsumer, "slow consumer")
+	}
+}
+
+func (h *Hub) unsubscribe(client *Client, ticker string) {
+	if !client.tickers[ticker] {
+		return
+	}
+	subs := h.topics[ticker]
+	delete(subs, client)
+	delete(client.tickers, ticker)
+	if len(subs) == 0 {
+		delete(h.topics, ticker)
+	}
+	if h.subs != nil {
+		h.subs.Release(ticker)
+	}
+}
+
+func (h *Hub) removeClient(client *Client, code int, reason string) {
+	for ticker := range client.tickers {
+		h.unsubscribe(client, ticker)
+	}
+	delete(h.clients, client)
+	client.out.close(code, reason)
+}
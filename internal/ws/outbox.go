@@ -0,0 +1,172 @@
+package ws
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Policy decides what happens when a client's queue is full.
+type Policy string
+
+const (
+	// PolicyDisconnect kicks the client (the historical behaviour).
+	PolicyDisconnect Policy = "disconnect"
+
+	// PolicyDropOldest discards the oldest queued message to make room.
+	PolicyDropOldest Policy = "drop-oldest"
+
+	// PolicyConflate replaces a queued trade or index value message for the
+	// same ticker with the newer one, so a slow client still sees the
+	// latest price. Otherwise it drops the oldest such message; bars,
+	// candles and status messages are never dropped, and a queue full of
+	// them disconnects the client.
+	PolicyConflate Policy = "conflate"
+)
+
+// ParsePolicy validates a policy name from config.
+func ParsePolicy(s string) (Policy, error) {
+	switch p := Policy(s); p {
+	case PolicyDisconnect, PolicyDropOldest, PolicyConflate:
+		return p, nil
+	}
+	return "", fmt.Errorf("unknown slow-consumer policy %q (want %s, %s or %s)", s, PolicyDisconnect, PolicyDropOldest, PolicyConflate)
+}
+
+// Close codes sent to the client when the server ends the connection.
+// 4000-4999 are free for applications, so the frontend can tell a kick from
+// a network failure (1006).
+const (
+	CloseNormal       = 1000
+	CloseSlowConsumer = 4000
+)
+
+// outbox is a client's bounded outbound queue. The hub pushes, the client's
+// writer (WritePump or the SSE loop) drains.
+type outbox struct {
+	mu     sync.Mutex
+	queue  []Message
+	limit  int
+	policy Policy
+
+	closed      bool
+	closeCode   int
+	closeReason string
+
+	// notify has room for one wake-up; the writer drains everything queued.
+	notify chan struct{}
+
+	sent    atomic.Int64
+	dropped atomic.Int64
+}
+
+func newOutbox(limit int, policy Policy) *outbox {
+	return &outbox{limit: limit, policy: policy, notify: make(chan struct{}, 1)}
+}
+
+// push queues m. It returns false when the queue is full under
+// PolicyDisconnect, meaning the client must be kicked.
+func (o *outbox) push(m Message) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return true
+	}
+	if len(o.queue) >= o.limit {
+		switch o.policy {
+		case PolicyDropOldest:
+			o.dropOldest()
+		case PolicyConflate:
+			if o.conflate(m) {
+				return true
+			}
+			if !o.dropOldestConflatable() {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	o.queue = append(o.queue, m)
+	o.wake()
+	return true
+}
+
+// conflate overwrites the newest queued message with m's key. Called with
+// o.mu held.
+func (o *outbox) conflate(m Message) bool {
+	if m.Key == "" {
+		return false
+	}
+	for i := len(o.queue) - 1; i >= 0; i-- {
+		if o.queue[i].Key == m.Key {
+			o.queue[i] = m
+			o.dropped.Add(1)
+			return true
+		}
+	}
+	return false
+}
+
+// dropOldestConflatable drops the oldest message that has a Key. Called
+// with o.mu held.
+func (o *outbox) dropOldestConflatable() bool {
+	for i, q := range o.queue {
+		if q.Key != "" {
+			o.queue = append(o.queue[:i], o.queue[i+1:]...)
+			o.dropped.Add(1)
+			return true
+		}
+	}
+	return false
+}
+
+func (o *outbox) dropOldest() {
+	o.queue = o.queue[1:]
+	o.dropped.Add(1)
+}
+
+// close stops further pushes and discards what's queued; the writer then
+// sends code / reason to the client.
+func (o *outbox) close(code int, reason string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return
+	}
+	o.dropped.Add(int64(len(o.queue)))
+	o.queue = nil
+	o.closed, o.closeCode, o.closeReason = true, code, reason
+	o.wake()
+}
+
+// drain takes everything queued. closed is true once close was called.
+func (o *outbox) drain() (msgs []Message, closed bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	msgs, o.queue = o.queue, nil
+	return msgs, o.closed
+}
+
+func (o *outbox) closeInfo() (int, string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.closeCode, o.closeReason
+}
+
+func (o *outbox) queued() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.queue)
+}
+
+// wake is called with o.mu held.
+func (o *outbox) wake() {
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+}
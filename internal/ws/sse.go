@@ -0,0 +1,203 @@
+/*
+Server-Sent Events flavour of the quote stream for consumers that can't use
+the websocket (dashboards behind proxies, curl scripts). It rides on the same
+hub and subscription refcounts as /api/ws.
+*/
+
+package ws
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// Comment lines keep proxies from closing an idle stream.
+	sseHeartbeat = 15 * time.Second
+
+	// Reconnect delay suggested to the browser's EventSource.
+	sseRetry = 3 * time.Second
+
+	// Room for a full watchlist (services.MaxWatchlistTickers).
+	sseMaxTickers = 100
+)
+
+// NewSSEHandler serves GET /api/stream/quotes?tickers=AAPL,I:SPX, or
+// ?watchlist=12 for the tickers of a watchlist (both can be combined).
+// Every event carries "<epoch>-<seq>" as its id, the hub's epoch and
+// sequence number; a client that reconnects with Last-Event-ID gets the
+// buffered messages it missed.
+func NewSSEHandler(hub *Hub) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tickers := make(map[string]bool)
+		for _, t := range strings.Split(c.Query("tickers"), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				tickers[t] = true
+			}
+		}
+		userID, _ := c.Locals("userID").(string)
+		if v := c.Query("watchlist"); v != "" {
+			id, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "invalid watchlist"})
+			}
+			list, err := hub.watchlistTickers(userID, id)
+			if err == errWatchlistNotFound {
+				return c.Status(404).JSON(fiber.Map{"error": err.Error()})
+			}
+			if err != nil {
+				return c.Status(500).JSON(fiber.Map{"error": err.Error()})
+			}
+			for _, t := range list {
+				tickers[t] = true
+			}
+		}
+		if len(tickers) == 0 {
+			return c.Status(400).JSON(fiber.Map{"error": "tickers query parameter is required"})
+		}
+		if len(tickers) > sseMaxTickers {
+			return c.Status(400).JSON(fiber.Map{"error": fmt.Sprintf("at most %d tickers per stream", sseMaxTickers)})
+		}
+
+		// EventSource sends the header; the query form is for clients that
+		// can't set headers on reconnect. An id from another epoch (another
+		// instance, or this one before a restart) can't be resumed: the
+		// client is told and starts over with snapshots.
+		lastEventID := strings.Clone(c.Get("Last-Event-ID", c.Query("lastEventId")))
+		var lastID uint64
+		resume, foreign := false, false
+		if lastEventID != "" {
+			epoch, seq, err := parseEventID(lastEventID)
+			if err != nil {
+				return c.Status(400).JSON(fiber.Map{"error": "invalid Last-Event-ID"})
+			}
+			if epoch == hub.epoch {
+				lastID, resume = seq, true
+			} else {
+				foreign = true
+			}
+		}
+
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+		c.Set("Connection", "keep-alive")
+		c.Set("X-Accel-Buffering", "no")
+
+		remoteAddr := c.IP()
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			client := newClient(hub, nil, "sse", userID, remoteAddr)
+			hub.Register <- client
+			defer func() { hub.Unregister <- client }()
+
+			// A resuming client already has state, a new one gets snapshots.
+			var snaps map[string][]byte
+			if !resume {
+				list := make([]string, 0, len(tickers))
+				for t := range tickers {
+					list = append(list, t)
+				}
+				snaps = hub.snapshots(list)
+			}
+			for t := range tickers {
+				hub.Subscribe <- Subscription{Client: client, Ticker: t, Snapshot: snaps[t]}
+			}
+
+			fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+
+			// Replay what the client missed. Live messages can overlap with the
+			// replay, so anything at or below sent is skipped later. An id
+			// newer than anything routed means the numbering started over:
+			// report the gap and take everything from here.
+			sent := lastID
+			if foreign {
+				fmt.Fprintf(w, "event: gap\ndata: {\"last_event_id\":%q,\"reset\":true}\n\n", lastEventID)
+			}
+			if resume {
+				missed, complete, reset := hub.Since(lastID, tickers)
+				switch {
+				case reset:
+					fmt.Fprintf(w, "event: gap\ndata: {\"last_event_id\":%q,\"reset\":true}\n\n", lastEventID)
+					sent = 0
+				case !complete:
+					fmt.Fprintf(w, "event: gap\ndata: {\"last_event_id\":%q}\n\n", lastEventID)
+				}
+				for _, m := range missed {
+					writeSSE(w, hub.epoch, m)
+					sent = m.Seq
+				}
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			heartbeat := time.NewTicker(sseHeartbeat)
+			defer heartbeat.Stop()
+
+			for {
+				select {
+				case <-client.out.notify:
+					messages, closed := client.out.drain()
+					// The hub dropped us (slow consumer or shutdown). There is
+					// no close frame in SSE, so say why in a final event.
+					if closed {
+						code, reason := client.out.closeInfo()
+						fmt.Fprintf(w, "event: close\ndata: {\"code\":%d,\"reason\":%q}\n\n", code, reason)
+						w.Flush()
+						return
+					}
+					for _, m := range messages {
+						// Snapshots and user frames (Seq 0) are per client
+						// and never replayed.
+						if m.Seq != 0 && m.Seq <= sent {
+							continue
+						}
+						writeSSE(w, hub.epoch, m)
+						client.out.sent.Add(1)
+						if m.Seq != 0 {
+							sent = m.Seq
+						}
+					}
+
+				case <-heartbeat.C:
+					w.WriteString(": heartbeat\n\n")
+				}
+
+				// A failed flush means the consumer went away.
+				if err := w.Flush(); err != nil {
+					return
+				}
+			}
+		})
+		return nil
+	}
+}
+
+// parseEventID splits an SSE id into epoch and sequence number. Plain
+// numbers, the ids from before epochs, have no epoch.
+func parseEventID(id string) (epoch string, seq uint64, err error) {
+	num := id
+	if i := strings.LastIndexByte(id, '-'); i >= 0 {
+		epoch, num = id[:i], id[i+1:]
+	}
+	seq, err = strconv.ParseUint(num, 10, 64)
+	return epoch, seq, err
+}
+
+func writeSSE(w *bufio.Writer, epoch string, m Message) {
+	switch {
+	case m.User != "":
+		fmt.Fprintf(w, "event: user\ndata: %s\n\n", m.Data)
+	case m.Seq == 0:
+		fmt.Fprintf(w, "event: snapshot\ndata: %s\n\n", m.Data)
+	case m.Ticker == "":
+		fmt.Fprintf(w, "id: %s-%d\nevent: status\ndata: %s\n\n", epoch, m.Seq, m.Data)
+	default:
+		fmt.Fprintf(w, "id: %s-%d\nevent: quote\ndata: %s\n\n", epoch, m.Seq, m.Data)
+	}
+}
@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Upstream subscription actions
+const (
+	ActionSubscribe   = "subscribe"
+	ActionUnsubscribe = "unsubscribe"
+)
+
+// SubRequest asks an upstream cluster to start or stop streaming a ticker.
+type SubRequest struct {
+	Action string
+	Ticker string
+}
+
+// TickerCount is one row of the refcount report.
+type TickerCount struct {
+	Ticker  string `json:"ticker"`
+	Viewers int    `json:"viewers"`
+}
+
+// SubscriptionManager sits between the clients and the upstream connections.
+// It reference-counts tickers so Massive only sees a subscribe for the first
+// viewer and an unsubscribe once the last viewer is gone.
+type SubscriptionManager struct {
+	mu     sync.Mutex
+	counts map[string]int
+
+	stockSubs chan<- SubRequest
+	indexSubs chan<- SubRequest
+}
+
+func NewSubscriptionManager(stockSubs, indexSubs chan<- SubRequest) *SubscriptionManager {
+	return &SubscriptionManager{
+		counts:    make(map[string]int),
+		stockSubs: stockSubs,
+		indexSubs: indexSubs,
+	}
+}
+
+// Acquire registers one more viewer for ticker.
+func (m *SubscriptionManager) Acquire(ticker string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.counts[ticker]++
+	if m.counts[ticker] == 1 {
+		m.send(SubRequest{Action: ActionSubscribe, Ticker: ticker})
+	}
+}
+
+// Release drops one viewer for ticker.
+func (m *SubscriptionManager) Release(ticker string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n, ok := m.counts[ticker]
+	if !ok {
+		return
+	}
+	if n > 1 {
+		m.counts[ticker] = n - 1
+		return
+	}
+	delete(m.counts, ticker)
+	m.send(SubRequest{Action: ActionUnsubscribe, Ticker: ticker})
+}
+
+// Counts returns the current refcounts sorted by ticker.
+func (m *SubscriptionManager) Counts() []TickerCount {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]TickerCount, 0, len(m.counts))
+	for t, n := range m.counts {
+		out = append(out, TickerCount{Ticker: t, Viewers: n})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Ticker < out[j].Ticker })
+	return out
+}
+
+// send is called with m.mu held so that upstream sees requests in order.
+func (m *SubscriptionManager) send(req SubRequest) {
+	// ROUTING LOGIC
+	// Indices in Massive always start with "I:"
+	if IsIndex(req.Ticker) {
+		m.indexSubs <- req
+	} else {
+		m.stockSubs <- req
+	}
+}
+
+// IsIndex reports whether ticker belongs to the indices cluster.
+func IsIndex(ticker string) bool {
+	return len(ticker) > 2 && strings.HasPrefix(ticker, "I:")
+}
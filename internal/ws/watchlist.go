@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive/stream"
+)
+
+// Client actions on top of subscribe / unsubscribe, e.g.
+// {"action":"open_watchlist","watchlist_id":12}.
+const (
+	ActionOpenWatchlist  = "open_watchlist"
+	ActionCloseWatchlist = "close_watchlist"
+)
+
+// WatchlistEventType is the "ev" of the reply to open_watchlist.
+const WatchlistEventType = "watchlist"
+
+var errWatchlistNotFound = errors.New("watchlist not found")
+
+// watchlistReply tells the client what an open_watchlist subscribed to.
+type watchlistReply struct {
+	ID      int64    `json:"id"`
+	Tickers []string `json:"tickers,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// watchlistTickers resolves a watchlist for userID. The error is fit for
+// the client.
+func (h *Hub) watchlistTickers(userID string, id int64) ([]string, error) {
+	if h.Watchlists == nil || userID == "" {
+		return nil, errors.New("watchlists are not available")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tickers, err := h.Watchlists.WatchlistTickers(ctx, userID, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, errWatchlistNotFound
+	}
+	if err != nil {
+		log.Printf("[Hub] watchlist %d for %s: %v", id, userID, err)
+		return nil, errors.New("could not load watchlist")
+	}
+	return tickers, nil
+}
+
+// reply sends a frame to this one client, on the hub goroutine like
+// everything else.
+func (c *Client) reply(typ string, payload any) {
+	data := stream.Encode([]stream.Event{stream.NewCustom(typ, "", payload)})
+	c.hub.Direct <- UserMessage{UserID: c.userID, Data: data, client: c}
+}
+
+// openWatchlist takes a reference on every ticker of a watchlist and
+// replies with the list. Opening a list again picks up its changes.
+func (c *Client) openWatchlist(id int64) {
+	tickers, err := c.hub.watchlistTickers(c.userID, id)
+	if err != nil {
+		c.reply(WatchlistEventType, watchlistReply{ID: id, Error: err.Error()})
+		return
+	}
+	// The reply goes first so the client knows what the snapshots are for.
+	c.reply(WatchlistEventType, watchlistReply{ID: id, Tickers: tickers})
+	c.acquire(tickers...)
+	for _, t := range c.opened[id] {
+		c.release(t)
+	}
+	c.opened[id] = tickers
+}
@@ -0,0 +1,197 @@
+package alerts
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dnhan1707/trader/internal/alerts"
+	"github.com/dnhan1707/trader/internal/services"
+)
+
+func allowAll(net.IP) bool { return true }
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"alert_id":1}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	got := alerts.Sign("secret", "1700000000", body)
+	if got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp string
+		body      []byte
+	}{
+		{"other timestamp", "secret", "1700000001", body},
+		{"other body", "secret", "1700000000", []byte(`{"alert_id":2}`)},
+		{"other secret", "other", "1700000000", body},
+		{"timestamp moved into the body", "secret", "170000000", []byte("0." + string(body))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if alerts.Sign(tt.secret, tt.timestamp, tt.body) == got {
+				t.Errorf("signature unchanged")
+			}
+		})
+	}
+}
+
+func TestWebhookIPAllowed(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want bool
+	}{
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"8.8.8.8", true},
+		{"2606:4700::1111", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			if got := services.WebhookIPAllowed(net.ParseIP(tt.ip)); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSendDelivers(t *testing.T) {
+	var ts, sig string
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts, sig = r.Header.Get(alerts.HeaderTimestamp), r.Header.Get(alerts.HeaderSignature)
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	status := alerts.NewWebhookSender(allowAll).Send(srv.URL, "secret", alerts.Payload{AlertID: 7, Ticker: "AAPL"})
+	if status != "delivered" {
+		t.Fatalf("status = %q, want delivered", status)
+	}
+	if ts == "" {
+		t.Fatalf("no %s header", alerts.HeaderTimestamp)
+	}
+	if want := alerts.Sign("secret", ts, body); sig != want {
+		t.Errorf("signature = %s, want %s", sig, want)
+	}
+}
+
+func TestSendBlocksPrivate(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer srv.Close()
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+
+	tests := []struct {
+		name string
+		url  string
+	}{
+		{"loopback literal", srv.URL},
+		{"host resolving to loopback", "http://localhost:" + port},
+	}
+	sender := alerts.NewWebhookSender(services.WebhookIPAllowed)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := sender.Send(tt.url, "secret", alerts.Payload{AlertID: 1})
+			if !strings.HasPrefix(status, "failed: ") {
+				t.Errorf("status = %q, want a failure", status)
+			}
+			if n := atomic.LoadInt32(&hits); n != 0 {
+				t.Errorf("server got %d requests", n)
+			}
+		})
+	}
+}
+
+func TestRedirectNotFollowed(t *testing.T) {
+	var hits int32
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer srv.Close()
+
+	status := alerts.NewWebhookSender(allowAll).Send(srv.URL, "secret", alerts.Payload{AlertID: 1})
+	if status != "failed: status 302" {
+		t.Errorf("status = %q, want failed: status 302", status)
+	}
+	if n := atomic.LoadInt32(&hits); n != 0 {
+		t.Errorf("redirect target got %d requests", n)
+	}
+}
+
+func TestValidateAlert(t *testing.T) {
+	tests := []struct {
+		name    string
+		alert   services.PriceAlert
+		ticker  string
+		mode    string
+		wantErr bool
+	}{
+		{name: "normalized", alert: services.PriceAlert{Ticker: " aapl ", Condition: services.AlertPriceAbove, Threshold: 200}, ticker: "AAPL", mode: services.AlertModeOnce},
+		{name: "rearm", alert: services.PriceAlert{Ticker: "MSFT", Condition: services.AlertPctFromOpen, Threshold: -3, Mode: services.AlertModeRearm, CooldownSeconds: 60}, ticker: "MSFT", mode: services.AlertModeRearm},
+		{name: "52 week high needs no threshold", alert: services.PriceAlert{Ticker: "SPY", Condition: services.AlertCross52wHigh}, ticker: "SPY", mode: services.AlertModeOnce},
+		{name: "public webhook", alert: services.PriceAlert{Ticker: "SPY", Condition: services.AlertVolumeSpike, Threshold: 3, WebhookURL: "http://93.184.216.34/hook"}, ticker: "SPY", mode: services.AlertModeOnce},
+		{name: "empty ticker", alert: services.PriceAlert{Ticker: " ", Condition: services.AlertPriceAbove, Threshold: 1}, wantErr: true},
+		{name: "unknown condition", alert: services.PriceAlert{Ticker: "AAPL", Condition: "price_between", Threshold: 1}, wantErr: true},
+		{name: "no condition", alert: services.PriceAlert{Ticker: "AAPL", Threshold: 1}, wantErr: true},
+		{name: "zero price", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertPriceBelow}, wantErr: true},
+		{name: "negative price", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertPriceAbove, Threshold: -1}, wantErr: true},
+		{name: "zero percent", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertPctFromOpen}, wantErr: true},
+		{name: "volume multiple of one", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertVolumeSpike, Threshold: 1}, wantErr: true},
+		{name: "unknown mode", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertCross52wLow, Mode: "always"}, wantErr: true},
+		{name: "negative cooldown", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertCross52wLow, CooldownSeconds: -1}, wantErr: true},
+		{name: "ftp webhook", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertCross52wLow, WebhookURL: "ftp://93.184.216.34/"}, wantErr: true},
+		{name: "loopback webhook", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertCross52wLow, WebhookURL: "http://127.0.0.1:8080/"}, wantErr: true},
+		{name: "localhost webhook", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertCross52wLow, WebhookURL: "http://localhost/"}, wantErr: true},
+		{name: "rfc1918 webhook", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertCross52wLow, WebhookURL: "http://10.0.0.1/"}, wantErr: true},
+		{name: "metadata webhook", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertCross52wLow, WebhookURL: "http://169.254.169.254/latest"}, wantErr: true},
+		{name: "ula webhook", alert: services.PriceAlert{Ticker: "AAPL", Condition: services.AlertCross52wLow, WebhookURL: "https://[fd00::1]/"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := tt.alert
+			err := services.ValidateAlert(&a)
+			if tt.wantErr {
+				var verr services.ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("got %v, want a validation error", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if a.Ticker != tt.ticker || a.Mode != tt.mode {
+				t.Errorf("ticker %q mode %q, want %q %q", a.Ticker, a.Mode, tt.ticker, tt.mode)
+			}
+		})
+	}
+}
@@ -0,0 +1,326 @@
+package backtest
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/dnhan1707/trader/internal/backtest"
+	"github.com/dnhan1707/trader/internal/indicators"
+)
+
+const dayMs = 24 * 60 * 60 * 1000
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+// ohlc builds one bar per {open, high, low, close}, a day apart.
+func ohlc(prices ...[4]float64) []indicators.Bar {
+	bars := make([]indicators.Bar, len(prices))
+	for i, p := range prices {
+		bars[i] = indicators.Bar{Time: int64(i) * dayMs, Open: p[0], High: p[1], Low: p[2], Close: p[3], Volume: 1000}
+	}
+	return bars
+}
+
+func strategy(t *testing.T, s string) backtest.Strategy {
+	t.Helper()
+	var st backtest.Strategy
+	if err := json.Unmarshal([]byte(s), &st); err != nil {
+		t.Fatalf("unmarshal %s: %v", s, err)
+	}
+	return st
+}
+
+// aboveTen buys once the close is over 10 and sells once it's back under.
+const aboveTen = `{"entry": {"all": [{"left": "close", "op": ">", "right": 10}]},
+	"exit": {"all": [{"left": "close", "op": "<", "right": 10}]}}`
+
+// upAndDown signals an entry on bar 1 (filled at 12 on bar 2) and an exit
+// on bar 3 (filled at 8 on bar 4).
+var upAndDown = ohlc(
+	[4]float64{9, 9, 9, 9},
+	[4]float64{9, 11, 9, 11},
+	[4]float64{12, 12, 12, 12},
+	[4]float64{12, 14, 9, 9},
+	[4]float64{8, 8, 8, 8},
+	[4]float64{8, 8, 8, 8},
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		wantErr string
+	}{
+		{name: "valid", json: aboveTen},
+		{name: "stop loss instead of an exit rule", json: `{"entry": {"all": [{"left": "rsi:14", "op": "<", "right": 30}]}, "stop_loss": 0.05}`},
+		{name: "indicator lines", json: `{"entry": {"any": [{"left": "macd", "op": "crosses_above", "right": "macd.signal"}, {"left": "close", "op": "<", "right": "bb:20:2.5.lower"}]}, "exit": {"all": [{"left": "ichimoku.tenkan", "op": ">", "right": "close"}]}}`},
+		{name: "no entry", json: `{"exit": {"all": [{"left": "close", "op": "<", "right": 10}]}}`, wantErr: "entry rule has no conditions"},
+		{name: "no way out", json: `{"entry": {"all": [{"left": "close", "op": ">", "right": 10}]}}`, wantErr: "no stop_loss or take_profit"},
+		{name: "stop loss of 100%", json: `{"entry": {"all": [{"left": "close", "op": ">", "right": 10}]}, "stop_loss": 1}`, wantErr: "stop_loss"},
+		{name: "negative take profit", json: `{"entry": {"all": [{"left": "close", "op": ">", "right": 10}]}, "take_profit": -0.1}`, wantErr: "take_profit"},
+		{name: "unknown op", json: `{"entry": {"all": [{"left": "close", "op": "==", "right": 10}]}, "stop_loss": 0.1}`, wantErr: "unknown op"},
+		{name: "two constants", json: `{"entry": {"all": [{"left": 1, "op": "<", "right": 2}]}, "stop_loss": 0.1}`, wantErr: "two constants"},
+		{name: "unknown indicator", json: `{"entry": {"all": [{"left": "foo:3", "op": "<", "right": 2}]}, "stop_loss": 0.1}`, wantErr: "unknown indicator"},
+		{name: "line needed", json: `{"entry": {"all": [{"left": "bb:20:2", "op": "<", "right": "close"}]}, "stop_loss": 0.1}`, wantErr: "pick one"},
+		{name: "chikou looks ahead", json: `{"entry": {"all": [{"left": "ichimoku.chikou", "op": ">", "right": "close"}]}, "stop_loss": 0.1}`, wantErr: "later bars"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := strategy(t, tt.json).Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got %v, want an error about %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTooManyConditions(t *testing.T) {
+	cond := backtest.Condition{Left: backtest.Operand{Series: "close"}, Op: backtest.OpGT, Right: backtest.Operand{Value: 1}}
+	s := backtest.Strategy{StopLoss: 0.1}
+	for i := 0; i <= backtest.MaxConditions; i++ {
+		s.Entry.All = append(s.Entry.All, cond)
+	}
+	if err := s.Validate(); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	tests := []struct {
+		json string
+		want int
+	}{
+		{aboveTen, 1},
+		{`{"entry": {"all": [{"left": "sma:50", "op": "crosses_above", "right": "sma:200"}]}, "exit": {"all": [{"left": "rsi:14", "op": ">", "right": 70}]}}`, 200},
+		{`{"entry": {"all": []}}`, 0}, // invalid
+	}
+	for _, tt := range tests {
+		if got := strategy(t, tt.json).Warmup(); got != tt.want {
+			t.Errorf("Warmup(%s) = %d, want %d", tt.json, got, tt.want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     backtest.Config
+		wantErr bool
+	}{
+		{name: "defaults", cfg: backtest.Config{}},
+		{name: "negative cash", cfg: backtest.Config{InitialCash: -1}, wantErr: true},
+		{name: "position over 1", cfg: backtest.Config{PositionSize: 1.5}, wantErr: true},
+		{name: "negative commission", cfg: backtest.Config{Costs: backtest.Costs{Commission: -1}}, wantErr: true},
+		{name: "slippage of 100%", cfg: backtest.Config{Costs: backtest.Costs{SlippageBps: 10000}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Strategy = strategy(t, aboveTen)
+			err := cfg.Normalize()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalize: %v", err)
+			}
+			if cfg.InitialCash != 100000 || cfg.PositionSize != 1 {
+				t.Errorf("defaults = %v cash, %v position", cfg.InitialCash, cfg.PositionSize)
+			}
+		})
+	}
+}
+
+func TestRunTrades(t *testing.T) {
+	stopOnly := `{"entry": {"all": [{"left": "close", "op": ">", "right": 10}]}, "stop_loss": 0.2}`
+	gapped := ohlc(
+		[4]float64{9, 9, 9, 9},
+		[4]float64{9, 11, 9, 11},
+		[4]float64{12, 12, 12, 12},
+		[4]float64{9, 9, 9, 9},
+		[4]float64{8, 8, 8, 8},
+	)
+
+	tests := []struct {
+		name     string
+		strategy string
+		costs    backtest.Costs
+		bars     []indicators.Bar
+		start    int
+		want     []backtest.Trade // times and prices, qty, fees, pnl and reason
+	}{
+		{
+			name:     "signals fill at the next open",
+			strategy: aboveTen,
+			bars:     upAndDown,
+			want:     []backtest.Trade{{EntryTime: 2 * dayMs, EntryPrice: 12, ExitTime: 4 * dayMs, ExitPrice: 8, Qty: 100, PnL: -400, Return: -1.0 / 3, Bars: 3, ExitReason: backtest.ExitSignal}},
+		},
+		{
+			name:     "costs and slippage",
+			strategy: aboveTen,
+			costs:    backtest.Costs{Commission: 10, SlippageBps: 100},
+			bars:     upAndDown,
+			want:     []backtest.Trade{{EntryTime: 2 * dayMs, EntryPrice: 12.12, ExitTime: 4 * dayMs, ExitPrice: 7.92, Qty: 98, Fees: 20, PnL: 98*7.92 - 10 - (98*12.12 + 10), Return: (98*7.92 - 10 - (98*12.12 + 10)) / (98*12.12 + 10), Bars: 3, ExitReason: backtest.ExitSignal}},
+		},
+		{
+			name:     "stop loss inside the bar",
+			strategy: stopOnly,
+			bars:     upAndDown,
+			want:     []backtest.Trade{{EntryTime: 2 * dayMs, EntryPrice: 12, ExitTime: 3 * dayMs, ExitPrice: 9.6, Qty: 100, PnL: -240, Return: -0.2, Bars: 2, ExitReason: backtest.ExitStopLoss}},
+		},
+		{
+			name:     "gap through the stop fills at the open",
+			strategy: stopOnly,
+			bars:     gapped,
+			want:     []backtest.Trade{{EntryTime: 2 * dayMs, EntryPrice: 12, ExitTime: 3 * dayMs, ExitPrice: 9, Qty: 100, PnL: -300, Return: -0.25, Bars: 2, ExitReason: backtest.ExitStopLoss}},
+		},
+		{
+			name:     "take profit",
+			strategy: `{"entry": {"all": [{"left": "close", "op": ">", "right": 10}]}, "take_profit": 0.1}`,
+			bars:     upAndDown,
+			want:     []backtest.Trade{{EntryTime: 2 * dayMs, EntryPrice: 12, ExitTime: 3 * dayMs, ExitPrice: 13.2, Qty: 100, PnL: 120, Return: 0.1, Bars: 2, ExitReason: backtest.ExitTakeProfit}},
+		},
+		{
+			name:     "closed at the end of the data",
+			strategy: `{"entry": {"all": [{"left": "close", "op": ">", "right": 10}]}, "exit": {"all": [{"left": "close", "op": ">", "right": 100}]}}`,
+			bars:     upAndDown,
+			want:     []backtest.Trade{{EntryTime: 2 * dayMs, EntryPrice: 12, ExitTime: 5 * dayMs, ExitPrice: 8, Qty: 100, PnL: -400, Return: -1.0 / 3, Bars: 4, ExitReason: backtest.ExitEndOfData}},
+		},
+		{
+			name:     "signals in the warm-up aren't taken",
+			strategy: aboveTen,
+			bars:     upAndDown,
+			start:    2,
+			want:     []backtest.Trade{{EntryTime: 3 * dayMs, EntryPrice: 12, ExitTime: 4 * dayMs, ExitPrice: 8, Qty: 100, PnL: -400, Return: -1.0 / 3, Bars: 2, ExitReason: backtest.ExitSignal}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := backtest.Config{Strategy: strategy(t, tt.strategy), Costs: tt.costs, InitialCash: 1200}
+			if err := cfg.Normalize(); err != nil {
+				t.Fatalf("normalize: %v", err)
+			}
+			res, err := backtest.Run(cfg, tt.bars, tt.start, indicators.Options{})
+			if err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if len(res.Trades) != len(tt.want) {
+				t.Fatalf("got %d trades, want %d: %+v", len(res.Trades), len(tt.want), res.Trades)
+			}
+			for i, got := range res.Trades {
+				want := tt.want[i]
+				if got.EntryTime != want.EntryTime || got.ExitTime != want.ExitTime || got.Bars != want.Bars || got.ExitReason != want.ExitReason ||
+					!near(got.EntryPrice, want.EntryPrice) || !near(got.ExitPrice, want.ExitPrice) || got.Qty != want.Qty ||
+					!near(got.Fees, want.Fees) || !near(got.PnL, want.PnL) || !near(got.Return, want.Return) {
+					t.Errorf("trade %d = %+v, want %+v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunStats(t *testing.T) {
+	cfg := backtest.Config{Strategy: strategy(t, aboveTen), InitialCash: 1200}
+	if err := cfg.Normalize(); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	res, err := backtest.Run(cfg, upAndDown, 0, indicators.Options{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	s := res.Stats
+	tests := []struct {
+		name      string
+		got, want float64
+	}{
+		{"final equity", s.FinalEquity, 800},
+		{"total return", s.TotalReturn, -1.0 / 3},
+		{"max drawdown", s.MaxDrawdown, 1.0 / 3},
+		{"exposure", s.Exposure, 2.0 / 6},
+		{"benchmark return", s.BenchmarkReturn, 8.0/9 - 1},
+		{"bars", float64(s.Bars), 6},
+		{"trades", float64(s.Trades), 1},
+	}
+	for _, tt := range tests {
+		if !near(tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if s.WinRate == nil || *s.WinRate != 0 {
+		t.Errorf("win rate = %v, want 0", s.WinRate)
+	}
+	if s.ProfitFactor == nil || *s.ProfitFactor != 0 {
+		t.Errorf("profit factor = %v, want 0", s.ProfitFactor)
+	}
+	if s.CAGR == nil || s.Sharpe == nil {
+		t.Errorf("cagr = %v, sharpe = %v, want both set", s.CAGR, s.Sharpe)
+	}
+	if len(res.Equity) != 6 || res.Equity[3].Equity != 900 || !near(res.Equity[3].Drawdown, 0.25) {
+		t.Errorf("equity = %+v", res.Equity)
+	}
+}
+
+func TestRunWithoutTrades(t *testing.T) {
+	cfg := backtest.Config{Strategy: strategy(t, `{"entry": {"all": [{"left": "close", "op": ">", "right": 100}]}, "stop_loss": 0.1}`)}
+	if err := cfg.Normalize(); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	res, err := backtest.Run(cfg, upAndDown, 0, indicators.Options{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	s := res.Stats
+	if s.TotalReturn != 0 || s.FinalEquity != cfg.InitialCash || s.WinRate != nil || s.ProfitFactor != nil || s.Sharpe != nil {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestRunBounds(t *testing.T) {
+	cfg := backtest.Config{Strategy: strategy(t, aboveTen)}
+	if err := cfg.Normalize(); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	for _, start := range []int{-1, len(upAndDown)} {
+		if _, err := backtest.Run(cfg, upAndDown, start, indicators.Options{}); err == nil {
+			t.Errorf("start %d: expected an error", start)
+		}
+	}
+}
+
+func TestEquityThinned(t *testing.T) {
+	prices := make([][4]float64, 2*backtest.MaxCurvePoints+1)
+	for i := range prices {
+		prices[i] = [4]float64{9, 9, 9, 9}
+	}
+	bars := ohlc(prices...)
+
+	cfg := backtest.Config{Strategy: strategy(t, aboveTen)}
+	if err := cfg.Normalize(); err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	res, err := backtest.Run(cfg, bars, 0, indicators.Options{})
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if n := len(res.Equity); n > backtest.MaxCurvePoints+1 {
+		t.Errorf("got %d points, want at most %d", n, backtest.MaxCurvePoints+1)
+	}
+	if last := res.Equity[len(res.Equity)-1]; last.Time != bars[len(bars)-1].Time {
+		t.Errorf("last point at %d, want %d", last.Time, bars[len(bars)-1].Time)
+	}
+	if res.Stats.Bars != len(bars) {
+		t.Errorf("stats over %d bars, want %d", res.Stats.Bars, len(bars))
+	}
+}
@@ -0,0 +1,62 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/breaker"
+)
+
+const cooldown = 30 * time.Millisecond
+
+// step is one call on the set: allow (checked against open), report ok or
+// failure, or wait out the cooldown.
+type step struct {
+	op       string // allow, ok, fail or wait
+	endpoint string
+	open     bool
+}
+
+func allow(open bool) step { return step{op: "allow", endpoint: "/a", open: open} }
+
+var (
+	ok   = step{op: "ok", endpoint: "/a"}
+	fail = step{op: "fail", endpoint: "/a"}
+	wait = step{op: "wait"}
+)
+
+func TestTransitions(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"closed under the threshold", []step{fail, allow(false)}},
+		{"opens at the threshold", []step{fail, fail, allow(true), allow(true)}},
+		{"a success resets the count", []step{fail, ok, fail, allow(false)}},
+		{"one probe after the cooldown", []step{fail, fail, wait, allow(false), allow(true)}},
+		{"a good probe closes it", []step{fail, fail, wait, allow(false), ok, allow(false), allow(false)}},
+		{"a failed probe opens it again", []step{fail, fail, wait, allow(false), fail, allow(true)}},
+		{"closed again means a fresh count", []step{fail, fail, wait, allow(false), ok, fail, allow(false)}},
+		{"an abandoned probe is replaced", []step{fail, fail, wait, allow(false), wait, allow(false), allow(true)}},
+		{"endpoints are separate", []step{fail, fail, allow(true), {op: "allow", endpoint: "/b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := breaker.NewSet("test", 2, cooldown)
+			for i, st := range tt.steps {
+				switch st.op {
+				case "allow":
+					err := s.Allow(st.endpoint)
+					if st.open != errors.Is(err, breaker.ErrOpen) {
+						t.Fatalf("step %d: Allow(%s) = %v, want open: %v", i, st.endpoint, err, st.open)
+					}
+				case "ok", "fail":
+					s.Report(st.endpoint, st.op == "ok")
+				case "wait":
+					time.Sleep(cooldown + 10*time.Millisecond)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package candles
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/dnhan1707/trader/internal/candles"
+	"github.com/dnhan1707/trader/internal/massive/stream"
+)
+
+func trade(ts int64, price, size float64) stream.Event {
+	return stream.NewTrade(stream.Trade{Symbol: "AAPL", Price: price, Size: size, Timestamp: ts})
+}
+
+// run feeds frames through an Aggregator and returns it once Run is done,
+// with the bar-close events it published.
+func run(t *testing.T, history int, frames ...[]stream.Event) (*candles.Aggregator, []stream.Event) {
+	t.Helper()
+
+	out := make(chan []stream.Event, 100)
+	agg := candles.New(out, history)
+	in := make(chan []stream.Event, len(frames))
+	for _, f := range frames {
+		in <- f
+	}
+	close(in)
+	agg.Run(in)
+	close(out)
+
+	var published []stream.Event
+	for events := range out {
+		published = append(published, events...)
+	}
+	return agg, published
+}
+
+func TestParseInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"5s", true},
+		{"1m", true},
+		{"1h", true},
+		{"2m", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		iv, ok := candles.ParseInterval(tt.name)
+		if ok != tt.ok || (ok && iv.Name != tt.name) {
+			t.Errorf("ParseInterval(%q) = %+v, %v", tt.name, iv, ok)
+		}
+	}
+}
+
+func TestAggregatorBars(t *testing.T) {
+	tests := []struct {
+		name    string
+		frames  [][]stream.Event
+		history int
+		closed  []candles.Bar // 5s bars, oldest first
+		current *candles.Bar
+	}{
+		{
+			name:    "one open bar",
+			frames:  [][]stream.Event{{trade(1000, 10, 1), trade(2000, 12, 3)}, {trade(3000, 9, 0)}},
+			current: &candles.Bar{Interval: "5s", Open: 10, High: 12, Low: 9, Close: 9, Volume: 4, VWAP: 11.5, Trades: 3, Start: 0, End: 5000},
+		},
+		{
+			name:   "next bucket closes the bar",
+			frames: [][]stream.Event{{trade(1000, 10, 1)}, {trade(6000, 11, 2)}},
+			closed: []candles.Bar{
+				{Interval: "5s", Open: 10, High: 10, Low: 10, Close: 10, Volume: 1, VWAP: 10, Trades: 1, Start: 0, End: 5000},
+			},
+			current: &candles.Bar{Interval: "5s", Open: 11, High: 11, Low: 11, Close: 11, Volume: 2, VWAP: 11, Trades: 1, Start: 5000, End: 10000},
+		},
+		{
+			name:   "late print is ignored",
+			frames: [][]stream.Event{{trade(1000, 10, 1), trade(6000, 11, 1)}, {trade(2000, 50, 1)}},
+			closed: []candles.Bar{
+				{Interval: "5s", Open: 10, High: 10, Low: 10, Close: 10, Volume: 1, VWAP: 10, Trades: 1, Start: 0, End: 5000},
+			},
+			current: &candles.Bar{Interval: "5s", Open: 11, High: 11, Low: 11, Close: 11, Volume: 1, VWAP: 11, Trades: 1, Start: 5000, End: 10000},
+		},
+		{
+			name:    "bad prints are skipped",
+			frames:  [][]stream.Event{{trade(1000, 0, 1), trade(0, 5, 1), trade(1500, 7, 1)}},
+			current: &candles.Bar{Interval: "5s", Open: 7, High: 7, Low: 7, Close: 7, Volume: 1, VWAP: 7, Trades: 1, Start: 0, End: 5000},
+		},
+		{
+			name:    "history is capped",
+			history: 2,
+			frames:  [][]stream.Event{{trade(1000, 1, 1), trade(6000, 2, 1), trade(11000, 3, 1), trade(16000, 4, 1)}},
+			closed: []candles.Bar{
+				{Interval: "5s", Open: 2, High: 2, Low: 2, Close: 2, Volume: 1, VWAP: 2, Trades: 1, Start: 5000, End: 10000},
+				{Interval: "5s", Open: 3, High: 3, Low: 3, Close: 3, Volume: 1, VWAP: 3, Trades: 1, Start: 10000, End: 15000},
+			},
+			current: &candles.Bar{Interval: "5s", Open: 4, High: 4, Low: 4, Close: 4, Volume: 1, VWAP: 4, Trades: 1, Start: 15000, End: 20000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agg, published := run(t, tt.history, tt.frames...)
+
+			closed, current := agg.History("AAPL", "5s", 0)
+			if len(closed) != len(tt.closed) {
+				t.Fatalf("expected %d closed bars, got %+v", len(tt.closed), closed)
+			}
+			for i := range closed {
+				if closed[i] != tt.closed[i] {
+					t.Errorf("closed[%d] = %+v, want %+v", i, closed[i], tt.closed[i])
+				}
+			}
+			if (current == nil) != (tt.current == nil) || (current != nil && *current != *tt.current) {
+				t.Errorf("current = %+v, want %+v", current, tt.current)
+			}
+
+			// Every 5s close was published, capped history or not.
+			var fives int
+			for _, e := range published {
+				if e.Type != candles.EventType || e.Symbol != "AAPL" {
+					t.Fatalf("unexpected event %+v", e)
+				}
+				var b candles.Bar
+				if err := json.Unmarshal(e.Raw, &b); err != nil {
+					t.Fatalf("decode bar: %v", err)
+				}
+				if b.Interval == "5s" {
+					fives++
+				}
+			}
+			want := len(tt.closed)
+			if tt.history > 0 {
+				want = 3
+			}
+			if fives != want {
+				t.Errorf("expected %d published 5s bars, got %d", want, fives)
+			}
+		})
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	agg, _ := run(t, 0, []stream.Event{trade(1000, 1, 1), trade(6000, 2, 1), trade(11000, 3, 1)})
+
+	tests := []struct {
+		limit int
+		want  []float64 // closes
+	}{
+		{limit: 0, want: []float64{1, 2}},
+		{limit: 1, want: []float64{2}},
+		{limit: 5, want: []float64{1, 2}},
+	}
+	for _, tt := range tests {
+		closed, _ := agg.History("AAPL", "5s", tt.limit)
+		if len(closed) != len(tt.want) {
+			t.Fatalf("limit %d: got %+v", tt.limit, closed)
+		}
+		for i, b := range closed {
+			if b.Close != tt.want[i] {
+				t.Errorf("limit %d: bar %d close %v, want %v", tt.limit, i, b.Close, tt.want[i])
+			}
+		}
+	}
+
+	if closed, current := agg.History("MSFT", "5s", 0); len(closed) != 0 || current != nil {
+		t.Errorf("expected nothing for an unknown ticker, got %+v %+v", closed, current)
+	}
+}
@@ -0,0 +1,68 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/dnhan1707/trader/internal/cache"
+	"github.com/dnhan1707/trader/internal/cluster"
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/ws"
+)
+
+const leaderKey = "quotes:leader"
+
+// source reports each Run and its end.
+type source struct {
+	started chan struct{}
+	stopped chan struct{}
+}
+
+func (s *source) Run(ctx context.Context, _ chan<- []stream.Event, _, _ <-chan ws.SubRequest) {
+	s.started <- struct{}{}
+	<-ctx.Done()
+	s.stopped <- struct{}{}
+}
+
+// wait fails unless ch fires within a couple of election rounds.
+func wait(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(12 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestLeaderStepsDownAndRecovers(t *testing.T) {
+	mr := miniredis.RunT(t)
+	c := cache.New(mr.Addr(), "", 0, 60, 60, 0, 0)
+
+	hub := ws.NewHub(nil)
+	go hub.Run()
+	src := &source{started: make(chan struct{}, 1), stopped: make(chan struct{}, 1)}
+	node := cluster.New("a", c, src, hub)
+	go node.Run(make(chan ws.SubRequest), make(chan ws.SubRequest))
+
+	wait(t, src.started, "the source to start")
+	if s := node.Status(); !s.Leader || s.LeaderID != "a" {
+		t.Fatalf("status %+v, want leader a", s)
+	}
+
+	// Another instance holds the lock, e.g. after ours expired while Redis
+	// was unreachable: stop the source but keep running as a follower.
+	mr.Set(leaderKey, "b")
+	wait(t, src.stopped, "the source to stop")
+	if s := node.Status(); s.Leader || s.LeaderID != "b" || s.Upstream != nil {
+		t.Fatalf("status %+v, want a follower of b", s)
+	}
+
+	// Once the lock is free again the node stands and wins.
+	mr.Del(leaderKey)
+	wait(t, src.started, "the source to restart")
+	if s := node.Status(); !s.Leader || s.LeaderID != "a" {
+		t.Fatalf("status %+v, want leader a again", s)
+	}
+}
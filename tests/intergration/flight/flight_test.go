@@ -0,0 +1,215 @@
+package flight
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/flight"
+)
+
+// settle gives goroutines started by a test time to join a call.
+const settle = 20 * time.Millisecond
+
+func TestCoalesce(t *testing.T) {
+	g := flight.New(time.Second)
+	var calls int32
+	release := make(chan struct{})
+	fn := func(ctx context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return "v", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make(chan string, 5)
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := g.Do(context.Background(), "k", fn)
+			if err != nil {
+				t.Errorf("do: %v", err)
+			}
+			results <- v
+		}()
+	}
+	time.Sleep(settle)
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for v := range results {
+		if v != "v" {
+			t.Errorf("got %q, want v", v)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("fn ran %d times, want 1", n)
+	}
+
+	// Done calls are forgotten.
+	if _, err := g.Do(context.Background(), "k", fn); err != nil {
+		t.Fatalf("do: %v", err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("fn ran %d times, want 2", n)
+	}
+}
+
+func TestKeysAndErrors(t *testing.T) {
+	g := flight.New(time.Second)
+	boom := errors.New("boom")
+	tests := []struct {
+		key  string
+		val  string
+		err  error
+		want string
+	}{
+		{"a", "1", nil, "1"},
+		{"b", "2", nil, "2"},
+		{"c", "", boom, ""},
+	}
+	for _, tt := range tests {
+		v, err := g.Do(context.Background(), tt.key, func(context.Context) (string, error) { return tt.val, tt.err })
+		if v != tt.want || err != tt.err {
+			t.Errorf("Do(%s) = %q, %v, want %q, %v", tt.key, v, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestWaitersLeaving(t *testing.T) {
+	tests := []struct {
+		name       string
+		background bool // started with Start before anyone waits
+		waiters    int
+		leave      int
+		cancelled  bool
+	}{
+		{name: "one of two leaves", waiters: 2, leave: 1},
+		{name: "every waiter leaves", waiters: 2, leave: 2, cancelled: true},
+		{name: "the only waiter leaves", waiters: 1, leave: 1, cancelled: true},
+		{name: "a background load outlives its waiters", background: true, waiters: 2, leave: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := flight.New(time.Second)
+			started, cancelled, release := make(chan struct{}), make(chan struct{}), make(chan struct{})
+			fn := func(ctx context.Context) (string, error) {
+				close(started)
+				select {
+				case <-ctx.Done():
+					close(cancelled)
+					return "", ctx.Err()
+				case <-release:
+					return "v", nil
+				}
+			}
+			if tt.background {
+				g.Start("k", fn)
+			}
+
+			type result struct {
+				v   string
+				err error
+			}
+			results := make([]chan result, tt.waiters)
+			cancels := make([]context.CancelFunc, tt.waiters)
+			for i := range results {
+				ctx, cancel := context.WithCancel(context.Background())
+				results[i], cancels[i] = make(chan result, 1), cancel
+				go func(i int) {
+					v, err := g.Do(ctx, "k", fn)
+					results[i] <- result{v, err}
+				}(i)
+			}
+			<-started
+			time.Sleep(settle)
+
+			for i := 0; i < tt.leave; i++ {
+				cancels[i]()
+				if r := <-results[i]; !errors.Is(r.err, context.Canceled) {
+					t.Errorf("waiter %d got %q, %v, want context.Canceled", i, r.v, r.err)
+				}
+			}
+
+			select {
+			case <-cancelled:
+				if !tt.cancelled {
+					t.Fatal("load cancelled with callers still waiting")
+				}
+				return
+			case <-time.After(50 * time.Millisecond):
+				if tt.cancelled {
+					t.Fatal("load not cancelled once every waiter had gone")
+				}
+			}
+			close(release)
+			for i := tt.leave; i < tt.waiters; i++ {
+				if r := <-results[i]; r.v != "v" || r.err != nil {
+					t.Errorf("waiter %d got %q, %v, want v", i, r.v, r.err)
+				}
+			}
+		})
+	}
+}
+
+func TestLeftCallStartsAfresh(t *testing.T) {
+	g := flight.New(time.Second)
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	go g.Do(ctx, "k", func(ctx context.Context) (string, error) {
+		close(started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	<-started
+	cancel()
+	time.Sleep(settle)
+
+	v, err := g.Do(context.Background(), "k", func(context.Context) (string, error) { return "fresh", nil })
+	if v != "fresh" || err != nil {
+		t.Errorf("got %q, %v, want a fresh load", v, err)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	g := flight.New(settle)
+	_, err := g.Do(context.Background(), "k", func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestStartJoinsInFlight(t *testing.T) {
+	g := flight.New(time.Second)
+	var calls int32
+	release := make(chan struct{})
+	done := make(chan string)
+	go func() {
+		v, _ := g.Do(context.Background(), "k", func(context.Context) (string, error) {
+			atomic.AddInt32(&calls, 1)
+			<-release
+			return "v", nil
+		})
+		done <- v
+	}()
+	time.Sleep(settle)
+	g.Start("k", func(context.Context) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return "other", nil
+	})
+	close(release)
+	if v := <-done; v != "v" {
+		t.Errorf("got %q, want v", v)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("%d loads, want 1", n)
+	}
+}
@@ -0,0 +1,290 @@
+package indicators
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/indicators"
+)
+
+var nan = math.NaN()
+
+// same compares lines value by value, NaN matching NaN.
+func same(got, want indicators.Line) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if math.IsNaN(want[i]) {
+			if !math.IsNaN(got[i]) {
+				return false
+			}
+			continue
+		}
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			return false
+		}
+	}
+	return true
+}
+
+func bar(high, low, close, volume float64) indicators.Bar {
+	return indicators.Bar{High: high, Low: low, Close: close, Volume: volume}
+}
+
+// threeBars has true ranges 2, 2 and 5.
+var threeBars = []indicators.Bar{
+	bar(10, 8, 9, 100),
+	bar(11, 9, 10, 200),
+	bar(12, 7, 8, 300),
+}
+
+func TestLineMath(t *testing.T) {
+	tests := []struct {
+		name string
+		got  indicators.Line
+		want indicators.Line
+	}{
+		{"sma", indicators.SMA(indicators.Line{1, 2, 3, 4, 5}, 3), indicators.Line{nan, nan, 2, 3, 4}},
+		{"sma skips windows with a gap", indicators.SMA(indicators.Line{1, nan, 3, 4, 5, 6}, 2), indicators.Line{nan, nan, nan, 3.5, 4.5, 5.5}},
+		{"sma longer than the input", indicators.SMA(indicators.Line{1, 2}, 3), indicators.Line{nan, nan}},
+		{"ema seeded with the sma", indicators.EMA(indicators.Line{2, 4, 6, 8, 12}, 2), indicators.Line{nan, 3, 5, 7, 10 + 1.0/3}},
+		{"ema starts at the first valid value", indicators.EMA(indicators.Line{nan, 2, 4, 6}, 2), indicators.Line{nan, nan, 3, 5}},
+		{"rsi", indicators.RSI(indicators.Line{1, 2, 3, 2, 4}, 2), indicators.Line{nan, nan, 100, 50, 100 - 100.0/6}},
+		{"rsi of a flat line is 50", indicators.RSI(indicators.Line{5, 5, 5}, 2), indicators.Line{nan, nan, 50}},
+		{"atr", indicators.ATR(threeBars, 2), indicators.Line{nan, 2, 3.5}},
+		{"obv", indicators.OBV(append(threeBars, bar(9, 7, 8, 400))), indicators.Line{0, 200, -100, -100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !same(tt.got, tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBands(t *testing.T) {
+	upper, middle, lower := indicators.Bollinger(indicators.Line{2, 4, 4, 4, 5, 5, 7, 9}, 8, 2)
+	if last := len(middle) - 1; middle[last] != 5 || upper[last] != 9 || lower[last] != 1 {
+		t.Errorf("bollinger = %v/%v/%v, want 9/5/1", upper[last], middle[last], lower[last])
+	}
+	if !math.IsNaN(upper[6]) || !math.IsNaN(lower[6]) {
+		t.Errorf("bollinger before a full window = %v/%v, want NaN", upper[6], lower[6])
+	}
+
+	upper, middle, lower = indicators.Donchian(threeBars, 2)
+	if !same(upper, indicators.Line{nan, 11, 12}) || !same(middle, indicators.Line{nan, 9.5, 9.5}) || !same(lower, indicators.Line{nan, 8, 7}) {
+		t.Errorf("donchian = %v/%v/%v", upper, middle, lower)
+	}
+}
+
+func TestStochastic(t *testing.T) {
+	k, d := indicators.Stochastic(threeBars, 2, 1, 1)
+	want := indicators.Line{nan, 200.0 / 3, 20}
+	if !same(k, want) || !same(d, want) {
+		t.Errorf("stochastic = %v/%v, want %v", k, d, want)
+	}
+}
+
+func TestMACD(t *testing.T) {
+	src := indicators.Line{1, 3, 2, 5, 4, 6, 8, 7}
+	macd, sig, hist := indicators.MACD(src, 2, 3, 2)
+	fast, slow := indicators.EMA(src, 2), indicators.EMA(src, 3)
+	for i := range src {
+		if !same(indicators.Line{macd[i]}, indicators.Line{fast[i] - slow[i]}) {
+			t.Errorf("macd[%d] = %v, want %v", i, macd[i], fast[i]-slow[i])
+		}
+		if !same(indicators.Line{hist[i]}, indicators.Line{macd[i] - sig[i]}) {
+			t.Errorf("histogram[%d] = %v, want %v", i, hist[i], macd[i]-sig[i])
+		}
+	}
+	if math.IsNaN(sig[len(sig)-1]) {
+		t.Error("signal never settled")
+	}
+}
+
+func TestVWAP(t *testing.T) {
+	first := indicators.Bar{High: 3, Low: 1, Close: 2, Volume: 10}
+	second := indicators.Bar{High: 6, Low: 4, Close: 5, Volume: 30}
+	withOwn := second
+	withOwn.VWAP = 4
+
+	tests := []struct {
+		name       string
+		bars       []indicators.Bar
+		newSession func(prev, cur indicators.Bar) bool
+		want       indicators.Line
+	}{
+		{"typical price", []indicators.Bar{first, second}, nil, indicators.Line{2, 4.25}},
+		{"the bar's own vwap wins", []indicators.Bar{first, withOwn}, nil, indicators.Line{2, 3.5}},
+		{"new session starts over", []indicators.Bar{first, second}, func(_, _ indicators.Bar) bool { return true }, indicators.Line{2, 5}},
+		{"no volume yet", []indicators.Bar{{High: 1, Low: 1, Close: 1}, first}, nil, indicators.Line{nan, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := indicators.VWAP(tt.bars, tt.newSession); !same(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		in      string
+		key     string
+		warmup  int
+		wantErr bool
+	}{
+		{in: "RSI", key: "rsi:14", warmup: 43},
+		{in: "bb:20:2.5", key: "bb:20:2.5", warmup: 20},
+		{in: "macd", key: "macd:12:26:9", warmup: 87},
+		{in: "vwap", key: "vwap", warmup: 0},
+		{in: "ichimoku", key: "ichimoku:9:26:52", warmup: 78},
+		{in: "stoch:5", key: "stoch:5:3:3", warmup: 11},
+		{in: "sma:1000", key: "sma:1000", warmup: 1000},
+		{in: "foo", wantErr: true},
+		{in: "sma:0", wantErr: true},
+		{in: "sma:1.5", wantErr: true},
+		{in: "sma:1001", wantErr: true},
+		{in: "sma:x", wantErr: true},
+		{in: "sma:10:2", wantErr: true},
+		{in: "vwap:1", wantErr: true},
+		{in: "bb:20:0", wantErr: true},
+		{in: "bb:20:11", wantErr: true},
+		{in: "macd:26:12", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			spec, err := indicators.ParseSpec(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", spec.Key())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if spec.Key() != tt.key {
+				t.Errorf("key = %q, want %q", spec.Key(), tt.key)
+			}
+			if spec.Warmup() != tt.warmup {
+				t.Errorf("warmup = %d, want %d", spec.Warmup(), tt.warmup)
+			}
+		})
+	}
+}
+
+func TestParseSet(t *testing.T) {
+	tooMany := make([]string, indicators.MaxSpecs+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("sma:%d", i+1)
+	}
+
+	tests := []struct {
+		name    string
+		in      string
+		keys    []string
+		wantErr bool
+	}{
+		{name: "defaults and dedup", in: "rsi, RSI:14 ,bb,,atr:7", keys: []string{"rsi:14", "bb:20:2", "atr:7"}},
+		{name: "empty", in: " , ", wantErr: true},
+		{name: "one bad item fails the set", in: "rsi,nope", wantErr: true},
+		{name: "too many", in: strings.Join(tooMany, ","), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			specs, err := indicators.ParseSet(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d specs", len(specs))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			var keys []string
+			for _, s := range specs {
+				keys = append(keys, s.Key())
+			}
+			if strings.Join(keys, ",") != strings.Join(tt.keys, ",") {
+				t.Errorf("keys = %v, want %v", keys, tt.keys)
+			}
+		})
+	}
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		spec  string
+		lines []string
+	}{
+		{"sma:2", []string{"sma"}},
+		{"bb:2:2", []string{"lower", "middle", "upper"}},
+		{"macd:2:3:2", []string{"histogram", "macd", "signal"}},
+		{"stoch:2:1:1", []string{"d", "k"}},
+		{"adx:2", []string{"adx", "minus_di", "plus_di"}},
+		{"ichimoku:2:3:4", []string{"chikou", "kijun", "senkou_a", "senkou_b", "tenkan"}},
+		{"donchian:2", []string{"lower", "middle", "upper"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			spec, err := indicators.ParseSpec(tt.spec)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			out := spec.Compute(threeBars, indicators.Options{})
+			var names []string
+			for name, line := range out {
+				names = append(names, name)
+				if len(line) != len(threeBars) {
+					t.Errorf("%s has %d values, want %d", name, len(line), len(threeBars))
+				}
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(tt.lines, ",") {
+				t.Errorf("lines = %v, want %v", names, tt.lines)
+			}
+		})
+	}
+}
+
+func TestLineMarshalJSON(t *testing.T) {
+	b, err := json.Marshal(indicators.Line{1, nan, 2.5, math.Inf(1)})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(b) != "[1,null,2.5,null]" {
+		t.Errorf("got %s", b)
+	}
+}
+
+func TestLookbackStart(t *testing.T) {
+	from := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		timespan   string
+		multiplier int
+		bars       int
+		days       int
+	}{
+		{"day", 1, 0, 0},
+		{"day", 1, 10, 19},
+		{"week", 1, 2, 21},
+		{"month", 1, 1, 62},
+		{"minute", 5, 78, 5},
+	}
+	for _, tt := range tests {
+		got := indicators.LookbackStart(from, tt.timespan, tt.multiplier, tt.bars)
+		if want := from.AddDate(0, 0, -tt.days); !got.Equal(want) {
+			t.Errorf("LookbackStart(%s, %d, %d) = %s, want %s", tt.timespan, tt.multiplier, tt.bars, got, want)
+		}
+	}
+}
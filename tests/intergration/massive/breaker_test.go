@@ -0,0 +1,95 @@
+package massive
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dnhan1707/trader/internal/breaker"
+	"github.com/dnhan1707/trader/internal/massive"
+)
+
+// Massive's circuits open after 5 failures in a row.
+const breakerThreshold = 5
+
+func TestEndpointCircuits(t *testing.T) {
+	ctx := context.Background()
+	// A 429 past the longest wait fails without being retried.
+	limited := response{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "60"}}
+
+	tests := []struct {
+		name string
+		// fail is called breakerThreshold times, then same and other once
+		fail  func(c *massive.Client) error
+		same  func(c *massive.Client) error
+		other func(c *massive.Client) error
+	}{
+		{
+			name:  "tickers",
+			fail:  func(c *massive.Client) error { _, err := c.GetTickerDetails(ctx, "AAPL"); return err },
+			same:  func(c *massive.Client) error { _, err := c.GetTickerDetails(ctx, "MSFT"); return err },
+			other: func(c *massive.Client) error { _, err := c.GetMarketStatus(ctx); return err },
+		},
+		{
+			name: "aggregates",
+			fail: func(c *massive.Client) error {
+				_, err := c.GetCustomBars(ctx, "AAPL", "1", "day", "2024-01-01", "2024-02-01", nil)
+				return err
+			},
+			same: func(c *massive.Client) error {
+				_, err := c.GetCustomBars(ctx, "BRK.B", "5", "minute", "2024-01-02", "2024-01-03", nil)
+				return err
+			},
+			other: func(c *massive.Client) error { _, err := c.GetTickerSnapshot(ctx, "AAPL"); return err },
+		},
+		{
+			name:  "snapshots",
+			fail:  func(c *massive.Client) error { _, err := c.GetTickerSnapshot(ctx, "AAPL"); return err },
+			same:  func(c *massive.Client) error { _, err := c.GetTickerSnapshot(ctx, "TSLA"); return err },
+			other: func(c *massive.Client) error { _, err := c.GetTickerDetails(ctx, "AAPL"); return err },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := serve(t, limited)
+			c := massive.New(srv.URL, "", 0)
+			for i := 0; i < breakerThreshold; i++ {
+				if err := tt.fail(c); massive.StatusOf(err) != http.StatusTooManyRequests {
+					t.Fatalf("call %d: got %v, want a 429", i, err)
+				}
+			}
+
+			before := atomic.LoadInt32(hits)
+			err := tt.same(c)
+			if !errors.Is(err, breaker.ErrOpen) {
+				t.Fatalf("got %v, want the circuit open", err)
+			}
+			if atomic.LoadInt32(hits) != before {
+				t.Error("an open circuit let the request through")
+			}
+			if !strings.HasPrefix(err.Error(), "massive /") {
+				t.Errorf("error %q doesn't name the endpoint", err)
+			}
+
+			if err := tt.other(c); massive.StatusOf(err) != http.StatusTooManyRequests {
+				t.Errorf("other endpoint: got %v, want a 429 from upstream", err)
+			}
+		})
+	}
+}
+
+func TestRejectionsKeepTheCircuitClosed(t *testing.T) {
+	srv, hits := serve(t, response{status: http.StatusNotFound, body: `{"error": "not found"}`})
+	c := massive.New(srv.URL, "", 0)
+	for i := 0; i <= breakerThreshold; i++ {
+		if _, err := c.GetTickerDetails(context.Background(), "NOPE"); massive.StatusOf(err) != http.StatusNotFound {
+			t.Fatalf("call %d: got %v, want a 404", i, err)
+		}
+	}
+	if got := atomic.LoadInt32(hits); got != breakerThreshold+1 {
+		t.Errorf("%d requests, want %d", got, breakerThreshold+1)
+	}
+}
@@ -0,0 +1,188 @@
+package massive
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive"
+)
+
+type response struct {
+	status int
+	header map[string]string
+	body   string
+}
+
+// serve answers with responses in turn, repeating the last one, and
+// counts the requests.
+func serve(t *testing.T, responses ...response) (*httptest.Server, *int32) {
+	t.Helper()
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&hits, 1)) - 1
+		if n >= len(responses) {
+			n = len(responses) - 1
+		}
+		resp := responses[n]
+		for k, v := range resp.header {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(resp.status)
+		w.Write([]byte(resp.body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+var okBody = response{status: http.StatusOK, body: `{"status": "OK"}`}
+
+func TestRetries(t *testing.T) {
+	tests := []struct {
+		name       string
+		responses  []response
+		hits       int32
+		status     int // 0 for success
+		message    string
+		requestID  string
+		retryAfter time.Duration
+	}{
+		{name: "ok", responses: []response{okBody}, hits: 1},
+		{name: "5xx is retried", responses: []response{{status: 503}, okBody}, hits: 2},
+		{name: "retry-after replaces the backoff", responses: []response{{status: 429, header: map[string]string{"Retry-After": "1"}}, okBody}, hits: 2},
+		{
+			name:      "4xx is not retried",
+			responses: []response{{status: 404, body: `{"status": "ERROR", "error": "ticker not found", "request_id": "abc"}`}, okBody},
+			hits:      1, status: 404, message: "ticker not found", requestID: "abc",
+		},
+		{
+			name:      "message field",
+			responses: []response{{status: 403, body: `{"status": "NOT_AUTHORIZED", "message": "upgrade your plan"}`}},
+			hits:      1, status: 403, message: "upgrade your plan",
+		},
+		{name: "plain body", responses: []response{{status: 400, body: "bad ticker"}}, hits: 1, status: 400, message: "bad ticker"},
+		{
+			name:      "retry-after past the longest wait",
+			responses: []response{{status: 429, header: map[string]string{"Retry-After": "60"}}, okBody},
+			hits:      1, status: 429, message: "Too Many Requests", retryAfter: time.Minute,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, hits := serve(t, tt.responses...)
+			c := massive.New(srv.URL, "secret", 0)
+
+			res, err := c.GetMarketStatus(context.Background())
+			if got := atomic.LoadInt32(hits); got != tt.hits {
+				t.Errorf("%d requests, want %d", got, tt.hits)
+			}
+			if tt.status == 0 {
+				if err != nil {
+					t.Fatalf("get: %v", err)
+				}
+				if res["status"] != "OK" {
+					t.Errorf("got %v", res)
+				}
+				return
+			}
+
+			var e *massive.Error
+			if !errors.As(err, &e) {
+				t.Fatalf("got %v, want a *massive.Error", err)
+			}
+			if massive.StatusOf(err) != tt.status || e.Message != tt.message || e.RequestID != tt.requestID || e.RetryAfter != tt.retryAfter {
+				t.Errorf("got %+v", e)
+			}
+			if strings.Contains(err.Error(), "secret") {
+				t.Errorf("error %q leaks the key", err)
+			}
+		})
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	var auth, query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, query = r.Header.Get("Authorization"), r.URL.RawQuery
+		w.Write([]byte(okBody.body))
+	}))
+	defer srv.Close()
+
+	if _, err := massive.New(srv.URL+"/", "secret", 0).GetMarketStatus(context.Background()); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	// The key goes in the header only, never in the URL.
+	if auth != "Bearer secret" || strings.Contains(query, "secret") {
+		t.Errorf("authorization %q, query %q", auth, query)
+	}
+}
+
+func TestNetError(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	// Too short a deadline for the first backoff, so it isn't waited out.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := massive.New(url, "secret", 0).GetMarketStatus(ctx)
+	var ne *massive.NetError
+	if !errors.As(err, &ne) {
+		t.Fatalf("got %v, want a *massive.NetError", err)
+	}
+	if massive.StatusOf(err) != 0 || strings.Contains(err.Error(), "secret") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestCancelled(t *testing.T) {
+	srv, hits := serve(t, okBody)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := massive.New(srv.URL, "", 0).GetMarketStatus(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want context.Canceled", err)
+	}
+	if got := atomic.LoadInt32(hits); got != 0 {
+		t.Errorf("%d requests after the caller gave up", got)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	t.Run("burst then spaced", func(t *testing.T) {
+		srv, _ := serve(t, okBody)
+		// 600 a minute: 10 banked, then one every 100ms.
+		c := massive.New(srv.URL, "", 600)
+		start := time.Now()
+		for i := 0; i < 12; i++ {
+			if _, err := c.GetMarketStatus(context.Background()); err != nil {
+				t.Fatalf("get %d: %v", i, err)
+			}
+		}
+		if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+			t.Errorf("12 requests took %s, want at least 150ms", elapsed)
+		}
+	})
+
+	t.Run("waiting ends with the context", func(t *testing.T) {
+		srv, hits := serve(t, okBody)
+		c := massive.New(srv.URL, "", 60)
+		if _, err := c.GetMarketStatus(context.Background()); err != nil {
+			t.Fatalf("get: %v", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := c.GetMarketStatus(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("got %v, want context.DeadlineExceeded", err)
+		}
+		if got := atomic.LoadInt32(hits); got != 1 {
+			t.Errorf("%d requests, want 1", got)
+		}
+	})
+}
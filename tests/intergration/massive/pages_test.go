@@ -0,0 +1,215 @@
+package massive
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dnhan1707/trader/internal/massive"
+)
+
+// pager serves any list endpoint as three pages of two results, tickers
+// T0 to T5. next_url comes without the apiKey, as from Massive, and points
+// at nextHost when set. The key must only ever come in the Authorization
+// header.
+type pager struct {
+	srv      *httptest.Server
+	nextHost string
+
+	mu       sync.Mutex
+	requests []string // the cursor query parameter of every request
+	badAuth  int      // requests without the header or with the key in the URL
+}
+
+func newPager(t *testing.T, nextHost string) *pager {
+	t.Helper()
+	p := &pager{nextHost: nextHost}
+	p.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		p.mu.Lock()
+		p.requests = append(p.requests, q.Get("cursor"))
+		if r.Header.Get("Authorization") != "Bearer secret" || q.Has("apiKey") {
+			p.badAuth++
+		}
+		p.mu.Unlock()
+
+		page, _ := strconv.Atoi(q.Get("cursor"))
+		resp := map[string]interface{}{"status": "OK", "request_id": fmt.Sprintf("req%d", page)}
+		var results []map[string]string
+		for i := 0; i < 2; i++ {
+			results = append(results, map[string]string{"ticker": fmt.Sprintf("T%d", page*2+i)})
+		}
+		resp["results"] = results
+		if page < 2 {
+			host := p.srv.URL
+			if p.nextHost != "" {
+				host = p.nextHost
+			}
+			resp["next_url"] = fmt.Sprintf("%s%s?cursor=%d&limit=2", host, r.URL.Path, page+1)
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+func (p *pager) client() *massive.Client { return massive.New(p.srv.URL, "secret", 0) }
+
+func TestIter(t *testing.T) {
+	tests := []struct {
+		name     string
+		nextHost string
+		max      int
+		tickers  string
+		err      error
+	}{
+		{name: "every page", max: 10, tickers: "T0,T1,T2,T3,T4,T5"},
+		{name: "exactly max", max: 6, tickers: "T0,T1,T2,T3,T4,T5"},
+		{name: "past max", max: 3, tickers: "T0,T1,T2", err: massive.ErrTooManyItems},
+		{name: "next_url on another host is not followed", nextHost: "https://example.com", max: 10, tickers: "T0,T1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPager(t, tt.nextHost)
+			it := p.client().IterShortInterest(massive.ListParams{Ticker: "AAPL"}, tt.max)
+			var tickers []string
+			for it.Next(context.Background()) {
+				tickers = append(tickers, it.Item().Ticker)
+			}
+			if it.Err() != tt.err {
+				t.Errorf("err = %v, want %v", it.Err(), tt.err)
+			}
+			if got := strings.Join(tickers, ","); got != tt.tickers {
+				t.Errorf("got %s, want %s", got, tt.tickers)
+			}
+			if p.badAuth > 0 {
+				t.Errorf("%d requests not authorized by header alone", p.badAuth)
+			}
+		})
+	}
+}
+
+func TestIterError(t *testing.T) {
+	srv, _ := serve(t, response{status: 404, body: `{"error": "not found"}`})
+	it := massive.New(srv.URL, "", 0).IterNews(massive.ListParams{}, 10)
+	if it.Next(context.Background()) {
+		t.Fatal("Next = true on an error")
+	}
+	if massive.StatusOf(it.Err()) != 404 {
+		t.Errorf("err = %v, want a 404", it.Err())
+	}
+}
+
+func tickersOf(t *testing.T, resp map[string]interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(resp["results"])
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var results []struct {
+		Ticker string `json:"ticker"`
+	}
+	if err := json.Unmarshal(b, &results); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	var tickers []string
+	for _, r := range results {
+		tickers = append(tickers, r.Ticker)
+	}
+	return strings.Join(tickers, ",")
+}
+
+func TestCursor(t *testing.T) {
+	p := newPager(t, "")
+	c := p.client()
+	ctx := context.Background()
+
+	first, err := c.GetDividends(ctx, map[string]string{"ticker": "AAPL"}, massive.Paging{})
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	if _, ok := first["next_url"]; ok {
+		t.Error("next_url passed through")
+	}
+	cursor, _ := first["next_cursor"].(string)
+	if cursor == "" {
+		t.Fatalf("no next_cursor in %v", first)
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		t.Fatalf("cursor %q isn't base64url: %v", cursor, err)
+	}
+	if strings.Contains(string(raw), "secret") || strings.Contains(string(raw), "127.0.0.1") {
+		t.Errorf("cursor holds %q", raw)
+	}
+
+	tests := []struct {
+		name    string
+		page    massive.Paging
+		tickers string
+		next    bool
+	}{
+		{name: "first page", page: massive.Paging{}, tickers: "T0,T1", next: true},
+		{name: "from the cursor", page: massive.Paging{Cursor: cursor}, tickers: "T2,T3", next: true},
+		{name: "all", page: massive.Paging{All: true}, tickers: "T0,T1,T2,T3,T4,T5"},
+		{name: "all from the cursor", page: massive.Paging{Cursor: cursor, All: true}, tickers: "T2,T3,T4,T5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := c.GetDividends(ctx, map[string]string{"ticker": "AAPL"}, tt.page)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			if got := tickersOf(t, resp); got != tt.tickers {
+				t.Errorf("got %s, want %s", got, tt.tickers)
+			}
+			if _, ok := resp["next_cursor"]; ok != tt.next {
+				t.Errorf("next_cursor present: %v, want %v", ok, tt.next)
+			}
+		})
+	}
+	if p.badAuth > 0 {
+		t.Errorf("%d requests not authorized by header alone", p.badAuth)
+	}
+}
+
+func TestInvalidCursor(t *testing.T) {
+	p := newPager(t, "")
+	c := p.client()
+	ctx := context.Background()
+
+	first, err := c.GetDividends(ctx, nil, massive.Paging{})
+	if err != nil {
+		t.Fatalf("first page: %v", err)
+	}
+	dividends := first["next_cursor"].(string)
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "!!"},
+		{"another endpoint's", dividends},
+		{"with a host", base64.RawURLEncoding.EncodeToString([]byte("https://example.com/v2/reference/news?cursor=1"))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := len(p.requests)
+			_, err := c.GetNews(ctx, nil, massive.Paging{Cursor: tt.cursor})
+			if !errors.Is(err, massive.ErrInvalidCursor) {
+				t.Errorf("got %v, want ErrInvalidCursor", err)
+			}
+			if len(p.requests) != before {
+				t.Error("an invalid cursor was fetched")
+			}
+		})
+	}
+}
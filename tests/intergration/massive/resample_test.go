@@ -0,0 +1,199 @@
+package massive
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/dnhan1707/trader/internal/massive"
+)
+
+func marketZone(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("load zone: %v", err)
+	}
+	return loc
+}
+
+func TestParsePeriod(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    massive.Period
+		wantErr bool
+	}{
+		{in: "week", want: massive.Period{Multiplier: 1, Timespan: "week"}},
+		{in: "15minute", want: massive.Period{Multiplier: 15, Timespan: "minute"}},
+		{in: "2Day", want: massive.Period{Multiplier: 2, Timespan: "day"}},
+		{in: "1000hour", want: massive.Period{Multiplier: 1000, Timespan: "hour"}},
+		{in: "0day", wantErr: true},
+		{in: "1001day", wantErr: true},
+		{in: "5second", wantErr: true},
+		{in: "fortnight", wantErr: true},
+		{in: "15", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := massive.ParsePeriod(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPeriodString(t *testing.T) {
+	if s := (massive.Period{Multiplier: 1, Timespan: "week"}).String(); s != "week" {
+		t.Errorf("got %q, want week", s)
+	}
+	if s := (massive.Period{Multiplier: 15, Timespan: "minute"}).String(); s != "15minute" {
+		t.Errorf("got %q, want 15minute", s)
+	}
+}
+
+func TestCoarserThan(t *testing.T) {
+	tests := []struct {
+		period     string
+		multiplier int
+		timespan   string
+		want       bool
+	}{
+		{"15minute", 5, "minute", true},
+		{"15minute", 10, "minute", false}, // 10-minute bars straddle 15-minute buckets
+		{"15minute", 15, "minute", false}, // not coarser
+		{"hour", 30, "minute", true},
+		{"hour", 7, "minute", false},
+		{"2day", 1, "day", true},
+		{"week", 1, "day", true},
+		{"week", 2, "day", false},
+		{"day", 1, "week", false},
+		{"month", 1, "day", true},
+		{"month", 1, "hour", true},
+		{"month", 7, "hour", false}, // doesn't tile a day
+		{"month", 2, "day", false},
+		{"month", 1, "week", false},
+		{"quarter", 1, "month", true},
+		{"quarter", 2, "month", false},
+		{"year", 1, "quarter", true},
+		{"year", 5, "month", false},
+		{"month", 1, "quarter", false},
+		{"day", 1, "month", false},
+		{"day", 0, "minute", false},
+		{"day", 1, "fortnight", false},
+	}
+	for _, tt := range tests {
+		p, err := massive.ParsePeriod(tt.period)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tt.period, err)
+		}
+		if got := p.CoarserThan(tt.multiplier, tt.timespan); got != tt.want {
+			t.Errorf("%s.CoarserThan(%d, %s) = %v, want %v", tt.period, tt.multiplier, tt.timespan, got, tt.want)
+		}
+	}
+}
+
+func TestResampleBuckets(t *testing.T) {
+	ny := marketZone(t)
+	at := func(y int, m time.Month, d, h, min int) int64 {
+		return time.Date(y, m, d, h, min, 0, 0, ny).UnixMilli()
+	}
+
+	tests := []struct {
+		name   string
+		period string
+		times  []int64
+		starts []int64
+	}{
+		{
+			name:   "15 minutes",
+			period: "15minute",
+			times:  []int64{at(2024, 6, 3, 9, 30), at(2024, 6, 3, 9, 35), at(2024, 6, 3, 9, 40), at(2024, 6, 3, 9, 45)},
+			starts: []int64{at(2024, 6, 3, 9, 30), at(2024, 6, 3, 9, 45)},
+		},
+		{
+			name:   "intraday buckets stop at midnight",
+			period: "2hour",
+			times:  []int64{at(2024, 6, 3, 23, 30), at(2024, 6, 4, 0, 30)},
+			starts: []int64{at(2024, 6, 3, 22, 0), at(2024, 6, 4, 0, 0)},
+		},
+		{
+			name:   "weeks start on monday",
+			period: "week",
+			times:  []int64{at(2024, 6, 5, 0, 0), at(2024, 6, 7, 0, 0), at(2024, 6, 10, 0, 0)},
+			starts: []int64{at(2024, 6, 3, 0, 0), at(2024, 6, 10, 0, 0)},
+		},
+		{
+			name:   "months",
+			period: "month",
+			times:  []int64{at(2024, 6, 28, 0, 0), at(2024, 7, 1, 0, 0), at(2024, 7, 31, 0, 0)},
+			starts: []int64{at(2024, 6, 1, 0, 0), at(2024, 7, 1, 0, 0)},
+		},
+		{
+			name:   "quarters",
+			period: "quarter",
+			times:  []int64{at(2024, 2, 1, 0, 0), at(2024, 3, 28, 0, 0), at(2024, 4, 1, 0, 0)},
+			starts: []int64{at(2024, 1, 1, 0, 0), at(2024, 4, 1, 0, 0)},
+		},
+		{
+			name:   "years",
+			period: "year",
+			times:  []int64{at(2023, 12, 29, 0, 0), at(2024, 1, 2, 0, 0)},
+			starts: []int64{at(2023, 1, 1, 0, 0), at(2024, 1, 1, 0, 0)},
+		},
+		{
+			name:   "no bars",
+			period: "day",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := massive.ParsePeriod(tt.period)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			var bars []massive.Agg
+			for _, ts := range tt.times {
+				bars = append(bars, massive.Agg{Open: 1, High: 1, Low: 1, Close: 1, Volume: 1, Timestamp: ts})
+			}
+			out := massive.Resample(bars, p, ny)
+			if len(out) != len(tt.starts) {
+				t.Fatalf("got %d bars, want %d", len(out), len(tt.starts))
+			}
+			for i, a := range out {
+				if a.Timestamp != tt.starts[i] {
+					t.Errorf("bar %d starts at %s, want %s", i, time.UnixMilli(a.Timestamp).In(ny), time.UnixMilli(tt.starts[i]).In(ny))
+				}
+			}
+		})
+	}
+}
+
+func TestResampleMerge(t *testing.T) {
+	ny := marketZone(t)
+	day := func(d int) int64 { return time.Date(2024, 6, d, 0, 0, 0, 0, ny).UnixMilli() }
+	bars := []massive.Agg{
+		{Open: 10, High: 12, Low: 9, Close: 11, Volume: 100, VWAP: 10.5, Transactions: 5, Timestamp: day(3)},
+		{Open: 11, High: 15, Low: 10, Close: 14, Volume: 300, Transactions: 7, Timestamp: day(4)}, // no vwap: the close stands in
+		{Open: 14, High: 14, Low: 8, Close: 9, Volume: 0, Timestamp: day(5)},
+	}
+
+	out := massive.Resample(bars, massive.Period{Multiplier: 1, Timespan: "week"}, ny)
+	if len(out) != 1 {
+		t.Fatalf("got %d bars, want 1", len(out))
+	}
+	want := massive.Agg{Open: 10, High: 15, Low: 8, Close: 9, Volume: 400, VWAP: (10.5*100 + 14*300) / 400, Transactions: 12, Timestamp: day(3)}
+	if out[0] != want {
+		t.Errorf("got %+v, want %+v", out[0], want)
+	}
+}
@@ -0,0 +1,345 @@
+package portfolio
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/dnhan1707/trader/internal/portfolio"
+)
+
+var day0 = time.Date(2024, 3, 1, 15, 0, 0, 0, time.UTC)
+
+func at(days int) time.Time { return day0.AddDate(0, 0, days) }
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func buy(days int, qty, price, fees float64) portfolio.Trade {
+	return portfolio.Trade{Ticker: "AAPL", Side: "buy", Qty: qty, Price: price, Fees: fees, Time: at(days)}
+}
+
+func sell(days int, qty, price, fees float64) portfolio.Trade {
+	return portfolio.Trade{Ticker: "AAPL", Side: "sell", Qty: qty, Price: price, Fees: fees, Time: at(days)}
+}
+
+func TestParseMethod(t *testing.T) {
+	tests := []struct {
+		in   string
+		want portfolio.Method
+		ok   bool
+	}{
+		{"", portfolio.FIFO, true},
+		{"fifo", portfolio.FIFO, true},
+		{"lifo", portfolio.LIFO, true},
+		{"avg", portfolio.Average, true},
+		{"hifo", "", false},
+	}
+	for _, tt := range tests {
+		if got, ok := portfolio.ParseMethod(tt.in); got != tt.want || ok != tt.ok {
+			t.Errorf("ParseMethod(%q) = %q, %v", tt.in, got, ok)
+		}
+	}
+}
+
+func TestBook(t *testing.T) {
+	twoBuysOneSell := []portfolio.Trade{buy(0, 10, 10, 0), buy(1, 10, 20, 0), sell(2, 15, 30, 5)}
+
+	tests := []struct {
+		name     string
+		trades   []portfolio.Trade
+		method   portfolio.Method
+		qty      float64
+		cost     float64
+		avg      float64
+		realized float64
+		lots     []float64 // lot prices, oldest first
+		wantErr  bool
+	}{
+		{name: "fifo sells the oldest lot first", trades: twoBuysOneSell, method: portfolio.FIFO, qty: 5, cost: 100, avg: 20, realized: 245, lots: []float64{20}},
+		{name: "lifo sells the newest lot first", trades: twoBuysOneSell, method: portfolio.LIFO, qty: 5, cost: 50, avg: 10, realized: 195, lots: []float64{10}},
+		{name: "avg keeps one lot", trades: twoBuysOneSell, method: portfolio.Average, qty: 5, cost: 75, avg: 15, realized: 220, lots: []float64{15}},
+		{name: "buy fees go into the cost", trades: []portfolio.Trade{buy(0, 10, 10, 10)}, method: portfolio.FIFO, qty: 10, cost: 110, avg: 11, lots: []float64{11}},
+		{name: "closed position keeps its realized pnl", trades: []portfolio.Trade{buy(0, 3, 1, 0), sell(1, 3, 2, 0)}, method: portfolio.FIFO, realized: 3, lots: []float64{}},
+		{name: "selling more than held", trades: []portfolio.Trade{buy(0, 1, 1, 0), sell(1, 2, 1, 0)}, method: portfolio.FIFO, wantErr: true},
+		{name: "unknown side", trades: []portfolio.Trade{{Ticker: "AAPL", Side: "short", Qty: 1, Price: 1, Time: at(0)}}, method: portfolio.FIFO, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			positions, err := portfolio.Book(tt.trades, tt.method)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("book: %v", err)
+			}
+			p := positions["AAPL"]
+			if p == nil {
+				t.Fatal("no AAPL position")
+			}
+			if !near(p.Qty, tt.qty) || !near(p.CostBasis, tt.cost) || !near(p.AvgCost, tt.avg) || !near(p.RealizedPnL, tt.realized) {
+				t.Fatalf("got qty %v cost %v avg %v realized %v", p.Qty, p.CostBasis, p.AvgCost, p.RealizedPnL)
+			}
+			if len(p.Lots) != len(tt.lots) {
+				t.Fatalf("expected %d lots, got %+v", len(tt.lots), p.Lots)
+			}
+			for i, l := range p.Lots {
+				if !near(l.Price, tt.lots[i]) {
+					t.Errorf("lot %d price %v, want %v", i, l.Price, tt.lots[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMarkAt(t *testing.T) {
+	positions, err := portfolio.Book([]portfolio.Trade{buy(0, 10, 10, 0)}, portfolio.FIFO)
+	if err != nil {
+		t.Fatalf("book: %v", err)
+	}
+	p := positions["AAPL"]
+	if p.Value() != 100 {
+		t.Fatalf("value without a mark should be the cost basis, got %v", p.Value())
+	}
+	p.MarkAt(12)
+	if *p.MarketValue != 120 || *p.UnrealizedPnL != 20 || p.Value() != 120 {
+		t.Fatalf("unexpected mark: value %v pnl %v", *p.MarketValue, *p.UnrealizedPnL)
+	}
+}
+
+func TestSortTrades(t *testing.T) {
+	trades := []portfolio.Trade{sell(1, 1, 1, 0), buy(2, 1, 1, 0), buy(1, 1, 1, 0), buy(0, 1, 1, 0)}
+	portfolio.SortTrades(trades)
+
+	want := []struct {
+		day  int
+		side string
+	}{{0, "buy"}, {1, "buy"}, {1, "sell"}, {2, "buy"}}
+	for i, w := range want {
+		if !trades[i].Time.Equal(at(w.day)) || trades[i].Side != w.side {
+			t.Fatalf("trade %d: got %s on %s", i, trades[i].Side, trades[i].Time)
+		}
+	}
+}
+
+func TestHeldBefore(t *testing.T) {
+	trades := []portfolio.Trade{buy(0, 10, 1, 0), sell(2, 4, 1, 0), buy(4, 1, 1, 0)}
+
+	tests := []struct {
+		at   time.Time
+		want float64
+	}{
+		{at(0), 0}, // the trade at t itself doesn't count
+		{at(1), 10},
+		{at(3), 6},
+		{at(5), 7},
+	}
+	for _, tt := range tests {
+		if got := portfolio.HeldBefore(trades, "AAPL", tt.at); got != tt.want {
+			t.Errorf("HeldBefore(%s) = %v, want %v", tt.at.Format("2006-01-02"), got, tt.want)
+		}
+	}
+	if got := portfolio.HeldBefore(trades, "MSFT", at(5)); got != 0 {
+		t.Errorf("expected nothing held of another ticker, got %v", got)
+	}
+}
+
+func TestCreditDividends(t *testing.T) {
+	trades := []portfolio.Trade{buy(0, 10, 1, 0)} // 2024-03-01
+	divs := []massive.Dividend{
+		{Ticker: "AAPL", CashAmount: 0.5, ExDividendDate: "2024-03-05", PayDate: "2024-03-20"},
+		{Ticker: "AAPL", CashAmount: 0.25, ExDividendDate: "2024-03-04"},                       // no pay date: paid on the ex date
+		{Ticker: "AAPL", CashAmount: 0.5, ExDividendDate: "2024-02-01", PayDate: "2024-02-15"}, // before the buy
+		{Ticker: "AAPL", CashAmount: 0.5, ExDividendDate: "2024-04-01", PayDate: "2024-04-15"}, // after today
+		{Ticker: "AAPL", CashAmount: 0, ExDividendDate: "2024-03-06"},
+	}
+
+	credits := portfolio.CreditDividends(trades, divs, time.UTC, "2024-03-10")
+	want := []portfolio.DividendCredit{
+		{Ticker: "AAPL", ExDate: "2024-03-04", PayDate: "2024-03-04", PerShare: 0.25, Qty: 10, Amount: 2.5, Paid: true},
+		{Ticker: "AAPL", ExDate: "2024-03-05", PayDate: "2024-03-20", PerShare: 0.5, Qty: 10, Amount: 5, Paid: false},
+	}
+	if len(credits) != len(want) {
+		t.Fatalf("expected %d credits, got %+v", len(want), credits)
+	}
+	for i := range want {
+		if credits[i] != want[i] {
+			t.Errorf("credit %d = %+v, want %+v", i, credits[i], want[i])
+		}
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name     string
+		points   []portfolio.EquityPoint
+		twr      float64
+		drawdown float64
+		trough   string
+		sharpe   bool
+	}{
+		{name: "empty"},
+		{
+			name:   "one day has no sharpe",
+			points: []portfolio.EquityPoint{{Date: "2024-03-01", Value: 100, Index: 1}},
+		},
+		{
+			name: "rise, fall and recovery",
+			points: []portfolio.EquityPoint{
+				{Date: "2024-03-01", Value: 100, Index: 1},
+				{Date: "2024-03-04", Value: 120, Index: 1.2, Return: 0.2},
+				{Date: "2024-03-05", Value: 90, Index: 0.9, Return: -0.25},
+				{Date: "2024-03-06", Value: 110, Index: 1.1, Return: 0.1 / 0.9},
+			},
+			twr: 0.1, drawdown: 0.25, trough: "2024-03-05", sharpe: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := portfolio.Summarize(tt.points, 0)
+			if s.Days != len(tt.points) || !near(s.TWR, tt.twr) || !near(s.MaxDrawdown, tt.drawdown) || s.DrawdownTrough != tt.trough {
+				t.Fatalf("unexpected stats %+v", s)
+			}
+			if (s.Sharpe != nil) != tt.sharpe {
+				t.Fatalf("sharpe = %v, want set %v", s.Sharpe, tt.sharpe)
+			}
+		})
+	}
+}
+
+func TestExposure(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	positions := []*portfolio.Position{
+		{Ticker: "MSFT", Qty: 1, Sector: "Tech", MarketValue: value(300)},
+		{Ticker: "AAPL", Qty: 1, Sector: "Tech", MarketValue: value(300)},
+		{Ticker: "XOM", Qty: 1, Sector: "Energy", CostBasis: 200}, // no mark: valued at cost
+		{Ticker: "ZZZ", Qty: 1, MarketValue: value(200)},
+		{Ticker: "OLD", Qty: 0, Sector: "Tech", MarketValue: value(1000)},
+	}
+
+	got := portfolio.Exposure(positions)
+	want := []portfolio.SectorExposure{
+		{Sector: "Tech", Value: 600, Weight: 0.6, Tickers: []string{"AAPL", "MSFT"}},
+		{Sector: "Energy", Value: 200, Weight: 0.2, Tickers: []string{"XOM"}},
+		{Sector: "Unknown", Value: 200, Weight: 0.2, Tickers: []string{"ZZZ"}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d sectors, got %+v", len(want), got)
+	}
+	if got[0].Sector != "Tech" || got[0].Value != 600 || !near(got[0].Weight, 0.6) || len(got[0].Tickers) != 2 || got[0].Tickers[0] != "AAPL" {
+		t.Fatalf("unexpected top sector %+v", got[0])
+	}
+	// Energy and Unknown tie on value; compare them by name.
+	bySector := map[string]portfolio.SectorExposure{}
+	for _, e := range got[1:] {
+		bySector[e.Sector] = e
+	}
+	for _, w := range want[1:] {
+		e := bySector[w.Sector]
+		if e.Value != w.Value || !near(e.Weight, w.Weight) || len(e.Tickers) != 1 || e.Tickers[0] != w.Tickers[0] {
+			t.Errorf("sector %s = %+v, want %+v", w.Sector, e, w)
+		}
+	}
+}
+
+func TestSince(t *testing.T) {
+	points := []portfolio.EquityPoint{
+		{Date: "2024-03-01", Index: 1, Return: 0},
+		{Date: "2024-03-04", Index: 1.25, Return: 0.25},
+		{Date: "2024-03-05", Index: 1.5, Return: 0.2},
+	}
+
+	tests := []struct {
+		from    string
+		indices []float64
+	}{
+		{from: "2024-01-01", indices: []float64{1, 1.25, 1.5}},
+		{from: "2024-03-02", indices: []float64{1, 1.2}},
+		{from: "2024-03-05", indices: []float64{1}},
+		{from: "2024-04-01", indices: nil},
+	}
+	for _, tt := range tests {
+		got := portfolio.Since(points, tt.from)
+		if len(got) != len(tt.indices) {
+			t.Fatalf("Since(%s): got %+v", tt.from, got)
+		}
+		for i, p := range got {
+			if !near(p.Index, tt.indices[i]) {
+				t.Errorf("Since(%s)[%d].Index = %v, want %v", tt.from, i, p.Index, tt.indices[i])
+			}
+		}
+		if len(got) > 0 && got[0].Return != 0 {
+			t.Errorf("Since(%s): first return should be 0, got %v", tt.from, got[0].Return)
+		}
+	}
+	if points[1].Index != 1.25 {
+		t.Fatal("Since modified its input")
+	}
+}
+
+func TestEquityCurve(t *testing.T) {
+	px := portfolio.Prices{
+		Closes: map[string]map[string]float64{"AAPL": {"2024-03-04": 11}},
+		Marks:  map[string]float64{"AAPL": 12},
+		Today:  "2024-03-05",
+		Loc:    time.UTC,
+	}
+	paperBuy := buy(0, 10, 10, 1)
+	paperBuy.Source = portfolio.SourcePaper
+	manualBuy := buy(0, 10, 10, 0)
+	manualBuy.Source = portfolio.SourceManual
+
+	type point struct {
+		date              string
+		cash, value, flow float64
+		index             float64
+	}
+	tests := []struct {
+		name    string
+		trades  []portfolio.Trade
+		credits []portfolio.DividendCredit
+		acct    *portfolio.Account
+		want    []point
+	}{
+		{
+			name:   "manual buy is money added",
+			trades: []portfolio.Trade{manualBuy},
+			want: []point{
+				{"2024-03-01", 0, 100, 100, 1},
+				{"2024-03-04", 0, 110, 0, 1.1},
+				{"2024-03-05", 0, 120, 0, 1.2},
+			},
+		},
+		{
+			name:    "paper buy is paid from cash, dividends credited on the pay date",
+			trades:  []portfolio.Trade{paperBuy},
+			credits: []portfolio.DividendCredit{{Ticker: "AAPL", PayDate: "2024-03-04", Amount: 5, Paid: true}},
+			acct:    &portfolio.Account{StartingCash: 1000, Opened: "2024-03-01"},
+			want: []point{
+				{"2024-03-01", 899, 999, 1000, 0.999},
+				{"2024-03-04", 904, 1014, 0, 1.014},
+				{"2024-03-05", 904, 1024, 0, 1.024},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := portfolio.EquityCurve(tt.trades, tt.credits, tt.acct, px)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %d points, got %+v", len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				p := got[i]
+				if p.Date != w.date || !near(p.Cash, w.cash) || !near(p.Value, w.value) || !near(p.Flow, w.flow) || !near(p.Index, w.index) {
+					t.Errorf("point %d = %+v, want %+v", i, p, w)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package pricestats
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive"
+	"github.com/dnhan1707/trader/internal/pricestats"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func ptr(v float64) *float64 { return &v }
+
+func day(d int) time.Time { return time.Date(2024, 6, d, 0, 0, 0, 0, time.UTC) }
+
+func TestParseWindows(t *testing.T) {
+	now := time.Date(2024, 6, 15, 13, 0, 0, 0, time.UTC)
+	tooMany := make([]string, pricestats.MaxWindows+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("%d%c", i/4+1, "dwmy"[i%4])
+	}
+
+	tests := []struct {
+		name    string
+		list    string
+		want    []pricestats.Window
+		wantErr bool
+	}{
+		{
+			name: "counts and ytd",
+			list: "1m, ytd,1Y",
+			want: []pricestats.Window{
+				{Name: "1m", Start: time.Date(2024, 5, 15, 0, 0, 0, 0, time.UTC)},
+				{Name: "ytd", Start: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{Name: "1y", Start: time.Date(2023, 6, 15, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name: "days, weeks, mtd and duplicates",
+			list: "5d,2w,mtd,,5D",
+			want: []pricestats.Window{
+				{Name: "5d", Start: time.Date(2024, 6, 10, 0, 0, 0, 0, time.UTC)},
+				{Name: "2w", Start: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+				{Name: "mtd", Start: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+			},
+		},
+		{
+			name: "longest allowed",
+			list: "20y",
+			want: []pricestats.Window{{Name: "20y", Start: time.Date(2004, 6, 15, 0, 0, 0, 0, time.UTC)}},
+		},
+		{name: "too long", list: "21y", wantErr: true},
+		{name: "zero", list: "0d", wantErr: true},
+		{name: "no count", list: "m", wantErr: true},
+		{name: "unknown unit", list: "3x", wantErr: true},
+		{name: "empty", list: " , ", wantErr: true},
+		{name: "too many", list: strings.Join(tooMany, ","), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := pricestats.ParseWindows(tt.list, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Name != tt.want[i].Name || !got[i].Start.Equal(tt.want[i].Start) {
+					t.Errorf("window %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEarliest(t *testing.T) {
+	windows := []pricestats.Window{{Name: "1m", Start: day(1)}, {Name: "ytd", Start: day(1).AddDate(0, -5, 0)}, {Name: "5d", Start: day(10)}}
+	if got := pricestats.Earliest(windows); !got.Equal(windows[1].Start) {
+		t.Errorf("got %s, want %s", got, windows[1].Start)
+	}
+}
+
+// fiveDays runs June 3-7, 2024: closes 10, 12, 9, 11, 10, the high of 12
+// on the 4th and the low of 8 on the 5th.
+var fiveDays = []massive.Agg{
+	{Open: 8, High: 11, Low: 9, Close: 10, Volume: 100, Timestamp: day(3).UnixMilli()},
+	{Open: 10, High: 12, Low: 10, Close: 12, Volume: 200, Timestamp: day(4).UnixMilli()},
+	{Open: 12, High: 12, Low: 8, Close: 9, Volume: 300, Timestamp: day(5).UnixMilli()},
+	{Open: 9, High: 11, Low: 9, Close: 11, Volume: 400, Timestamp: day(6).UnixMilli()},
+	{Open: 11, High: 11, Low: 10, Close: 10, Volume: 500, Timestamp: day(7).UnixMilli()},
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		name   string
+		start  time.Time
+		ok     bool
+		from   string
+		bars   int
+		high   float64
+		low    float64
+		avgVol float64
+		ret    *float64 // total return, percent
+		dd     float64
+		vol    bool // whether volatility is set
+	}{
+		{name: "whole series starts from the first open", start: day(1), ok: true, from: "2024-06-03", bars: 5, high: 12, low: 8, avgVol: 300, ret: ptr(25), dd: 25, vol: true},
+		{name: "return from the close before the window", start: day(4), ok: true, from: "2024-06-04", bars: 4, high: 12, low: 8, avgVol: 350, ret: ptr(0), dd: 25, vol: true},
+		{name: "one bar", start: day(7), ok: true, from: "2024-06-07", bars: 1, high: 11, low: 10, avgVol: 500, ret: ptr(-100.0 / 11), dd: 0},
+		{name: "empty window", start: day(8), ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, ok := pricestats.Compute(fiveDays, nil, pricestats.Window{Name: "w", Start: tt.start}, time.UTC)
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if s.Window != "w" {
+				t.Errorf("window = %q, want w", s.Window)
+			}
+			if !ok {
+				if s.Bars != 0 || s.TotalReturn != nil {
+					t.Errorf("empty window has stats %+v", s)
+				}
+				return
+			}
+			if s.From != tt.from || s.To != "2024-06-07" || s.Bars != tt.bars || s.Close != 10 {
+				t.Errorf("from %s to %s, %d bars, close %v", s.From, s.To, s.Bars, s.Close)
+			}
+			if s.High != tt.high || s.Low != tt.low || !near(s.AvgVolume, tt.avgVol) {
+				t.Errorf("high %v, low %v, avg volume %v", s.High, s.Low, s.AvgVolume)
+			}
+			if s.TotalReturn == nil || !near(*s.TotalReturn, *tt.ret) {
+				t.Errorf("total return = %v, want %v", s.TotalReturn, *tt.ret)
+			}
+			if !near(s.MaxDrawdown, tt.dd) {
+				t.Errorf("max drawdown = %v, want %v", s.MaxDrawdown, tt.dd)
+			}
+			if (s.Volatility != nil) != tt.vol {
+				t.Errorf("volatility = %v, want set: %v", s.Volatility, tt.vol)
+			}
+			if s.Beta != nil || s.BenchmarkReturn != nil {
+				t.Errorf("beta %v and benchmark %v without a benchmark", s.Beta, s.BenchmarkReturn)
+			}
+		})
+	}
+}
+
+func TestComputeRange(t *testing.T) {
+	s, ok := pricestats.Compute(fiveDays, nil, pricestats.Window{Name: "1w", Start: day(4)}, time.UTC)
+	if !ok {
+		t.Fatal("no bars")
+	}
+	tests := []struct {
+		name string
+		got  *float64
+		want float64
+	}{
+		{"percent from high", s.PctFromHigh, (10.0/12 - 1) * 100},
+		{"percent from low", s.PctFromLow, 25},
+		{"range position", s.RangePosition, 50},
+	}
+	for _, tt := range tests {
+		if tt.got == nil || !near(*tt.got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if s.HighDate != "2024-06-04" || s.LowDate != "2024-06-05" {
+		t.Errorf("high on %s, low on %s", s.HighDate, s.LowDate)
+	}
+	if s.DrawdownPeak != "2024-06-04" || s.DrawdownTrough != "2024-06-05" {
+		t.Errorf("drawdown from %s to %s", s.DrawdownPeak, s.DrawdownTrough)
+	}
+
+	logs := []float64{math.Log(9.0 / 12), math.Log(11.0 / 9), math.Log(10.0 / 11)}
+	var m, v float64
+	for _, l := range logs {
+		m += l / 3
+	}
+	for _, l := range logs {
+		v += (l - m) * (l - m) / 2
+	}
+	if want := math.Sqrt(v) * math.Sqrt(252) * 100; s.Volatility == nil || !near(*s.Volatility, want) {
+		t.Errorf("volatility = %v, want %v", s.Volatility, want)
+	}
+}
+
+func TestComputeBeta(t *testing.T) {
+	// The benchmark moves half as much as the stock every day.
+	bench := make([]massive.Agg, len(fiveDays))
+	bench[0] = massive.Agg{Open: 100, Close: 100, Timestamp: fiveDays[0].Timestamp}
+	for i := 1; i < len(fiveDays); i++ {
+		r := fiveDays[i].Close/fiveDays[i-1].Close - 1
+		c := bench[i-1].Close * (1 + r/2)
+		bench[i] = massive.Agg{Open: c, Close: c, Timestamp: fiveDays[i].Timestamp}
+	}
+
+	s, ok := pricestats.Compute(fiveDays, bench, pricestats.Window{Name: "1w", Start: day(4)}, time.UTC)
+	if !ok {
+		t.Fatal("no bars")
+	}
+	if s.Beta == nil || !near(*s.Beta, 2) {
+		t.Errorf("beta = %v, want 2", s.Beta)
+	}
+	if want := (bench[4].Close/bench[0].Close - 1) * 100; s.BenchmarkReturn == nil || !near(*s.BenchmarkReturn, want) {
+		t.Errorf("benchmark return = %v, want %v", s.BenchmarkReturn, want)
+	}
+
+	// Too few matching days for a regression.
+	s, _ = pricestats.Compute(fiveDays, bench[:2], pricestats.Window{Name: "1w", Start: day(4)}, time.UTC)
+	if s.Beta != nil {
+		t.Errorf("beta = %v from one matching day", *s.Beta)
+	}
+}
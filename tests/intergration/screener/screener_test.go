@@ -0,0 +1,112 @@
+package screener
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dnhan1707/trader/internal/screener"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name  string
+		expr  string
+		where string
+		args  []interface{}
+	}{
+		{name: "empty", expr: "  ", where: "TRUE"},
+		{name: "one comparison", expr: "rsi_14 < 30", where: "rsi_14 < $1", args: []interface{}{30.0}},
+		{name: "suffix", expr: "market_cap > 10B", where: "market_cap > $1", args: []interface{}{1e10}},
+		{name: "lower-case suffix", expr: "Price > 5k", where: "price > $1", args: []interface{}{5000.0}},
+		{name: "exponent and sign", expr: "volume > 1.5e6 and change_pct > -2.5", where: "(volume > $1 AND change_pct > $2)", args: []interface{}{1.5e6, -2.5}},
+		{name: "symbols for and", expr: "pe<=15 && pb >= 1.5", where: "(pe <= $1 AND pb >= $2)", args: []interface{}{15.0, 1.5}},
+		{name: "and binds tighter than or", expr: "price > 1 or pe < 2 and pb < 3", where: "(price > $1 OR (pe < $2 AND pb < $3))", args: []interface{}{1.0, 2.0, 3.0}},
+		{name: "parentheses", expr: "(price > 1 || pe < 2) and pb < 3", where: "((price > $1 OR pe < $2) AND pb < $3)", args: []interface{}{1.0, 2.0, 3.0}},
+		{name: "not on text", expr: "not type = 'ETF'", where: "(NOT (lower(type) = $1)) IS TRUE", args: []interface{}{"etf"}},
+		{name: "bang", expr: "!(pe < 10)", where: "(NOT (pe < $1)) IS TRUE", args: []interface{}{10.0}},
+		{name: "not equal spellings", expr: `type <> "CS" and exchange != 'XNAS'`, where: "(lower(type) <> $1 AND lower(exchange) <> $2)", args: []interface{}{"cs", "xnas"}},
+		{name: "field against field", expr: "sma_50 > sma_200 and price == prev_close", where: "(sma_50 > sma_200 AND price = prev_close)"},
+		{name: "constant first", expr: "30 > rsi_14", where: "$1 > rsi_14", args: []interface{}{30.0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := screener.ParseFilter(tt.expr)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if f.Where() != tt.where {
+				t.Errorf("where = %q, want %q", f.Where(), tt.where)
+			}
+			if len(f.Args()) != 0 || len(tt.args) != 0 {
+				if !reflect.DeepEqual(f.Args(), tt.args) {
+					t.Errorf("args = %#v, want %#v", f.Args(), tt.args)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		expr    string
+		wantErr string
+	}{
+		{"unknown field", "foo > 1", "unknown field"},
+		{"two constants", "1 < 2", "two constants"},
+		{"number against text", "price > 'x'", "cannot compare"},
+		{"ordering text", "type < 'x'", "only support = and !="},
+		{"dangling and", "price > 1 and", "unexpected end"},
+		{"unclosed paren", "(price > 1", "expected )"},
+		{"stray paren", "price > 1)", "unexpected"},
+		{"missing op", "price 1", "expected a comparison"},
+		{"unterminated string", "type = 'abc", "unterminated string"},
+		{"single ampersand", "price > 1 & pe < 2", "unexpected"},
+		{"bad number", "price > 1.2.3", "invalid number"},
+		{"bad character", "price > 1 # x", "unexpected"},
+		{"too many comparisons", strings.Repeat("price > 1 and ", screener.MaxComparisons) + "price > 1", "comparisons"},
+		{"too long", "price > " + strings.Repeat("1", screener.MaxFilterLength), "longer than"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := screener.ParseFilter(tt.expr)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("got %v, want an error about %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: "ticker ASC"},
+		{in: "-market_cap", want: "market_cap DESC NULLS LAST, ticker ASC"},
+		{in: "+pe, -PE, ticker", want: "pe ASC NULLS LAST, ticker ASC NULLS LAST"},
+		{in: "-ticker", want: "ticker DESC NULLS LAST"},
+		{in: "rsi_14,,return_1m", want: "rsi_14 ASC NULLS LAST, return_1m ASC NULLS LAST, ticker ASC"},
+		{in: "foo", wantErr: true},
+		{in: "-", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := screener.ParseSort(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
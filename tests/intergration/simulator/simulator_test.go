@@ -0,0 +1,95 @@
+package simulator
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/config"
+	"github.com/dnhan1707/trader/internal/feed"
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/simulator"
+	"github.com/dnhan1707/trader/internal/ws"
+)
+
+func TestParseModel(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "gbm", want: simulator.ModelGBM},
+		{in: "randomwalk", want: simulator.ModelRandomWalk},
+		{in: "", wantErr: true},
+		{in: "GBM", wantErr: true},
+		{in: "random-walk", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := simulator.ParseModel(tt.in)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("ParseModel(%q) = %q, %v", tt.in, got, err)
+			}
+
+			// The feed refuses to start on a bad model.
+			_, err = feed.New(&config.Config{FeedSource: feed.SourceSimulator, SimModel: tt.in})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("feed.New with SIM_MODEL=%q: %v", tt.in, err)
+			}
+		})
+	}
+}
+
+// trades runs a simulator on one stock until it has made n trades.
+func trades(t *testing.T, cfg simulator.Config, n int) []stream.Trade {
+	t.Helper()
+
+	cfg.TickInterval = 5 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Queued before Run starts, so it is in place before the first tick.
+	stockSubs := make(chan ws.SubRequest, 1)
+	stockSubs <- ws.SubRequest{Action: ws.ActionSubscribe, Ticker: "AAPL"}
+	out := make(chan []stream.Event)
+	go simulator.New(cfg).Run(ctx, out, stockSubs, make(chan ws.SubRequest))
+
+	var got []stream.Trade
+	for len(got) < n {
+		select {
+		case events := <-out:
+			for _, e := range events {
+				if e.Trade != nil {
+					// Only the clock differs between runs.
+					tr := *e.Trade
+					tr.Timestamp = 0
+					got = append(got, tr)
+				}
+			}
+		case <-ctx.Done():
+			t.Fatalf("got %d trades, want %d", len(got), n)
+		}
+	}
+	return got[:n]
+}
+
+func TestSeededRunsMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  simulator.Config
+		equal bool
+	}{
+		{name: "same seed", a: simulator.Config{Seed: 42, Volatility: 0.3}, b: simulator.Config{Seed: 42, Volatility: 0.3}, equal: true},
+		{name: "same seed, random walk", a: simulator.Config{Model: simulator.ModelRandomWalk, Seed: 7, Drift: 0.05, Volatility: 0.3}, b: simulator.Config{Model: simulator.ModelRandomWalk, Seed: 7, Drift: 0.05, Volatility: 0.3}, equal: true},
+		{name: "other seed", a: simulator.Config{Seed: 42, Volatility: 0.3}, b: simulator.Config{Seed: 43, Volatility: 0.3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := trades(t, tt.a, 20), trades(t, tt.b, 20)
+			if equal := reflect.DeepEqual(a, b); equal != tt.equal {
+				t.Errorf("runs equal = %v, want %v\n%v\n%v", equal, tt.equal, a, b)
+			}
+		})
+	}
+}
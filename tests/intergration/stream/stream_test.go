@@ -0,0 +1,169 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/dnhan1707/trader/internal/massive/stream"
+)
+
+func TestParseTypedEvents(t *testing.T) {
+	frame := []byte(`[
+		{"ev":"T","sym":"AAPL","x":4,"i":"123","z":3,"p":189.5,"s":100,"c":[12],"t":1700000000000,"q":42},
+		{"ev":"AM","sym":"AAPL","v":1200,"av":50000,"op":188,"vw":189.4,"o":189,"c":189.6,"h":189.9,"l":188.8,"a":189.1,"z":80,"s":1700000000000,"e":1700000060000},
+		{"ev":"V","val":4500.25,"T":"I:SPX","t":1700000001000},
+		{"ev":"A","sym":"I:SPX","op":4490,"o":4500,"c":4500.3,"h":4500.5,"l":4499.9,"s":1700000001000,"e":1700000002000},
+		{"ev":"status","status":"auth_success","message":"authenticated"}
+	]`)
+
+	events, err := stream.Parse(frame)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(events) != 5 {
+		t.Fatalf("expected 5 events, got %d", len(events))
+	}
+
+	trade := events[0]
+	if trade.Trade == nil || trade.Symbol != "AAPL" || trade.Trade.Price != 189.5 || trade.Trade.Size != 100 || trade.Trade.Timestamp != 1700000000000 {
+		t.Fatalf("unexpected trade: %+v", trade.Trade)
+	}
+
+	bar := events[1]
+	if bar.Aggregate == nil || bar.Type != stream.TypeMinuteAgg || bar.Aggregate.Close != 189.6 || bar.Aggregate.AccumulatedVolume != 50000 {
+		t.Fatalf("unexpected minute bar: %+v", bar.Aggregate)
+	}
+
+	// "T" (ticker) and "t" (timestamp) must not be mixed up.
+	value := events[2]
+	if value.Index == nil || value.Symbol != "I:SPX" || value.Index.Value != 4500.25 || value.Index.Timestamp != 1700000001000 {
+		t.Fatalf("unexpected index value: %+v", value.Index)
+	}
+
+	if events[3].Type != stream.TypeSecondAgg || events[3].Symbol != "I:SPX" {
+		t.Fatalf("unexpected second bar: %+v", events[3])
+	}
+
+	status := events[4]
+	if status.Status == nil || status.Status.Status != "auth_success" || status.Symbol != "" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+}
+
+func TestDecodeDropsMalformed(t *testing.T) {
+	before := stream.Counters()
+
+	if events := stream.Decode("test", []byte(`not json`)); events != nil {
+		t.Fatalf("expected no events from a malformed frame, got %d", len(events))
+	}
+
+	events := stream.Decode("test", []byte(`[
+		{"ev":"T","sym":"AAPL","p":1,"s":1,"t":1},
+		{"ev":"T","p":1},
+		{"ev":"T","sym":"MSFT","p":"oops"},
+		{"ev":"XYZ","sym":"AAPL"}
+	]`))
+	if len(events) != 1 || events[0].Symbol != "AAPL" {
+		t.Fatalf("expected only the valid trade, got %+v", events)
+	}
+
+	after := stream.Counters()
+	if got := after.MalformedFrames - before.MalformedFrames; got != 1 {
+		t.Fatalf("expected 1 malformed frame, got %d", got)
+	}
+	if got := after.MalformedEvents - before.MalformedEvents; got != 3 {
+		t.Fatalf("expected 3 malformed events, got %d", got)
+	}
+}
+
+func TestEncodeRoundTrip(t *testing.T) {
+	status := stream.NewStatus("upstream_connected", "stocks", "Stocks feed connected")
+	events, err := stream.Parse(stream.Encode([]stream.Event{status}))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(events) != 1 || events[0].Status == nil || events[0].Status.Cluster != "stocks" {
+		t.Fatalf("unexpected round trip: %+v", events)
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   string
+		typ     string
+		symbol  string
+		time    int64
+		wantErr bool
+	}{
+		{name: "trade", event: `{"ev":"T","sym":"AAPL","p":1,"s":1,"t":10}`, typ: stream.TypeTrade, symbol: "AAPL", time: 10},
+		{name: "minute bar", event: `{"ev":"AM","sym":"AAPL","o":1,"c":2,"s":20,"e":80}`, typ: stream.TypeMinuteAgg, symbol: "AAPL", time: 20},
+		{name: "second bar", event: `{"ev":"A","sym":"I:SPX","o":1,"c":2,"s":30,"e":31}`, typ: stream.TypeSecondAgg, symbol: "I:SPX", time: 30},
+		{name: "index value", event: `{"ev":"V","T":"I:NDX","val":2,"t":40}`, typ: stream.TypeIndexValue, symbol: "I:NDX", time: 40},
+		{name: "status", event: `{"ev":"status","status":"connected","message":"hi"}`, typ: stream.TypeStatus},
+		{name: "no ev", event: `{"sym":"AAPL"}`, wantErr: true},
+		{name: "unknown ev", event: `{"ev":"Q","sym":"AAPL"}`, wantErr: true},
+		{name: "trade without symbol", event: `{"ev":"T","p":1}`, wantErr: true},
+		{name: "index value without symbol", event: `{"ev":"V","val":1}`, wantErr: true},
+		{name: "wrong field type", event: `{"ev":"AM","sym":"AAPL","o":"1"}`, wantErr: true},
+		{name: "not an object", event: `42`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := stream.Parse([]byte("[" + tt.event + "]"))
+			if tt.wantErr {
+				if err == nil || len(events) != 0 {
+					t.Fatalf("expected an error and no events, got %+v, %v", events, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if len(events) != 1 {
+				t.Fatalf("expected 1 event, got %d", len(events))
+			}
+			e := events[0]
+			if e.Type != tt.typ || e.Symbol != tt.symbol || e.Time() != tt.time {
+				t.Fatalf("got type %q symbol %q time %d", e.Type, e.Symbol, e.Time())
+			}
+			if string(e.Raw) != tt.event {
+				t.Fatalf("raw not kept: %s", e.Raw)
+			}
+		})
+	}
+}
+
+func TestConstructorsRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		event  stream.Event
+		symbol string
+	}{
+		{name: "trade", event: stream.NewTrade(stream.Trade{Symbol: "AAPL", Price: 1, Size: 2, Timestamp: 3}), symbol: "AAPL"},
+		{name: "minute bar", event: stream.NewAggregate(stream.TypeMinuteAgg, stream.Aggregate{Symbol: "MSFT", Open: 1, Start: 60000}), symbol: "MSFT"},
+		{name: "index value", event: stream.NewIndexValue(stream.IndexValue{Symbol: "I:SPX", Value: 5, Timestamp: 6}), symbol: "I:SPX"},
+		{name: "status", event: stream.NewStatus("upstream_disconnected", "indices", "gone")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, err := stream.Parse(stream.Encode([]stream.Event{tt.event}))
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+			if len(events) != 1 || events[0].Type != tt.event.Type || events[0].Symbol != tt.symbol || events[0].Time() != tt.event.Time() {
+				t.Fatalf("unexpected round trip: %+v", events)
+			}
+		})
+	}
+}
+
+func TestNewCustomAddsHead(t *testing.T) {
+	e := stream.NewCustom("bar", "AAPL", struct {
+		Close float64 `json:"c"`
+	}{Close: 2})
+	if got, want := string(e.Raw), `{"ev":"bar","sym":"AAPL","c":2}`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,132 @@
+package trading
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dnhan1707/trader/internal/services"
+)
+
+func price(v float64) *float64 { return &v }
+
+func TestOrderMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   services.PaperOrder
+		price   float64
+		fill    bool
+		trigger bool
+	}{
+		{name: "market buy", order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderMarket}, price: 100, fill: true},
+		{name: "market sell", order: services.PaperOrder{Side: services.SideSell, Type: services.OrderMarket}, price: 100, fill: true},
+
+		{name: "buy limit above", order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderLimit, LimitPrice: price(99)}, price: 100},
+		{name: "buy limit at", order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderLimit, LimitPrice: price(100)}, price: 100, fill: true},
+		{name: "buy limit below", order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderLimit, LimitPrice: price(101)}, price: 100, fill: true},
+		{name: "sell limit below", order: services.PaperOrder{Side: services.SideSell, Type: services.OrderLimit, LimitPrice: price(101)}, price: 100},
+		{name: "sell limit above", order: services.PaperOrder{Side: services.SideSell, Type: services.OrderLimit, LimitPrice: price(99)}, price: 100, fill: true},
+
+		{name: "buy stop not hit", order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderStop, StopPrice: price(101)}, price: 100},
+		{name: "buy stop hit", order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderStop, StopPrice: price(100)}, price: 100, fill: true},
+		{name: "sell stop not hit", order: services.PaperOrder{Side: services.SideSell, Type: services.OrderStop, StopPrice: price(99)}, price: 100},
+		{name: "sell stop hit", order: services.PaperOrder{Side: services.SideSell, Type: services.OrderStop, StopPrice: price(101)}, price: 100, fill: true},
+
+		{
+			name:  "buy stop limit, stop not hit",
+			order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderStopLimit, StopPrice: price(105), LimitPrice: price(106), Status: services.OrderOpen},
+			price: 100,
+		},
+		{
+			name:  "buy stop limit, stop and limit hit",
+			order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderStopLimit, StopPrice: price(105), LimitPrice: price(106), Status: services.OrderOpen},
+			price: 105.5, fill: true,
+		},
+		{
+			name:  "buy stop limit, stop hit above limit",
+			order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderStopLimit, StopPrice: price(105), LimitPrice: price(106), Status: services.OrderOpen},
+			price: 107, trigger: true,
+		},
+		{
+			name:  "triggered buy stop limit works as a limit",
+			order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderStopLimit, StopPrice: price(105), LimitPrice: price(106), Status: services.OrderTriggered},
+			price: 100, fill: true,
+		},
+		{
+			name:  "triggered buy stop limit above limit",
+			order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderStopLimit, StopPrice: price(105), LimitPrice: price(106), Status: services.OrderTriggered},
+			price: 107,
+		},
+		{
+			name:  "sell stop limit, stop hit below limit",
+			order: services.PaperOrder{Side: services.SideSell, Type: services.OrderStopLimit, StopPrice: price(95), LimitPrice: price(94), Status: services.OrderOpen},
+			price: 93, trigger: true,
+		},
+		{
+			name:  "sell stop limit, stop and limit hit",
+			order: services.PaperOrder{Side: services.SideSell, Type: services.OrderStopLimit, StopPrice: price(95), LimitPrice: price(94), Status: services.OrderOpen},
+			price: 94.5, fill: true,
+		},
+
+		{name: "unknown type", order: services.PaperOrder{Side: services.SideBuy, Type: "iceberg"}, price: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fill, trigger := tt.order.Match(tt.price)
+			if fill != tt.fill || trigger != tt.trigger {
+				t.Fatalf("Match(%v) = fill %v trigger %v, want %v %v", tt.price, fill, trigger, tt.fill, tt.trigger)
+			}
+		})
+	}
+}
+
+func TestValidateOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   services.PaperOrder
+		wantErr bool
+		check   func(o services.PaperOrder) bool
+	}{
+		{
+			name:  "market order drops prices and defaults to day",
+			order: services.PaperOrder{Ticker: " aapl ", Side: services.SideBuy, Type: services.OrderMarket, Qty: 1, LimitPrice: price(1), StopPrice: price(2)},
+			check: func(o services.PaperOrder) bool {
+				return o.Ticker == "AAPL" && o.LimitPrice == nil && o.StopPrice == nil && o.TimeInForce == services.TIFDay
+			},
+		},
+		{
+			name:  "limit order drops the stop",
+			order: services.PaperOrder{Ticker: "AAPL", Side: services.SideSell, Type: services.OrderLimit, Qty: 1, LimitPrice: price(10), StopPrice: price(9), TimeInForce: services.TIFGTC},
+			check: func(o services.PaperOrder) bool { return o.StopPrice == nil && *o.LimitPrice == 10 },
+		},
+		{name: "no ticker", order: services.PaperOrder{Side: services.SideBuy, Type: services.OrderMarket, Qty: 1}, wantErr: true},
+		{name: "index", order: services.PaperOrder{Ticker: "I:SPX", Side: services.SideBuy, Type: services.OrderMarket, Qty: 1}, wantErr: true},
+		{name: "bad side", order: services.PaperOrder{Ticker: "AAPL", Side: "short", Type: services.OrderMarket, Qty: 1}, wantErr: true},
+		{name: "zero qty", order: services.PaperOrder{Ticker: "AAPL", Side: services.SideBuy, Type: services.OrderMarket}, wantErr: true},
+		{name: "limit without price", order: services.PaperOrder{Ticker: "AAPL", Side: services.SideBuy, Type: services.OrderLimit, Qty: 1}, wantErr: true},
+		{name: "stop with zero price", order: services.PaperOrder{Ticker: "AAPL", Side: services.SideBuy, Type: services.OrderStop, Qty: 1, StopPrice: price(0)}, wantErr: true},
+		{name: "stop limit without limit", order: services.PaperOrder{Ticker: "AAPL", Side: services.SideBuy, Type: services.OrderStopLimit, Qty: 1, StopPrice: price(1)}, wantErr: true},
+		{name: "unknown type", order: services.PaperOrder{Ticker: "AAPL", Side: services.SideBuy, Type: "iceberg", Qty: 1}, wantErr: true},
+		{name: "bad time in force", order: services.PaperOrder{Ticker: "AAPL", Side: services.SideBuy, Type: services.OrderMarket, Qty: 1, TimeInForce: "ioc"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.order
+			err := services.ValidateOrder(&o)
+			if tt.wantErr {
+				var verr services.ValidationError
+				if !errors.As(err, &verr) {
+					t.Fatalf("expected a ValidationError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("validate: %v", err)
+			}
+			if !tt.check(o) {
+				t.Fatalf("unexpected order %+v", o)
+			}
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package ws
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/ws"
+	"github.com/gorilla/websocket"
+)
+
+// watchlists serves fixed watchlists. A list with several versions returns
+// the next one each time it is opened, staying on the last.
+type watchlists struct {
+	mu     sync.Mutex
+	lists  map[int64][][]string
+	opened map[int64]int
+}
+
+func (w *watchlists) WatchlistTickers(_ context.Context, _ string, id int64) ([]string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	versions, ok := w.lists[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	n := min(w.opened[id], len(versions)-1)
+	w.opened[id]++
+	return versions[n], nil
+}
+
+func newWatchlists() *watchlists {
+	return &watchlists{
+		lists: map[int64][][]string{
+			1: {{"AAPL", "MSFT"}},
+			2: {{"AAPL", "MSFT"}, {"MSFT", "NVDA"}},
+			3: {{"MSFT", "I:SPX"}},
+		},
+		opened: make(map[int64]int),
+	}
+}
+
+// snapshotter records every lookup and knows every ticker but NODATA.
+type snapshotter struct {
+	mu    sync.Mutex
+	calls [][]string
+}
+
+func (s *snapshotter) Snapshots(tickers []string) map[string]stream.Event {
+	s.mu.Lock()
+	s.calls = append(s.calls, append([]string{}, tickers...))
+	s.mu.Unlock()
+
+	out := make(map[string]stream.Event)
+	for _, t := range tickers {
+		if t != "NODATA" {
+			out[t] = stream.NewCustom("snapshot", t, map[string]float64{"p": 100})
+		}
+	}
+	return out
+}
+
+func (s *snapshotter) lookups() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string{}, s.calls...)
+}
+
+// startWatchlistHub serves a hub with upstream refcounts, watchlists and
+// snapshots to user u1.
+func startWatchlistHub(t *testing.T) (*ws.Hub, *ws.SubscriptionManager, *snapshotter, *websocket.Conn) {
+	t.Helper()
+
+	// Large enough that the test never has to read them.
+	subs := ws.NewSubscriptionManager(make(chan ws.SubRequest, 1024), make(chan ws.SubRequest, 1024))
+	hub := ws.NewHub(subs)
+	snaps := &snapshotter{}
+	hub.Snapshots = snaps
+	hub.Watchlists = newWatchlists()
+	return hub, subs, snaps, dial(t, serveHub(t, hub, "u1"))
+}
+
+func send(t *testing.T, conn *websocket.Conn, req map[string]any) {
+	t.Helper()
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatalf("send %v: %v", req, err)
+	}
+}
+
+func sub(ticker string) map[string]any { return map[string]any{"ticker": ticker} }
+func unsub(ticker string) map[string]any {
+	return map[string]any{"action": ws.ActionUnsubscribe, "ticker": ticker}
+}
+func open(id int64) map[string]any {
+	return map[string]any{"action": ws.ActionOpenWatchlist, "watchlist_id": id}
+}
+func closeList(id int64) map[string]any {
+	return map[string]any{"action": ws.ActionCloseWatchlist, "watchlist_id": id}
+}
+
+// settle waits until the client has handled every request sent so far: they
+// are handled in order, so once a marker ticker has come and gone the rest
+// are done.
+func settle(t *testing.T, hub *ws.Hub, conn *websocket.Conn) {
+	t.Helper()
+	has := func(ticker string) bool {
+		for _, c := range hub.Clients() {
+			for _, tk := range c.Tickers {
+				if tk == ticker {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	send(t, conn, sub("SETTLE"))
+	waitFor(t, "the marker subscribe", func() bool { return has("SETTLE") })
+	send(t, conn, unsub("SETTLE"))
+	waitFor(t, "the marker unsubscribe", func() bool { return !has("SETTLE") })
+}
+
+func TestTickerRefcounts(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   []map[string]any
+		tickers []string
+	}{
+		{name: "explicit outlives a closed watchlist", steps: []map[string]any{sub("AAPL"), open(1), closeList(1)}, tickers: []string{"AAPL"}},
+		{name: "watchlist outlives an explicit unsubscribe", steps: []map[string]any{open(1), sub("AAPL"), unsub("AAPL")}, tickers: []string{"AAPL", "MSFT"}},
+		{name: "unsubscribe doesn't touch watchlist tickers", steps: []map[string]any{open(1), unsub("AAPL")}, tickers: []string{"AAPL", "MSFT"}},
+		{name: "two watchlists share a ticker", steps: []map[string]any{open(1), open(3), closeList(1)}, tickers: []string{"I:SPX", "MSFT"}},
+		{name: "reopening picks up changes", steps: []map[string]any{open(2), open(2)}, tickers: []string{"MSFT", "NVDA"}},
+		{name: "repeated subscribes count once", steps: []map[string]any{sub("AAPL"), sub("AAPL"), unsub("AAPL")}},
+		{name: "closing twice releases once", steps: []map[string]any{sub("MSFT"), open(1), closeList(1), closeList(1)}, tickers: []string{"MSFT"}},
+		{name: "closing an unopened watchlist", steps: []map[string]any{sub("AAPL"), closeList(1)}, tickers: []string{"AAPL"}},
+		{name: "unknown watchlist", steps: []map[string]any{open(9)}},
+		{name: "everything released", steps: []map[string]any{sub("AAPL"), open(1), open(3), unsub("AAPL"), closeList(3), closeList(1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, subs, _, conn := startWatchlistHub(t)
+			for _, step := range tt.steps {
+				send(t, conn, step)
+			}
+			settle(t, hub, conn)
+
+			clients := hub.Clients()
+			if len(clients) != 1 {
+				t.Fatalf("got %d clients", len(clients))
+			}
+			if strings.Join(clients[0].Tickers, ",") != strings.Join(tt.tickers, ",") {
+				t.Errorf("tickers = %v, want %v", clients[0].Tickers, tt.tickers)
+			}
+			// One client holds each ticker once upstream.
+			var counts []ws.TickerCount
+			for _, tk := range tt.tickers {
+				counts = append(counts, ws.TickerCount{Ticker: tk, Viewers: 1})
+			}
+			if got := subs.Counts(); len(got) != len(counts) || (len(got) > 0 && !reflect.DeepEqual(got, counts)) {
+				t.Errorf("upstream counts = %v, want %v", got, counts)
+			}
+		})
+	}
+}
+
+// readFrames reads n frames and names each event "ev" or "ev:sym".
+func readFrames(t *testing.T, conn *websocket.Conn, n int) []string {
+	t.Helper()
+	var got []string
+	for i := 0; i < n; i++ {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read after %v: %v", got, err)
+		}
+		var events []struct {
+			Ev  string `json:"ev"`
+			Sym string `json:"sym"`
+		}
+		if err := json.Unmarshal(data, &events); err != nil {
+			t.Fatalf("decode %s: %v", data, err)
+		}
+		for _, e := range events {
+			if e.Sym != "" {
+				got = append(got, e.Ev+":"+e.Sym)
+			} else {
+				got = append(got, e.Ev)
+			}
+		}
+	}
+	return got
+}
+
+func TestSubscribeSnapshots(t *testing.T) {
+	hub, _, snaps, conn := startWatchlistHub(t)
+
+	// A watchlist is looked up in one batch; the reply, then the snapshots,
+	// come before live data.
+	send(t, conn, open(1))
+	got := readFrames(t, conn, 3)
+	hub.Broadcast <- []stream.Event{stream.NewTrade(stream.Trade{Symbol: "AAPL", Price: 1, Timestamp: 1})}
+	got = append(got, readFrames(t, conn, 1)...)
+
+	// Tickers already held need no snapshot, those the snapshotter doesn't
+	// know get none.
+	send(t, conn, sub("AAPL"))
+	send(t, conn, sub("NODATA"))
+	send(t, conn, sub("TSLA"))
+	got = append(got, readFrames(t, conn, 1)...)
+
+	want := []string{"watchlist", "snapshot:AAPL", "snapshot:MSFT", "T:AAPL", "snapshot:TSLA"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("frames = %v, want %v", got, want)
+	}
+
+	lookups := snaps.lookups()
+	for _, l := range lookups {
+		sort.Strings(l)
+	}
+	wantLookups := [][]string{{"AAPL", "MSFT"}, {"NODATA"}, {"TSLA"}}
+	if !reflect.DeepEqual(lookups, wantLookups) {
+		t.Errorf("lookups = %v, want %v", lookups, wantLookups)
+	}
+}
@@ -0,0 +1,242 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/ws"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gorilla/websocket"
+)
+
+// Frames big enough that a client which stops reading backs the server up
+// past the socket buffers and into its queue.
+const (
+	padSize = 64 << 10
+	frames  = 300
+)
+
+var pad = strings.Repeat("x", padSize)
+
+// startHub serves a hub over a real websocket and returns its URL.
+func startHub(t *testing.T, policy ws.Policy) (*ws.Hub, string) {
+	t.Helper()
+
+	hub := ws.NewHub(nil)
+	hub.SendBuffer = 4
+	hub.SlowConsumer = policy
+	return hub, serveHub(t, hub, "")
+}
+
+// serveHub runs hub behind /ws, connecting as userID, and returns its URL.
+func serveHub(t *testing.T, hub *ws.Hub, userID string) string {
+	t.Helper()
+
+	go hub.Run()
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Use(func(c *fiber.Ctx) error {
+		c.Locals("userID", userID)
+		return c.Next()
+	})
+	app.Get("/ws", ws.NewHandler(hub))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(ln)
+	t.Cleanup(func() { app.Shutdown() })
+	return "ws://" + ln.Addr().String() + "/ws"
+}
+
+func dial(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatalf("timed out waiting for %s", what)
+}
+
+func subscribe(t *testing.T, hub *ws.Hub, conn *websocket.Conn, ticker string) {
+	t.Helper()
+	if err := conn.WriteJSON(map[string]string{"ticker": ticker}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	waitFor(t, "the subscription", func() bool {
+		clients := hub.Clients()
+		return len(clients) == 1 && len(clients[0].Tickers) == 1 && clients[0].Tickers[0] == ticker
+	})
+}
+
+func tradeFrame(i int) []stream.Event {
+	return []stream.Event{stream.NewTrade(stream.Trade{Symbol: "AAPL", ID: pad, Price: float64(i + 1), Size: 1, Timestamp: int64(i + 1)})}
+}
+
+func barFrame(i int) []stream.Event {
+	return []stream.Event{stream.NewCustom("bar", "AAPL", map[string]any{"c": float64(i + 1), "pad": pad})}
+}
+
+// readAll reads frames until the connection is closed or the price of the
+// last frame sent shows up, and returns the highest price seen ("p" of
+// trades, "c" of bars), the number of frames and the close code, 0 if it
+// wasn't closed.
+func readAll(t *testing.T, conn *websocket.Conn) (last float64, count, code int) {
+	t.Helper()
+	for last < frames {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			var ce *websocket.CloseError
+			if errors.As(err, &ce) {
+				return last, count, ce.Code
+			}
+			t.Fatalf("read after %d frames up to %v: %v", count, last, err)
+		}
+		var events []struct {
+			P float64 `json:"p"`
+			C float64 `json:"c"`
+		}
+		if err := json.Unmarshal(data, &events); err != nil {
+			t.Fatalf("decode %q...: %v", data[:40], err)
+		}
+		for _, e := range events {
+			last = max(last, e.P, e.C)
+		}
+		count++
+	}
+	return last, count, 0
+}
+
+func TestSlowConsumerPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ws.Policy
+		publish bool // route as server events (bars) instead of upstream trades
+		kicked  bool
+	}{
+		{name: "disconnect", policy: ws.PolicyDisconnect, kicked: true},
+		{name: "drop oldest", policy: ws.PolicyDropOldest},
+		{name: "conflate trades", policy: ws.PolicyConflate},
+		{name: "conflate never drops bars", policy: ws.PolicyConflate, publish: true, kicked: true},
+		{name: "drop oldest drops bars too", policy: ws.PolicyDropOldest, publish: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub, url := startHub(t, tt.policy)
+			conn := dial(t, url)
+			subscribe(t, hub, conn, "AAPL")
+
+			for i := 0; i < frames; i++ {
+				if tt.publish {
+					hub.Publish <- barFrame(i)
+				} else {
+					hub.Broadcast <- tradeFrame(i)
+				}
+			}
+
+			if tt.kicked {
+				waitFor(t, "the kick", func() bool { return len(hub.Clients()) == 0 })
+			} else {
+				clients := hub.Clients()
+				if len(clients) != 1 || clients[0].Dropped == 0 || clients[0].Policy != tt.policy {
+					t.Fatalf("expected a connected client with drops, got %+v", clients)
+				}
+			}
+
+			last, count, code := readAll(t, conn)
+			if tt.kicked {
+				if code != ws.CloseSlowConsumer {
+					t.Fatalf("expected close code %d, got %d after %d frames", ws.CloseSlowConsumer, code, count)
+				}
+				return
+			}
+			if code != 0 {
+				t.Fatalf("unexpected close %d", code)
+			}
+			if count >= frames {
+				t.Fatalf("expected some frames dropped, got all %d", count)
+			}
+			// Nothing newer is ever dropped in favour of something older.
+			if last != frames {
+				t.Fatalf("expected the latest price %d last, got %v", frames, last)
+			}
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    ws.Policy
+		wantErr bool
+	}{
+		{in: "disconnect", want: ws.PolicyDisconnect},
+		{in: "drop-oldest", want: ws.PolicyDropOldest},
+		{in: "conflate", want: ws.PolicyConflate},
+		{in: "", wantErr: true},
+		{in: "Conflate", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ws.ParsePolicy(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePolicy(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
+
+func TestRoutesOnlySubscribedTickers(t *testing.T) {
+	hub, url := startHub(t, ws.PolicyDisconnect)
+	conn := dial(t, url)
+	subscribe(t, hub, conn, "MSFT")
+
+	hub.Broadcast <- []stream.Event{
+		stream.NewTrade(stream.Trade{Symbol: "AAPL", Price: 1, Timestamp: 1}),
+		stream.NewTrade(stream.Trade{Symbol: "MSFT", Price: 2, Timestamp: 2}),
+		stream.NewStatus(stream.StatusUpstreamConnected, "stocks", "connected"),
+		// Massive's acks name every ticker on the shared connection.
+		stream.NewStatus("success", "", "subscribed to: T.AAPL,T.MSFT"),
+	}
+
+	var got []string
+	for len(got) < 2 {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v (got %v)", err, got)
+		}
+		events, err := stream.Parse(data)
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		for _, e := range events {
+			if e.Status != nil {
+				got = append(got, e.Type+":"+e.Status.Status)
+				continue
+			}
+			got = append(got, e.Type+":"+e.Symbol+":"+strconv.FormatInt(e.Time(), 10))
+		}
+	}
+	if len(got) != 2 || got[0] != "T:MSFT:2" || got[1] != "status:upstream_connected" {
+		t.Fatalf("unexpected frames %v", got)
+	}
+}
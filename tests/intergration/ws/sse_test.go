@@ -0,0 +1,170 @@
+package ws
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dnhan1707/trader/internal/massive/stream"
+	"github.com/dnhan1707/trader/internal/ws"
+	"github.com/gofiber/fiber/v2"
+)
+
+// lastTrades snapshots every ticker as a trade at 100.
+type lastTrades struct{}
+
+func (lastTrades) Snapshots(tickers []string) map[string]stream.Event {
+	out := make(map[string]stream.Event, len(tickers))
+	for _, t := range tickers {
+		out[t] = stream.NewTrade(stream.Trade{Symbol: t, Price: 100, Timestamp: 1})
+	}
+	return out
+}
+
+// startSSE serves a hub's SSE stream and returns its URL.
+func startSSE(t *testing.T) (*ws.Hub, string) {
+	t.Helper()
+
+	hub := ws.NewHub(nil)
+	hub.Snapshots = lastTrades{}
+	go hub.Run()
+
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	app.Get("/sse", ws.NewSSEHandler(hub))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go app.Listener(ln)
+	// Streams only notice a gone client at the next heartbeat; don't wait.
+	t.Cleanup(func() { app.ShutdownWithTimeout(100 * time.Millisecond) })
+	return hub, "http://" + ln.Addr().String() + "/sse?tickers=AAPL"
+}
+
+type sseEvent struct {
+	id, event, data string
+}
+
+// sseStream reads one SSE response event by event.
+type sseStream struct {
+	t *testing.T
+	r *bufio.Reader
+}
+
+// openSSE connects with lastEventID, if set, and reads up to the retry line,
+// by when the subscriptions are in place.
+func openSSE(t *testing.T, url, lastEventID string) *sseStream {
+	t.Helper()
+
+	req, _ := http.NewRequest("GET", url, nil)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	if resp.StatusCode != 200 {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	s := &sseStream{t: t, r: bufio.NewReader(resp.Body)}
+	s.next()
+	return s
+}
+
+// next reads up to the next blank line; the retry line reads as an empty event.
+func (s *sseStream) next() sseEvent {
+	s.t.Helper()
+	var e sseEvent
+	for {
+		line, err := s.r.ReadString('\n')
+		if err != nil {
+			s.t.Fatalf("read: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "":
+			return e
+		case strings.HasPrefix(line, "id: "):
+			e.id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "event: "):
+			e.event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			e.data = strings.TrimPrefix(line, "data: ")
+		}
+	}
+}
+
+func trade(price float64) []stream.Event {
+	return []stream.Event{stream.NewTrade(stream.Trade{Symbol: "AAPL", Price: price, Timestamp: int64(price)})}
+}
+
+func TestSSEResume(t *testing.T) {
+	hub, url := startSSE(t)
+
+	first := openSSE(t, url, "")
+	if e := first.next(); e.event != "snapshot" {
+		t.Fatalf("got %+v, want a snapshot", e)
+	}
+	hub.Broadcast <- trade(1)
+	e := first.next()
+	epoch, seq, ok := strings.Cut(e.id, "-")
+	if e.event != "quote" || !ok || epoch == "" || seq != "1" {
+		t.Fatalf("got %+v, want a quote with id <epoch>-1", e)
+	}
+	// Missed by the clients below.
+	hub.Broadcast <- trade(2)
+
+	tests := []struct {
+		name        string
+		lastEventID string
+		want        []string // event:id of what comes before the next live quote
+	}{
+		{name: "same epoch replays", lastEventID: epoch + "-1", want: []string{"quote:" + epoch + "-2"}},
+		{name: "up to date", lastEventID: epoch + "-2"},
+		{name: "other epoch starts over", lastEventID: "other-1", want: []string{"gap:", "snapshot:"}},
+		{name: "plain number starts over", lastEventID: "2", want: []string{"gap:", "snapshot:"}},
+	}
+	price := 2.0
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := openSSE(t, url, tt.lastEventID)
+			var got []string
+			for range tt.want {
+				e := s.next()
+				got = append(got, e.event+":"+e.id)
+				if e.event == "gap" && !strings.Contains(e.data, `"reset":true`) {
+					t.Errorf("gap %s is not a reset", e.data)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+
+			price++
+			hub.Broadcast <- trade(price)
+			if e := s.next(); e.event != "quote" || !strings.HasPrefix(e.id, epoch+"-") {
+				t.Fatalf("got %+v, want the live quote", e)
+			}
+		})
+	}
+}
+
+func TestSSEBadLastEventID(t *testing.T) {
+	_, url := startSSE(t)
+
+	req, _ := http.NewRequest("GET", url, nil)
+	req.Header.Set("Last-Event-ID", "abc-x")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 400 {
+		t.Fatalf("status %d, want 400", resp.StatusCode)
+	}
+}